@@ -0,0 +1,125 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// scanBacklog counts metrics files waiting for processing in Pillars directories and kept in failed
+// and quarantine directories. Directories failed to be read are logged and skipped.
+func scanBacklog(c config.Config) metrics.Backlog {
+	l := zap.L().Sugar()
+
+	backlog := metrics.Backlog{
+		PendingFiles: make(map[string]int, len(c.Telemetry.Pillars)),
+	}
+
+	for _, pillar := range c.Telemetry.Pillars {
+		count, err := metrics.CountPillarMetricsFiles(pillar.Path)
+		if err != nil {
+			l.Warnw("failed to count pending "+pillar.Name+" metrics files", zap.Error(err))
+			continue
+		}
+
+		backlog.PendingFiles[pillar.Name] = count
+	}
+
+	var err error
+
+	backlog.FailedFiles, err = metrics.CountStoredMetricsFiles(c.Telemetry.FailedPath)
+	if err != nil {
+		l.Warnw("failed to count failed metrics files", zap.Error(err))
+	}
+
+	backlog.QuarantinedFiles, err = metrics.CountStoredMetricsFiles(c.Telemetry.QuarantinePath)
+	if err != nil {
+		l.Warnw("failed to count quarantined metrics files", zap.Error(err))
+	}
+
+	return backlog
+}
+
+// rootBacklog is the backlog of telemetry root path.
+type rootBacklog struct {
+	c       config.Config
+	backlog metrics.Backlog
+}
+
+// scanRootsBacklog counts backlog of the telemetry root path and all extra root paths.
+func scanRootsBacklog(c config.Config) []rootBacklog {
+	roots := c.Roots()
+	backlogs := make([]rootBacklog, 0, len(roots))
+
+	for _, root := range roots {
+		backlogs = append(backlogs, rootBacklog{c: root, backlog: scanBacklog(root)})
+	}
+
+	return backlogs
+}
+
+// prometheusLabelReplacer escapes label values of Prometheus text exposition format.
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeBacklogMetrics writes backlog gauges of telemetry root paths in Prometheus text exposition format.
+func writeBacklogMetrics(w io.Writer, backlogs []rootBacklog) error {
+	var b strings.Builder
+
+	b.WriteString("# HELP percona_telemetry_agent_pending_files Number of Pillar metrics files waiting for processing.\n")
+	b.WriteString("# TYPE percona_telemetry_agent_pending_files gauge\n")
+
+	for _, rb := range backlogs {
+		root := prometheusLabelReplacer.Replace(rb.c.Telemetry.RootPath)
+
+		for _, pillar := range rb.c.Telemetry.Pillars {
+			count, ok := rb.backlog.PendingFiles[pillar.Name]
+			if !ok {
+				// directory failed to be read, gauge is absent rather than misleading zero.
+				continue
+			}
+
+			fmt.Fprintf(&b, "percona_telemetry_agent_pending_files{root=\"%s\",pillar=\"%s\",directory=\"%s\"} %d\n",
+				root, prometheusLabelReplacer.Replace(pillar.Name), prometheusLabelReplacer.Replace(pillar.Path), count)
+		}
+	}
+
+	b.WriteString("# HELP percona_telemetry_agent_failed_files Number of metrics files moved into failed metrics directory.\n")
+	b.WriteString("# TYPE percona_telemetry_agent_failed_files gauge\n")
+
+	for _, rb := range backlogs {
+		fmt.Fprintf(&b, "percona_telemetry_agent_failed_files{root=\"%s\"} %d\n",
+			prometheusLabelReplacer.Replace(rb.c.Telemetry.RootPath), rb.backlog.FailedFiles)
+	}
+
+	b.WriteString("# HELP percona_telemetry_agent_quarantined_files Number of corrupt metrics files moved into quarantine directory.\n")
+	b.WriteString("# TYPE percona_telemetry_agent_quarantined_files gauge\n")
+
+	for _, rb := range backlogs {
+		fmt.Fprintf(&b, "percona_telemetry_agent_quarantined_files{root=\"%s\"} %d\n",
+			prometheusLabelReplacer.Replace(rb.c.Telemetry.RootPath), rb.backlog.QuarantinedFiles)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+func TestWriteBacklogMetrics(t *testing.T) {
+	t.Parallel()
+
+	var c config.Config
+	c.Telemetry.RootPath = "/usr/local/percona/telemetry"
+	c.Telemetry.Pillars = []config.PillarOpts{
+		{Name: "PS", Path: "/usr/local/percona/telemetry/ps"},
+		{Name: "PG", Path: "/usr/local/percona/telemetry/pg"},
+	}
+
+	backlogs := []rootBacklog{
+		{
+			c:       c,
+			backlog: metrics.Backlog{PendingFiles: map[string]int{"PS": 2}, FailedFiles: 1},
+		},
+		{
+			c:       c.ForRoot("/srv/tenant-1/telemetry"),
+			backlog: metrics.Backlog{PendingFiles: map[string]int{"PS": 0, "PG": 3}, QuarantinedFiles: 4},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, writeBacklogMetrics(&b, backlogs))
+	require.Equal(t, `# HELP percona_telemetry_agent_pending_files Number of Pillar metrics files waiting for processing.
+# TYPE percona_telemetry_agent_pending_files gauge
+percona_telemetry_agent_pending_files{root="/usr/local/percona/telemetry",pillar="PS",directory="/usr/local/percona/telemetry/ps"} 2
+percona_telemetry_agent_pending_files{root="/srv/tenant-1/telemetry",pillar="PS",directory="/srv/tenant-1/telemetry/ps"} 0
+percona_telemetry_agent_pending_files{root="/srv/tenant-1/telemetry",pillar="PG",directory="/srv/tenant-1/telemetry/pg"} 3
+# HELP percona_telemetry_agent_failed_files Number of metrics files moved into failed metrics directory.
+# TYPE percona_telemetry_agent_failed_files gauge
+percona_telemetry_agent_failed_files{root="/usr/local/percona/telemetry"} 1
+percona_telemetry_agent_failed_files{root="/srv/tenant-1/telemetry"} 0
+# HELP percona_telemetry_agent_quarantined_files Number of corrupt metrics files moved into quarantine directory.
+# TYPE percona_telemetry_agent_quarantined_files gauge
+percona_telemetry_agent_quarantined_files{root="/usr/local/percona/telemetry"} 0
+percona_telemetry_agent_quarantined_files{root="/srv/tenant-1/telemetry"} 4
+`, b.String())
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+const (
+	// bundleLogTailSize is the maximum size of the most recent part of each log file included into support bundle.
+	bundleLogTailSize     = 1024 * 1024
+	bundleFilePermissions = 0o600
+)
+
+// createSupportBundle creates tar.gz archive with telemetry history, effective configuration,
+// version info and recent logs for attaching to support tickets.
+// Parts that can't be collected are skipped, so the bundle is created anyway.
+func createSupportBundle(c config.Config, w io.Writer) error {
+	l := zap.L().Sugar()
+
+	outFile, err := os.OpenFile(filepath.Clean(c.Bundle.Output), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, bundleFilePermissions)
+	if err != nil {
+		return fmt.Errorf("can't create support bundle file: %w", err)
+	}
+
+	zw := gzip.NewWriter(outFile)
+	tw := tar.NewWriter(zw)
+
+	err = writeSupportBundle(tw, c)
+
+	// archive is closed in any case, so partially written bundle is still readable.
+	if cErr := tw.Close(); err == nil {
+		err = cErr
+	}
+
+	if cErr := zw.Close(); err == nil {
+		err = cErr
+	}
+
+	if cErr := outFile.Close(); err == nil {
+		err = cErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't write support bundle: %w", err)
+	}
+
+	l.Infow("support bundle is created", zap.String("file", c.Bundle.Output))
+
+	_, err = fmt.Fprintf(w, "support bundle is written to %s\n", c.Bundle.Output)
+
+	return err
+}
+
+func writeSupportBundle(tw *tar.Writer, c config.Config) error {
+	l := zap.L().Sugar()
+
+	versionInfo := fmt.Sprintf("Version: %s\nCommit: %s\nBuild date: %s\n", config.Version, config.Commit, config.BuildDate)
+	if err := addBundleContent(tw, "version.txt", []byte(versionInfo)); err != nil {
+		return err
+	}
+
+	configData, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal configuration into JSON: %w", err)
+	}
+
+	if err := addBundleContent(tw, "config.json", configData); err != nil {
+		return err
+	}
+
+	for _, file := range []string{c.Telemetry.LastRunPath, c.Telemetry.ErrorCountersPath} {
+		if _, err := os.Stat(file); err == nil {
+			if err := addBundleFile(tw, filepath.Base(file), file, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	historyFiles, err := metrics.ListMetricsHistory(c.Telemetry.HistoryPath)
+	if err != nil {
+		l.Warnw("failed to list telemetry history files, skip them", zap.Error(err))
+	}
+
+	for _, file := range historyFiles {
+		if err := addBundleFile(tw, filepath.Join("history", filepath.Base(file.Name)), file.Name, 0); err != nil {
+			return err
+		}
+	}
+
+	logFiles, err := filepath.Glob(filepath.Join(filepath.Clean(c.Bundle.LogDir), "*.log"))
+	if err != nil {
+		l.Warnw("failed to list log files, skip them", zap.Error(err))
+	}
+
+	for _, logFile := range logFiles {
+		if err := addBundleFile(tw, filepath.Join("logs", filepath.Base(logFile)), logFile, bundleLogTailSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addBundleContent adds content into support bundle archive as the file with given name.
+func addBundleContent(tw *tar.Writer, name string, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    bundleFilePermissions,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(content)
+
+	return err
+}
+
+// addBundleFile adds the file into support bundle archive with given name.
+// Only the last maxSize bytes of the file are added if maxSize is positive.
+// Files that can't be read are skipped.
+func addBundleFile(tw *tar.Writer, name, fileName string, maxSize int64) error {
+	l := zap.L().Sugar().With(zap.String("file", fileName))
+
+	f, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		l.Warnw("failed to open file, skip it", zap.Error(err))
+		return nil
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		l.Warnw("failed to get file info, skip it", zap.Error(err))
+		return nil
+	}
+
+	size := info.Size()
+	if maxSize > 0 && size > maxSize {
+		if _, err := f.Seek(size-maxSize, io.SeekStart); err != nil {
+			l.Warnw("failed to seek file, skip it", zap.Error(err))
+			return nil
+		}
+
+		size = maxSize
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    bundleFilePermissions,
+		Size:    size,
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// file may grow while it is being read, so exactly size bytes are written.
+	_, err = io.CopyN(tw, f, size)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("file %s is truncated while adding it into support bundle: %w", fileName, err)
+	}
+
+	return err
+}
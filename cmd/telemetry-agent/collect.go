@@ -0,0 +1,38 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/pipeline"
+)
+
+// printCollectedReport collects host, installed packages and Pillars metrics and prints telemetry report
+// that would be sent to Percona Platform. Nothing is sent and no files are changed, so it is safe to run
+// next to the running service.
+func printCollectedReport(ctx context.Context, c config.Config, w io.Writer) error {
+	// sender is not used for collecting, errors are not counted as nothing is processed.
+	processor, err := newProcessor(c, nil, newAgentStatus(nil))
+	if err != nil {
+		return fmt.Errorf("can't create metrics processor: %w", err)
+	}
+
+	return pipeline.WriteReport(w, processor.Collect(ctx))
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// loadErrorCounters reads error counters kept by previous agent runs, counting starts from scratch
+// if the file is absent or corrupt.
+func loadErrorCounters(c config.Config) *metrics.ErrorCounters {
+	counters, err := metrics.ReadErrorCounters(c.Telemetry.ErrorCountersPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			zap.L().Sugar().Warnw("failed to read error counters, errors are counted from scratch",
+				zap.String("file", c.Telemetry.ErrorCountersPath),
+				zap.Error(err))
+		}
+
+		return metrics.NewErrorCounters()
+	}
+
+	return counters
+}
+
+// saveErrorCounters writes error counters, so they are kept across agent restarts. Errors are not critical, so only logged.
+func saveErrorCounters(ctx context.Context, c config.Config, counters *metrics.ErrorCounters) {
+	if err := counters.Write(c.Telemetry.ErrorCountersPath); err != nil {
+		logger.FromContext(ctx).Sugar().Errorw("failed to write error counters",
+			zap.String("file", c.Telemetry.ErrorCountersPath),
+			zap.Error(err))
+	}
+}
+
+// writeErrorCountersMetrics writes error counters in Prometheus text exposition format.
+func writeErrorCountersMetrics(w io.Writer, counts map[metrics.ErrorCategory]int64) error {
+	var b strings.Builder
+
+	b.WriteString("# HELP percona_telemetry_agent_errors_total Number of failures by category, kept across agent restarts.\n")
+	b.WriteString("# TYPE percona_telemetry_agent_errors_total counter\n")
+
+	for _, category := range metrics.ErrorCategories {
+		fmt.Fprintf(&b, "percona_telemetry_agent_errors_total{category=\"%s\"} %d\n", category, counts[category])
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+// exitCode returns exit code corresponding to the cause of err, defaultCode is returned if the cause is not known.
+func exitCode(err error, defaultCode int) int {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return config.ExitCodePermission
+	case errors.As(err, &netErr) && !isErrno(netErr):
+		return config.ExitCodeUnavailable
+	default:
+		return defaultCode
+	}
+}
+
+// isErrno returns true if err is bare system call error, it implements net.Error but isn't network error.
+func isErrno(err error) bool {
+	_, ok := err.(syscall.Errno) //nolint:errorlint
+
+	return ok
+}
+
+// fatalw logs err and terminates Telemetry Agent with exit code corresponding to its cause.
+// Unlike Panic, it produces no stack trace. Deferred functions are not run.
+func fatalw(l *zap.SugaredLogger, msg string, err error, defaultCode int) {
+	code := exitCode(err, defaultCode)
+
+	// startup errors are expected failures, so error is logged without stack trace on behalf of the caller.
+	l = l.WithOptions(zap.AddStacktrace(zapcore.FatalLevel), zap.AddCallerSkip(1))
+	l.Errorw(msg, zap.Error(err), zap.Int("exit code", code))
+	_ = l.Sync()
+
+	os.Exit(code)
+}
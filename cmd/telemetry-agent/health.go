@@ -0,0 +1,128 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+const (
+	healthReadHeaderTimeout = 5 * time.Second
+	healthShutdownTimeout   = 5 * time.Second
+)
+
+// startHealthServer serves liveness (/healthz) and readiness (/readyz) endpoints on addr until ctx is done.
+// Both endpoints respond with agent status in JSON, /readyz responds with 503 status code if the agent is not ready.
+// Self-metrics are served on /metrics in Prometheus text exposition format.
+func startHealthServer(ctx context.Context, c config.Config, status *agentStatus) error {
+	l := zap.L().Sugar()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeHealthResponse(w, http.StatusOK, status.snapshot())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		s := status.snapshot()
+
+		code := http.StatusOK
+		if !s.Ready {
+			code = http.StatusServiceUnavailable
+		}
+
+		writeHealthResponse(w, code, s)
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		err := writeBacklogMetrics(w, scanRootsBacklog(c))
+		if err == nil {
+			err = writeErrorCountersMetrics(w, status.snapshot().Errors)
+		}
+
+		if err == nil {
+			err = writeClockSkewMetrics(w, status.snapshot().ClockSkewSeconds)
+		}
+
+		if err != nil {
+			zap.L().Sugar().Debugw("failed to write metrics response", zap.Error(err))
+		}
+	})
+
+	// listen synchronously, so address errors are reported on startup.
+	listener, err := net.Listen("tcp", c.Health.Addr)
+	if err != nil {
+		return fmt.Errorf("can't listen health check address: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: healthReadHeaderTimeout,
+	}
+
+	go func() {
+		err := srv.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Errorw("health check server failed", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthShutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	l.Infow("serving health check endpoints", zap.String("address", listener.Addr().String()))
+
+	return nil
+}
+
+func writeHealthResponse(w http.ResponseWriter, code int, s agentStatusSnapshot) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		zap.L().Sugar().Debugw("failed to write health check response", zap.Error(err))
+	}
+}
+
+// writeClockSkewMetrics writes skew of host clock in Prometheus text exposition format, nothing is written
+// until it is measured.
+func writeClockSkewMetrics(w io.Writer, skewSeconds *int64) error {
+	if skewSeconds == nil {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "# HELP percona_telemetry_agent_clock_skew_seconds Skew of host clock relative to Percona Platform clock.\n"+
+		"# TYPE percona_telemetry_agent_clock_skew_seconds gauge\n"+
+		"percona_telemetry_agent_clock_skew_seconds %d\n", *skewSeconds)
+
+	return err
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// runHistoryCommand executes one of the history commands and prints its result to w.
+func runHistoryCommand(c config.Config, w io.Writer) error {
+	switch c.Command {
+	case config.CommandHistoryList:
+		return printHistoryList(c, w)
+	case config.CommandHistoryShow:
+		return printHistoryFile(c, w)
+	case config.CommandHistoryPrune:
+		return pruneHistory(c, w)
+	default:
+		return fmt.Errorf("unknown history command: %s", c.Command)
+	}
+}
+
+func printHistoryList(c config.Config, w io.Writer) error {
+	historyFiles, err := metrics.ListMetricsHistory(c.Telemetry.HistoryPath)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err = fmt.Fprintln(tw, "FILE\tCREATED\tSIZE")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range historyFiles {
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%d\n",
+			filepath.Base(file.Name),
+			file.Timestamp.Format(time.RFC3339),
+			file.Size)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func printHistoryFile(c config.Config, w io.Writer) error {
+	historyFile := c.History.Show.File
+	if !strings.ContainsRune(historyFile, os.PathSeparator) {
+		// file name inside telemetry history directory.
+		historyFile = filepath.Join(c.Telemetry.HistoryPath, historyFile)
+	}
+
+	report, delivery, err := metrics.ReadMetricsHistory(historyFile)
+	if err != nil {
+		return err
+	}
+
+	// history files written before delivery metadata was recorded have none.
+	if delivery != nil {
+		requestID := delivery.RequestID
+		if requestID == "" {
+			requestID = "-"
+		}
+
+		// suppressed reports are recorded at the time of suppression instead of sending.
+		sentLabel := "Sent"
+		if delivery.Suppressed {
+			sentLabel = "Suppressed"
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		_, err = fmt.Fprintf(tw, "%s:\t%s\nRequest ID:\t%s\n\n", sentLabel, delivery.SentAt.Format(time.RFC3339), requestID)
+		if err != nil {
+			return err
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	for i, r := range report.GetReports() {
+		if i != 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		if err := printHistoryReport(r, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printHistoryReport(r *platformReporter.GenericReport, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err := fmt.Fprintf(tw, "Report ID:\t%s\nCreated:\t%s\nInstance ID:\t%s\nProduct family:\t%s\n\n",
+		r.GetId(),
+		r.GetCreateTime().AsTime().Format(time.RFC3339),
+		r.GetInstanceId(),
+		r.GetProductFamily())
+	if err != nil {
+		return err
+	}
+
+	reportMetrics := slices.Clone(r.GetMetrics())
+	slices.SortStableFunc(reportMetrics, func(a, b *platformReporter.GenericReport_Metric) int {
+		return strings.Compare(a.GetKey(), b.GetKey())
+	})
+
+	_, err = fmt.Fprintln(tw, "KEY\tVALUE")
+	if err != nil {
+		return err
+	}
+
+	for _, m := range reportMetrics {
+		_, err = fmt.Fprintf(tw, "%s\t%s\n", m.GetKey(), m.GetValue())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func pruneHistory(c config.Config, w io.Writer) error {
+	historyFiles, err := metrics.ListMetricsHistory(c.Telemetry.HistoryPath)
+	if err != nil {
+		return err
+	}
+
+	timeThreshold := time.Now().Add(-time.Duration(c.History.Prune.OlderThan) * time.Second)
+
+	for _, file := range historyFiles {
+		if c.History.Prune.OlderThan != 0 && file.Timestamp.After(timeThreshold) {
+			continue
+		}
+
+		if err := os.Remove(file.Name); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "removed %s\n", filepath.Base(file.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
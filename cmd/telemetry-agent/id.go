@@ -0,0 +1,161 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+var uuidRegexp = regexp.MustCompile(`[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`)
+
+// runIDCommand prints instance ID of each telemetry root path and validates its instance file.
+func runIDCommand(c config.Config, w io.Writer) error {
+	for i, root := range c.Roots() {
+		if i != 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		if err := runRootIDCommand(root, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rootInstanceFile returns the file instance ID of telemetry root path is stored in.
+func rootInstanceFile(c config.Config) string {
+	if c.Telemetry.InstanceFile == "" {
+		return metrics.TelemetryFile()
+	}
+
+	return c.Telemetry.InstanceFile
+}
+
+// runRootIDCommand prints instance ID of telemetry root path and validates its instance file, Percona telemetry file
+// for the telemetry root path. Absent file is created, corrupted file is repaired keeping instance ID if it can be
+// recovered. Instance ID is replaced with new one if regeneration is requested.
+func runRootIDCommand(c config.Config, w io.Writer) error {
+	l := zap.L().Sugar()
+	instanceFile := rootInstanceFile(c)
+
+	instanceID, err := metrics.ParseInstanceIDFile(instanceFile)
+
+	var status string
+
+	switch {
+	case err == nil:
+		status = "valid"
+	case errors.Is(err, os.ErrNotExist):
+		instanceID = uuid.NewString()
+		status = "absent, created with new instance ID"
+	case errors.Is(err, metrics.ErrInvalidInstanceID):
+		instanceID = recoverInstanceID(instanceFile)
+		if instanceID != "" {
+			status = fmt.Sprintf("corrupted (%s), repaired keeping instance ID", err)
+		} else {
+			instanceID = uuid.NewString()
+			status = fmt.Sprintf("corrupted (%s), repaired with new instance ID", err)
+		}
+	default:
+		return fmt.Errorf("can't read instance ID file %s: %w", instanceFile, err)
+	}
+
+	if c.ID.Regenerate {
+		previousInstanceID := instanceID
+		instanceID = uuid.NewString()
+		status = "regenerated, previous instance ID: " + previousInstanceID
+
+		// mapping is recorded before the file is replaced, so previous instance ID is never lost.
+		if err := recordInstanceIDChange(c, previousInstanceID, instanceID); err != nil {
+			return err
+		}
+	}
+
+	if status != "valid" {
+		if err := metrics.WriteInstanceID(instanceFile, instanceID); err != nil {
+			return err
+		}
+
+		l.Infow("instance ID file is updated", zap.String("file", instanceFile), zap.String("status", status))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err = fmt.Fprintf(tw, "Instance ID:\t%s\nFile:\t%s\nStatus:\t%s\n", instanceID, instanceFile, status)
+	if err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+// recoverInstanceID returns the first UUID found in corrupted Percona telemetry file, empty string if there is no one.
+func recoverInstanceID(instanceFile string) string {
+	data, err := os.ReadFile(filepath.Clean(instanceFile))
+	if err != nil {
+		return ""
+	}
+
+	return string(uuidRegexp.Find(data))
+}
+
+// instanceIDChangeFamily returns product family instance ID change is recorded with. Percona Platform doesn't define
+// product family of Telemetry Agent yet, so the self-report one is used if configured and PMM otherwise.
+func instanceIDChangeFamily(c config.Config) platformReporter.ProductFamily {
+	if c.Telemetry.SelfReportProductFamily != platformReporter.ProductFamily_PRODUCT_FAMILY_INVALID {
+		return c.Telemetry.SelfReportProductFamily
+	}
+
+	return platformReporter.ProductFamily_PRODUCT_FAMILY_PMM
+}
+
+// recordInstanceIDChange appends previous and new instance ID to instance ID changes file of telemetry root path,
+// so telemetry sent before regeneration can still be attributed to the host.
+func recordInstanceIDChange(c config.Config, previousInstanceID, instanceID string) error {
+	if err := createTelemetryDirs(c.Telemetry.RootPath); err != nil {
+		return fmt.Errorf("can't create telemetry root directory: %w", err)
+	}
+
+	change := metrics.InstanceIDChange{
+		Time:               time.Now(),
+		PreviousInstanceID: previousInstanceID,
+		InstanceID:         instanceID,
+		ProductFamily:      instanceIDChangeFamily(c).String(),
+	}
+
+	if err := metrics.AppendInstanceIDChange(c.Telemetry.InstanceIDChangesPath, change); err != nil {
+		return fmt.Errorf("can't record instance ID change: %w", err)
+	}
+
+	return nil
+}
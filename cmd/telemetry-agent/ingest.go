@@ -0,0 +1,207 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// ingestSocketPermissions allows only the agent user and members of telemetry group to submit reports.
+const ingestSocketPermissions = 0o660
+
+// ingestServer implements Percona Platform ReporterAPI for local Percona components. Received reports are saved
+// into metrics directories of Pillars with the same product family, so they are processed as regular metrics files.
+// Only Pillars of the telemetry root path are served, extra root paths are not.
+type ingestServer struct {
+	platformReporter.UnimplementedReporterAPIServer
+
+	c config.Config
+}
+
+// listenIngestSocket creates ingest unix socket and sets its permissions. It is called before privileges are dropped,
+// so the socket can be created in directories writable by root only (e.g. /run) and given to the telemetry group.
+func listenIngestSocket(c config.Config) (net.Listener, error) {
+	socketPath := filepath.Clean(c.Ingest.Socket)
+
+	// socket file is left if the agent was killed, it is not possible to listen on it.
+	if info, err := os.Lstat(socketPath); err == nil && info.Mode().Type() == os.ModeSocket {
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("can't remove stale ingest socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen ingest socket: %w", err)
+	}
+
+	// socket permissions are the only authentication of clients.
+	if err := setIngestSocketPermissions(socketPath, c.Telemetry.Group); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// startIngestServer serves gRPC ReporterAPI on listener created by listenIngestSocket until ctx is done.
+func startIngestServer(ctx context.Context, c config.Config, listener net.Listener) {
+	l := zap.L().Sugar()
+
+	srv := grpc.NewServer()
+	platformReporter.RegisterReporterAPIServer(srv, &ingestServer{c: c})
+
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			l.Errorw("ingest server failed", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		// unix socket file is removed on close if its directory is writable by unprivileged user,
+		// otherwise it is removed as stale one on the next start.
+		srv.GracefulStop()
+	}()
+
+	l.Infow("serving gRPC ingestion on unix socket", zap.String("socket", listener.Addr().String()))
+}
+
+func setIngestSocketPermissions(socketPath, group string) error {
+	if err := os.Chmod(socketPath, ingestSocketPermissions); err != nil {
+		return fmt.Errorf("can't change ingest socket permissions: %w", err)
+	}
+
+	if group == "" {
+		return nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		// socket stays accessible to the agent user and its primary group only.
+		zap.L().Sugar().Warnw("failed to find telemetry group, ingest socket group is not changed", zap.Error(err))
+		return nil
+	}
+
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid of group %q: %w", group, err)
+	}
+
+	if err := os.Lchown(socketPath, -1, gid); err != nil {
+		return fmt.Errorf("can't change ingest socket group: %w", err)
+	}
+
+	return nil
+}
+
+// GenericReport saves each report as metrics file of the Pillar with report product family.
+// Report metrics become metrics file content, report instance ID is used as 'db_instance_id' metric if it is absent.
+func (s *ingestServer) GenericReport(ctx context.Context, req *platformReporter.ReportRequest) (*platformReporter.ReportResponse, error) {
+	l := zap.L().Sugar()
+
+	pillars := make([]config.PillarOpts, 0, len(req.GetReports()))
+
+	// all reports are checked first, so the request is either rejected or accepted completely in most cases.
+	for _, report := range req.GetReports() {
+		pillar, found := lookupPillarByFamily(s.c.Telemetry.Pillars, report.GetProductFamily())
+		if !found {
+			return nil, status.Errorf(codes.InvalidArgument, "no pillar with product family %s", report.GetProductFamily())
+		}
+
+		pillars = append(pillars, pillar)
+	}
+
+	for i, report := range req.GetReports() {
+		content, err := ingestedReportContent(report)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid report: %v", err)
+		}
+
+		// metrics file name is used as report creation time, current time is used if it is not set.
+		var createTime time.Time
+		if report.GetCreateTime() != nil {
+			createTime = report.GetCreateTime().AsTime()
+		}
+
+		fileName, err := metrics.SavePushedMetrics(ctx, pillars[i].Path, pillars[i].ProductFamily, content, createTime, metrics.ProcessOpts{
+			FlattenNested: s.c.Telemetry.FlattenNested,
+			SchemaDir:     s.c.Telemetry.SchemasPath,
+		})
+
+		switch {
+		case errors.Is(err, metrics.ErrInvalidPushedMetrics):
+			l.Warnw("ingested report is rejected", zap.String("id", report.GetId()), zap.Error(err))
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, metrics.ErrPillarDisabled):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			l.Errorw("failed to save ingested report", zap.String("id", report.GetId()), zap.Error(err))
+			return nil, status.Error(codes.Internal, "can't save report")
+		}
+
+		l.Infow("ingested report is saved", zap.String("id", report.GetId()), zap.String("file", fileName))
+	}
+
+	return &platformReporter.ReportResponse{}, nil
+}
+
+// ingestedReportContent returns report metrics in metrics file format.
+func ingestedReportContent(report *platformReporter.GenericReport) ([]byte, error) {
+	if len(report.GetMetrics()) == 0 {
+		return nil, errors.New("report has no metrics")
+	}
+
+	fileMetrics := make(map[string]string, len(report.GetMetrics())+1)
+	for _, m := range report.GetMetrics() {
+		fileMetrics[m.GetKey()] = m.GetValue()
+	}
+
+	if _, found := fileMetrics[metrics.DBInstanceIDKey]; !found && report.GetInstanceId() != "" {
+		fileMetrics[metrics.DBInstanceIDKey] = report.GetInstanceId()
+	}
+
+	return json.Marshal(fileMetrics)
+}
+
+// lookupPillarByFamily returns the first Pillar reported with productFamily.
+func lookupPillarByFamily(pillars []config.PillarOpts, productFamily platformReporter.ProductFamily) (config.PillarOpts, bool) {
+	for _, pillar := range pillars {
+		if pillar.ProductFamily == productFamily {
+			return pillar, true
+		}
+	}
+
+	return config.PillarOpts{}, false
+}
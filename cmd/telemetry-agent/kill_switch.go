@@ -0,0 +1,82 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+// killSwitch checks whether collecting and sending telemetry is disabled by operator,
+// either by configuration or by existence of the kill-switch file. It is not safe for concurrent use.
+type killSwitch struct {
+	disable     bool
+	disableFile string
+	// disabled is the result of the previous check, so only state changes are logged.
+	disabled bool
+}
+
+func newKillSwitch(c config.Config) *killSwitch {
+	return &killSwitch{
+		disable:     c.Telemetry.Disable,
+		disableFile: c.Telemetry.DisableFile,
+	}
+}
+
+// isDisabled returns true if telemetry is disabled. Kill-switch file is checked on each call,
+// so telemetry may be disabled and enabled back without restarting the agent.
+func (k *killSwitch) isDisabled() bool {
+	l := zap.L().Sugar()
+
+	disabled, reason := k.check()
+
+	switch {
+	case disabled && !k.disabled:
+		l.Infow("telemetry is disabled, collecting and sending telemetry is skipped", zap.String("reason", reason))
+	case !disabled && k.disabled:
+		l.Info("telemetry is enabled again, kill-switch file is removed")
+	}
+
+	k.disabled = disabled
+
+	return disabled
+}
+
+// check returns true and the reason if telemetry is disabled.
+func (k *killSwitch) check() (bool, string) {
+	if k.disable {
+		return true, "disabled by configuration"
+	}
+
+	if k.disableFile == "" {
+		return false, ""
+	}
+
+	_, err := os.Stat(k.disableFile)
+	switch {
+	case err == nil:
+		return true, "kill-switch file " + k.disableFile + " exists"
+	case errors.Is(err, os.ErrNotExist):
+		return false, ""
+	default:
+		// operator's opt-out can't be verified, so nothing is collected.
+		return true, "can't check kill-switch file " + k.disableFile + ": " + err.Error()
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// writeLastRunSummary completes summary of finished run with its result and writes it into last run summary file.
+// The last error is carried over from the previous summary if the run succeeded, the last self-report time is always
+// carried over. Errors are not critical, so only logged.
+func writeLastRunSummary(ctx context.Context, c config.Config, summary metrics.RunSummary, runErr error) {
+	l := logger.FromContext(ctx).Sugar().With(zap.String("file", c.Telemetry.LastRunPath))
+
+	summary.FinishTime = time.Now()
+	summary.Backlog = scanBacklog(c)
+	summary.DurationSeconds = summary.FinishTime.Sub(summary.StartTime).Seconds()
+
+	prevSummary, err := metrics.ReadRunSummary(c.Telemetry.LastRunPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.Warnw("failed to read previous last run summary, last error and last self-report time are not kept",
+			zap.Error(err))
+	}
+
+	summary.LastSelfReportTime = prevSummary.LastSelfReportTime
+
+	if runErr != nil {
+		summary.Error = runErr.Error()
+		summary.LastError = summary.Error
+		summary.LastErrorTime = &summary.FinishTime
+	} else {
+		summary.LastError = prevSummary.LastError
+		summary.LastErrorTime = prevSummary.LastErrorTime
+	}
+
+	if err := metrics.WriteRunSummary(c.Telemetry.LastRunPath, summary); err != nil {
+		l.Errorw("failed to write last run summary", zap.Error(err))
+	}
+}
+
+// recordSelfReportTime records the time self-telemetry was sent into last run summary file, keeping the rest of it.
+// Errors are not critical, so only logged.
+func recordSelfReportTime(c config.Config, t time.Time) {
+	l := zap.L().Sugar().With(zap.String("file", c.Telemetry.LastRunPath))
+
+	summary, err := metrics.ReadRunSummary(c.Telemetry.LastRunPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.Warnw("failed to read last run summary, last self-report time is not recorded", zap.Error(err))
+		return
+	}
+
+	summary.LastSelfReportTime = &t
+
+	if err := metrics.WriteRunSummary(c.Telemetry.LastRunPath, summary); err != nil {
+		l.Errorw("failed to record last self-report time", zap.Error(err))
+	}
+}
+
+// selfReportDelay returns the time left until self-telemetry is due, that is selfReportInterval after the last
+// self-report recorded in last run summary file. It is due right away if no self-report is recorded, so agents
+// restarted more often than daily still send it.
+func selfReportDelay(c config.Config, now time.Time) time.Duration {
+	summary, err := metrics.ReadRunSummary(c.Telemetry.LastRunPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			zap.L().Sugar().Warnw("failed to read last self-report time, self-telemetry is sent right away",
+				zap.String("file", c.Telemetry.LastRunPath), zap.Error(err))
+		}
+
+		return 0
+	}
+
+	if summary.LastSelfReportTime == nil {
+		return 0
+	}
+
+	return max(summary.LastSelfReportTime.Add(selfReportInterval).Sub(now), 0)
+}
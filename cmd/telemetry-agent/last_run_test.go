@@ -0,0 +1,96 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+func TestSelfReportDelay(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 2, 15, 19, 42, 36, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		lastSelfReport *time.Time
+		noSummary      bool
+		expectedDelay  time.Duration
+	}{
+		{
+			name:      "no_summary",
+			noSummary: true,
+		},
+		{
+			name: "never_sent",
+		},
+		{
+			name:           "sent_recently",
+			lastSelfReport: new(now.Add(-5 * time.Hour)),
+			expectedDelay:  19 * time.Hour,
+		},
+		{
+			name:           "overdue",
+			lastSelfReport: new(now.Add(-30 * time.Hour)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var c config.Config
+			c.Telemetry.LastRunPath = filepath.Join(t.TempDir(), "last_run.json")
+
+			if !tt.noSummary {
+				summary := metrics.RunSummary{StartTime: now, LastSelfReportTime: tt.lastSelfReport}
+				require.NoError(t, metrics.WriteRunSummary(c.Telemetry.LastRunPath, summary))
+			}
+
+			require.Equal(t, tt.expectedDelay, selfReportDelay(c, now))
+		})
+	}
+}
+
+func TestLastSelfReportTimeKept(t *testing.T) {
+	t.Parallel()
+
+	var c config.Config
+	c.Telemetry.LastRunPath = filepath.Join(t.TempDir(), "last_run.json")
+
+	sent := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	recordSelfReportTime(c, sent)
+
+	// the time is kept by both successful and failed runs, so it survives agent restarts.
+	for _, runErr := range []error{nil, errors.New("failed to send")} {
+		writeLastRunSummary(t.Context(), c, metrics.RunSummary{StartTime: time.Now()}, runErr)
+
+		summary, err := metrics.ReadRunSummary(c.Telemetry.LastRunPath)
+		require.NoError(t, err)
+		require.NotNil(t, summary.LastSelfReportTime)
+		require.True(t, sent.Equal(*summary.LastSelfReportTime))
+	}
+
+	require.Equal(t, selfReportInterval-time.Hour, selfReportDelay(c, sent.Add(time.Hour)))
+}
@@ -18,24 +18,26 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	platformReporter "github.com/percona/platform/gen/telemetry/generic"
-	platformLogger "github.com/percona/platform/pkg/logger"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/percona/telemetry-agent/config"
 	"github.com/percona/telemetry-agent/logger"
 	"github.com/percona/telemetry-agent/metrics"
+	"github.com/percona/telemetry-agent/pipeline"
 	platformClient "github.com/percona/telemetry-agent/platform"
 	"github.com/percona/telemetry-agent/utils"
 )
@@ -69,199 +71,399 @@ func createTelemetryDirs(dirs ...string) error {
 	return nil
 }
 
-// Create Percona Platform HTTP client for sending telemetry reports.
-func createPerconaPlatformClient(c config.Config) (*platformClient.Client, error) {
-	u, err := url.ParseRequestURI(c.Platform.URL)
+// platformClientLogger writes Percona Platform HTTP client logs into the global logger. Messages about sent requests
+// are written into the logger of request context, so they have the fields of the current iteration.
+type platformClientLogger struct{}
+
+func (platformClientLogger) Errorf(format string, v ...any) {
+	zap.L().Named("perconaPlatformClient").Sugar().Errorf(format, v...)
+}
+
+func (platformClientLogger) Warnf(format string, v ...any) {
+	zap.L().Named("perconaPlatformClient").Sugar().Warnf(format, v...)
+}
+
+func (platformClientLogger) Debugf(format string, v ...any) {
+	zap.L().Named("perconaPlatformClient").Sugar().Debugf(format, v...)
+}
+
+// Create Percona Platform HTTP client for sending telemetry reports to platformURL.
+func createPerconaPlatformClient(c config.Config, platformURL string, auditLog *platformClient.AuditLog,
+	credentials *platformClient.CredentialProvider,
+) (*platformClient.Client, error) {
+	u, err := url.ParseRequestURI(platformURL)
 	if err != nil {
 		return nil, fmt.Errorf("can't create Percona Platform client: %w", err)
 	}
 
-	if u.Scheme == "" || u.Host == "" {
+	baseURL := u.Scheme + "://" + u.Host
+
+	switch {
+	case u.Scheme == config.UnixSocketScheme && u.Path != "":
+		// host is not used to connect to local relay, so it only fills Host header.
+		baseURL = "http://localhost"
+	case u.Scheme == "" || u.Host == "":
 		return nil, errors.New("invalid Percona Platform Telemetry URL: scheme or host is missed")
 	}
 
-	return platformClient.New(
-		platformClient.WithLogger(zap.L().Named("perconaPlatformClient").Sugar()),
-		platformClient.WithBaseURL(u.Scheme+"://"+u.Host),
-		platformClient.WithLogFullRequest(),
-		platformClient.WithResendTimeout(time.Second*time.Duration(c.Platform.ResendTimeout)),
+	opts := []platformClient.Option{
+		platformClient.WithLogger(platformClientLogger{}),
+		platformClient.WithBaseURL(baseURL),
+		platformClient.WithLogFullRequest(logger.NewRedactor(c.Log.RedactKeys)),
+		platformClient.WithResendTimeout(time.Second * time.Duration(c.Platform.ResendTimeout)),
 		platformClient.WithRetryCount(5),
-		platformClient.WithClientTimeout(60*time.Second)), nil
+		platformClient.WithClientTimeout(60 * time.Second),
+	}
+
+	if auditLog != nil {
+		opts = append(opts, platformClient.WithAuditLog(auditLog))
+	}
+
+	if u.Scheme == config.UnixSocketScheme {
+		opts = append(opts, platformClient.WithUnixSocket(u.Path))
+	}
+
+	switch c.Platform.Compression {
+	case "auto":
+		// zstd gives better ratio on repetitive key/value structure of reports.
+		opts = append(opts, platformClient.WithCompression(platformClient.EncodingZstd, platformClient.EncodingGzip))
+	case platformClient.EncodingZstd, platformClient.EncodingGzip:
+		opts = append(opts, platformClient.WithCompression(c.Platform.Compression))
+	}
+
+	if credentials != nil {
+		opts = append(opts, platformClient.WithCredentials(credentials))
+	}
+
+	return platformClient.New(opts...), nil
 }
 
-func processPillarsMetrics(c config.Config) []*metrics.File {
-	l := zap.L().Sugar()
+// createPlatformSender creates sender of telemetry reports to Percona Platform,
+// reports of product families with dedicated Percona Platform URLs are routed to them.
+func createPlatformSender(c config.Config) (pipeline.Sender, error) {
+	var auditLog *platformClient.AuditLog
 
-	pillarMetrics := make([]*metrics.File, 0, 1)
+	if c.Platform.AuditLog != "" {
+		var err error
+
+		// audit log is shared by all clients, so records of all Percona Platform URLs are in one file.
+		auditLog, err = platformClient.OpenAuditLog(c.Platform.AuditLog)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	l.Infow("processing PS metrics", zap.String("directory", c.Telemetry.PSMetricsPath))
+	// credentials are shared by all clients, so they are fetched once for all Percona Platform URLs.
+	credentials := newCredentialProvider(c)
 
-	pMetrics, err := metrics.ProcessPSMetrics(c.Telemetry.PSMetricsPath)
+	defaultClient, err := createPerconaPlatformClient(c, c.Platform.URL, auditLog, credentials)
 	if err != nil {
-		l.Warnw("failed to process PS metrics", zap.Error(err))
-	} else {
-		pillarMetrics = append(pillarMetrics, pMetrics...)
+		return nil, err
 	}
 
-	l.Infow("processing PBS metrics", zap.String("directory", c.Telemetry.PBSMetricsPath))
+	if len(c.Platform.Routes) == 0 {
+		return defaultClient, nil
+	}
 
-	pMetrics, err = metrics.ProcessPBSMetrics(c.Telemetry.PBSMetricsPath)
-	if err != nil {
-		l.Warnw("failed to process PBS metrics", zap.Error(err))
-	} else {
-		pillarMetrics = append(pillarMetrics, pMetrics...)
+	routes := make(map[platformReporter.ProductFamily]pipeline.Sender, len(c.Platform.Routes))
+	for productFamily, familyURL := range c.Platform.Routes {
+		zap.L().Sugar().Infow("telemetry of product family is sent to dedicated Percona Platform URL",
+			zap.String("product family", productFamily.String()),
+			zap.String("url", familyURL))
+
+		routes[productFamily], err = createPerconaPlatformClient(c, familyURL, auditLog, credentials)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	l.Infow("processing PXC metrics", zap.String("directory", c.Telemetry.PXCMetricsPath))
+	return pipeline.NewFamilyRouter(defaultClient, routes), nil
+}
 
-	pMetrics, err = metrics.ProcessPXCMetrics(c.Telemetry.PXCMetricsPath)
-	if err != nil {
-		l.Warnw("failed to process PXC metrics", zap.Error(err))
-	} else {
-		pillarMetrics = append(pillarMetrics, pMetrics...)
+// newCredentialProvider creates provider of Percona Platform credentials read from secrets storage,
+// nil is returned if no credentials source is configured.
+func newCredentialProvider(c config.Config) *platformClient.CredentialProvider {
+	switch {
+	case c.Platform.CredentialCommand != "":
+		zap.L().Sugar().Infow("Percona Platform credentials are provided by credential command")
+
+		return platformClient.NewCredentialProvider(platformClient.CommandCredentialSource{
+			Args: strings.Fields(c.Platform.CredentialCommand),
+		})
+	case c.Platform.VaultAddr != "":
+		zap.L().Sugar().Infow("Percona Platform credentials are read from Vault",
+			zap.String("address", c.Platform.VaultAddr),
+			zap.String("secret path", c.Platform.VaultSecretPath))
+
+		return platformClient.NewCredentialProvider(platformClient.VaultCredentialSource{
+			Addr:       c.Platform.VaultAddr,
+			SecretPath: c.Platform.VaultSecretPath,
+			Token:      c.Platform.VaultToken,
+			Client:     &http.Client{Timeout: 30 * time.Second},
+		})
+	default:
+		return nil
 	}
+}
 
-	l.Infow("processing PSMDB (mongod) metrics", zap.String("directory", c.Telemetry.PSMDBMongodMetricsPath))
+// newScrubber creates scrubber of personal data from metric values, nil is returned if scrubbing is not configured.
+func newScrubber(c config.Config) (*pipeline.Scrubber, error) {
+	if len(c.Telemetry.ScrubRules) == 0 && len(c.Telemetry.ScrubPatterns) == 0 && len(c.Telemetry.ScrubKeys) == 0 {
+		return nil, nil //nolint:nilnil
+	}
 
-	pMetrics, err = metrics.ProcessPSMDBMetrics(c.Telemetry.PSMDBMongodMetricsPath)
-	if err != nil {
-		l.Warnw("failed to process PSMDB (mongod) metrics", zap.Error(err))
-	} else {
-		pillarMetrics = append(pillarMetrics, pMetrics...)
+	return pipeline.NewScrubber(c.Telemetry.ScrubRules, c.Telemetry.ScrubPatterns, c.Telemetry.ScrubKeys)
+}
+
+// newHasher creates hasher of identifying metric values, nil is returned if hashing is not configured.
+// Random salt stored in telemetry root path is used unless the salt is configured.
+func newHasher(c config.Config) (*pipeline.Hasher, error) {
+	if len(c.Telemetry.HashKeys) == 0 {
+		return nil, nil //nolint:nilnil
 	}
 
-	l.Infow("processing PSMDB (mongos) metrics", zap.String("directory", c.Telemetry.PSMDBMongosMetricsPath))
+	salt := []byte(c.Telemetry.HashSalt)
+
+	var err error
+
+	switch {
+	case len(salt) != 0:
+	case c.ReadOnly():
+		// salt file is not created, so hashes of values differ from the sent ones until the salt is created by the service.
+		salt, err = pipeline.ReadHashSalt(c.Telemetry.HashSaltPath)
+		if errors.Is(err, os.ErrNotExist) {
+			salt, err = pipeline.RandomHashSalt()
+		}
+	default:
+		salt, err = pipeline.LoadOrCreateHashSalt(c.Telemetry.HashSaltPath)
+	}
 
-	pMetrics, err = metrics.ProcessPSMDBMetrics(c.Telemetry.PSMDBMongosMetricsPath)
 	if err != nil {
-		l.Warnw("failed to process PSMDB (mongos) metrics", zap.Error(err))
-	} else {
-		pillarMetrics = append(pillarMetrics, pMetrics...)
+		return nil, err
 	}
 
-	l.Infow("processing PG metrics", zap.String("directory", c.Telemetry.PGMetricsPath))
+	return pipeline.NewHasher(c.Telemetry.HashKeys, salt)
+}
+
+// newProcessor creates Pillars metrics files processor sending telemetry with the given sender.
+// Sender is not used in dry-run mode, so it may be nil.
+func newProcessor(c config.Config, sender pipeline.Sender, status *agentStatus) (*pipeline.Processor, error) {
+	return newCollectorProcessor(c, pipeline.NewLocalCollector(c, status.errorCounters, nil), sender, status, nil)
+}
 
-	pMetrics, err = metrics.ProcessPGMetrics(c.Telemetry.PGMetricsPath)
+// newCollectorProcessor creates Pillars metrics files processor collecting metrics with the given collector,
+// so collecting may be adjusted after the processor is created. Processed metrics files are recorded in stateDB
+// instead of being removed if it is not nil.
+func newCollectorProcessor(c config.Config, collector *pipeline.LocalCollector, sender pipeline.Sender,
+	status *agentStatus, stateDB *metrics.StateDB,
+) (*pipeline.Processor, error) {
+	scrubber, err := newScrubber(c)
 	if err != nil {
-		l.Warnw("failed to process PG metrics", zap.Error(err))
-	} else {
-		pillarMetrics = append(pillarMetrics, pMetrics...)
+		return nil, err
 	}
 
-	return pillarMetrics
-}
+	hasher, err := newHasher(c)
+	if err != nil {
+		return nil, err
+	}
 
-// The main function for processing Percona Pillar's telemetry and sending it to Percona Platform.
-func processMetrics(ctx context.Context, c config.Config, platformClient *platformClient.Client) {
-	l := zap.L().Sugar()
+	// reports sent before agent restart are suppressed as well.
+	sentReports := metrics.NewSentReports(time.Duration(c.Telemetry.DedupeWindow) * time.Second)
+	if err := sentReports.LoadHistory(c.Telemetry.HistoryPath); err != nil {
+		zap.L().Sugar().Warnw("failed to load sent reports from history, they are not deduplicated", zap.Error(err))
+	}
 
-	pillarMetrics := processPillarsMetrics(c)
-	if len(pillarMetrics) == 0 {
-		l.Info("no Pillar metrics files found, skip scraping host metrics and sending telemetry")
-		return
+	return pipeline.New(
+		collector,
+		sender,
+		pipeline.NewHistoryDir(c.Telemetry.HistoryPath, c.Telemetry.HistoryCompress),
+		pipeline.Opts{
+			RootPath:        c.Telemetry.RootPath,
+			ProcessingPath:  c.Telemetry.ProcessingPath,
+			FailedPath:      c.Telemetry.FailedPath,
+			MergeWindow:     time.Duration(c.Telemetry.MergeWindow) * time.Second,
+			DedupeWindow:    time.Duration(c.Telemetry.DedupeWindow) * time.Second,
+			Workers:         c.Telemetry.SendWorkers,
+			MaxSendAttempts: c.Telemetry.MaxSendAttempts,
+			SendSpread:      time.Duration(c.Telemetry.SendSpread) * time.Second,
+			BatchSize:       c.Telemetry.BatchSize,
+			DryRun:          c.DryRun,
+			DryRunOutput:    os.Stdout,
+			Scrubber:        scrubber,
+			Hasher:          hasher,
+			ErrorCounters:   status.errorCounters,
+			StateDB:         stateDB,
+			Tags:            c.Telemetry.Tags,
+			ClockSkew:       status.clockSkew,
+		},
+		pipeline.WithSendObserver(status.sendFinished),
+		pipeline.WithProgressObserver(status.workProgressed),
+		pipeline.WithSentReports(sentReports),
+	), nil
+}
+
+// openStateDB opens state database processed metrics files are recorded in if they are kept in place,
+// nil is returned otherwise.
+func openStateDB(c config.Config) (*metrics.StateDB, error) {
+	if !c.Telemetry.KeepMetricsFiles {
+		return nil, nil
 	}
 
-	l.Info("scraping host metrics")
+	zap.L().Sugar().Infow("processed metrics files are kept in place and recorded in state database",
+		zap.String("file", c.Telemetry.StateDBPath))
+
+	return metrics.OpenStateDB(c.Telemetry.StateDBPath)
+}
 
-	hostMetrics := metrics.ScrapeHostMetrics(ctx)
-	hostInstanceID := hostMetrics.Metrics[metrics.InstanceIDKey]
-	// instanceId is not needed in main metrics set
-	delete(hostMetrics.Metrics, metrics.InstanceIDKey)
+// iterationContext returns ctx with logger adding new iteration ID to all log entries,
+// so interleaved messages of long iterations can be grouped.
+func iterationContext(ctx context.Context) context.Context {
+	return logger.WithContext(ctx, logger.FromContext(ctx).With(zap.String("iteration", uuid.NewString())))
+}
 
-	l.Info("scraping installed Percona packages")
+// runIteration performs single metrics processing iteration of each telemetry root path: cleans up history
+// and quarantine directories, processes Pillars metrics files and sends telemetry to Percona Platform
+// unless telemetry is disabled by kill-switch. Cleanup errors are not critical, so only telemetry processing errors
+// are returned.
+func runIteration(ctx context.Context, roots []*rootProcessor, ks *killSwitch, status *agentStatus) (err error) {
+	ctx = iterationContext(ctx)
 
-	installedPackages := metrics.ScrapeInstalledPackages(ctx)
-	if len(installedPackages) != 0 {
-		// add info about installed packages to host metrics.
-		jsonData, err := json.Marshal(installedPackages)
-		if err != nil {
-			l.Warnw("failed to marshal installed Percona packages into JSON, skip it", zap.Error(err))
-		} else {
-			hostMetrics.Metrics["installed_packages"] = string(jsonData)
-		}
+	status.workStarted()
+	defer status.workFinished()
+
+	logger.FromContext(ctx).Sugar().Info("start metrics processing iteration")
+
+	ctx, span := otel.Tracer(pipeline.TracerName).Start(ctx, "iteration")
+	defer func() { pipeline.EndSpan(span, err) }()
+
+	// kill-switch is checked once, so all root paths are either processed or skipped.
+	disabled := ks.isDisabled()
+	errs := make([]error, 0, len(roots))
+
+	for _, root := range roots {
+		errs = append(errs, runRootIteration(rootContext(ctx, roots, root), root, disabled, status))
 	}
 
-	for _, pillarM := range pillarMetrics {
-		// prepare request to Percona Platform
-		reportMetrics := make([]*platformReporter.GenericReport_Metric, 0, 1)
+	if disabled {
+		return nil
+	}
 
-		// copy host metrics to Platform request
-		for k, v := range hostMetrics.Metrics {
-			reportMetrics = append(reportMetrics, &platformReporter.GenericReport_Metric{
-				Key:   k,
-				Value: v,
-			})
-		}
+	err = errors.Join(errs...)
+	status.iterationFinished(err)
 
-		// copy pillar metrics to Platform request
-		for k, v := range pillarM.Metrics {
-			reportMetrics = append(reportMetrics, &platformReporter.GenericReport_Metric{
-				Key:   k,
-				Value: v,
-			})
-		}
+	return err
+}
 
-		report := &platformReporter.ReportRequest{
-			Reports: []*platformReporter.GenericReport{
-				{
-					Id:            uuid.New().String(), // each request shall have unique ID
-					CreateTime:    timestamppb.New(pillarM.Timestamp),
-					InstanceId:    hostInstanceID,
-					ProductFamily: pillarM.ProductFamily,
-					Metrics:       reportMetrics,
-				},
-			},
-		}
+// runWatchdog sends systemd watchdog heartbeats every interval until ctx is done. Heartbeats are sent from
+// separate goroutine, so a long iteration doesn't block them, but they are skipped if metrics processing makes
+// no progress within watchdog timeout, so systemd restarts the agent if it hangs.
+func runWatchdog(ctx context.Context, status *agentStatus, interval time.Duration) {
+	l := zap.L().Sugar()
 
-		metricsLogger := l.With(zap.String("file", pillarM.Filename))
-		platformCtx := platformLogger.GetContextWithLogger(ctx, metricsLogger.Desugar())
-		// send request to Percona Platform
-		err := platformClient.SendTelemetry(platformCtx, "", report)
-		if err != nil {
-			switch {
-			case errors.Is(err, context.Canceled):
-				// main process loop is terminated, no need to continue.
-				// we can't continue this particular metrics file processing because we don't know what was sent and what was not.
-				// try to send this metrics file again on next iteration.
-				return
-			default:
-				// any other errors during sending data (including request timeout).
-				// we can't continue this particular metrics file processing because we don't know what was sent and what was not.
-				// try to send this metrics file again on next iteration.
-				// pass over to next metrics file.
-				metricsLogger.Warnw("error during sending telemetry, will try on next iteration", zap.Error(err))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if status.isStalled(2 * interval) {
+				l.Warnw("metrics processing makes no progress, systemd watchdog heartbeat is skipped")
 				continue
 			}
+
+			if err := utils.SdNotify(utils.SdNotifyWatchdog); err != nil {
+				l.Warnw("failed to send systemd watchdog heartbeat", zap.Error(err))
+			}
 		}
+	}
+}
 
-		// write sent data to history file
-		historyFile := filepath.Join(c.Telemetry.HistoryPath, filepath.Base(pillarM.Filename))
-		l.Infow("writing metrics to history file",
-			zap.String("pillar file", pillarM.Filename),
-			zap.String("history file", historyFile))
+// runRootIteration performs metrics processing iteration of telemetry root path, metrics files are not processed
+// if telemetry is disabled.
+func runRootIteration(ctx context.Context, root *rootProcessor, disabled bool, status *agentStatus) error {
+	l := logger.FromContext(ctx).Sugar()
+	c := root.c
 
-		err = metrics.WriteMetricsToHistory(historyFile, report)
-		if err != nil {
-			l.Errorw("failed to write metrics into history file, will try on next iteration",
-				zap.String("pillar file", pillarM.Filename),
-				zap.String("history file", historyFile),
-				zap.Error(err))
+	start := time.Now()
 
-			continue
-		}
+	l.Infow("cleaning up history metric files", zap.String("directory", c.Telemetry.HistoryPath))
 
-		// remove original Pillar's metrics file
-		l.Infow("removing metrics file", zap.String("file", pillarM.Filename))
+	err := metrics.CleanupMetricsHistory(ctx, c.Telemetry.HistoryPath, c.Telemetry.HistoryKeepInterval)
+	if err != nil {
+		l.Errorw("error during history metrics directory cleanup", zap.Error(err))
+		// not critical error, keep processing
+	}
 
-		err = os.Remove(pillarM.Filename)
+	if c.Telemetry.HistoryMaxSize > 0 {
+		err = metrics.CleanupMetricsHistoryBySize(ctx, c.Telemetry.HistoryPath, int64(c.Telemetry.HistoryMaxSize)*1024*1024)
 		if err != nil {
-			l.Errorw("failed to remove metrics file, will try on next iteration",
-				zap.String("file", pillarM.Filename),
-				zap.Error(err))
+			l.Errorw("error during history metrics directory size cleanup", zap.Error(err))
+			// not critical error, keep processing
+		}
+	}
 
-			continue
+	if c.Telemetry.HistoryMaxFiles > 0 {
+		err = metrics.CleanupMetricsHistoryByCount(ctx, c.Telemetry.HistoryPath, c.Telemetry.HistoryMaxFiles)
+		if err != nil {
+			l.Errorw("error during history metrics directory files count cleanup", zap.Error(err))
+			// not critical error, keep processing
 		}
 	}
+
+	l.Infow("cleaning up quarantined metric files", zap.String("directory", c.Telemetry.QuarantinePath))
+
+	err = metrics.CleanupQuarantine(ctx, c.Telemetry.QuarantinePath, c.Telemetry.QuarantineKeepInterval)
+	if err != nil {
+		l.Errorw("error during quarantine directory cleanup", zap.Error(err))
+		// not critical error, keep processing
+	}
+
+	l.Infow("cleaning up failed metric files", zap.String("directory", c.Telemetry.FailedPath))
+
+	// failed metrics files are kept as long as quarantined ones.
+	err = metrics.CleanupQuarantine(ctx, c.Telemetry.FailedPath, c.Telemetry.QuarantineKeepInterval)
+	if err != nil {
+		l.Errorw("error during failed metrics directory cleanup", zap.Error(err))
+		// not critical error, keep processing
+	}
+
+	summary := metrics.RunSummary{
+		StartTime:              start,
+		CleanupDurationSeconds: time.Since(start).Seconds(),
+	}
+
+	if disabled {
+		// the rest of iteration is skipped, metrics files are kept until telemetry is enabled back.
+		summary.Disabled = true
+		writeLastRunSummary(ctx, c, summary, nil)
+
+		return nil
+	}
+
+	return processMetrics(ctx, c, root.processor, status, summary)
+}
+
+// processMetrics processes Pillars metrics files, sends telemetry to Percona Platform
+// and writes the summary of the run into last run summary file.
+func processMetrics(ctx context.Context, c config.Config, processor *pipeline.Processor, status *agentStatus,
+	summary metrics.RunSummary,
+) error {
+	logger.FromContext(ctx).Sugar().Info("processing Pillars metrics files")
+
+	start := time.Now()
+	res, err := processor.Process(ctx)
+
+	summary.ProcessingDurationSeconds = time.Since(start).Seconds()
+	summary.FilesProcessed = res.Processed
+	summary.FilesSent = res.Sent
+	summary.FilesSuppressed = res.Suppressed
+	summary.FilesFailed = res.Failed
+	writeLastRunSummary(ctx, c, summary, err)
+	saveErrorCounters(ctx, c, status.errorCounters)
+
+	return err
 }
 
 func main() {
@@ -285,39 +487,348 @@ func main() {
 		os.Exit(0)
 	}
 
-	logger.SetupGlobal(&logger.GlobalOpts{LogName: "telemetry-agent", LogDevMode: conf.Log.DevMode, LogDebug: conf.Log.Verbose})
+	if conf.Command == config.CommandCompletion {
+		// completion script is printed before logger setup, so nothing but the script is written.
+		if err := config.WriteCompletion(os.Stdout, conf.Completion.Shell); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print shell completion script: %v\n", err)
+			os.Exit(config.ExitCodeError)
+		}
+
+		return
+	}
+
+	// commands are run interactively, so only service logs are written into log file, syslog and journald.
+	isService := conf.Command == config.CommandRun && !conf.DryRun
+
+	logFile := ""
+	if isService {
+		logFile = conf.Log.File
+	}
+
+	logger.SetupGlobal(&logger.GlobalOpts{
+		LogName:    "telemetry-agent",
+		LogDevMode: conf.Log.DevMode,
+		LogDebug:   conf.Log.Verbose,
+		// stdout is reserved for command output.
+		LogStderr:           !isService,
+		LogFile:             logFile,
+		LogMaxSize:          conf.Log.MaxSize,
+		LogMaxBackups:       conf.Log.MaxBackups,
+		LogMaxAge:           conf.Log.MaxAge,
+		LogSyslog:           isService && conf.Log.Syslog,
+		LogSyslogFacility:   conf.Log.SyslogFacility,
+		LogSyslogTag:        conf.Log.SyslogTag,
+		LogJournald:         isService && conf.Log.Journald,
+		LogSamplingFirst:    conf.Log.SamplingFirst,
+		LogSamplingInterval: time.Duration(conf.Log.SamplingInterval) * time.Second,
+		LogColor:            conf.Log.Color,
+		LogTimeFormat:       conf.Log.TimeFormat,
+	})
 
 	l := zap.L().Sugar()
 	defer func(l *zap.SugaredLogger) {
 		_ = l.Sync()
 	}(l)
 
-	l.Infow("values from config:", zap.Any("config", conf))
+	l.Infow("values from config:", zap.Any("config", logger.NewRedactor(conf.Log.RedactKeys).Value(conf)))
 
-	// check that <telemetry root>/history dir exists on filesystem
-	err := createTelemetryDirs(conf.Telemetry.HistoryPath)
+	// external commands are recorded or replayed in all commands scraping host metrics and packages.
+	baseCtx, err := commandRecorderContext(context.Background(), conf)
 	if err != nil {
-		l.Panic(err)
+		fatalw(l, "failed to set up recording of external commands", err, config.ExitCodeConfig)
+	}
+
+	if conf.Command == config.CommandPackages {
+		err := printInstalledPackages(baseCtx, conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to print installed Percona packages", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandStatus {
+		err := printAgentStatus(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to query Telemetry Agent status", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandSchedule {
+		err := printSchedule(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to print schedule", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandDoctor {
+		err := runDoctorCommand(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "telemetry directories check failed", err, config.ExitCodePermission)
+		}
+
+		return
 	}
 
-	pltClient, err := createPerconaPlatformClient(conf)
+	if conf.Command == config.CommandID {
+		err := runIDCommand(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to process host instance ID", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandPurge {
+		err := purgeLocalState(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to purge local telemetry state", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandCollect {
+		err := printCollectedReport(baseCtx, conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to collect telemetry report", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandSendFile {
+		err := sendMetricsFile(baseCtx, conf, os.Stdin, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to send metrics file", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.Command == config.CommandBundle {
+		err := createSupportBundle(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to create support bundle", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if strings.HasPrefix(conf.Command, "history ") {
+		err := runHistoryCommand(conf, os.Stdout)
+		if err != nil {
+			fatalw(l, "failed to run history command", err, config.ExitCodeError)
+		}
+
+		return
+	}
+
+	if conf.DryRun {
+		// single iteration without cleanup, nothing is sent to Percona Platform and no files are changed.
+		for _, rc := range conf.Roots() {
+			processor, err := newProcessor(rc, nil, newAgentStatus(nil))
+			if err != nil {
+				fatalw(l, "failed to create metrics processor", err, config.ExitCodeConfig)
+			}
+
+			_, err = processor.Process(baseCtx)
+			if err != nil {
+				fatalw(l, "failed to process Pillars metrics in dry-run mode", err, config.ExitCodeError)
+			}
+		}
+
+		return
+	}
+
+	for _, rc := range conf.Roots() {
+		// check that <telemetry root>/history dir exists on filesystem
+		err = createTelemetryDirs(rc.Telemetry.HistoryPath)
+		if err != nil {
+			fatalw(l, "failed to create telemetry directories", err, config.ExitCodeError)
+		}
+
+		warnTelemetryDirsProblems(rc)
+	}
+
+	sender, err := createPlatformSender(conf)
 	if err != nil {
-		l.Panic(err)
+		// client creation fails only on invalid configuration (e.g. Percona Platform URL or audit log path).
+		fatalw(l, "failed to create Percona Platform client", err, config.ExitCodeConfig)
+	}
+
+	for _, rc := range conf.Roots() {
+		l.Infow("recovering metrics files left in processing directory", zap.String("directory", rc.Telemetry.ProcessingPath))
+
+		err = metrics.RecoverProcessingFiles(rc.Telemetry.ProcessingPath, rc.Telemetry.RootPath, rc.Telemetry.HistoryPath)
+		if err != nil {
+			l.Errorw("error during processing directory recovery", zap.Error(err))
+			// not critical error, keep processing
+		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(baseCtx)
 	defer cancel()
 
 	l.Info("Percona Telemetry Agent started")
 
+	status := newAgentStatus(loadErrorCounters(conf))
+	status.clockSkew = metrics.NewClockSkew(time.Duration(conf.Telemetry.MaxClockSkew) * time.Second)
+
+	roots, err := newRootProcessors(conf, sender, status)
+	if err != nil {
+		fatalw(l, "failed to create metrics processor", err, config.ExitCodeConfig)
+	}
+	defer closeRootProcessors(roots)
+
+	remoteConf, err := newRemoteConfig(conf, rootCollectors(roots))
+	if err != nil {
+		fatalw(l, "failed to create remote configuration client", err, config.ExitCodeConfig)
+	}
+
+	// ingest socket is created before privileges are dropped, but it is served only after the agent is set up.
+	var ingestListener net.Listener
+
+	if conf.Ingest.Socket != "" && !conf.Oneshot {
+		ingestListener, err = listenIngestSocket(conf)
+		if err != nil {
+			l.Warnw("failed to start gRPC ingest server, reports can't be ingested over unix socket", zap.Error(err))
+		}
+	}
+
+	// privileges are dropped after all files requiring them are created or opened.
+	err = dropPrivileges(conf)
+	if err != nil {
+		fatalw(l, "failed to switch to unprivileged user", err, config.ExitCodeError)
+	}
+
+	if conf.Tracing.Endpoint != "" {
+		stopTracing, err := startTracing(conf)
+		if err != nil {
+			l.Warnw("failed to set up tracing, traces are not exported", zap.Error(err))
+		} else {
+			l.Infow("exporting traces", zap.String("endpoint", conf.Tracing.Endpoint))
+			// deferred functions are not run on fatal exit, so spans of failed oneshot iteration may be lost.
+			defer stopTracing()
+		}
+	}
+
+	if conf.Security.Sandbox {
+		err = enableSandbox(conf)
+		if err != nil {
+			fatalw(l, "failed to enable sandboxing", err, config.ExitCodeError)
+		}
+	}
+
+	ks := newKillSwitch(conf)
+
+	if conf.Oneshot {
+		// single iteration for cron jobs and CI validation, exit status reflects its result.
+		remoteConf.refresh(ctx)
+
+		err = runIteration(ctx, roots, ks, status)
+		if err != nil {
+			fatalw(l, "failed to process and send telemetry", err, config.ExitCodeError)
+		}
+
+		l.Info("finished")
+
+		return
+	}
+
+	// nil channel is never ready, so watch events are ignored when watch mode is disabled.
+	var watchC <-chan *rootProcessor
+
+	if conf.Telemetry.Watch {
+		watchC = watchRoots(ctx, roots, time.Duration(conf.Telemetry.WatchDelay)*time.Second)
+	}
+
+	if watchdogIntv := utils.SdWatchdogInterval(); watchdogIntv > 0 {
+		l.Infow("systemd watchdog is enabled", zap.Duration("heartbeat interval", watchdogIntv))
+
+		// heartbeat interval is half of the watchdog timeout, paced requests are up to a half of send spread apart.
+		if sendSpread := time.Duration(conf.Telemetry.SendSpread) * time.Second; sendSpread >= 4*watchdogIntv {
+			l.Warnw("send spread is too long for systemd watchdog timeout, the agent may be restarted during iteration",
+				zap.Duration("send spread", sendSpread),
+				zap.Duration("watchdog timeout", 2*watchdogIntv))
+		}
+
+		go runWatchdog(ctx, status, watchdogIntv)
+	}
+
+	if conf.Debug.PprofAddr != "" {
+		err = startPprofServer(ctx, conf.Debug.PprofAddr)
+		if err != nil {
+			l.Warnw("failed to start pprof server, pprof endpoints are disabled", zap.Error(err))
+		}
+	}
+
+	if conf.Health.Addr != "" {
+		err = startHealthServer(ctx, conf, status)
+		if err != nil {
+			l.Warnw("failed to start health check server, health check endpoints are disabled", zap.Error(err))
+		}
+	}
+
+	if conf.Push.Addr != "" {
+		err = startPushServer(ctx, conf)
+		if err != nil {
+			l.Warnw("failed to start push server, metrics can be delivered with files only", zap.Error(err))
+		}
+	}
+
+	if ingestListener != nil {
+		startIngestServer(ctx, conf, ingestListener)
+	}
+
+	err = startStatusServer(ctx, conf, status, remoteConf)
+	if err != nil {
+		l.Warnw("failed to start status socket server, status command is not available", zap.Error(err))
+	}
+
+	status.setReady(true)
+
+	if err := utils.SdNotify(utils.SdNotifyReady); err != nil {
+		l.Warnw("failed to notify systemd about service readiness", zap.Error(err))
+	}
+
+	// self-telemetry channel stays nil if disabled, so it never fires.
+	var (
+		selfReportTimer *time.Timer
+		selfReportC     <-chan time.Time
+	)
+
+	if conf.Telemetry.SelfReport {
+		selfReportTimer = time.NewTimer(selfReportDelay(conf, time.Now()))
+		defer selfReportTimer.Stop()
+
+		selfReportC = selfReportTimer.C
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	utils.SignalRunner(
 		func() {
-			checkIntv := time.Duration(conf.Telemetry.CheckInterval) * time.Second
-			l.Infof("sleeping for %d seconds before first iteration", conf.Telemetry.CheckInterval)
+			// remote configuration is fetched before the first sleep, so it may change the check interval.
+			remoteConf.refresh(ctx)
+			checkIntv := remoteConf.checkInterval()
+
+			if conf.Telemetry.RunOnStart {
+				// freshly installed hosts report existing metrics files without waiting for the whole check interval.
+				l.Info("running first iteration on start")
+				// errors are logged during processing, failed telemetry is retried on next iteration.
+				_ = runIteration(ctx, roots, ks, status)
+				l.Info(fmt.Sprintf("sleep for %d seconds", int(checkIntv.Seconds())))
+			} else {
+				l.Infof("sleeping for %d seconds before first iteration", int(checkIntv.Seconds()))
+			}
 
 			ticker := time.NewTicker(checkIntv)
+			status.setNextIteration(time.Now().Add(checkIntv))
 
 			for {
 				select {
@@ -330,23 +841,45 @@ func main() {
 					return
 				case <-ticker.C:
 					// start new metrics processing iteration
-					l.Info("start metrics processing iteration")
+					remoteConf.refresh(ctx)
+					// errors are logged during processing, failed telemetry is retried on next iteration.
+					_ = runIteration(ctx, roots, ks, status)
 
-					l.Infow("cleaning up history metric files", zap.String("directory", conf.Telemetry.HistoryPath))
+					if intv := remoteConf.checkInterval(); intv != checkIntv {
+						checkIntv = intv
+						ticker.Reset(checkIntv)
+					}
 
-					err = metrics.CleanupMetricsHistory(conf.Telemetry.HistoryPath, conf.Telemetry.HistoryKeepInterval)
-					if err != nil {
-						l.Errorw("error during history metrics directory cleanup", zap.Error(err))
-						// not critical error, keep processing
+					status.setNextIteration(time.Now().Add(checkIntv))
+					l.Info(fmt.Sprintf("sleep for %d seconds", int(checkIntv.Seconds())))
+				case <-selfReportC:
+					if !ks.isDisabled() {
+						sendSelfReport(ctx, conf, sender, status)
 					}
 
-					l.Info("processing Pillars metrics files")
-					processMetrics(ctx, conf, pltClient)
-					l.Info(fmt.Sprintf("sleep for %d seconds", conf.Telemetry.CheckInterval))
+					// failed self-report is not retried until the next one is due.
+					selfReportTimer.Reset(selfReportInterval)
+				case root := <-watchC:
+					// new metrics files are dropped by Pillars, process them without waiting for the next iteration.
+					if !ks.isDisabled() {
+						status.workStarted()
+						watchCtx := rootContext(iterationContext(ctx), roots, root)
+						logger.FromContext(watchCtx).Info("new Pillars metrics files detected, processing them")
+						watchCtx, span := otel.Tracer(pipeline.TracerName).Start(watchCtx, "watch iteration")
+						err := processMetrics(watchCtx, root.c, root.processor, status, metrics.RunSummary{StartTime: time.Now()})
+						pipeline.EndSpan(span, err)
+						status.workFinished()
+					}
 				}
 			}
 		},
 		func() {
+			status.setReady(false)
+
+			if err := utils.SdNotify(utils.SdNotifyStopping); err != nil {
+				l.Warnw("failed to notify systemd about service shutdown", zap.Error(err))
+			}
+
 			cancel()
 			wg.Wait()
 		},
@@ -0,0 +1,95 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// printInstalledPackages scrapes installed Percona packages the same way as it is done
+// during metrics processing iteration and prints them to w in requested format.
+func printInstalledPackages(ctx context.Context, c config.Config, w io.Writer) error {
+	installedPackages := metrics.ScrapeInstalledPackages(ctx, metrics.PackageScrapeOpts{
+		Workers:          c.Telemetry.PackageQueryWorkers,
+		CmdTimeout:       time.Duration(c.Telemetry.CmdTimeout) * time.Second,
+		RepoqueryTimeout: time.Duration(c.Telemetry.RepoqueryTimeout) * time.Second,
+	})
+
+	switch c.Packages.Format {
+	case "table":
+		return printPackagesTable(installedPackages, w)
+	case "pretty":
+		jsonData, err := json.MarshalIndent(installedPackages, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, string(jsonData))
+
+		return err
+	default:
+		// the same format as reported in "installed_packages" host metric.
+		jsonData, err := json.Marshal(installedPackages)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, string(jsonData))
+
+		return err
+	}
+}
+
+func printPackagesTable(pkgL []*metrics.Package, w io.Writer) error {
+	valueOrDash := func(v string) string {
+		if len(v) == 0 {
+			return "-"
+		}
+
+		return v
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, err := fmt.Fprintln(tw, "NAME\tVERSION\tARCH\tREPOSITORY\tCOMPONENT\tORIGIN\tUPDATE AVAILABLE")
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgL {
+		_, err = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			pkg.Name,
+			valueOrDash(pkg.Version),
+			valueOrDash(pkg.Arch),
+			valueOrDash(pkg.Repository.Name),
+			valueOrDash(pkg.Repository.Component),
+			valueOrDash(pkg.Origin),
+			valueOrDash(pkg.UpdateAvailable))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
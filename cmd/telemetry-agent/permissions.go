@@ -0,0 +1,198 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+	"github.com/percona/telemetry-agent/utils"
+)
+
+// sharedDirMode is the mode telemetry directories shared with Pillars must have,
+// so Pillars users being members of telemetry group can write metrics files.
+const sharedDirMode = 0o070
+
+// dirCheckResult holds problems of telemetry directory left after optional fixing.
+type dirCheckResult struct {
+	path     string
+	problems []string
+}
+
+// telemetryDirExpectations returns expected owner, group and mode of telemetry directories
+// the same as they are set up by packages.
+func telemetryDirExpectations(c config.Config) ([]utils.DirExpectation, error) {
+	gid := -1
+
+	if c.Telemetry.Group != "" {
+		g, err := user.LookupGroup(c.Telemetry.Group)
+		if err != nil {
+			return nil, fmt.Errorf("can't find telemetry group: %w", err)
+		}
+
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return nil, fmt.Errorf("invalid gid of group %q: %w", c.Telemetry.Group, err)
+		}
+	}
+
+	// directories owned by Telemetry Agent are expected to be owned by the user it runs as,
+	// root has access to any directory, so owner of them isn't checked for root.
+	uid := -1
+
+	switch {
+	case c.Telemetry.User != "":
+		u, err := lookupUnprivilegedUser(c.Telemetry.User)
+		if err != nil {
+			return nil, err
+		}
+
+		uid = u.uid
+	case os.Geteuid() != 0:
+		uid = os.Geteuid()
+	}
+
+	// access is checked for the current process only when it runs as Telemetry Agent service user.
+	accessible := uid >= 0 && uid == os.Geteuid()
+
+	var expectations []utils.DirExpectation
+	if !c.Kubernetes.Enabled {
+		// Percona telemetry file is created by both Telemetry Agent and Pillars, it is not used in Kubernetes sidecar mode.
+		expectations = append(expectations, utils.DirExpectation{
+			Path: filepath.Dir(metrics.TelemetryFile()), UID: -1, GID: gid, Mode: sharedDirMode,
+		})
+	}
+
+	expectations = append(expectations,
+		utils.DirExpectation{Path: c.Telemetry.RootPath, UID: uid, GID: gid, Mode: sharedDirMode, Accessible: accessible},
+		// history files inherit telemetry group.
+		utils.DirExpectation{Path: c.Telemetry.HistoryPath, UID: uid, GID: gid, Mode: sharedDirMode | os.ModeSetgid, Accessible: accessible},
+	)
+
+	for _, dir := range []string{c.Telemetry.ProcessingPath, c.Telemetry.FailedPath, c.Telemetry.QuarantinePath} {
+		expectations = append(expectations, utils.DirExpectation{Path: dir, UID: uid, GID: -1, Optional: true, Accessible: accessible})
+	}
+
+	for _, pillar := range c.Telemetry.Pillars {
+		// Pillar directories are created by Pillars running as their own users, e.g. mysql.
+		expectations = append(expectations, utils.DirExpectation{
+			Path:       pillar.Path,
+			UID:        -1,
+			GID:        gid,
+			Mode:       sharedDirMode,
+			Optional:   true,
+			Accessible: accessible,
+		})
+	}
+
+	return expectations, nil
+}
+
+// checkTelemetryDirs checks owner, group and mode of telemetry directories and fixes them if requested.
+func checkTelemetryDirs(c config.Config, fix bool) ([]dirCheckResult, error) {
+	l := zap.L().Sugar()
+
+	expectations, err := telemetryDirExpectations(c)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]dirCheckResult, 0, len(expectations))
+
+	for _, e := range expectations {
+		problems := utils.CheckDir(e)
+
+		if len(problems) != 0 && fix {
+			if err := utils.FixDir(e); err != nil {
+				problems = append(problems, "can't fix: "+err.Error())
+			} else {
+				l.Infow("telemetry directory permissions are fixed", zap.String("directory", e.Path), zap.Strings("problems", problems))
+				problems = utils.CheckDir(e)
+			}
+		}
+
+		results = append(results, dirCheckResult{path: e.Path, problems: problems})
+	}
+
+	return results, nil
+}
+
+// warnTelemetryDirsProblems checks telemetry directories at startup and logs found problems,
+// they are not fatal, since Telemetry Agent may still process metrics of some Pillars.
+func warnTelemetryDirsProblems(c config.Config) {
+	l := zap.L().Sugar()
+
+	results, err := checkTelemetryDirs(c, c.Telemetry.FixPermissions)
+	if err != nil {
+		l.Warnw("can't check telemetry directories permissions", zap.Error(err))
+		return
+	}
+
+	for _, r := range results {
+		if len(r.problems) != 0 {
+			l.Warnw("telemetry directory has wrong permissions, Pillars metrics may not be collected, run 'doctor --fix' command as root to fix it",
+				zap.String("directory", r.path),
+				zap.Strings("problems", r.problems))
+		}
+	}
+}
+
+// runDoctorCommand prints the results of telemetry directories check, error is returned if there are problems.
+func runDoctorCommand(c config.Config, w io.Writer) error {
+	results, err := checkTelemetryDirs(c, c.Doctor.Fix)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if _, err := fmt.Fprintln(tw, "DIRECTORY\tSTATUS"); err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, r := range results {
+		status := "OK"
+		if len(r.problems) != 0 {
+			status = strings.Join(r.problems, "; ")
+			failed++
+		}
+
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", r.path, status); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d telemetry directories have wrong permissions", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// unprivilegedUser holds credentials of the user Telemetry Agent switches to.
+type unprivilegedUser struct {
+	name   string
+	uid    int
+	gid    int
+	groups []int
+}
+
+func lookupUnprivilegedUser(name string) (*unprivilegedUser, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("can't find user %q: %w", name, err)
+	}
+
+	res := &unprivilegedUser{name: name}
+
+	if res.uid, err = strconv.Atoi(u.Uid); err != nil {
+		return nil, fmt.Errorf("invalid uid of user %q: %w", name, err)
+	}
+
+	if res.gid, err = strconv.Atoi(u.Gid); err != nil {
+		return nil, fmt.Errorf("invalid gid of user %q: %w", name, err)
+	}
+
+	if res.uid == 0 {
+		return nil, fmt.Errorf("user %q is privileged", name)
+	}
+
+	// supplementary groups give access to Pillars metrics directories, e.g. 'percona-telemetry' group.
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("can't get groups of user %q: %w", name, err)
+	}
+
+	for _, g := range groupIDs {
+		gid, err := strconv.Atoi(g)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group id of user %q: %w", name, err)
+		}
+
+		res.groups = append(res.groups, gid)
+	}
+
+	return res, nil
+}
+
+// dropPrivileges switches Telemetry Agent started as root to the configured unprivileged user
+// for the rest of its life, so package scraping commands and sending telemetry are run without root privileges.
+// Telemetry directories and files requiring root privileges to create are created and handed over to the user first.
+func dropPrivileges(c config.Config) error {
+	l := zap.L().Sugar()
+
+	if c.Telemetry.User == "" {
+		return nil
+	}
+
+	u, err := lookupUnprivilegedUser(c.Telemetry.User)
+	if err != nil {
+		return err
+	}
+
+	if os.Geteuid() != 0 {
+		if os.Geteuid() != u.uid {
+			l.Warnw("Telemetry Agent is not started as root, switching user is skipped",
+				zap.String("user", u.name), zap.Int("uid", os.Geteuid()))
+		}
+
+		return nil
+	}
+
+	// Percona telemetry file is created by root, since its directory is usually writable by root only.
+	if !c.Kubernetes.Enabled {
+		metrics.InitInstanceID()
+	}
+
+	for _, rc := range c.Roots() {
+		if err := handOverTelemetryRoot(rc, u); err != nil {
+			return err
+		}
+	}
+
+	// ingest socket keeps the telemetry group, so it stays accessible to the user and members of the group.
+	for _, file := range []string{c.Telemetry.ErrorCountersPath, c.Platform.AuditLog, c.Log.File, c.Ingest.Socket} {
+		if err := chownFile(file, u); err != nil {
+			return err
+		}
+	}
+
+	// groups are set before gid and gid before uid, since changing them requires root privileges.
+	if err := syscall.Setgroups(u.groups); err != nil {
+		return fmt.Errorf("can't set supplementary groups: %w", err)
+	}
+
+	if err := syscall.Setgid(u.gid); err != nil {
+		return fmt.Errorf("can't set gid: %w", err)
+	}
+
+	if err := syscall.Setuid(u.uid); err != nil {
+		return fmt.Errorf("can't set uid: %w", err)
+	}
+
+	// make sure root privileges can't be regained.
+	if err := syscall.Setuid(0); err == nil {
+		return errors.New("root privileges are not dropped")
+	}
+
+	l.Infow("switched to unprivileged user", zap.String("user", u.name), zap.Int("uid", u.uid), zap.Int("gid", u.gid))
+
+	return nil
+}
+
+// handOverTelemetryRoot creates telemetry directories inside telemetry root path and hands them over to the user
+// along with telemetry files inside it. Only the owner is changed: the group (e.g. 'percona-telemetry' set
+// by packaging) gives Pillars users access to telemetry root path, so it is kept.
+func handOverTelemetryRoot(c config.Config, u *unprivilegedUser) error {
+	dirs := []string{c.Telemetry.HistoryPath, c.Telemetry.ProcessingPath, c.Telemetry.FailedPath, c.Telemetry.QuarantinePath}
+	if err := createTelemetryDirs(dirs...); err != nil {
+		return err
+	}
+
+	// telemetry root path itself is handed over without its content, since Pillars directories are owned by Pillars.
+	if err := os.Lchown(c.Telemetry.RootPath, u.uid, -1); err != nil {
+		return fmt.Errorf("can't change owner of telemetry root directory: %w", err)
+	}
+
+	for _, dir := range dirs {
+		if err := chownTree(dir, u.uid); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range []string{
+		c.Telemetry.LastRunPath, c.Telemetry.HashSaltPath, c.Telemetry.StateDBPath, c.Telemetry.FirstSeenPath,
+		c.Telemetry.InstanceIDChangesPath, c.Telemetry.InstanceFile,
+	} {
+		if err := chownFile(file, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chownFile hands over file to the user keeping its group, absent file and empty path are skipped.
+func chownFile(file string, u *unprivilegedUser) error {
+	if file == "" {
+		return nil
+	}
+
+	if err := os.Lchown(file, u.uid, -1); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("can't change owner of %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// chownTree changes owner of root and everything inside it keeping their groups.
+func chownTree(root string, uid int) error {
+	err := filepath.WalkDir(filepath.Clean(root), func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		return os.Lchown(p, uid, -1)
+	})
+	if err != nil {
+		return fmt.Errorf("can't change owner of %s: %w", root, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+func TestHandOverTelemetryRootKeepsGroup(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing owner of files requires root privileges")
+	}
+
+	const (
+		uid            = 54321
+		gid            = 54321
+		telemetryGroup = 54322
+	)
+
+	rootPath := t.TempDir()
+	c := config.Config{
+		Telemetry: config.TelemetryOpts{
+			RootPath:       rootPath,
+			HistoryPath:    filepath.Join(rootPath, "history"),
+			ProcessingPath: filepath.Join(rootPath, "processing"),
+			FailedPath:     filepath.Join(rootPath, "failed"),
+			QuarantinePath: filepath.Join(rootPath, "quarantine"),
+			LastRunPath:    filepath.Join(rootPath, "last_run.json"),
+			HashSaltPath:   filepath.Join(rootPath, "hash_salt"),
+			StateDBPath:    filepath.Join(rootPath, "state.db"),
+		},
+	}
+
+	// packaging sets 'percona-telemetry' group on telemetry root path and history directory.
+	require.NoError(t, os.MkdirAll(c.Telemetry.HistoryPath, 0o2775))
+	require.NoError(t, os.WriteFile(filepath.Join(c.Telemetry.HistoryPath, "1708026156-a.json"), []byte("{}"), 0o660))
+	require.NoError(t, os.WriteFile(c.Telemetry.LastRunPath, []byte("{}"), 0o660))
+
+	for _, p := range []string{rootPath, c.Telemetry.HistoryPath, filepath.Join(c.Telemetry.HistoryPath, "1708026156-a.json"), c.Telemetry.LastRunPath} {
+		require.NoError(t, os.Lchown(p, 0, telemetryGroup))
+	}
+
+	require.NoError(t, handOverTelemetryRoot(c, &unprivilegedUser{name: "daemon", uid: uid, gid: gid}))
+
+	for _, p := range []string{rootPath, c.Telemetry.HistoryPath, filepath.Join(c.Telemetry.HistoryPath, "1708026156-a.json"), c.Telemetry.LastRunPath} {
+		st, err := os.Lstat(p)
+		require.NoError(t, err)
+
+		sys, ok := st.Sys().(*syscall.Stat_t)
+		require.True(t, ok)
+		require.Equal(t, uint32(uid), sys.Uid, p)
+		require.Equal(t, uint32(telemetryGroup), sys.Gid, p)
+	}
+}
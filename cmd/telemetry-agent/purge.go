@@ -0,0 +1,187 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+const (
+	killSwitchFilePermissions = 0o644
+	killSwitchDirPermissions  = 0o755
+)
+
+// purgeLocalState removes instance IDs, telemetry history and pending metrics files of each telemetry root path,
+// so the host is forgotten locally. Percona Platform doesn't provide telemetry deletion API yet,
+// so instance IDs are printed for requesting deletion of already sent telemetry from Percona.
+// Nothing is removed unless it is confirmed by --yes.
+func purgeLocalState(c config.Config, w io.Writer) error {
+	l := zap.L().Sugar()
+
+	var (
+		paths       []string
+		instanceIDs int
+	)
+
+	for _, root := range c.Roots() {
+		instanceID, err := metrics.ReadInstanceID(rootInstanceFile(root))
+		if err != nil {
+			return fmt.Errorf("can't read instance ID of %s: %w", root.Telemetry.RootPath, err)
+		}
+
+		if instanceID != "" {
+			instanceIDs++
+
+			if _, err := fmt.Fprintf(w, "instance ID: %s (%s)\n", instanceID, root.Telemetry.RootPath); err != nil {
+				return err
+			}
+		}
+
+		rootPaths, err := purgePaths(root)
+		if err != nil {
+			return err
+		}
+
+		paths = append(paths, rootPaths...)
+	}
+
+	if instanceIDs != 0 {
+		if _, err := fmt.Fprintln(w, "Percona Platform doesn't provide telemetry deletion API yet, "+
+			"contact Percona to request deletion of telemetry sent with listed instance IDs."); err != nil {
+			return err
+		}
+	}
+
+	// error counters are shared by all telemetry root paths.
+	if _, err := os.Lstat(c.Telemetry.ErrorCountersPath); err == nil {
+		paths = append(paths, c.Telemetry.ErrorCountersPath)
+	}
+
+	var errs []error
+
+	for _, p := range paths {
+		if !c.Purge.Yes {
+			if _, err := fmt.Fprintf(w, "would remove %s\n", p); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := os.RemoveAll(p); err != nil {
+			// do our best: remove as much as possible.
+			errs = append(errs, err)
+			continue
+		}
+
+		l.Infow("removed local telemetry state", zap.String("path", p))
+
+		if _, err := fmt.Fprintf(w, "removed %s\n", p); err != nil {
+			return err
+		}
+	}
+
+	if !c.Purge.Yes {
+		_, err := fmt.Fprintln(w, "nothing is removed, rerun with --yes to remove listed files")
+		return err
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to remove local telemetry state: %w", err)
+	}
+
+	if c.Purge.Disable {
+		if err := createKillSwitchFile(c.Telemetry.DisableFile); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "telemetry is disabled by kill-switch file %s\n", c.Telemetry.DisableFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purgePaths returns existing files and directories entries holding local telemetry state of telemetry root path,
+// except for shared error counters. Pillar metrics directories themselves are kept, since they are created and
+// owned by Pillars.
+func purgePaths(c config.Config) ([]string, error) {
+	paths := []string{rootInstanceFile(c)}
+
+	dirs := []string{c.Telemetry.HistoryPath, c.Telemetry.ProcessingPath, c.Telemetry.FailedPath, c.Telemetry.QuarantinePath}
+	for _, pillar := range c.Telemetry.Pillars {
+		// files of Pillars disabled by consent file are never touched.
+		if !metrics.PillarDisabled(pillar.Path) {
+			dirs = append(dirs, pillar.Path)
+		}
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(filepath.Clean(dir))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, fmt.Errorf("can't read directory %s: %w", dir, err)
+		}
+
+		for _, e := range entries {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	paths = append(paths, c.Telemetry.LastRunPath, c.Telemetry.HashSaltPath, c.Telemetry.StateDBPath,
+		c.Telemetry.FirstSeenPath, c.Telemetry.InstanceIDChangesPath)
+
+	existing := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err == nil {
+			existing = append(existing, p)
+		}
+	}
+
+	return existing, nil
+}
+
+func createKillSwitchFile(disableFile string) error {
+	if disableFile == "" {
+		return errors.New("kill-switch file path is not defined")
+	}
+
+	cleanFile := filepath.Clean(disableFile)
+
+	if err := os.MkdirAll(filepath.Dir(cleanFile), os.ModeDir|killSwitchDirPermissions); err != nil {
+		return fmt.Errorf("failed to create kill-switch file directory: %w", err)
+	}
+
+	if err := os.WriteFile(cleanFile, nil, killSwitchFilePermissions); err != nil {
+		return fmt.Errorf("failed to create kill-switch file: %w", err)
+	}
+
+	return nil
+}
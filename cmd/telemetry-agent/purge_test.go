@@ -0,0 +1,61 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+func TestPurgePathsExtraRoot(t *testing.T) {
+	t.Parallel()
+
+	var c config.Config
+	c.Telemetry.Pillars = []config.PillarOpts{{Path: filepath.Join("/usr", "local", "percona", "telemetry", "ps")}}
+
+	rootPath := t.TempDir()
+	root := c.ForRoot(rootPath)
+
+	for _, dir := range []string{"history", "processing", "failed", "quarantine", "ps"} {
+		require.NoError(t, os.Mkdir(filepath.Join(rootPath, dir), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(rootPath, dir, "1708026156-a.json"), nil, 0o600))
+	}
+
+	for _, file := range []string{"telemetry_uuid", "last_run.json", "hash_salt", "state.db", "first_seen", "instance_id_changes.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(rootPath, file), nil, 0o600))
+	}
+
+	paths, err := purgePaths(root)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		filepath.Join(rootPath, "telemetry_uuid"),
+		filepath.Join(rootPath, "history", "1708026156-a.json"),
+		filepath.Join(rootPath, "processing", "1708026156-a.json"),
+		filepath.Join(rootPath, "failed", "1708026156-a.json"),
+		filepath.Join(rootPath, "quarantine", "1708026156-a.json"),
+		filepath.Join(rootPath, "ps", "1708026156-a.json"),
+		filepath.Join(rootPath, "last_run.json"),
+		filepath.Join(rootPath, "hash_salt"),
+		filepath.Join(rootPath, "state.db"),
+		filepath.Join(rootPath, "first_seen"),
+		filepath.Join(rootPath, "instance_id_changes.json"),
+	}, paths)
+}
@@ -0,0 +1,158 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// pushMaxBodySize is the maximum size of metrics pushed in a single request.
+const pushMaxBodySize = 1 << 20 // 1 MiB
+
+// startPushServer serves 'POST /v1/metrics/{pillar}' endpoint on loopback address until ctx is done.
+// Pushed metrics are validated and saved into the Pillar metrics directory, so they are sent, saved into history
+// and removed by the regular metrics processing. Only Pillars of the telemetry root path are served,
+// extra root paths are not.
+func startPushServer(ctx context.Context, c config.Config) error {
+	l := zap.L().Sugar()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/metrics/{pillar}", func(w http.ResponseWriter, r *http.Request) {
+		handlePushedMetrics(w, r, c)
+	})
+
+	listener, err := net.Listen("tcp", c.Push.Addr)
+	if err != nil {
+		return fmt.Errorf("can't listen push address: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: healthReadHeaderTimeout,
+	}
+
+	go func() {
+		err := srv.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Errorw("push server failed", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthShutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	l.Infow("serving metrics push endpoint", zap.String("address", listener.Addr().String()))
+
+	return nil
+}
+
+// handlePushedMetrics saves metrics pushed for the Pillar the request path refers to by its directory name.
+// Optional 'instance' query parameter puts metrics into instance subdirectory of the Pillar directory.
+func handlePushedMetrics(w http.ResponseWriter, r *http.Request, c config.Config) {
+	l := zap.L().Sugar().With(zap.String("pillar", r.PathValue("pillar")))
+
+	pillar, found := lookupPillar(c.Telemetry.Pillars, r.PathValue("pillar"))
+	if !found {
+		http.Error(w, "unknown pillar", http.StatusNotFound)
+		return
+	}
+
+	dir := pillar.Path
+
+	if instance := r.URL.Query().Get("instance"); instance != "" {
+		if instance == "." || instance == ".." || strings.ContainsAny(instance, `/\`) {
+			http.Error(w, "invalid instance name", http.StatusBadRequest)
+			return
+		}
+
+		dir = filepath.Join(dir, instance)
+	}
+
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, pushMaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "metrics are too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, "can't read metrics", http.StatusBadRequest)
+
+		return
+	}
+
+	fileName, err := metrics.SavePushedMetrics(r.Context(), dir, pillar.ProductFamily, content, time.Time{}, metrics.ProcessOpts{
+		FlattenNested: c.Telemetry.FlattenNested,
+		SchemaDir:     c.Telemetry.SchemasPath,
+	})
+
+	switch {
+	case errors.Is(err, metrics.ErrInvalidPushedMetrics):
+		l.Warnw("pushed metrics are rejected", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	case errors.Is(err, metrics.ErrPillarDisabled):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		l.Errorw("failed to save pushed metrics", zap.Error(err))
+		http.Error(w, "can't save metrics", http.StatusInternalServerError)
+
+		return
+	}
+
+	l.Infow("pushed metrics are saved", zap.String("file", fileName))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	err = json.NewEncoder(w).Encode(map[string]string{"file": filepath.Base(fileName)})
+	if err != nil {
+		l.Debugw("failed to write push response", zap.Error(err))
+	}
+}
+
+// lookupPillar returns the Pillar with metrics directory named name, case is ignored.
+func lookupPillar(pillars []config.PillarOpts, name string) (config.PillarOpts, bool) {
+	for _, pillar := range pillars {
+		if strings.EqualFold(filepath.Base(pillar.Path), name) {
+			return pillar, true
+		}
+	}
+
+	return config.PillarOpts{}, false
+}
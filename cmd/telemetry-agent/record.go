@@ -0,0 +1,51 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// commandRecorderContext returns copy of ctx external commands run while scraping metrics are recorded
+// into or replayed from directory set by debug options with, ctx is returned as is if neither is set.
+func commandRecorderContext(ctx context.Context, c config.Config) (context.Context, error) {
+	var (
+		r   *metrics.CommandRecorder
+		err error
+	)
+
+	switch {
+	case c.Debug.RecordDir != "":
+		zap.L().Sugar().Infow("recording output of external commands", zap.String("directory", c.Debug.RecordDir))
+		r, err = metrics.NewCommandRecorder(c.Debug.RecordDir)
+	case c.Debug.ReplayDir != "":
+		zap.L().Sugar().Infow("replaying recorded output of external commands", zap.String("directory", c.Debug.ReplayDir))
+		r, err = metrics.NewCommandReplayer(c.Debug.ReplayDir)
+	default:
+		return ctx, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics.WithCommandRecorder(ctx, r), nil
+}
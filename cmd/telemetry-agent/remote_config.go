@@ -0,0 +1,144 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/pipeline"
+	platformClient "github.com/percona/telemetry-agent/platform"
+)
+
+// remoteConfig applies configuration fetched from Percona Platform on top of local configuration,
+// local configuration takes precedence. Only checkInterval is safe for concurrent use, so status server
+// can report the effective check interval while the main loop refreshes configuration.
+type remoteConfig struct {
+	c config.Config
+	// client is nil if remote configuration is disabled.
+	client     *platformClient.Client
+	path       string
+	publicKey  ed25519.PublicKey
+	collectors []*pipeline.LocalCollector
+	// applied is the last successfully fetched configuration, it is kept while Percona Platform is unavailable.
+	// It is changed by refresh only, mu guards its changes from concurrent checkInterval calls.
+	mu      sync.Mutex
+	applied *platformClient.RemoteConfig
+}
+
+// newRemoteConfig creates remote configuration adjusting collecting of collectors,
+// only local configuration is used if remote configuration URL is not configured.
+func newRemoteConfig(c config.Config, collectors []*pipeline.LocalCollector) (*remoteConfig, error) {
+	if c.Platform.RemoteConfigURL == "" {
+		return &remoteConfig{c: c}, nil
+	}
+
+	u, err := url.ParseRequestURI(c.Platform.RemoteConfigURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote configuration URL: %w", err)
+	}
+
+	// failed fetch is retried before the next iteration, so the main loop is not blocked by retries.
+	client := platformClient.New(
+		platformClient.WithLogger(platformClientLogger{}),
+		platformClient.WithBaseURL(u.Scheme+"://"+u.Host),
+		platformClient.WithLogFullRequest(logger.NewRedactor(c.Log.RedactKeys)),
+		platformClient.WithClientTimeout(30*time.Second),
+	)
+
+	return &remoteConfig{
+		c:          c,
+		client:     client,
+		path:       u.RequestURI(),
+		publicKey:  c.Platform.RemoteConfigPublicKey,
+		collectors: collectors,
+	}, nil
+}
+
+// refresh fetches remote configuration and applies it, the previous configuration is kept on failure.
+func (rc *remoteConfig) refresh(ctx context.Context) {
+	if rc.client == nil {
+		return
+	}
+
+	l := zap.L().Sugar()
+
+	fetched, err := rc.client.FetchRemoteConfig(ctx, rc.path, rc.publicKey)
+	if err != nil {
+		l.Warnw("failed to fetch remote configuration, keep using the previous one", zap.Error(err))
+		return
+	}
+
+	if rc.applied != nil && reflect.DeepEqual(*rc.applied, *fetched) {
+		return
+	}
+
+	for _, name := range fetched.DisabledCollectors {
+		if !slices.Contains(pipeline.CollectorNames(), name) {
+			l.Warnw("unknown collector is disabled by remote configuration, skip it", zap.String("collector", name))
+		}
+	}
+
+	switch {
+	case fetched.CheckInterval <= 0:
+	case !rc.c.DefaultCheckInterval():
+		l.Infow("check interval of remote configuration is ignored as it is set in local configuration",
+			zap.Int("check interval", rc.c.Telemetry.CheckInterval))
+	case fetched.CheckInterval <= rc.c.Telemetry.SendSpread:
+		l.Warnw("check interval of remote configuration is ignored as it is not greater than send spread",
+			zap.Int("check interval", fetched.CheckInterval),
+			zap.Int("send spread", rc.c.Telemetry.SendSpread))
+	}
+
+	l.Infow("applying remote configuration",
+		zap.Int("check interval", fetched.CheckInterval),
+		zap.Strings("disabled collectors", fetched.DisabledCollectors),
+		zap.Strings("extra package patterns", fetched.ExtraPackagePatterns))
+
+	for _, collector := range rc.collectors {
+		collector.SetOverrides(pipeline.CollectorOverrides{
+			DisabledCollectors:   fetched.DisabledCollectors,
+			ExtraPackagePatterns: fetched.ExtraPackagePatterns,
+		})
+	}
+
+	rc.mu.Lock()
+	rc.applied = fetched
+	rc.mu.Unlock()
+}
+
+// checkInterval returns interval between metrics processing iterations. Remote check interval is used
+// only if local check interval is left default and requests of iteration can be spread over it.
+func (rc *remoteConfig) checkInterval() time.Duration {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.applied == nil || rc.applied.CheckInterval <= rc.c.Telemetry.SendSpread || !rc.c.DefaultCheckInterval() {
+		return time.Duration(rc.c.Telemetry.CheckInterval) * time.Second
+	}
+
+	return time.Duration(rc.applied.CheckInterval) * time.Second
+}
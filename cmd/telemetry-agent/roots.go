@@ -0,0 +1,147 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/metrics"
+	"github.com/percona/telemetry-agent/pipeline"
+	"github.com/percona/telemetry-agent/utils"
+)
+
+// rootProcessor processes Pillars metrics files of one telemetry root path.
+type rootProcessor struct {
+	// c is the configuration of the telemetry root path, see config.Config.ForRoot.
+	c         config.Config
+	collector *pipeline.LocalCollector
+	processor *pipeline.Processor
+	stateDB   *metrics.StateDB
+}
+
+// newRootProcessors creates processors of telemetry root path and of each extra telemetry root path,
+// so all of them are processed by one Telemetry Agent service. Processors keep state between iterations,
+// so they are created once.
+func newRootProcessors(c config.Config, sender pipeline.Sender, status *agentStatus) ([]*rootProcessor, error) {
+	rootConfigs := c.Roots()
+	roots := make([]*rootProcessor, 0, len(rootConfigs))
+
+	for _, rc := range rootConfigs {
+		stateDB, err := openStateDB(rc)
+		if err != nil {
+			closeRootProcessors(roots)
+			return nil, err
+		}
+
+		collector := pipeline.NewLocalCollector(rc, status.errorCounters, stateDB)
+
+		processor, err := newCollectorProcessor(rc, collector, sender, status, stateDB)
+		if err != nil {
+			_ = stateDB.Close()
+			closeRootProcessors(roots)
+
+			return nil, err
+		}
+
+		roots = append(roots, &rootProcessor{c: rc, collector: collector, processor: processor, stateDB: stateDB})
+	}
+
+	return roots, nil
+}
+
+// closeRootProcessors releases resources held by root processors.
+func closeRootProcessors(roots []*rootProcessor) {
+	for _, root := range roots {
+		if err := root.stateDB.Close(); err != nil {
+			zap.L().Sugar().Warnw("failed to close state database", zap.String("file", root.c.Telemetry.StateDBPath), zap.Error(err))
+		}
+	}
+}
+
+// rootCollectors returns collectors of all root processors.
+func rootCollectors(roots []*rootProcessor) []*pipeline.LocalCollector {
+	collectors := make([]*pipeline.LocalCollector, 0, len(roots))
+	for _, root := range roots {
+		collectors = append(collectors, root.collector)
+	}
+
+	return collectors
+}
+
+// rootContext returns ctx with logger adding telemetry root path to all log entries if several root paths
+// are processed, so entries of different root paths can be told apart.
+func rootContext(ctx context.Context, roots []*rootProcessor, root *rootProcessor) context.Context {
+	if len(roots) == 1 {
+		return ctx
+	}
+
+	return logger.WithContext(ctx, logger.FromContext(ctx).With(zap.String("root", root.c.Telemetry.RootPath)))
+}
+
+// watchRoots watches Pillars metrics directories of all telemetry root paths, the returned channel receives
+// root processors new metrics files are detected for. Root paths failed to be watched are only checked periodically,
+// nil channel is returned if none of them is watched.
+func watchRoots(ctx context.Context, roots []*rootProcessor, delay time.Duration) <-chan *rootProcessor {
+	l := zap.L().Sugar()
+
+	var watchC chan *rootProcessor
+
+	for _, root := range roots {
+		pillarDirs := make([]string, 0, len(root.c.Telemetry.Pillars))
+		for _, pillar := range root.c.Telemetry.Pillars {
+			pillarDirs = append(pillarDirs, pillar.Path)
+		}
+
+		rootC, err := utils.WatchDirs(ctx, root.c.Telemetry.RootPath, pillarDirs, ".json", delay)
+		if err != nil {
+			l.Warnw("failed to watch Pillars metrics directories, only periodic check is used",
+				zap.String("root", root.c.Telemetry.RootPath), zap.Error(err))
+
+			continue
+		}
+
+		if watchC == nil {
+			watchC = make(chan *rootProcessor)
+		}
+
+		go func() {
+			for {
+				select {
+				case <-rootC:
+				case <-ctx.Done():
+					return
+				}
+
+				select {
+				case watchC <- root:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if watchC != nil {
+		l.Info("watching Pillars metrics directories for new metrics files")
+	}
+
+	return watchC
+}
@@ -0,0 +1,301 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// landlockWriteAccess are write access rights of the first Landlock ABI version.
+	landlockWriteAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR | unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK | unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	// landlockFileAccess are access rights applicable to files, other rights are applicable to directories only.
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	// landlockMinABI is the first Landlock ABI version allowing to move files between directories,
+	// metrics files are moved between Pillars, processing and history directories.
+	landlockMinABI = 2
+
+	// x32SyscallBit is set in numbers of x32 ABI system calls, they are not used by Telemetry Agent.
+	x32SyscallBit = 0x40000000
+	// seccompDataArchOffset and seccompDataNrOffset are offsets of fields of struct seccomp_data.
+	seccompDataNrOffset   = 0
+	seccompDataArchOffset = 4
+)
+
+// sandboxReadPaths are directories Telemetry Agent and package manager commands it runs read and execute files from.
+var sandboxReadPaths = []string{
+	"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/usr", "/etc", "/opt", "/var", "/run", "/proc", "/sys", "/dev", "/home", "/root",
+}
+
+// sandboxWritePaths are directories package manager commands write temporary and cache files into.
+var sandboxWritePaths = []string{"/tmp", "/var/tmp", "/var/cache", "/dev/null"}
+
+// sandboxDeniedSyscalls are system calls Telemetry Agent and commands it runs never need,
+// they are denied to limit the damage in case the agent is compromised.
+var sandboxDeniedSyscalls = []uint32{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_BPF,
+	unix.SYS_CHROOT,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_PTRACE,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+var seccompAuditArch = map[string]uint32{
+	"386":     unix.AUDIT_ARCH_I386,
+	"amd64":   unix.AUDIT_ARCH_X86_64,
+	"arm":     unix.AUDIT_ARCH_ARM,
+	"arm64":   unix.AUDIT_ARCH_AARCH64,
+	"loong64": unix.AUDIT_ARCH_LOONGARCH64,
+	"ppc64le": unix.AUDIT_ARCH_PPC64LE,
+	"riscv64": unix.AUDIT_ARCH_RISCV64,
+	"s390x":   unix.AUDIT_ARCH_S390X,
+}
+
+// enableSandbox restricts filesystem access of Telemetry Agent with Landlock and denies dangerous system calls
+// with seccomp for the rest of its life, restrictions are inherited by commands it runs.
+// Sandboxing not supported by the kernel is skipped with warning.
+func enableSandbox(c config.Config) error {
+	l := zap.L().Sugar()
+
+	// no_new_privs is required for unprivileged sandboxing, it is set for all threads of the process.
+	_, _, errno := syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0)
+	switch {
+	case errno == unix.ENOTSUP:
+		// system calls can't be applied to all threads of binary built with cgo.
+		l.Warn("sandboxing is not supported by Telemetry Agent built with cgo, it is skipped")
+		return nil
+	case errno != 0:
+		return fmt.Errorf("can't set no_new_privs: %w", errno)
+	}
+
+	abi, err := enableLandlock(c)
+	switch {
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EOPNOTSUPP):
+		l.Warnw("Landlock is not supported by the kernel, filesystem access is not restricted", zap.Error(err))
+	case err != nil:
+		return err
+	case abi < landlockMinABI:
+		l.Warnw("Landlock ABI version is too old, filesystem access is not restricted",
+			zap.Int("abi", abi), zap.Int("required abi", landlockMinABI))
+	default:
+		l.Infow("filesystem access is restricted with Landlock", zap.Int("abi", abi))
+	}
+
+	err = enableSeccomp()
+	switch {
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EINVAL):
+		l.Warnw("seccomp is not supported by the kernel, system calls are not restricted", zap.Error(err))
+	case err != nil:
+		return err
+	default:
+		l.Infow("dangerous system calls are denied with seccomp", zap.Int("denied", len(sandboxDeniedSyscalls)))
+	}
+
+	return nil
+}
+
+// enableLandlock restricts filesystem access if supported Landlock ABI version is not less than landlockMinABI,
+// the version is returned.
+func enableLandlock(c config.Config) (int, error) {
+	r, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	abi := int(r)
+	if abi < landlockMinABI {
+		return abi, nil
+	}
+
+	handled := uint64(landlockReadAccess | landlockWriteAccess | unix.LANDLOCK_ACCESS_FS_REFER)
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+
+	r, _, errno = unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return abi, fmt.Errorf("can't create Landlock ruleset: %w", errno)
+	}
+
+	rulesetFd := int(r)
+	defer unix.Close(rulesetFd) //nolint:errcheck
+
+	readPaths := sandboxReadPaths
+	if args := strings.Fields(c.Platform.CredentialCommand); len(args) != 0 && filepath.IsAbs(args[0]) {
+		// credential helper may be installed outside of system directories.
+		readPaths = append(slices.Clone(readPaths), filepath.Dir(args[0]))
+	}
+
+	if c.Debug.ReplayDir != "" {
+		// recorded output of external commands is read on every host metrics scraping.
+		readPaths = append(slices.Clone(readPaths), c.Debug.ReplayDir)
+	}
+
+	for _, p := range readPaths {
+		if err := addLandlockRule(rulesetFd, p, landlockReadAccess); err != nil {
+			return abi, err
+		}
+	}
+
+	for _, p := range landlockWritePaths(c) {
+		if err := addLandlockRule(rulesetFd, p, handled); err != nil {
+			return abi, err
+		}
+	}
+
+	if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0); errno != 0 {
+		return abi, fmt.Errorf("can't enforce Landlock ruleset: %w", errno)
+	}
+
+	return abi, nil
+}
+
+// landlockWritePaths returns directories Telemetry Agent is allowed to write into with Landlock.
+func landlockWritePaths(c config.Config) []string {
+	// Percona telemetry file directory is writable, so instance ID can be regenerated if the file is corrupted.
+	writePaths := append([]string{c.Telemetry.RootPath, filepath.Dir(metrics.TelemetryFile())}, sandboxWritePaths...)
+	writePaths = append(writePaths, c.Telemetry.ExtraRootPaths...)
+	if c.Log.File != "" {
+		// rotated log files are created in the same directory.
+		writePaths = append(writePaths, filepath.Dir(c.Log.File))
+	}
+
+	if c.Debug.RecordDir != "" {
+		// output of external commands is recorded on every host metrics scraping.
+		writePaths = append(writePaths, c.Debug.RecordDir)
+	}
+
+	return writePaths
+}
+
+// addLandlockRule allows access beneath the path, absent paths are skipped.
+func addLandlockRule(rulesetFd int, path string, access uint64) error {
+	fd, err := unix.Open(filepath.Clean(path), unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			return nil
+		}
+
+		return fmt.Errorf("can't open %s for Landlock rule: %w", path, err)
+	}
+	defer unix.Close(fd) //nolint:errcheck
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("can't stat %s for Landlock rule: %w", path, err)
+	}
+
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)} //nolint:gosec
+
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("can't add Landlock rule for %s: %w", path, errno)
+	}
+
+	return nil
+}
+
+// enableSeccomp installs seccomp filter denying sandboxDeniedSyscalls with EPERM for all threads.
+func enableSeccomp() error {
+	arch, ok := seccompAuditArch[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("%w: architecture %s", unix.ENOSYS, runtime.GOARCH)
+	}
+
+	deny := unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+
+	checks := []unix.SockFilter{{Code: unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K, K: x32SyscallBit}}
+	for _, nr := range sandboxDeniedSyscalls {
+		checks = append(checks, unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: nr})
+	}
+
+	// each matching check jumps over the remaining checks and allowing return to denying return.
+	for i := range checks {
+		checks[i].Jt = uint8(len(checks) - i) //nolint:gosec
+	}
+
+	filter := []unix.SockFilter{
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataArchOffset},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, K: arch},
+		{Code: unix.BPF_RET | unix.BPF_K, K: deny},
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: seccompDataNrOffset},
+	}
+	filter = append(filter, checks...)
+	filter = append(filter,
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: unix.SECCOMP_RET_ALLOW},
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: deny},
+	)
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]} //nolint:gosec
+
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER, unix.SECCOMP_FILTER_FLAG_TSYNC,
+		uintptr(unsafe.Pointer(&prog)))
+	runtime.KeepAlive(filter)
+
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+func TestLandlockWritePaths(t *testing.T) {
+	t.Parallel()
+
+	var c config.Config
+	c.Telemetry.RootPath = filepath.Join("/usr", "local", "percona", "telemetry")
+
+	// ingest socket is created before sandbox is enabled, so its directory is not writable.
+	c.Ingest.Socket = filepath.Join("/run", "percona-telemetry", "ingest.sock")
+	require.NotContains(t, landlockWritePaths(c), filepath.Join("/run", "percona-telemetry"))
+
+	// output of external commands is recorded after sandbox is enabled.
+	c.Debug.RecordDir = filepath.Join("/srv", "telemetry-record")
+	require.Contains(t, landlockWritePaths(c), filepath.Join("/srv", "telemetry-record"))
+}
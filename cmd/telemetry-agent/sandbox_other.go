@@ -0,0 +1,31 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package main
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+// enableSandbox is no-op, since Landlock and seccomp are available on Linux only.
+func enableSandbox(_ config.Config) error {
+	zap.L().Sugar().Warn("sandboxing is supported on Linux only, it is skipped")
+
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+)
+
+// agentSchedule describes when metrics processing iterations are run and telemetry is sent.
+type agentSchedule struct {
+	// ServiceRunning is false if the schedule is computed from configuration as the service is not running.
+	ServiceRunning       bool `json:"service_running"`
+	CheckIntervalSeconds int  `json:"check_interval_seconds"`
+	RunOnStart           bool `json:"run_on_start"`
+	// SendSpreadSeconds is the time interval requests of one iteration are spaced out over.
+	SendSpreadSeconds int  `json:"send_spread_seconds"`
+	Watch             bool `json:"watch"`
+	WatchDelaySeconds int  `json:"watch_delay_seconds,omitempty"`
+	// SelfReportIntervalSeconds is 0 if self-telemetry report is disabled.
+	SelfReportIntervalSeconds int `json:"self_report_interval_seconds"`
+	// DisabledReason is set if iterations are skipped by kill-switch.
+	DisabledReason    string     `json:"disabled_reason,omitempty"`
+	NextIterationTime *time.Time `json:"next_iteration_time,omitempty"`
+}
+
+// newAgentSchedule returns the schedule of Telemetry Agent service configured with c, next iteration time is not set.
+func newAgentSchedule(c config.Config) agentSchedule {
+	schedule := agentSchedule{
+		CheckIntervalSeconds: c.Telemetry.CheckInterval,
+		RunOnStart:           c.Telemetry.RunOnStart,
+		SendSpreadSeconds:    c.Telemetry.SendSpread,
+		Watch:                c.Telemetry.Watch,
+	}
+
+	if c.Telemetry.Watch {
+		schedule.WatchDelaySeconds = c.Telemetry.WatchDelay
+	}
+
+	if c.Telemetry.SelfReport {
+		schedule.SelfReportIntervalSeconds = int(selfReportInterval.Seconds())
+	}
+
+	if disabled, reason := newKillSwitch(c).check(); disabled {
+		schedule.DisabledReason = reason
+	}
+
+	return schedule
+}
+
+// printSchedule prints the schedule of running Telemetry Agent service, the schedule is computed from
+// configuration if the service is not running, so the next iteration time is unknown.
+func printSchedule(c config.Config, w io.Writer) error {
+	var status struct {
+		Schedule *agentSchedule `json:"schedule"`
+	}
+
+	var buf bytes.Buffer
+
+	err := printAgentStatus(c, &buf)
+	if err == nil {
+		err = json.Unmarshal(buf.Bytes(), &status)
+	}
+
+	if err != nil || status.Schedule == nil {
+		zap.L().Sugar().Warnw("failed to query schedule of running Telemetry Agent service, printing configured schedule",
+			zap.Error(err))
+
+		status.Schedule = new(newAgentSchedule(c))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(status.Schedule); err != nil {
+		return fmt.Errorf("can't write schedule: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	platformLogger "github.com/percona/platform/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+	"github.com/percona/telemetry-agent/pipeline"
+)
+
+// selfReportInterval is the interval of sending Telemetry Agent self-telemetry, it is counted from the last sent one
+// recorded in last run summary file, not from the agent start.
+const selfReportInterval = 24 * time.Hour
+
+// selfReportMetrics returns metrics about Telemetry Agent itself.
+func selfReportMetrics(status *agentStatus) []*platformReporter.GenericReport_Metric {
+	snapshot := status.snapshot()
+
+	values := []struct {
+		key   string
+		value string
+	}{
+		{"agent_version", config.Version},
+		{"agent_commit", config.Commit},
+		{"agent_uptime_seconds", strconv.FormatInt(int64(time.Since(snapshot.StartTime).Seconds()), 10)},
+		{"iterations", strconv.FormatInt(snapshot.Counters.Iterations, 10)},
+		{"failed_iterations", strconv.FormatInt(snapshot.Counters.FailedIterations, 10)},
+		{"requests", strconv.FormatInt(snapshot.Counters.Requests, 10)},
+		{"failed_requests", strconv.FormatInt(snapshot.Counters.FailedRequests, 10)},
+		{"unsupported_os_occurrences", strconv.FormatInt(metrics.UnsupportedOSOccurrences(), 10)},
+	}
+
+	reportMetrics := make([]*platformReporter.GenericReport_Metric, 0, len(values)+len(snapshot.Errors))
+	for _, v := range values {
+		reportMetrics = append(reportMetrics, &platformReporter.GenericReport_Metric{Key: v.key, Value: v.value})
+	}
+
+	for _, category := range metrics.ErrorCategories {
+		reportMetrics = append(reportMetrics, &platformReporter.GenericReport_Metric{
+			Key:   "errors_" + string(category),
+			Value: strconv.FormatInt(snapshot.Errors[category], 10),
+		})
+	}
+
+	return append(reportMetrics, status.clockSkew.Metrics()...)
+}
+
+// sendSelfReport sends report about Telemetry Agent itself to Percona Platform.
+// Errors are logged only, self-telemetry is not retried and doesn't affect send counters.
+func sendSelfReport(ctx context.Context, c config.Config, sender pipeline.Sender, status *agentStatus) {
+	l := zap.L().Sugar()
+
+	var (
+		instanceID string
+		err        error
+	)
+
+	if c.Kubernetes.Enabled {
+		// Percona telemetry file is not used in Kubernetes sidecar mode.
+		instanceID = metrics.ReadPodInfo(c.Kubernetes.PodInfoPath).UID
+	} else {
+		instanceID, err = metrics.ReadInstanceID(metrics.TelemetryFile())
+	}
+	if err != nil || instanceID == "" {
+		l.Warnw("host instance ID is not available, self-telemetry is not sent", zap.Error(err))
+		return
+	}
+
+	report := &platformReporter.ReportRequest{
+		Reports: []*platformReporter.GenericReport{
+			{
+				Id:            uuid.NewString(),
+				CreateTime:    timestamppb.Now(),
+				InstanceId:    instanceID,
+				ProductFamily: c.Telemetry.SelfReportProductFamily,
+				Metrics:       append(selfReportMetrics(status), metrics.TagMetrics(c.Telemetry.Tags)...),
+			},
+		},
+	}
+
+	l.Info("sending Telemetry Agent self-telemetry")
+
+	platformCtx := platformLogger.GetContextWithLogger(ctx, l.Desugar())
+	if err := sender.SendTelemetry(platformCtx, "", report); err != nil {
+		l.Warnw("failed to send Telemetry Agent self-telemetry", zap.Error(err))
+		return
+	}
+
+	// the time is persisted, so the next self-report is sent on schedule even if the agent is restarted.
+	recordSelfReportTime(c, time.Now())
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// stdinFileName is the metrics file name meaning metrics document is read from stdin.
+const stdinFileName = "-"
+
+// sendMetricsFile sends single metrics file to Percona Platform with host metrics attached and saves it
+// into telemetry history. The file is left in place, so the operator removes it once it is sent.
+// Metrics document is read from stdin if the file name is "-".
+func sendMetricsFile(ctx context.Context, c config.Config, stdin io.Reader, w io.Writer) error {
+	// manual sending doesn't override operator's opt-out.
+	if disabled, reason := newKillSwitch(c).check(); disabled {
+		return errors.New("telemetry is disabled: " + reason)
+	}
+
+	fileName := c.SendFile.File
+	source := fileName
+
+	if fileName == stdinFileName {
+		var err error
+
+		// metrics document is stored into temporary metrics file, so it is parsed, sent and saved into history
+		// under regular metrics file name the same way as metrics files written by Pillars.
+		fileName, err = saveStdinMetrics(stdin)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = os.Remove(fileName)
+		}()
+
+		source = "metrics document from stdin"
+	}
+
+	fileMetrics, err := metrics.ParseMetricsFile(ctx, fileName, c.SendFile.ProductFamily, metrics.ProcessOpts{
+		FlattenNested: c.Telemetry.FlattenNested,
+		SchemaDir:     c.Telemetry.SchemasPath,
+	})
+	if err != nil {
+		return fmt.Errorf("can't parse metrics file: %w", err)
+	}
+
+	if c.SendFile.File == stdinFileName && len(fileMetrics) != 1 {
+		return fmt.Errorf("stdin must contain one metrics document, got %d", len(fileMetrics))
+	}
+
+	// request is only printed in dry-run mode, so history directory is not needed.
+	if !c.DryRun {
+		if err := createTelemetryDirs(c.Telemetry.HistoryPath); err != nil {
+			return err
+		}
+	}
+
+	sender, err := createPlatformSender(c)
+	if err != nil {
+		return err
+	}
+
+	processor, err := newProcessor(c, sender, newAgentStatus(nil))
+	if err != nil {
+		return fmt.Errorf("can't create metrics processor: %w", err)
+	}
+
+	if err := processor.SendMetricsFile(ctx, fileMetrics); err != nil {
+		return err
+	}
+
+	if c.DryRun {
+		return nil
+	}
+
+	if c.SendFile.File == stdinFileName {
+		_, err = fmt.Fprintf(w, "%s is sent as %s telemetry and saved into history\n", source, c.SendFile.ProductFamily)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s is sent as %s telemetry and saved into history, it may be removed now\n",
+		source, c.SendFile.ProductFamily)
+
+	return err
+}
+
+// saveStdinMetrics saves metrics document read from stdin into temporary '<unixtime>-<random token>.json' file
+// and returns its name.
+func saveStdinMetrics(stdin io.Reader) (string, error) {
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("can't read metrics document from stdin: %w", err)
+	}
+
+	fileName := filepath.Join(os.TempDir(), fmt.Sprintf("%d-%s.json", time.Now().Unix(), uuid.NewString()))
+	if err := os.WriteFile(fileName, content, 0o600); err != nil {
+		return "", fmt.Errorf("can't save metrics document from stdin: %w", err)
+	}
+
+	return fileName, nil
+}
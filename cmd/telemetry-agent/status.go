@@ -0,0 +1,211 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// agentStatus tracks Telemetry Agent state reported by health check endpoints and status command.
+// It is safe for concurrent use.
+type agentStatus struct {
+	mu                      sync.Mutex
+	startTime               time.Time
+	ready                   bool
+	lastIterationTime       time.Time
+	lastSuccessfulIteration time.Time
+	nextIterationTime       time.Time
+	lastSendTime            time.Time
+	lastSendError           string
+	lastPlatformError       string
+	lastPlatformErrorTime   time.Time
+	counters                agentCounters
+	// errorCounters are shared with metrics processor, which counts failures by category.
+	errorCounters *metrics.ErrorCounters
+	// clockSkew is shared with metrics processors, which measure it with Percona Platform responses.
+	clockSkew *metrics.ClockSkew
+	// busy is set while the main loop processes metrics, progressTime is the last time it made progress.
+	busy         bool
+	progressTime time.Time
+}
+
+// agentCounters holds the numbers of iterations and requests to Percona Platform since Telemetry Agent start.
+type agentCounters struct {
+	Iterations       int64 `json:"iterations"`
+	FailedIterations int64 `json:"failed_iterations"`
+	Requests         int64 `json:"requests"`
+	FailedRequests   int64 `json:"failed_requests"`
+}
+
+// agentStatusSnapshot is a point-in-time copy of agentStatus.
+type agentStatusSnapshot struct {
+	StartTime               time.Time  `json:"start_time"`
+	Uptime                  string     `json:"uptime"`
+	Ready                   bool       `json:"ready"`
+	LastIterationTime       *time.Time `json:"last_iteration_time,omitempty"`
+	LastSuccessfulIteration *time.Time `json:"last_successful_iteration_time,omitempty"`
+	NextIterationTime       *time.Time `json:"next_iteration_time,omitempty"`
+	LastSendTime            *time.Time `json:"last_send_time,omitempty"`
+	// LastSendResult is "success" or error of the last request to Percona Platform.
+	LastSendResult        string        `json:"last_send_result,omitempty"`
+	LastPlatformError     string        `json:"last_platform_error,omitempty"`
+	LastPlatformErrorTime *time.Time    `json:"last_platform_error_time,omitempty"`
+	Counters              agentCounters `json:"counters"`
+	// Errors maps error category to the number of failures, they are counted across agent restarts.
+	Errors map[metrics.ErrorCategory]int64 `json:"errors"`
+	// ClockSkewSeconds is the skew of host clock relative to Percona Platform clock, nil until it is measured.
+	ClockSkewSeconds *int64 `json:"clock_skew_seconds,omitempty"`
+	// Backlog is counted on request by status command, it is not reported by health check endpoints.
+	*metrics.Backlog
+	// ExtraRootBacklogs maps extra telemetry root path to its backlog, it is reported by status command only as well.
+	ExtraRootBacklogs map[string]*metrics.Backlog `json:"extra_root_backlogs,omitempty"`
+	// Schedule is reported by status command only as well.
+	Schedule *agentSchedule `json:"schedule,omitempty"`
+}
+
+// newAgentStatus creates agentStatus reporting errorCounters, they may be nil.
+func newAgentStatus(errorCounters *metrics.ErrorCounters) *agentStatus {
+	return &agentStatus{
+		startTime:     time.Now(),
+		errorCounters: errorCounters,
+	}
+}
+
+// setReady marks the agent as ready (or not) to process Pillars metrics.
+func (s *agentStatus) setReady(ready bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ready = ready
+}
+
+// iterationFinished registers finished metrics processing iteration, err is its result.
+func (s *agentStatus) iterationFinished(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastIterationTime = time.Now()
+	s.counters.Iterations++
+
+	if err == nil {
+		s.lastSuccessfulIteration = s.lastIterationTime
+	} else {
+		s.counters.FailedIterations++
+	}
+}
+
+// setNextIteration registers the time of next scheduled metrics processing iteration.
+func (s *agentStatus) setNextIteration(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextIterationTime = t
+}
+
+// workStarted registers that the main loop started processing metrics.
+func (s *agentStatus) workStarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.busy = true
+	s.progressTime = time.Now()
+}
+
+// workProgressed registers progress of metrics processing, e.g. processed group of metrics files.
+func (s *agentStatus) workProgressed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.progressTime = time.Now()
+}
+
+// workFinished registers that the main loop finished processing metrics and is idle.
+func (s *agentStatus) workFinished() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.busy = false
+}
+
+// isStalled returns true if the main loop processes metrics without any progress for longer than timeout.
+func (s *agentStatus) isStalled(timeout time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.busy && time.Since(s.progressTime) > timeout
+}
+
+// sendFinished registers finished request to Percona Platform, err is its result.
+func (s *agentStatus) sendFinished(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSendTime = time.Now()
+	s.lastSendError = ""
+	s.counters.Requests++
+
+	if err != nil {
+		s.counters.FailedRequests++
+		s.lastSendError = err.Error()
+		s.lastPlatformError = s.lastSendError
+		s.lastPlatformErrorTime = s.lastSendTime
+	}
+}
+
+func (s *agentStatus) snapshot() agentStatusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := agentStatusSnapshot{
+		StartTime:               s.startTime,
+		Uptime:                  time.Since(s.startTime).Round(time.Second).String(),
+		Ready:                   s.ready,
+		LastIterationTime:       timeOrNil(s.lastIterationTime),
+		LastSuccessfulIteration: timeOrNil(s.lastSuccessfulIteration),
+		NextIterationTime:       timeOrNil(s.nextIterationTime),
+		LastSendTime:            timeOrNil(s.lastSendTime),
+		LastPlatformError:       s.lastPlatformError,
+		LastPlatformErrorTime:   timeOrNil(s.lastPlatformErrorTime),
+		Counters:                s.counters,
+		Errors:                  s.errorCounters.Counts(),
+	}
+
+	if !s.lastSendTime.IsZero() {
+		snapshot.LastSendResult = "success"
+		if s.lastSendError != "" {
+			snapshot.LastSendResult = s.lastSendError
+		}
+	}
+
+	if skew, ok := s.clockSkew.Skew(); ok {
+		seconds := int64(skew / time.Second)
+		snapshot.ClockSkewSeconds = &seconds
+	}
+
+	return snapshot
+}
+
+// timeOrNil returns nil for zero time, so it is omitted in JSON.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
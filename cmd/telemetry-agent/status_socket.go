@@ -0,0 +1,146 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+const (
+	// statusSocketPermissions allows members of telemetry group to query agent status.
+	statusSocketPermissions = 0o660
+	statusSocketTimeout     = 10 * time.Second
+)
+
+// startStatusServer serves agent status in JSON on unix socket until ctx is done.
+// Each connection receives the status and is closed, no request is expected. Check interval of the schedule
+// is taken from remoteConf, since remote configuration may change it.
+func startStatusServer(ctx context.Context, c config.Config, status *agentStatus, remoteConf *remoteConfig) error {
+	l := zap.L().Sugar()
+
+	socketPath := filepath.Clean(c.Telemetry.StatusSocketPath)
+
+	// socket file is left if the agent was killed, it is not possible to listen on it.
+	if info, err := os.Lstat(socketPath); err == nil && info.Mode().Type() == os.ModeSocket {
+		if err := os.Remove(socketPath); err != nil {
+			return fmt.Errorf("can't remove stale status socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("can't listen status socket: %w", err)
+	}
+
+	if err := os.Chmod(socketPath, statusSocketPermissions); err != nil {
+		l.Warnw("failed to change status socket permissions", zap.String("socket", socketPath), zap.Error(err))
+	}
+
+	go func() {
+		<-ctx.Done()
+		// unix socket file is removed on close.
+		_ = listener.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					l.Errorw("status socket server failed", zap.Error(err))
+				}
+
+				return
+			}
+
+			writeAgentStatus(conn, c, status, remoteConf)
+		}
+	}()
+
+	l.Infow("serving status on unix socket", zap.String("socket", socketPath))
+
+	return nil
+}
+
+func writeAgentStatus(conn net.Conn, c config.Config, status *agentStatus, remoteConf *remoteConfig) {
+	l := zap.L().Sugar()
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	snapshot := status.snapshot()
+	for i, rb := range scanRootsBacklog(c) {
+		if i == 0 {
+			snapshot.Backlog = &rb.backlog
+			continue
+		}
+
+		if snapshot.ExtraRootBacklogs == nil {
+			snapshot.ExtraRootBacklogs = make(map[string]*metrics.Backlog)
+		}
+
+		snapshot.ExtraRootBacklogs[rb.c.Telemetry.RootPath] = &rb.backlog
+	}
+
+	schedule := newAgentSchedule(c)
+	schedule.ServiceRunning = true
+	schedule.CheckIntervalSeconds = int(remoteConf.checkInterval().Seconds())
+	schedule.NextIterationTime = snapshot.NextIterationTime
+	snapshot.Schedule = &schedule
+
+	_ = conn.SetWriteDeadline(time.Now().Add(statusSocketTimeout))
+
+	encoder := json.NewEncoder(conn)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(snapshot); err != nil {
+		l.Debugw("failed to write status into status socket", zap.Error(err))
+	}
+}
+
+// printAgentStatus queries running Telemetry Agent over its status socket and prints the status to w.
+func printAgentStatus(c config.Config, w io.Writer) error {
+	conn, err := net.DialTimeout("unix", filepath.Clean(c.Telemetry.StatusSocketPath), statusSocketTimeout)
+	if err != nil {
+		return fmt.Errorf("can't connect to Telemetry Agent status socket, is the agent running? %w", err)
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(statusSocketTimeout))
+
+	if _, err := io.Copy(w, conn); err != nil {
+		return fmt.Errorf("can't read Telemetry Agent status: %w", err)
+	}
+
+	return nil
+}
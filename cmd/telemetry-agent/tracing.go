@@ -0,0 +1,85 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+const (
+	tracingServiceName = "percona-telemetry-agent"
+	// tracingShutdownTimeout limits time of exporting spans left on shutdown, collector may be unavailable.
+	tracingShutdownTimeout = 5 * time.Second
+)
+
+// startTracing sets up exporting OpenTelemetry traces to OTLP/HTTP collector, returned function flushes
+// spans left and stops exporting. Spans are exported in background, unavailable collector doesn't block processing.
+func startTracing(c config.Config) (func(), error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(c.Tracing.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("can't create OTLP traces exporter: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("service.name", tracingServiceName),
+		attribute.String("service.version", config.Version),
+	}
+
+	// traces of different hosts are told apart by host instance ID.
+	if instanceID := tracingInstanceID(c); instanceID != "" {
+		attrs = append(attrs, attribute.String("service.instance.id", instanceID))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+	otel.SetTracerProvider(provider)
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		zap.L().Sugar().Warnw("failed to export traces", zap.Error(err))
+	}))
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+
+		if err := provider.Shutdown(ctx); err != nil {
+			zap.L().Sugar().Warnw("failed to export traces on shutdown", zap.Error(err))
+		}
+	}, nil
+}
+
+// tracingInstanceID returns pod UID in Kubernetes sidecar mode, host instance ID otherwise.
+func tracingInstanceID(c config.Config) string {
+	if c.Kubernetes.Enabled {
+		return metrics.ReadPodInfo(c.Kubernetes.PodInfoPath).UID
+	}
+
+	return metrics.InitInstanceID()
+}
@@ -0,0 +1,450 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// completionProgramNames are the names Telemetry Agent binary is installed under, completion is registered for all of them.
+var completionProgramNames = []string{"telemetry-agent", "percona-telemetry-agent"}
+
+// completionCommand is a command of Telemetry Agent command line model completion is generated for.
+type completionCommand struct {
+	// path is space-separated names of the command and its parents, empty for top-level.
+	path        string
+	subcommands []*kong.Node
+	// flags are the flags of the command and its parents, ownFlags are the flags of the command only.
+	flags    []*kong.Flag
+	ownFlags []*kong.Flag
+	// args are the values of enum positional argument, files is true if the command takes
+	// other positional arguments, they are completed with file names.
+	args  []string
+	files bool
+}
+
+// WriteCompletion writes shell completion script generated from Telemetry Agent command line model.
+func WriteCompletion(w io.Writer, shell string) error {
+	parser, err := kong.New(&Config{}, kongOptions()...)
+	if err != nil {
+		return fmt.Errorf("can't create command line parser: %w", err)
+	}
+
+	commands := collectCompletionCommands(parser.Model.Node, nil)
+
+	var script string
+
+	switch shell {
+	case "bash":
+		script = bashCompletion(commands)
+	case "zsh":
+		script = zshCompletion(commands)
+	case "fish":
+		script = fishCompletion(commands)
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+
+	_, err = io.WriteString(w, script)
+
+	return err
+}
+
+// collectCompletionCommands returns node and all its visible subcommands, parent commands go first.
+func collectCompletionCommands(node *kong.Node, path []string) []completionCommand {
+	cmd := completionCommand{
+		path:     strings.Join(path, " "),
+		ownFlags: visibleFlags(node.Flags),
+	}
+
+	for _, arg := range node.Positional {
+		if arg.Enum != "" {
+			cmd.args = append(cmd.args, arg.EnumSlice()...)
+		} else {
+			cmd.files = true
+		}
+	}
+
+	for _, group := range node.AllFlags(true) {
+		cmd.flags = append(cmd.flags, group...)
+	}
+
+	for _, child := range node.Children {
+		if child.Type == kong.CommandNode && !child.Hidden {
+			cmd.subcommands = append(cmd.subcommands, child)
+		}
+	}
+
+	commands := []completionCommand{cmd}
+	for _, child := range cmd.subcommands {
+		commands = append(commands, collectCompletionCommands(child, append(path[:len(path):len(path)], child.Name))...)
+	}
+
+	return commands
+}
+
+func visibleFlags(flags []*kong.Flag) []*kong.Flag {
+	visible := make([]*kong.Flag, 0, len(flags))
+	for _, f := range flags {
+		if !f.Hidden {
+			visible = append(visible, f)
+		}
+	}
+
+	return visible
+}
+
+// completionCommandPatterns returns "<parent path>:<name>" case patterns matching command line words
+// that enter subcommands, the same patterns are used by all shells to find the command being completed.
+func completionCommandPatterns(commands []completionCommand) []string {
+	var patterns []string
+
+	for _, cmd := range commands {
+		for _, sub := range cmd.subcommands {
+			patterns = append(patterns, shellQuote(cmd.path+":"+sub.Name))
+		}
+	}
+
+	return patterns
+}
+
+// shellQuote quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// completionValueFlags returns flags taking values of all commands, flags of different commands with the same name
+// are expected to take the same values.
+func completionValueFlags(commands []completionCommand) []*kong.Flag {
+	var flags []*kong.Flag
+
+	seen := make(map[string]bool)
+
+	for _, cmd := range commands {
+		for _, f := range cmd.ownFlags {
+			if f.IsBool() || f.IsCounter() || seen[f.Name] {
+				continue
+			}
+
+			seen[f.Name] = true
+			flags = append(flags, f)
+		}
+	}
+
+	return flags
+}
+
+// isPathFlag returns true if flag takes file or directory path, its values are completed with file names.
+func isPathFlag(f *kong.Flag) bool {
+	for _, suffix := range []string{"-path", "file", "-dir", "output", "audit-log"} {
+		if strings.HasSuffix(f.Name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commandFlags returns flags of cmd and its parents except top-level flags which are accepted by all commands
+// and completed separately.
+func commandFlags(cmd completionCommand, commands []completionCommand) []*kong.Flag {
+	if cmd.path == "" {
+		return nil
+	}
+
+	topLevel := make(map[string]bool, len(commands[0].ownFlags))
+	for _, f := range commands[0].ownFlags {
+		topLevel[f.Name] = true
+	}
+
+	var flags []*kong.Flag
+
+	for _, f := range cmd.flags {
+		if !topLevel[f.Name] && !f.Hidden {
+			flags = append(flags, f)
+		}
+	}
+
+	return flags
+}
+
+// flagNames returns long and short names of flag as they are typed on command line.
+func flagNames(f *kong.Flag) []string {
+	names := []string{"--" + f.Name}
+	if f.Short != 0 {
+		names = append(names, "-"+string(f.Short))
+	}
+
+	return names
+}
+
+func bashCompletion(commands []completionCommand) string {
+	var b strings.Builder
+
+	b.WriteString("# bash completion for telemetry-agent, generated by 'telemetry-agent completion bash'.\n")
+	b.WriteString("_telemetry_agent() {\n")
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\" cmd=\"\" word i\n")
+	b.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("\t\tword=\"${COMP_WORDS[i]}\"\n")
+	b.WriteString("\t\tcase \"${cmd}:${word}\" in\n")
+	fmt.Fprintf(&b, "\t\t%s) cmd=\"${cmd:+${cmd} }${word}\" ;;\n", strings.Join(completionCommandPatterns(commands), " | "))
+	b.WriteString("\t\tesac\n")
+	b.WriteString("\tdone\n\n")
+
+	// values of flags are completed regardless of command.
+	var pathFlags, otherFlags []string
+
+	b.WriteString("\tcase \"${prev}\" in\n")
+
+	for _, f := range completionValueFlags(commands) {
+		switch {
+		case f.Enum != "":
+			fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %s -- \"${cur}\")); return ;;\n",
+				strings.Join(flagNames(f), " | "), shellQuote(strings.Join(f.EnumSlice(), " ")))
+		case isPathFlag(f):
+			pathFlags = append(pathFlags, flagNames(f)...)
+		default:
+			otherFlags = append(otherFlags, flagNames(f)...)
+		}
+	}
+
+	fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -f -- \"${cur}\")); return ;;\n", strings.Join(pathFlags, " | "))
+	fmt.Fprintf(&b, "\t%s) COMPREPLY=(); return ;;\n", strings.Join(otherFlags, " | "))
+	b.WriteString("\tesac\n\n")
+
+	// top-level flags are accepted by all commands.
+	topLevel := make([]string, 0, len(commands[0].ownFlags))
+	for _, f := range commands[0].ownFlags {
+		topLevel = append(topLevel, "--"+f.Name)
+	}
+
+	fmt.Fprintf(&b, "\tlocal flags=%s\n", shellQuote(strings.Join(topLevel, " ")))
+	b.WriteString("\tcase \"${cmd}\" in\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(cmd.path))
+
+		var words []string
+		for _, sub := range cmd.subcommands {
+			words = append(words, sub.Name)
+		}
+
+		words = append(words, cmd.args...)
+
+		for _, f := range commandFlags(cmd, commands) {
+			words = append(words, "--"+f.Name)
+		}
+
+		words = append(words, "${flags}")
+		fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"${cur}\"))\n", strings.Join(words, " "))
+
+		if cmd.files {
+			b.WriteString("\t\t[[ \"${cur}\" == -* ]] || COMPREPLY+=($(compgen -f -- \"${cur}\"))\n")
+		}
+
+		b.WriteString("\t\t;;\n")
+	}
+
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -F _telemetry_agent %s\n", strings.Join(completionProgramNames, " "))
+
+	return b.String()
+}
+
+// zshDescribeItem returns "name:description" item of zsh _describe function.
+func zshDescribeItem(name, help string) string {
+	return shellQuote(strings.ReplaceAll(name, ":", `\:`) + ":" + help)
+}
+
+func zshCompletion(commands []completionCommand) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "#compdef %s\n", strings.Join(completionProgramNames, " "))
+	b.WriteString("# zsh completion for telemetry-agent, generated by 'telemetry-agent completion zsh'.\n\n")
+	b.WriteString("_telemetry_agent() {\n")
+	b.WriteString("\tlocal cmd=\"\" word i\n")
+	b.WriteString("\tlocal -a subcommands flags\n")
+	b.WriteString("\tfor ((i = 2; i < CURRENT; i++)); do\n")
+	b.WriteString("\t\tword=\"${words[i]}\"\n")
+	b.WriteString("\t\tcase \"${cmd}:${word}\" in\n")
+	fmt.Fprintf(&b, "\t\t%s) cmd=\"${cmd:+${cmd} }${word}\" ;;\n", strings.Join(completionCommandPatterns(commands), " | "))
+	b.WriteString("\t\tesac\n")
+	b.WriteString("\tdone\n\n")
+
+	// values of flags are completed regardless of command.
+	var pathFlags, otherFlags []string
+
+	b.WriteString("\tcase \"${words[CURRENT-1]}\" in\n")
+
+	for _, f := range completionValueFlags(commands) {
+		switch {
+		case f.Enum != "":
+			fmt.Fprintf(&b, "\t%s) compadd -- %s; return ;;\n", strings.Join(flagNames(f), " | "), strings.Join(f.EnumSlice(), " "))
+		case isPathFlag(f):
+			pathFlags = append(pathFlags, flagNames(f)...)
+		default:
+			otherFlags = append(otherFlags, flagNames(f)...)
+		}
+	}
+
+	fmt.Fprintf(&b, "\t%s) _files; return ;;\n", strings.Join(pathFlags, " | "))
+	fmt.Fprintf(&b, "\t%s) return ;;\n", strings.Join(otherFlags, " | "))
+	b.WriteString("\tesac\n\n")
+
+	// top-level flags are accepted by all commands.
+	b.WriteString("\tflags=(")
+
+	for _, f := range commands[0].ownFlags {
+		b.WriteString("\n\t\t" + zshDescribeItem("--"+f.Name, f.Help))
+	}
+
+	b.WriteString("\n\t)\n")
+	b.WriteString("\tcase \"${cmd}\" in\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(cmd.path))
+
+		if len(cmd.subcommands) != 0 {
+			b.WriteString("\t\tsubcommands=(")
+
+			for _, sub := range cmd.subcommands {
+				b.WriteString("\n\t\t\t" + zshDescribeItem(sub.Name, sub.Help))
+			}
+
+			b.WriteString("\n\t\t)\n")
+		}
+
+		if len(cmd.args) != 0 {
+			fmt.Fprintf(&b, "\t\t[[ \"${PREFIX}\" == -* ]] || compadd -- %s\n", strings.Join(cmd.args, " "))
+		}
+
+		if flags := commandFlags(cmd, commands); len(flags) != 0 {
+			b.WriteString("\t\tflags+=(")
+
+			for _, f := range flags {
+				b.WriteString("\n\t\t\t" + zshDescribeItem("--"+f.Name, f.Help))
+			}
+
+			b.WriteString("\n\t\t)\n")
+		}
+
+		if cmd.files {
+			b.WriteString("\t\t[[ \"${PREFIX}\" == -* ]] || _files\n")
+		}
+
+		b.WriteString("\t\t;;\n")
+	}
+
+	b.WriteString("\tesac\n\n")
+	b.WriteString("\tif [[ \"${PREFIX}\" == -* ]]; then\n")
+	b.WriteString("\t\t_describe -t options 'option' flags\n")
+	b.WriteString("\telif (( ${#subcommands} )); then\n")
+	b.WriteString("\t\t_describe -t commands 'command' subcommands\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("}\n\n")
+	b.WriteString("if [[ \"${funcstack[1]}\" == \"_telemetry_agent\" ]]; then\n")
+	b.WriteString("\t_telemetry_agent \"$@\"\n")
+	b.WriteString("else\n")
+	fmt.Fprintf(&b, "\tcompdef _telemetry_agent %s\n", strings.Join(completionProgramNames, " "))
+	b.WriteString("fi\n")
+
+	return b.String()
+}
+
+func fishCompletion(commands []completionCommand) string {
+	var b strings.Builder
+
+	b.WriteString("# fish completion for telemetry-agent, generated by 'telemetry-agent completion fish'.\n\n")
+	b.WriteString("function __telemetry_agent_command\n")
+	b.WriteString("\tset -l cmd ''\n")
+	b.WriteString("\tfor word in (commandline -opc)[2..-1]\n")
+	b.WriteString("\t\tswitch \"$cmd:$word\"\n")
+
+	patterns := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		for _, sub := range cmd.subcommands {
+			patterns = append(patterns, fishQuote(cmd.path+":"+sub.Name))
+		}
+	}
+
+	fmt.Fprintf(&b, "\t\t\tcase %s\n", strings.Join(patterns, " "))
+	b.WriteString("\t\t\t\tset cmd (string trim -- \"$cmd $word\")\n")
+	b.WriteString("\t\tend\n")
+	b.WriteString("\tend\n")
+	b.WriteString("\techo $cmd\n")
+	b.WriteString("end\n\n")
+	b.WriteString("function __telemetry_agent_is\n")
+	b.WriteString("\tset -l cmd (__telemetry_agent_command)\n")
+	b.WriteString("\ttest \"$cmd\" = \"$argv[1]\"\n")
+	b.WriteString("end\n\n")
+
+	fmt.Fprintf(&b, "for prog in %s\n", strings.Join(completionProgramNames, " "))
+	b.WriteString("\tcomplete -c $prog -f\n")
+
+	for _, cmd := range commands {
+		condition := fishQuote("__telemetry_agent_is " + fishQuote(cmd.path))
+
+		for _, sub := range cmd.subcommands {
+			fmt.Fprintf(&b, "\tcomplete -c $prog -n %s -a %s -d %s\n", condition, sub.Name, fishQuote(sub.Help))
+		}
+
+		if len(cmd.args) != 0 {
+			fmt.Fprintf(&b, "\tcomplete -c $prog -n %s -a %s\n", condition, fishQuote(strings.Join(cmd.args, " ")))
+		}
+
+		if cmd.files {
+			fmt.Fprintf(&b, "\tcomplete -c $prog -n %s -F\n", condition)
+		}
+
+		for _, f := range cmd.ownFlags {
+			line := "\tcomplete -c $prog"
+			if cmd.path != "" {
+				// flags of top-level are accepted by all commands.
+				line += " -n " + condition
+			}
+
+			line += " -l " + f.Name
+			if f.Short != 0 {
+				line += " -s " + string(f.Short)
+			}
+
+			switch {
+			case f.Enum != "":
+				line += " -x -a " + fishQuote(strings.Join(f.EnumSlice(), " "))
+			case isPathFlag(f):
+				line += " -r -F"
+			case !f.IsBool() && !f.IsCounter():
+				line += " -x"
+			}
+
+			b.WriteString(line + " -d " + fishQuote(f.Help) + "\n")
+		}
+	}
+
+	b.WriteString("end\n")
+
+	return b.String()
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		shell    string
+		expected []string
+	}{
+		{
+			shell: "bash",
+			expected: []string{
+				"complete -F _telemetry_agent telemetry-agent percona-telemetry-agent",
+				"'history:show'",
+				"--telemetry.root-path",
+				"compgen -W 'auto always never'",
+				"bash zsh fish",
+			},
+		},
+		{
+			shell: "zsh",
+			expected: []string{
+				"#compdef telemetry-agent percona-telemetry-agent",
+				"'history:show'",
+				"'--telemetry.root-path:define Percona telemetry root path on local filesystem.'",
+				"--log.color) compadd -- auto always never",
+				"compadd -- bash zsh fish",
+			},
+		},
+		{
+			shell: "fish",
+			expected: []string{
+				"for prog in telemetry-agent percona-telemetry-agent",
+				"-a show",
+				"-l telemetry.root-path -r -F",
+				"-l log.color -x -a 'auto always never'",
+				"-a 'bash zsh fish'",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.shell, func(t *testing.T) {
+			t.Parallel()
+
+			var b strings.Builder
+			require.NoError(t, WriteCompletion(&b, tc.shell))
+
+			for _, expected := range tc.expected {
+				require.Contains(t, b.String(), expected)
+			}
+		})
+	}
+
+	require.Error(t, WriteCompletion(&strings.Builder{}, "powershell"))
+}
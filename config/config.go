@@ -17,22 +17,174 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/alecthomas/kong"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
 )
 
 const (
-	telemetryRootPath              = "PERCONA_TELEMETRY_ROOT_PATH"
-	telemetryCheckInterval         = "PERCONA_TELEMETRY_CHECK_INTERVAL"
-	telemetryResendInterval        = "PERCONA_TELEMETRY_RESEND_INTERVAL"
-	telemetryHistoryKeepInterval   = "PERCONA_TELEMETRY_HISTORY_KEEP_INTERVAL"
-	telemetryURL                   = "PERCONA_TELEMETRY_URL"
-	telemetryCheckIntervalDefault  = 24 * 60 * 60     // seconds
-	telemetryResendIntervalDefault = 60               // seconds
-	historyKeepIntervalDefault     = 7 * 24 * 60 * 60 // 7d
-	perconaTelemetryURLDefault     = "https://check.percona.com/v1/telemetry/GenericReport"
+	telemetryRootPath               = "PERCONA_TELEMETRY_ROOT_PATH"
+	telemetryCheckInterval          = "PERCONA_TELEMETRY_CHECK_INTERVAL"
+	telemetryResendInterval         = "PERCONA_TELEMETRY_RESEND_INTERVAL"
+	telemetryHistoryKeepInterval    = "PERCONA_TELEMETRY_HISTORY_KEEP_INTERVAL"
+	telemetryURL                    = "PERCONA_TELEMETRY_URL"
+	telemetryFamilyURLs             = "PERCONA_TELEMETRY_FAMILY_URLS"
+	telemetryAuditLog               = "PERCONA_TELEMETRY_AUDIT_LOG"
+	telemetryRemoteConfigURL        = "PERCONA_TELEMETRY_REMOTE_CONFIG_URL"
+	telemetryRemoteConfigKey        = "PERCONA_TELEMETRY_REMOTE_CONFIG_KEY"
+	telemetryCredentialCommand      = "PERCONA_TELEMETRY_CREDENTIAL_COMMAND"
+	telemetryVaultAddr              = "PERCONA_TELEMETRY_VAULT_ADDR"
+	telemetryVaultSecretPath        = "PERCONA_TELEMETRY_VAULT_SECRET_PATH"
+	telemetryVaultToken             = "PERCONA_TELEMETRY_VAULT_TOKEN"
+	telemetryCompression            = "PERCONA_TELEMETRY_COMPRESSION"
+	telemetryPackageQueryWorkers    = "PERCONA_TELEMETRY_PACKAGE_QUERY_WORKERS"
+	telemetryCmdTimeout             = "PERCONA_TELEMETRY_CMD_TIMEOUT"
+	telemetryRepoqueryTimeout       = "PERCONA_TELEMETRY_REPOQUERY_TIMEOUT"
+	telemetryExtraPillars           = "PERCONA_TELEMETRY_EXTRA_PILLARS"
+	telemetryExtraRootPaths         = "PERCONA_TELEMETRY_EXTRA_ROOT_PATHS"
+	telemetryTags                   = "PERCONA_TELEMETRY_TAGS"
+	telemetryWatch                  = "PERCONA_TELEMETRY_WATCH"
+	telemetryWatchDelay             = "PERCONA_TELEMETRY_WATCH_DELAY"
+	telemetryFlattenNested          = "PERCONA_TELEMETRY_FLATTEN_NESTED"
+	telemetryQuarantineKeepInterval = "PERCONA_TELEMETRY_QUARANTINE_KEEP_INTERVAL"
+	telemetryMergeWindow            = "PERCONA_TELEMETRY_MERGE_WINDOW"
+	telemetryMaxMetricsAge          = "PERCONA_TELEMETRY_MAX_METRICS_AGE"
+	telemetryHistoryCompress        = "PERCONA_TELEMETRY_HISTORY_COMPRESS"
+	telemetryHistoryMaxSize         = "PERCONA_TELEMETRY_HISTORY_MAX_SIZE"
+	telemetryHistoryMaxFiles        = "PERCONA_TELEMETRY_HISTORY_MAX_FILES"
+	telemetryDedupeWindow           = "PERCONA_TELEMETRY_DEDUPE_WINDOW"
+	telemetryKeepMetricsFiles       = "PERCONA_TELEMETRY_KEEP_METRICS_FILES"
+	telemetryRunOnStart             = "PERCONA_TELEMETRY_RUN_ON_START"
+	telemetrySendWorkers            = "PERCONA_TELEMETRY_SEND_WORKERS"
+	telemetryMaxSendAttempts        = "PERCONA_TELEMETRY_MAX_SEND_ATTEMPTS"
+	telemetrySendSpread             = "PERCONA_TELEMETRY_SEND_SPREAD"
+	telemetryBatchSize              = "PERCONA_TELEMETRY_BATCH_SIZE"
+	telemetryMaxClockSkew           = "PERCONA_TELEMETRY_MAX_CLOCK_SKEW"
+	telemetryScrubRules             = "PERCONA_TELEMETRY_SCRUB_RULES"
+	telemetryScrubPatterns          = "PERCONA_TELEMETRY_SCRUB_PATTERNS"
+	telemetryScrubKeys              = "PERCONA_TELEMETRY_SCRUB_KEYS"
+	telemetryHashKeys               = "PERCONA_TELEMETRY_HASH_KEYS"
+	telemetryHashSalt               = "PERCONA_TELEMETRY_HASH_SALT"
+	telemetryUser                   = "PERCONA_TELEMETRY_USER"
+	telemetryGroup                  = "PERCONA_TELEMETRY_GROUP"
+	telemetryFixPermissions         = "PERCONA_TELEMETRY_FIX_PERMISSIONS"
+	telemetrySelfReport             = "PERCONA_TELEMETRY_SELF_REPORT"
+	telemetrySelfReportFamily       = "PERCONA_TELEMETRY_SELF_REPORT_FAMILY"
+	telemetryDisable                = "PERCONA_TELEMETRY_DISABLE"
+	telemetryDisableFile            = "PERCONA_TELEMETRY_DISABLE_FILE"
+	telemetryOneshot                = "PERCONA_TELEMETRY_ONESHOT"
+	telemetryDryRun                 = "PERCONA_TELEMETRY_DRY_RUN"
+	telemetryHealthAddr             = "PERCONA_TELEMETRY_HEALTH_ADDR"
+	telemetryDebugPprofAddr         = "PERCONA_TELEMETRY_DEBUG_PPROF_ADDR"
+	telemetryDebugRecordDir         = "PERCONA_TELEMETRY_DEBUG_RECORD_DIR"
+	telemetryDebugReplayDir         = "PERCONA_TELEMETRY_DEBUG_REPLAY_DIR"
+	telemetrySecuritySandbox        = "PERCONA_TELEMETRY_SECURITY_SANDBOX"
+	telemetryTracingEndpoint        = "PERCONA_TELEMETRY_TRACING_ENDPOINT"
+	telemetryKubernetes             = "PERCONA_TELEMETRY_KUBERNETES"
+	telemetryPushAddr               = "PERCONA_TELEMETRY_PUSH_ADDR"
+	telemetryIngestSocket           = "PERCONA_TELEMETRY_INGEST_SOCKET"
+	telemetryKubernetesPodInfoPath  = "PERCONA_TELEMETRY_KUBERNETES_POD_INFO_PATH"
+	telemetryLogFile                = "PERCONA_TELEMETRY_LOG_FILE"
+	telemetryLogMaxSize             = "PERCONA_TELEMETRY_LOG_MAX_SIZE"
+	telemetryLogMaxBackups          = "PERCONA_TELEMETRY_LOG_MAX_BACKUPS"
+	telemetryLogMaxAge              = "PERCONA_TELEMETRY_LOG_MAX_AGE"
+	telemetryLogSyslog              = "PERCONA_TELEMETRY_LOG_SYSLOG"
+	telemetryLogSyslogFacility      = "PERCONA_TELEMETRY_LOG_SYSLOG_FACILITY"
+	telemetryLogSyslogTag           = "PERCONA_TELEMETRY_LOG_SYSLOG_TAG"
+	telemetryLogJournald            = "PERCONA_TELEMETRY_LOG_JOURNALD"
+	telemetryLogRedactKeys          = "PERCONA_TELEMETRY_LOG_REDACT_KEYS"
+	telemetryLogSamplingFirst       = "PERCONA_TELEMETRY_LOG_SAMPLING_FIRST"
+	telemetryLogSamplingInterval    = "PERCONA_TELEMETRY_LOG_SAMPLING_INTERVAL"
+	telemetryLogColor               = "PERCONA_TELEMETRY_LOG_COLOR"
+	telemetryLogTimeFormat          = "PERCONA_TELEMETRY_LOG_TIME_FORMAT"
+	telemetryCheckIntervalDefault   = 24 * 60 * 60      // seconds
+	telemetryResendIntervalDefault  = 60                // seconds
+	historyKeepIntervalDefault      = 7 * 24 * 60 * 60  // 7d
+	quarantineKeepIntervalDefault   = 30 * 24 * 60 * 60 // 30d
+	perconaTelemetryURLDefault      = "https://check.percona.com/v1/telemetry/GenericReport"
+	packageQueryWorkersDefault      = 4
+	cmdTimeoutDefault               = 30  // seconds
+	repoqueryTimeoutDefault         = 120 // seconds
+	watchDelayDefault               = 5   // seconds
+	sendWorkersDefault              = 1
+	maxSendAttemptsDefault          = 10
+	batchSizeDefault                = 1000
+	maxClockSkewDefault             = 300 // seconds
+	compressionDefault              = "auto"
+	logMaxSizeDefault               = 100 // MiB
+	logMaxBackupsDefault            = 5
+	logMaxAgeDefault                = 30 // days
+	logSyslogFacilityDefault        = "daemon"
+	logSyslogTagDefault             = "percona-telemetry-agent"
+	logSamplingFirstDefault         = 10
+	logSamplingIntervalDefault      = 60 // seconds
+	logColorDefault                 = "auto"
+	logTimeFormatDefault            = "iso8601"
+	logRedactKeysDefault            = "*token*,*password*,*secret*,*salt*,*api?key*,authorization,cookie"
+	disableFileDefault              = "/usr/local/percona/telemetry_disabled"
+	telemetryGroupDefault           = "percona-telemetry"
+	kubernetesPodInfoPathDefault    = "/etc/podinfo"
+)
+
+// InstanceFileName is the name of the file with instance ID inside extra telemetry root path.
+const InstanceFileName = "telemetry_uuid"
+
+// UnixSocketScheme is the scheme of Percona Platform URL pointing to unix socket of local relay.
+const UnixSocketScheme = "unix"
+
+// Telemetry Agent commands.
+const (
+	// CommandRun runs Telemetry Agent service, it is the default command.
+	CommandRun = "run"
+	// CommandPackages prints detected Percona software and exits.
+	CommandPackages = "packages"
+	// CommandHistoryList lists sent telemetry history files and exits.
+	CommandHistoryList = "history list"
+	// CommandHistoryShow prints sent telemetry stored in history file and exits.
+	CommandHistoryShow = "history show <file>"
+	// CommandHistoryPrune removes telemetry history files and exits.
+	CommandHistoryPrune = "history prune"
+	// CommandBundle creates support bundle archive and exits.
+	CommandBundle = "bundle"
+	// CommandStatus prints status of running Telemetry Agent service and exits.
+	CommandStatus = "status"
+	// CommandPurge removes local telemetry state and exits.
+	CommandPurge = "purge"
+	// CommandDoctor checks telemetry directories permissions and exits.
+	CommandDoctor = "doctor"
+	// CommandID prints, validates and repairs or regenerates host instance ID and exits.
+	CommandID = "id"
+	// CommandCollect prints telemetry report that would be sent to Percona Platform and exits.
+	CommandCollect = "collect"
+	// CommandSendFile sends single metrics file to Percona Platform and exits.
+	CommandSendFile = "send-file <file>"
+	// CommandSchedule prints schedule of metrics processing iterations and exits.
+	CommandSchedule = "schedule"
+	// CommandCompletion prints shell completion script and exits.
+	CommandCompletion = "completion <shell>"
+)
+
+// Telemetry Agent exit codes. Values follow sysexits.h conventions, so systemd OnFailure handlers
+// and packaging tests can react to the cause of failure.
+const (
+	// ExitCodeError is the exit code of any other failure.
+	ExitCodeError = 1
+	// ExitCodeUnavailable is the exit code of Percona Platform address validation or connection failure.
+	ExitCodeUnavailable = 69
+	// ExitCodePermission is the exit code of insufficient permissions on telemetry directories or files.
+	ExitCodePermission = 77
+	// ExitCodeConfig is the exit code of invalid configuration parameters or command line arguments.
+	ExitCodeConfig = 78
 )
 
 var (
@@ -44,6 +196,16 @@ var (
 	BuildDate string
 )
 
+// tagNameRe matches valid static tag names.
+var tagNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// PillarOpts represents Percona Pillar metrics directory and product family its metrics are reported with.
+type PillarOpts struct {
+	Name          string
+	Path          string
+	ProductFamily platformReporter.ProductFamily
+}
+
 // TelemetryOpts represents the options for configuring telemetry paths on local filesystem.
 type TelemetryOpts struct {
 	RootPath       string `help:"define Percona telemetry root path on local filesystem." env:"PERCONA_TELEMETRY_ROOT_PATH" default:"/usr/local/percona/telemetry"`
@@ -55,37 +217,294 @@ type TelemetryOpts struct {
 	PSMDBMongosMetricsPath string `kong:"-"`
 	PXCMetricsPath         string `kong:"-"`
 	PGMetricsPath          string `kong:"-"`
+	PBMMetricsPath         string `kong:"-"`
+	PMMMetricsPath         string `kong:"-"`
 	HistoryPath            string `kong:"-"`
+	SchemasPath            string `kong:"-"`
+	QuarantinePath         string `kong:"-"`
+	StatusSocketPath       string `kong:"-"`
+	FailedPath             string `kong:"-"`
+	ProcessingPath         string `kong:"-"`
+	LastRunPath            string `kong:"-"`
+	ErrorCountersPath      string `kong:"-"`
+	StateDBPath            string `kong:"-"`
 	CheckInterval          int    `help:"define time interval in seconds for checking Percona Pillars telemetry." env:"PERCONA_TELEMETRY_CHECK_INTERVAL" default:"86400"`
+	CheckIntervalSet       bool   `kong:"-"`
 	HistoryKeepInterval    int    `help:"define time interval in seconds for keeping old history telemetry files on filesystem." env:"PERCONA_TELEMETRY_HISTORY_KEEP_INTERVAL" default:"604800"`
+	QuarantineKeepInterval int    `help:"define time interval in seconds for keeping quarantined corrupt or rejected metrics files on filesystem." env:"PERCONA_TELEMETRY_QUARANTINE_KEEP_INTERVAL" default:"2592000"`
+	HistoryCompress        bool   `help:"enable gzip compression of telemetry history files." env:"PERCONA_TELEMETRY_HISTORY_COMPRESS" default:"false"`
+	HistoryMaxSize         int    `help:"define maximum total size in MiB of telemetry history files on filesystem, the oldest files are removed when it is exceeded, 0 disables the limit." env:"PERCONA_TELEMETRY_HISTORY_MAX_SIZE" default:"0"`
+	HistoryMaxFiles        int    `help:"define maximum number of telemetry history files on filesystem, the oldest files are removed when it is exceeded, 0 disables the limit." env:"PERCONA_TELEMETRY_HISTORY_MAX_FILES" default:"0"`
+	PackageQueryWorkers    int    `help:"define maximum number of package manager queries running concurrently." env:"PERCONA_TELEMETRY_PACKAGE_QUERY_WORKERS" default:"4"`
+	CmdTimeout             int    `help:"define timeout in seconds for a single package manager or hardware info query." env:"PERCONA_TELEMETRY_CMD_TIMEOUT" default:"30"`
+	RepoqueryTimeout       int    `help:"define timeout in seconds for a single repoquery/yum/dnf query, it may be slow on first run when repositories metadata is refreshed." env:"PERCONA_TELEMETRY_REPOQUERY_TIMEOUT" default:"120"`
+	// Tags maps static tag name to value, tags are attached to every report sent to Percona Platform.
+	Tags map[string]string `name:"tag" help:"define static tags attached to every report (repeatable), e.g. 'environment=prod' or 'environment=prod,team=dba'." env:"PERCONA_TELEMETRY_TAGS" mapsep:","`
+	// ExtraPillars maps additional Pillar metrics directory name (relative to RootPath) to product family name.
+	ExtraPillars    map[string]string `help:"define additional Pillar metrics directories (relative to telemetry root path) and their product families, e.g. 'everest=EVEREST,pxb=PXB'." env:"PERCONA_TELEMETRY_EXTRA_PILLARS" mapsep:","`
+	ExtraRootPaths  []string          `help:"define additional telemetry root paths (e.g. one per customer environment on multi-tenant host) processed the same way as the telemetry root path, each with its own instance ID stored in 'telemetry_uuid' file inside it." env:"PERCONA_TELEMETRY_EXTRA_ROOT_PATHS"`
+	Watch           bool              `help:"enable watching Pillar metrics directories and processing new metrics files immediately, periodic check is kept as well." env:"PERCONA_TELEMETRY_WATCH" default:"false"`
+	WatchDelay      int               `help:"define time interval in seconds to wait for metrics files changes to settle before processing them in watch mode." env:"PERCONA_TELEMETRY_WATCH_DELAY" default:"5"`
+	FlattenNested   bool              `help:"enable flattening nested objects of Pillars metrics into dotted keys instead of sending them as JSON strings." env:"PERCONA_TELEMETRY_FLATTEN_NESTED" default:"false"`
+	MergeWindow     int               `help:"define time interval in seconds within which metrics files of the same database instance are sent as one batched report, 0 disables merging." env:"PERCONA_TELEMETRY_MERGE_WINDOW" default:"0"`
+	MaxMetricsAge   int               `help:"define maximum age in seconds of Pillar metrics files, older files are removed without sending, 0 disables the limit." env:"PERCONA_TELEMETRY_MAX_METRICS_AGE" default:"0"`
+	DedupeWindow    int               `help:"define time interval in seconds within which a report identical to already sent one is not sent again, 0 disables deduplication." env:"PERCONA_TELEMETRY_DEDUPE_WINDOW" default:"0"`
+	RunOnStart      bool              `help:"enable running the first metrics processing iteration right after start instead of waiting for the whole check interval." env:"PERCONA_TELEMETRY_RUN_ON_START" default:"false"`
+	SendWorkers     int               `help:"define maximum number of metrics files (or batches of merged files) sent, saved into history and removed concurrently." env:"PERCONA_TELEMETRY_SEND_WORKERS" default:"1"`
+	MaxSendAttempts int               `help:"define number of failed attempts to send metrics file after which it is moved into failed directory with the last error, 0 means retrying forever." env:"PERCONA_TELEMETRY_MAX_SEND_ATTEMPTS" default:"10"`
+	SendSpread      int               `help:"define time interval in seconds the requests of one iteration are evenly spaced out over instead of being sent back-to-back, 0 disables pacing." env:"PERCONA_TELEMETRY_SEND_SPREAD" default:"0"`
+	BatchSize       int               `help:"define maximum number of metrics files parsed and held in memory at once, larger backlogs are processed in several batches within one iteration, 0 disables the limit." env:"PERCONA_TELEMETRY_BATCH_SIZE" default:"1000"`
+	MaxClockSkew    int               `help:"define maximum tolerated skew in seconds of host clock relative to Percona Platform clock (measured with Date header of its responses), creation time of reports from hosts with larger skew is corrected, 0 disables the correction." env:"PERCONA_TELEMETRY_MAX_CLOCK_SKEW" default:"300"`
+	Disable         bool              `help:"disable collecting and sending telemetry, the service keeps running but does nothing." env:"PERCONA_TELEMETRY_DISABLE" default:"false"`
+	DisableFile     string            `help:"define path of kill-switch file, collecting and sending telemetry is skipped while it exists." env:"PERCONA_TELEMETRY_DISABLE_FILE" default:"/usr/local/percona/telemetry_disabled"`
+	// ScrubRules, ScrubPatterns and ScrubKeys define scrubbing of personal data from metric values before sending.
+	ScrubRules    []string `help:"define built-in rules scrubbing personal data from metric values before sending and saving into history: hostname, ip, email, path." env:"PERCONA_TELEMETRY_SCRUB_RULES" enum:"hostname,ip,email,path"`
+	ScrubPatterns []string `help:"define semicolon-separated regular expressions, matching parts of metric values are scrubbed before sending and saving into history." env:"PERCONA_TELEMETRY_SCRUB_PATTERNS" sep:";"`
+	ScrubKeys     []string `help:"define patterns of metric keys (e.g. '*_host'), values of matching metrics are scrubbed entirely before sending and saving into history." env:"PERCONA_TELEMETRY_SCRUB_KEYS"`
+	// HashKeys are patterns of identifying metric keys whose values are replaced with salted hashes before sending.
+	HashKeys []string `help:"define patterns of identifying metric keys (e.g. 'db_instance_id'), values of matching metrics are replaced with salted SHA-256 hashes before sending and saving into history." env:"PERCONA_TELEMETRY_HASH_KEYS"`
+	HashSalt string   `help:"define salt of metric value hashes, the same salt on all hosts allows correlating hashes across hosts. Random salt stored in telemetry root path is used by default." env:"PERCONA_TELEMETRY_HASH_SALT" default:""`
+	// User is unprivileged user Telemetry Agent service switches to after startup when it is started as root.
+	User string `help:"define unprivileged user Telemetry Agent service switches to after creating telemetry directories and files when it is started as root, it is not switched if empty." env:"PERCONA_TELEMETRY_USER" default:""`
+	// Group is shared by Telemetry Agent and Pillars users, telemetry directories must be writable by its members.
+	Group          string `help:"define group shared by Telemetry Agent and Pillars users, telemetry directories are checked to be writable by its members at startup." env:"PERCONA_TELEMETRY_GROUP" default:"percona-telemetry"`
+	FixPermissions bool   `help:"enable fixing group and mode of telemetry directories at startup if they are wrong." env:"PERCONA_TELEMETRY_FIX_PERMISSIONS" default:"false"`
+	// SelfReport enables daily report about Telemetry Agent itself sent with SelfReportFamily product family.
+	SelfReport bool `help:"enable sending daily report about Telemetry Agent itself (version, uptime, iterations and requests counters) to Percona Platform, requires --telemetry.self-report-family." env:"PERCONA_TELEMETRY_SELF_REPORT" default:"false"`
+	// SelfReportFamily is set explicitly, since Percona Platform doesn't define product family of Telemetry Agent yet.
+	SelfReportFamily string `help:"define product family name (e.g. 'PMM') daily report about Telemetry Agent itself is sent with." env:"PERCONA_TELEMETRY_SELF_REPORT_FAMILY" default:""`
+	// SelfReportProductFamily is SelfReportFamily resolved into Percona Platform product family.
+	SelfReportProductFamily platformReporter.ProductFamily `kong:"-"`
+	// KeepMetricsFiles enables recording processed metrics files in state database instead of removing them.
+	KeepMetricsFiles bool `help:"enable keeping processed Pillar metrics files in place (e.g. on read-only or shared volumes) and recording them in state database instead, recorded files are skipped until their content changes." env:"PERCONA_TELEMETRY_KEEP_METRICS_FILES" default:"false"`
+	// HashSaltPath is the file random hash salt is stored in when HashSalt is not set.
+	HashSaltPath string `kong:"-"`
+	// FirstSeenPath is the file the time Telemetry Agent first ran on the host is stored in.
+	FirstSeenPath string `kong:"-"`
+	// InstanceIDChangesPath is the append-only file instance ID regenerations are recorded in.
+	InstanceIDChangesPath string `kong:"-"`
+	// InstanceFile is the file with instance ID telemetry of the root path is reported with,
+	// Percona telemetry file is used if it is empty.
+	InstanceFile string `kong:"-"`
+	// Pillars is the registry of all Pillar metrics directories processed by Telemetry Agent.
+	Pillars []PillarOpts `kong:"-"`
 }
 
 // PlatformOpts represents the options for configuring communication with Percona Platform parameters.
 type PlatformOpts struct {
 	ResendTimeout int    `help:"define wait time in seconds to sleep before retrying request to Percona Platform in case of request failure." env:"PERCONA_TELEMETRY_RESEND_INTERVAL" default:"60"`
 	URL           string `help:"define Percona Platform URL for sending Pillars telemetry to." env:"PERCONA_TELEMETRY_URL" default:"https://check.percona.com/v1/telemetry/GenericReport"`
+	// FamilyURLs maps product family name to Percona Platform URL its telemetry is sent to instead of URL.
+	FamilyURLs map[string]string `help:"define Percona Platform URLs for sending telemetry of particular product families to, e.g. 'PMM=https://pmm-relay.example.com/v1/telemetry/GenericReport'." env:"PERCONA_TELEMETRY_FAMILY_URLS" mapsep:","`
+	// Routes is FamilyURLs with parsed product families.
+	Routes map[platformReporter.ProductFamily]string `kong:"-"`
+	// AuditLog is the path of append-only audit log with one JSON line per report sent to Percona Platform.
+	AuditLog string `help:"define path of append-only audit log of reports sent to Percona Platform, empty disables audit log." env:"PERCONA_TELEMETRY_AUDIT_LOG" default:""`
+	// Compression is used only after Percona Platform advertises acceptance of the encoding.
+	Compression string `help:"define compression of requests to Percona Platform: auto (the best of zstd and gzip accepted by Percona Platform), zstd, gzip or none." env:"PERCONA_TELEMETRY_COMPRESSION" enum:"auto,zstd,gzip,none" default:"auto"`
+	// RemoteConfigURL is the URL of signed configuration document fetched before each iteration, local configuration takes precedence over it.
+	RemoteConfigURL string `help:"define Percona Platform URL of signed remote configuration (check interval, disabled collectors, extra package patterns) fetched before each iteration, empty value disables remote configuration." env:"PERCONA_TELEMETRY_REMOTE_CONFIG_URL" default:""`
+	RemoteConfigKey string `help:"define base64 encoded ed25519 public key remote configuration must be signed with." env:"PERCONA_TELEMETRY_REMOTE_CONFIG_KEY" default:""`
+	// RemoteConfigPublicKey is decoded RemoteConfigKey.
+	RemoteConfigPublicKey []byte `kong:"-"`
+	// CredentialCommand and Vault options are sources of access token and client certificate, they are refreshed before expiry.
+	CredentialCommand string `help:"define exec-credential helper command printing JSON with Percona Platform credentials (token, client_certificate, client_key, expires_at) into stdout, empty value disables it." env:"PERCONA_TELEMETRY_CREDENTIAL_COMMAND" default:""`
+	VaultAddr         string `help:"define HashiCorp Vault address (e.g. 'https://vault.example.com:8200') Percona Platform credentials are read from, empty value disables reading them from Vault." env:"PERCONA_TELEMETRY_VAULT_ADDR" default:""`
+	VaultSecretPath   string `help:"define path of Vault secret with Percona Platform credentials (e.g. 'secret/data/percona-telemetry')." env:"PERCONA_TELEMETRY_VAULT_SECRET_PATH" default:""`
+	VaultToken        string `help:"define Vault token Percona Platform credentials secret is read with." env:"PERCONA_TELEMETRY_VAULT_TOKEN" default:""`
 }
 
 // LogOpts represents the options for configuring logging.
 type LogOpts struct {
-	Verbose bool `help:"enable verbose logging." default:"false"`
-	DevMode bool `help:"enable development mode logging." default:"false"`
+	Verbose        bool   `help:"enable verbose logging." default:"false"`
+	DevMode        bool   `help:"enable development mode logging." default:"false"`
+	File           string `help:"define path of log file to write service logs to instead of stdout, the file is rotated by size." env:"PERCONA_TELEMETRY_LOG_FILE" default:""`
+	MaxSize        int    `help:"define maximum size in MiB of log file before it is rotated." env:"PERCONA_TELEMETRY_LOG_MAX_SIZE" default:"100"`
+	MaxBackups     int    `help:"define maximum number of rotated log files to keep, 0 keeps all of them." env:"PERCONA_TELEMETRY_LOG_MAX_BACKUPS" default:"5"`
+	MaxAge         int    `help:"define maximum number of days to keep rotated log files, 0 disables removing them by age." env:"PERCONA_TELEMETRY_LOG_MAX_AGE" default:"30"`
+	Syslog         bool   `help:"enable writing service logs into local syslog in addition to stdout or log file." env:"PERCONA_TELEMETRY_LOG_SYSLOG" default:"false"`
+	SyslogFacility string `help:"define syslog facility (e.g. daemon, user, local0-local7)." env:"PERCONA_TELEMETRY_LOG_SYSLOG_FACILITY" enum:"kern,user,mail,daemon,auth,syslog,lpr,news,uucp,cron,authpriv,ftp,local0,local1,local2,local3,local4,local5,local6,local7" default:"daemon"`
+	SyslogTag      string `help:"define syslog tag, it is used as journald identifier as well." env:"PERCONA_TELEMETRY_LOG_SYSLOG_TAG" default:"percona-telemetry-agent"`
+	Journald       bool   `help:"enable writing service logs into journald with log fields mapped to journal fields instead of stdout." env:"PERCONA_TELEMETRY_LOG_JOURNALD" default:"false"`
+	// RedactKeys are case-insensitive shell patterns of config and HTTP header/body keys whose values are masked in logs.
+	RedactKeys       []string `help:"define comma-separated patterns of keys (e.g. '*token*') whose values are masked in logged config and Percona Platform requests." env:"PERCONA_TELEMETRY_LOG_REDACT_KEYS" default:"*token*,*password*,*secret*,*salt*,*api?key*,authorization,cookie"`
+	SamplingFirst    int      `help:"define number of log messages with the same level and text logged during sampling interval, the rest of them are suppressed and their count is logged at the end of interval. 0 disables sampling." env:"PERCONA_TELEMETRY_LOG_SAMPLING_FIRST" default:"10"`
+	SamplingInterval int      `help:"define log sampling interval in seconds." env:"PERCONA_TELEMETRY_LOG_SAMPLING_INTERVAL" default:"60"`
+	Color            string   `help:"define colors mode of development mode console output: auto (colors are used if output is a terminal), always or never." env:"PERCONA_TELEMETRY_LOG_COLOR" enum:"auto,always,never" default:"auto"`
+	TimeFormat       string   `help:"define log timestamps format: iso8601, rfc3339 or epoch (seconds since the Unix epoch)." env:"PERCONA_TELEMETRY_LOG_TIME_FORMAT" enum:"iso8601,rfc3339,epoch" default:"iso8601"`
+}
+
+// HealthOpts represents the options for configuring local health check HTTP endpoints.
+type HealthOpts struct {
+	Addr string `help:"define address (host:port) to serve /healthz and /readyz health check endpoints on, empty value disables them." env:"PERCONA_TELEMETRY_HEALTH_ADDR" default:""`
+}
+
+// DebugOpts represents the options for diagnosing Telemetry Agent.
+type DebugOpts struct {
+	PprofAddr string `help:"define loopback address (host:port) to serve net/http/pprof endpoints on, empty value disables them." env:"PERCONA_TELEMETRY_DEBUG_PPROF_ADDR" default:""`
+	// RecordDir and ReplayDir turn field-reported parsing bugs of external commands output into reproducible fixtures.
+	RecordDir string `help:"define directory raw output of every external command (dpkg-query, apt-cache, repoquery, uname, etc.) is recorded into, empty value disables recording." env:"PERCONA_TELEMETRY_DEBUG_RECORD_DIR" default:""`
+	ReplayDir string `help:"define directory with external commands output recorded by --debug.record-dir, recorded output is fed into parsers instead of running the commands." env:"PERCONA_TELEMETRY_DEBUG_REPLAY_DIR" default:""`
+}
+
+// TracingOpts represents the options for OpenTelemetry tracing of metrics processing.
+type TracingOpts struct {
+	Endpoint string `help:"define URL of OTLP/HTTP collector (e.g. 'http://localhost:4318') traces of iterations and sent metrics files are exported to, empty value disables tracing." env:"PERCONA_TELEMETRY_TRACING_ENDPOINT" default:""`
+}
+
+// SecurityOpts represents the options for hardening Telemetry Agent service.
+type SecurityOpts struct {
+	Sandbox bool `help:"enable Landlock and seccomp sandboxing of Telemetry Agent service after startup, filesystem access is restricted and dangerous system calls are denied." env:"PERCONA_TELEMETRY_SECURITY_SANDBOX" default:"false"`
+}
+
+// PushOpts represents the options for receiving metrics pushed by Pillars over HTTP.
+type PushOpts struct {
+	Addr string `help:"define loopback address (host:port) Pillars push metrics to with 'POST /v1/metrics/<pillar directory>' requests, empty value disables push API." env:"PERCONA_TELEMETRY_PUSH_ADDR" default:""`
+}
+
+// IngestOpts represents the options for receiving reports from local Percona components over gRPC.
+type IngestOpts struct {
+	Socket string `help:"define path of unix socket serving gRPC ReporterAPI for local Percona components, only the agent user and members of telemetry group can connect to it. Empty value disables gRPC ingestion." env:"PERCONA_TELEMETRY_INGEST_SOCKET" default:""`
+}
+
+// KubernetesOpts represents the options for running Telemetry Agent as a sidecar container of Kubernetes pod.
+type KubernetesOpts struct {
+	Enabled     bool   `help:"enable Kubernetes sidecar mode: pod metadata is reported with host metrics, pod UID is used as host instance ID and Percona telemetry file is not written." env:"PERCONA_TELEMETRY_KUBERNETES" default:"false"`
+	PodInfoPath string `help:"define path of downward API volume with pod metadata files: namespace, uid, labels and operator_version." env:"PERCONA_TELEMETRY_KUBERNETES_POD_INFO_PATH" default:"/etc/podinfo"`
+}
+
+// RunCmd represents the options of the command running Telemetry Agent service.
+type RunCmd struct{}
+
+// PackagesCmd represents the options of the command printing detected Percona software.
+type PackagesCmd struct {
+	Format string `help:"define output format (json, pretty, table)." enum:"json,pretty,table" default:"json"`
+}
+
+// HistoryCmd represents the commands for auditing and cleaning up sent telemetry history.
+type HistoryCmd struct {
+	List  HistoryListCmd  `cmd:"" help:"List sent telemetry history files."`
+	Show  HistoryShowCmd  `cmd:"" help:"Print sent telemetry stored in history file in human-readable form."`
+	Prune HistoryPruneCmd `cmd:"" help:"Remove telemetry history files."`
+}
+
+// HistoryListCmd represents the options of the command listing sent telemetry history files.
+type HistoryListCmd struct{}
+
+// HistoryShowCmd represents the options of the command printing sent telemetry stored in history file.
+type HistoryShowCmd struct {
+	File string `arg:"" help:"history file name inside telemetry history directory or path to history file."`
+}
+
+// HistoryPruneCmd represents the options of the command removing telemetry history files.
+type HistoryPruneCmd struct {
+	OlderThan int `help:"remove only history files older than defined time interval in seconds, all history files are removed if 0." default:"0"`
+}
+
+// BundleCmd represents the options of the command creating support bundle archive.
+type BundleCmd struct {
+	Output string `help:"define path of support bundle archive to create." short:"o" default:"telemetry-agent-bundle.tar.gz"`
+	LogDir string `help:"define directory with Telemetry Agent log files to include recent logs from." default:"/var/log/percona/telemetry-agent"`
+}
+
+// StatusCmd represents the options of the command printing status of running Telemetry Agent service.
+type StatusCmd struct{}
+
+// CollectCmd represents the options of the command printing telemetry report that would be sent to Percona Platform.
+type CollectCmd struct{}
+
+// SendFileCmd represents the options of the command sending single metrics file to Percona Platform.
+type SendFileCmd struct {
+	File   string `arg:"" help:"path of metrics file to send, e.g. the file recovered from quarantine or failed metrics directory, '-' reads metrics document from stdin."`
+	Family string `help:"define Pillar metrics directory name (e.g. 'pg') or product family name (e.g. 'POSTGRESQL') the file is reported with." required:""`
+	// ProductFamily is Family resolved into Percona Platform product family.
+	ProductFamily platformReporter.ProductFamily `kong:"-"`
+}
+
+// ScheduleCmd represents the options of the command printing schedule of metrics processing iterations.
+type ScheduleCmd struct{}
+
+// CompletionCmd represents the options of the command printing shell completion script.
+type CompletionCmd struct {
+	Shell string `arg:"" enum:"bash,zsh,fish" help:"shell to print completion script for: bash, zsh or fish."`
+}
+
+// DoctorCmd represents the options of the command checking telemetry directories permissions.
+type DoctorCmd struct {
+	Fix bool `help:"fix owner, group and mode of telemetry directories found wrong." default:"false"`
+}
+
+// IDCmd represents the options of the command showing and validating host instance ID.
+type IDCmd struct {
+	Regenerate bool `help:"replace host instance ID with new random one, the previous instance ID is recorded in telemetry history." default:"false"`
+}
+
+// PurgeCmd represents the options of the command removing local telemetry state.
+type PurgeCmd struct {
+	Yes     bool `help:"confirm removing local telemetry state, files to remove are only listed without it." default:"false"`
+	Disable bool `help:"create kill-switch file after purging, so telemetry is not collected and sent again." default:"false"`
 }
 
 // Config struct used for storing Telemetry Agent configuration parameters.
 type Config struct {
-	Telemetry TelemetryOpts `embed:"" prefix:"telemetry."`
-	Platform  PlatformOpts  `embed:"" prefix:"platform."`
-	Log       LogOpts       `embed:"" prefix:"log."`
-	Version   bool          `help:"Show version and exit"`
+	Telemetry  TelemetryOpts  `embed:"" prefix:"telemetry."`
+	Platform   PlatformOpts   `embed:"" prefix:"platform."`
+	Log        LogOpts        `embed:"" prefix:"log."`
+	Health     HealthOpts     `embed:"" prefix:"health."`
+	Debug      DebugOpts      `embed:"" prefix:"debug."`
+	Security   SecurityOpts   `embed:"" prefix:"security."`
+	Tracing    TracingOpts    `embed:"" prefix:"tracing."`
+	Kubernetes KubernetesOpts `embed:"" prefix:"kubernetes."`
+	Push       PushOpts       `embed:"" prefix:"push."`
+	Ingest     IngestOpts     `embed:"" prefix:"ingest."`
+	Version    bool           `help:"Show version and exit"`
+	Oneshot    bool           `help:"Run single cleanup, collection and send iteration immediately and exit, exit status is non-zero if telemetry is not sent." env:"PERCONA_TELEMETRY_ONESHOT" default:"false"`
+	DryRun     bool           `help:"Collect metrics once, print requests that would be sent to Percona Platform and exit. Nothing is sent and Pillars metrics files are left untouched." env:"PERCONA_TELEMETRY_DRY_RUN" default:"false"`
+	Run        RunCmd         `cmd:"" default:"1" help:"Run Telemetry Agent service (default)."`
+	Packages   PackagesCmd    `cmd:"" help:"Print detected Percona software the same way it is reported and exit. Nothing is sent to Percona Platform."`
+	History    HistoryCmd     `cmd:"" help:"List, show or prune sent telemetry history and exit."`
+	Bundle     BundleCmd      `cmd:"" help:"Create support bundle archive with telemetry history, effective configuration and recent logs and exit."`
+	Status     StatusCmd      `cmd:"" help:"Print status of running Telemetry Agent service in JSON and exit."`
+	Purge      PurgeCmd       `cmd:"" help:"Remove host instance ID, telemetry history and pending metrics files to forget this host and exit."`
+	ID         IDCmd          `cmd:"" name:"id" help:"Print host instance ID, validate and repair Percona telemetry file and exit."`
+	Doctor     DoctorCmd      `cmd:"" help:"Check owner, group and mode of telemetry directories, optionally fix them and exit."`
+	Collect    CollectCmd     `cmd:"" help:"Collect host, installed packages and Pillars metrics, print telemetry report that would be sent to Percona Platform and exit. Nothing is sent and no files are changed."`
+	SendFile   SendFileCmd    `cmd:"" aliases:"send" help:"Send single metrics file to Percona Platform with host metrics attached, save it into telemetry history and exit. The file is left in place."`
+	Schedule   ScheduleCmd    `cmd:"" help:"Print schedule of metrics processing iterations and the next iteration time of running Telemetry Agent service in JSON and exit."`
+	Completion CompletionCmd  `cmd:"" help:"Print shell completion script and exit."`
+	// Command holds the name of the command to execute.
+	Command string `kong:"-"`
 }
 
-// InitConfig parses Telemetry Agent configuration parameters.
-// If some parameters are not defined - default values are used instead.
-func InitConfig() Config {
-	var conf Config
+// ReadOnly returns true if Telemetry Agent only collects and prints telemetry, so no files may be changed.
+func (c Config) ReadOnly() bool {
+	return c.DryRun || c.Command == CommandCollect
+}
+
+// DefaultCheckInterval returns true if check interval is not set explicitly, even to default value,
+// only then it may be overridden by remote configuration.
+func (c Config) DefaultCheckInterval() bool {
+	return !c.Telemetry.CheckIntervalSet
+}
+
+// flagSet returns true if flag is set on command line or with its environment variable.
+func flagSet(ctx *kong.Context, name, envVar string) bool {
+	if _, ok := os.LookupEnv(envVar); ok {
+		return true
+	}
 
-	ctx := kong.Parse(&conf,
+	for _, el := range ctx.Path {
+		if el.Flag != nil && el.Flag.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// kongOptions returns options of Telemetry Agent command line parser.
+func kongOptions() []kong.Option {
+	return []kong.Option{
 		kong.Name("telemetry-agent"),
 		kong.Description("Percona Telemetry Agent gathers information from running Percona Pillar products, about the host and installed Percona software and sends it to Percona Platform."),
 		kong.UsageOnError(),
@@ -95,7 +514,26 @@ func InitConfig() Config {
 		kong.Vars{
 			"version": Version,
 		},
-	)
+		kong.Exit(func(code int) {
+			// kong exits only on parsing or validation errors here (and with 0 after printing help).
+			if code != 0 {
+				code = ExitCodeConfig
+			}
+
+			os.Exit(code)
+		}),
+	}
+}
+
+// InitConfig parses Telemetry Agent configuration parameters.
+// If some parameters are not defined - default values are used instead.
+func InitConfig() Config {
+	var conf Config
+
+	ctx := kong.Parse(&conf, kongOptions()...)
+
+	// check interval set explicitly is not overridden by remote configuration.
+	conf.Telemetry.CheckIntervalSet = flagSet(ctx, "telemetry.check-interval", telemetryCheckInterval)
 
 	if len(conf.Telemetry.RootPath) == 0 {
 		ctx.Fatalf("No telemetry root path was specified. You must specify the path with the --telemetry.rootPath command argument or the PERCONA_TELEMETRY_ROOT_PATH environment variable")
@@ -106,22 +544,386 @@ func InitConfig() Config {
 		ctx.Fatalf("No Percona Platform URL was specified for sending Pillars telemetry. You must specify the path with the --platform.url command argument or the PERCONA_TELEMETRY_URL environment variable")
 	}
 
-	u, err := url.ParseRequestURI(conf.Platform.URL)
+	_, err := url.ParseRequestURI(conf.Platform.URL)
 	if err != nil {
 		ctx.Fatalf("Invalid Percona Platform Telemetry URL: %q", err)
 	}
 
-	if u.Scheme == "" || u.Host == "" {
+	if !isPlatformURL(conf.Platform.URL) {
 		ctx.Fatalf("Invalid Percona Platform Telemetry URL: scheme or host is missed")
 	}
 
-	conf.Telemetry.PSMetricsPath = filepath.Join(conf.Telemetry.RootPath, "ps")
-	conf.Telemetry.PBSMetricsPath = filepath.Join(conf.Telemetry.RootPath, "pbs")
-	conf.Telemetry.PSMDBMongodMetricsPath = filepath.Join(conf.Telemetry.RootPath, "psmdb")
-	conf.Telemetry.PSMDBMongosMetricsPath = filepath.Join(conf.Telemetry.RootPath, "psmdbs")
-	conf.Telemetry.PXCMetricsPath = filepath.Join(conf.Telemetry.RootPath, "pxc")
-	conf.Telemetry.PGMetricsPath = filepath.Join(conf.Telemetry.RootPath, "pg")
-	conf.Telemetry.HistoryPath = filepath.Join(conf.Telemetry.RootPath, "history")
+	for familyName, familyURL := range conf.Platform.FamilyURLs {
+		productFamily, ok := parseProductFamily(familyName)
+		if !ok {
+			ctx.Fatalf("Invalid product family %q for Percona Platform URL %q", familyName, familyURL)
+		}
+
+		if !isPlatformURL(familyURL) {
+			ctx.Fatalf("Invalid Percona Platform Telemetry URL %q for product family %q", familyURL, familyName)
+		}
+
+		if _, ok := conf.Platform.Routes[productFamily]; ok {
+			ctx.Fatalf("Duplicate Percona Platform URL for product family %q", familyName)
+		}
+
+		if conf.Platform.Routes == nil {
+			conf.Platform.Routes = make(map[platformReporter.ProductFamily]string, len(conf.Platform.FamilyURLs))
+		}
+
+		conf.Platform.Routes[productFamily] = familyURL
+	}
+
+	if conf.Platform.RemoteConfigURL != "" {
+		u, err := url.ParseRequestURI(conf.Platform.RemoteConfigURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			ctx.Fatalf("Invalid Percona Platform remote configuration URL: %q", conf.Platform.RemoteConfigURL)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(conf.Platform.RemoteConfigKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			ctx.Fatalf("Invalid remote configuration key: must be base64 encoded ed25519 public key")
+		}
+
+		conf.Platform.RemoteConfigPublicKey = key
+	}
+
+	if conf.Platform.CredentialCommand != "" && conf.Platform.VaultAddr != "" {
+		ctx.Fatalf("Invalid Percona Platform credentials options: credential command and Vault can't be used at the same time")
+	}
+
+	if conf.Platform.VaultAddr != "" {
+		u, err := url.ParseRequestURI(conf.Platform.VaultAddr)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			ctx.Fatalf("Invalid Vault address: %q", conf.Platform.VaultAddr)
+		}
+
+		if conf.Platform.VaultSecretPath == "" {
+			ctx.Fatalf("No Vault secret path was specified. You must specify it with the --platform.vault-secret-path command argument or the PERCONA_TELEMETRY_VAULT_SECRET_PATH environment variable")
+		}
+	}
+
+	if conf.Telemetry.PackageQueryWorkers < 1 {
+		ctx.Fatalf("Invalid number of package query workers: %d, must be greater than 0", conf.Telemetry.PackageQueryWorkers)
+	}
+
+	if conf.Telemetry.CmdTimeout < 1 {
+		ctx.Fatalf("Invalid command timeout: %d, must be greater than 0", conf.Telemetry.CmdTimeout)
+	}
+
+	if conf.Telemetry.RepoqueryTimeout < 1 {
+		ctx.Fatalf("Invalid repoquery timeout: %d, must be greater than 0", conf.Telemetry.RepoqueryTimeout)
+	}
+
+	if conf.Telemetry.SendWorkers < 1 {
+		ctx.Fatalf("Invalid number of send workers: %d, must be greater than 0", conf.Telemetry.SendWorkers)
+	}
+
+	if conf.Telemetry.MaxSendAttempts < 0 {
+		ctx.Fatalf("Invalid max send attempts: %d, must not be negative", conf.Telemetry.MaxSendAttempts)
+	}
+
+	if conf.Telemetry.SendSpread < 0 || conf.Telemetry.SendSpread >= conf.Telemetry.CheckInterval {
+		ctx.Fatalf("Invalid send spread: %d, must not be negative and must be less than check interval", conf.Telemetry.SendSpread)
+	}
+
+	if conf.Telemetry.BatchSize < 0 {
+		ctx.Fatalf("Invalid batch size: %d, must not be negative", conf.Telemetry.BatchSize)
+	}
+
+	if conf.Telemetry.MaxClockSkew < 0 {
+		ctx.Fatalf("Invalid max clock skew: %d, must not be negative", conf.Telemetry.MaxClockSkew)
+	}
+
+	if conf.Telemetry.WatchDelay < 1 {
+		ctx.Fatalf("Invalid watch delay: %d, must be greater than 0", conf.Telemetry.WatchDelay)
+	}
+
+	if conf.Telemetry.MergeWindow < 0 {
+		ctx.Fatalf("Invalid merge window: %d, must not be negative", conf.Telemetry.MergeWindow)
+	}
+
+	if conf.Telemetry.HistoryMaxSize < 0 {
+		ctx.Fatalf("Invalid history max size: %d, must not be negative", conf.Telemetry.HistoryMaxSize)
+	}
+
+	if conf.Telemetry.HistoryMaxFiles < 0 {
+		ctx.Fatalf("Invalid history max files: %d, must not be negative", conf.Telemetry.HistoryMaxFiles)
+	}
+
+	for _, pattern := range conf.Telemetry.ScrubPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			ctx.Fatalf("Invalid scrub pattern %q: %q", pattern, err)
+		}
+	}
+
+	for _, pattern := range conf.Telemetry.HashKeys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			ctx.Fatalf("Invalid hash key pattern %q: %q", pattern, err)
+		}
+	}
+
+	for _, pattern := range conf.Telemetry.ScrubKeys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			ctx.Fatalf("Invalid scrub key pattern %q: %q", pattern, err)
+		}
+	}
+
+	if conf.Telemetry.SelfReport {
+		if conf.Telemetry.SelfReportFamily == "" {
+			ctx.Fatalf("Self-report requires product family: Percona Platform doesn't define product family of Telemetry Agent yet")
+		}
+
+		productFamily, ok := parseProductFamily(conf.Telemetry.SelfReportFamily)
+		if !ok {
+			ctx.Fatalf("Invalid self-report product family %q", conf.Telemetry.SelfReportFamily)
+		}
+
+		conf.Telemetry.SelfReportProductFamily = productFamily
+	}
+
+	if conf.Telemetry.DedupeWindow < 0 {
+		ctx.Fatalf("Invalid dedupe window: %d, must not be negative", conf.Telemetry.DedupeWindow)
+	}
+
+	if conf.Telemetry.MaxMetricsAge < 0 {
+		ctx.Fatalf("Invalid max metrics age: %d, must not be negative", conf.Telemetry.MaxMetricsAge)
+	}
+
+	if conf.Log.MaxSize < 1 {
+		ctx.Fatalf("Invalid log file max size: %d, must be greater than 0", conf.Log.MaxSize)
+	}
+
+	if conf.Log.MaxBackups < 0 {
+		ctx.Fatalf("Invalid log file max backups: %d, must not be negative", conf.Log.MaxBackups)
+	}
+
+	if conf.Log.MaxAge < 0 {
+		ctx.Fatalf("Invalid log file max age: %d, must not be negative", conf.Log.MaxAge)
+	}
+
+	if conf.Log.SamplingFirst < 0 {
+		ctx.Fatalf("Invalid log sampling first: %d, must not be negative", conf.Log.SamplingFirst)
+	}
+
+	if conf.Log.SamplingInterval < 1 {
+		ctx.Fatalf("Invalid log sampling interval: %d, must be greater than 0", conf.Log.SamplingInterval)
+	}
+
+	for _, pattern := range conf.Log.RedactKeys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			ctx.Fatalf("Invalid log redact key pattern %q: %q", pattern, err)
+		}
+	}
+
+	if conf.Debug.PprofAddr != "" && !isLoopbackAddr(conf.Debug.PprofAddr) {
+		ctx.Fatalf("Invalid pprof address: %q, must be loopback address, e.g. 'localhost:6060'", conf.Debug.PprofAddr)
+	}
+
+	if conf.Debug.RecordDir != "" && conf.Debug.ReplayDir != "" {
+		ctx.Fatalf("Invalid debug options: commands output can't be recorded and replayed at the same time")
+	}
+
+	if conf.Push.Addr != "" && !isLoopbackAddr(conf.Push.Addr) {
+		ctx.Fatalf("Invalid push address: %q, must be loopback address, e.g. 'localhost:8079'", conf.Push.Addr)
+	}
+
+	if conf.Tracing.Endpoint != "" {
+		u, err := url.ParseRequestURI(conf.Tracing.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			ctx.Fatalf("Invalid tracing endpoint: %q, must be http or https URL, e.g. 'http://localhost:4318'", conf.Tracing.Endpoint)
+		}
+	}
+
+	if conf.History.Prune.OlderThan < 0 {
+		ctx.Fatalf("Invalid history prune interval: %d, must not be negative", conf.History.Prune.OlderThan)
+	}
+
+	conf.Command = ctx.Command()
+
+	conf.Telemetry.setRootPaths()
+	conf.Telemetry.StatusSocketPath = filepath.Join(conf.Telemetry.RootPath, "telemetry-agent.sock")
+	conf.Telemetry.ErrorCountersPath = filepath.Join(conf.Telemetry.RootPath, "error_counters.json")
+
+	for i, rootPath := range conf.Telemetry.ExtraRootPaths {
+		if len(rootPath) == 0 || filepath.Clean(rootPath) == filepath.Clean(conf.Telemetry.RootPath) ||
+			slices.ContainsFunc(conf.Telemetry.ExtraRootPaths[:i], func(p string) bool { return filepath.Clean(p) == filepath.Clean(rootPath) }) {
+			ctx.Fatalf("Invalid extra telemetry root path: %q, must be a unique path different from telemetry root path", rootPath)
+		}
+	}
+
+	if len(conf.Telemetry.ExtraRootPaths) != 0 && conf.Kubernetes.Enabled {
+		ctx.Fatalf("Extra telemetry root paths are not supported in Kubernetes mode, pod UID is the only instance ID")
+	}
+
+	for name := range conf.Telemetry.Tags {
+		if !tagNameRe.MatchString(name) {
+			ctx.Fatalf("Invalid tag name: %q, must be non-empty and contain only letters, digits, '_', '-' and '.'", name)
+		}
+	}
+
+	conf.Telemetry.Pillars = []PillarOpts{
+		{Name: "PS", Path: conf.Telemetry.PSMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS},
+		{Name: "PBS", Path: conf.Telemetry.PBSMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PBS},
+		{Name: "PXC", Path: conf.Telemetry.PXCMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PXC},
+		{Name: "PSMDB (mongod)", Path: conf.Telemetry.PSMDBMongodMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB},
+		// mongos telemetry is reported within the same product family as mongod one.
+		{Name: "PSMDB (mongos)", Path: conf.Telemetry.PSMDBMongosMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB},
+		{Name: "PBM", Path: conf.Telemetry.PBMMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PBM},
+		{Name: "PMM Client", Path: conf.Telemetry.PMMMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PMM},
+		{Name: "PG", Path: conf.Telemetry.PGMetricsPath, ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL},
+	}
+
+	// sort extra Pillars by name to process them in predictable order.
+	extraPillarDirs := make([]string, 0, len(conf.Telemetry.ExtraPillars))
+	for dir := range conf.Telemetry.ExtraPillars {
+		extraPillarDirs = append(extraPillarDirs, dir)
+	}
+
+	slices.Sort(extraPillarDirs)
+
+	for _, dir := range extraPillarDirs {
+		pillarPath := filepath.Join(conf.Telemetry.RootPath, dir)
+		if len(dir) == 0 || filepath.Base(pillarPath) != dir || slices.Contains([]string{conf.Telemetry.HistoryPath, conf.Telemetry.SchemasPath, conf.Telemetry.QuarantinePath, conf.Telemetry.FailedPath, conf.Telemetry.ProcessingPath}, pillarPath) {
+			ctx.Fatalf("Invalid extra Pillar directory name: %q, must be a single directory name inside telemetry root path", dir)
+		}
+
+		if slices.ContainsFunc(conf.Telemetry.Pillars, func(p PillarOpts) bool { return p.Path == pillarPath }) {
+			ctx.Fatalf("Invalid extra Pillar directory name: %q, the directory is already used by another Pillar", dir)
+		}
+
+		productFamily, ok := parseProductFamily(conf.Telemetry.ExtraPillars[dir])
+		if !ok {
+			ctx.Fatalf("Invalid product family %q for extra Pillar directory %q", conf.Telemetry.ExtraPillars[dir], dir)
+		}
+
+		conf.Telemetry.Pillars = append(conf.Telemetry.Pillars, PillarOpts{
+			Name:          dir,
+			Path:          pillarPath,
+			ProductFamily: productFamily,
+		})
+	}
+
+	if conf.Command == CommandSendFile {
+		productFamily, ok := resolveProductFamily(conf.Telemetry.Pillars, conf.SendFile.Family)
+		if !ok {
+			ctx.Fatalf("Invalid metrics file family %q, must be Pillar metrics directory name (e.g. 'pg') or product family name (e.g. 'POSTGRESQL')", conf.SendFile.Family)
+		}
+
+		conf.SendFile.ProductFamily = productFamily
+	}
 
 	return conf
 }
+
+// setRootPaths sets paths inside telemetry root path.
+func (t *TelemetryOpts) setRootPaths() {
+	t.PSMetricsPath = filepath.Join(t.RootPath, "ps")
+	t.PBSMetricsPath = filepath.Join(t.RootPath, "pbs")
+	t.PSMDBMongodMetricsPath = filepath.Join(t.RootPath, "psmdb")
+	t.PSMDBMongosMetricsPath = filepath.Join(t.RootPath, "psmdbs")
+	t.PXCMetricsPath = filepath.Join(t.RootPath, "pxc")
+	t.PGMetricsPath = filepath.Join(t.RootPath, "pg")
+	t.PBMMetricsPath = filepath.Join(t.RootPath, "pbm")
+	t.PMMMetricsPath = filepath.Join(t.RootPath, "pmm")
+	t.HistoryPath = filepath.Join(t.RootPath, "history")
+	t.SchemasPath = filepath.Join(t.RootPath, "schemas")
+	t.QuarantinePath = filepath.Join(t.RootPath, "quarantine")
+	t.FailedPath = filepath.Join(t.RootPath, "failed")
+	t.ProcessingPath = filepath.Join(t.RootPath, "processing")
+	t.LastRunPath = filepath.Join(t.RootPath, "last_run.json")
+	t.StateDBPath = filepath.Join(t.RootPath, "state.db")
+	t.HashSaltPath = filepath.Join(t.RootPath, "hash_salt")
+	t.FirstSeenPath = filepath.Join(t.RootPath, "first_seen")
+	t.InstanceIDChangesPath = filepath.Join(t.RootPath, "instance_id_changes.json")
+}
+
+// Roots returns configurations of telemetry root path and of each extra telemetry root path, in that order.
+func (c Config) Roots() []Config {
+	roots := make([]Config, 0, 1+len(c.Telemetry.ExtraRootPaths))
+	roots = append(roots, c)
+
+	for _, rootPath := range c.Telemetry.ExtraRootPaths {
+		roots = append(roots, c.ForRoot(rootPath))
+	}
+
+	return roots
+}
+
+// ForRoot returns configuration processing extra telemetry root path the same way as the telemetry root path.
+// Pillars metrics directories, history and other paths are inside rootPath, and instance ID is stored in
+// InstanceFileName file inside it. Status socket and error counters are shared by all root paths.
+func (c Config) ForRoot(rootPath string) Config {
+	c.Telemetry.RootPath = rootPath
+	c.Telemetry.ExtraRootPaths = nil
+	c.Telemetry.setRootPaths()
+	c.Telemetry.InstanceFile = filepath.Join(rootPath, InstanceFileName)
+
+	pillars := make([]PillarOpts, 0, len(c.Telemetry.Pillars))
+	for _, pillar := range c.Telemetry.Pillars {
+		pillar.Path = filepath.Join(rootPath, filepath.Base(pillar.Path))
+		pillars = append(pillars, pillar)
+	}
+
+	c.Telemetry.Pillars = pillars
+
+	return c
+}
+
+// resolveProductFamily returns product family of Pillar with metrics directory name (e.g. 'pg'),
+// name is treated as product family name if there is no such Pillar.
+func resolveProductFamily(pillars []PillarOpts, name string) (platformReporter.ProductFamily, bool) {
+	for _, pillar := range pillars {
+		if strings.EqualFold(filepath.Base(pillar.Path), name) {
+			return pillar.ProductFamily, true
+		}
+	}
+
+	return parseProductFamily(name)
+}
+
+// parseProductFamily converts product family name (e.g. 'PS' or 'PRODUCT_FAMILY_PS') into its Percona Platform value.
+func parseProductFamily(name string) (platformReporter.ProductFamily, bool) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "PRODUCT_FAMILY_") {
+		name = "PRODUCT_FAMILY_" + name
+	}
+
+	value, ok := platformReporter.ProductFamily_value[name]
+	if !ok || value == int32(platformReporter.ProductFamily_PRODUCT_FAMILY_INVALID) {
+		return platformReporter.ProductFamily_PRODUCT_FAMILY_INVALID, false
+	}
+
+	return platformReporter.ProductFamily(value), true
+}
+
+// isPlatformURL returns true if rawURL is Percona Platform URL with scheme and host
+// or unix:// URL with absolute path of local relay socket, e.g. unix:///var/run/telemetry-relay.sock.
+func isPlatformURL(rawURL string) bool {
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme == UnixSocketScheme {
+		return u.Host == "" && path.IsAbs(u.Path)
+	}
+
+	return u.Scheme != "" && u.Host != ""
+}
+
+// isLoopbackAddr returns true if host of addr (host:port) is localhost or loopback IP address.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
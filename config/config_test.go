@@ -19,8 +19,10 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,18 +48,65 @@ func TestInitConfig(t *testing.T) { //nolint:paralleltest
 					PSMDBMongosMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdbs"),
 					PXCMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pxc"),
 					PGMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "pg"),
+					PBMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbm"),
+					PMMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pmm"),
 					CheckInterval:          telemetryCheckIntervalDefault,
 					HistoryPath:            filepath.Join("/usr", "local", "percona", "telemetry", "history"),
+					SchemasPath:            filepath.Join("/usr", "local", "percona", "telemetry", "schemas"),
+					QuarantinePath:         filepath.Join("/usr", "local", "percona", "telemetry", "quarantine"),
+					StatusSocketPath:       filepath.Join("/usr", "local", "percona", "telemetry", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/usr", "local", "percona", "telemetry", "failed"),
+					ProcessingPath:         filepath.Join("/usr", "local", "percona", "telemetry", "processing"),
+					LastRunPath:            filepath.Join("/usr", "local", "percona", "telemetry", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/usr", "local", "percona", "telemetry", "error_counters.json"),
+					StateDBPath:            filepath.Join("/usr", "local", "percona", "telemetry", "state.db"),
+					HashSaltPath:           filepath.Join("/usr", "local", "percona", "telemetry", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/usr", "local", "percona", "telemetry", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/usr", "local", "percona", "telemetry", "instance_id_changes.json"),
 					HistoryKeepInterval:    historyKeepIntervalDefault,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault,
+					PackageQueryWorkers:    packageQueryWorkersDefault,
+					CmdTimeout:             cmdTimeoutDefault,
+					RepoqueryTimeout:       repoqueryTimeoutDefault,
+					WatchDelay:             watchDelayDefault,
+					SendWorkers:            sendWorkersDefault,
+					MaxSendAttempts:        maxSendAttemptsDefault,
+					BatchSize:              batchSizeDefault,
+					MaxClockSkew:           maxClockSkewDefault,
+					DisableFile:            disableFileDefault,
+					Group:                  telemetryGroupDefault,
+					Pillars:                expectedPillars(filepath.Join("/usr", "local", "percona", "telemetry")),
 				},
 				Platform: PlatformOpts{
 					ResendTimeout: telemetryResendIntervalDefault,
 					URL:           perconaTelemetryURLDefault,
+					Compression:   compressionDefault,
 				},
 				Log: LogOpts{
-					Verbose: false,
-					DevMode: false,
+					Verbose:          false,
+					DevMode:          false,
+					MaxSize:          logMaxSizeDefault,
+					MaxBackups:       logMaxBackupsDefault,
+					MaxAge:           logMaxAgeDefault,
+					SyslogFacility:   logSyslogFacilityDefault,
+					SyslogTag:        logSyslogTagDefault,
+					RedactKeys:       strings.Split(logRedactKeysDefault, ","),
+					SamplingFirst:    logSamplingFirstDefault,
+					SamplingInterval: logSamplingIntervalDefault,
+					Color:            logColorDefault,
+					TimeFormat:       logTimeFormatDefault,
 				},
+				Kubernetes: KubernetesOpts{
+					PodInfoPath: kubernetesPodInfoPathDefault,
+				},
+				Packages: PackagesCmd{
+					Format: "json",
+				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				Command: CommandRun,
 			},
 		},
 		{
@@ -71,7 +120,68 @@ func TestInitConfig(t *testing.T) { //nolint:paralleltest
 				t.Setenv(telemetryCheckInterval, strconv.Itoa(telemetryCheckIntervalDefault*2))
 				t.Setenv(telemetryResendInterval, strconv.Itoa(telemetryResendIntervalDefault*3))
 				t.Setenv(telemetryHistoryKeepInterval, strconv.Itoa(historyKeepIntervalDefault*4))
+				t.Setenv(telemetryQuarantineKeepInterval, strconv.Itoa(quarantineKeepIntervalDefault*2))
 				t.Setenv(telemetryURL, "https://check.percona.com/v1/telemetry/GenericReport2")
+				t.Setenv(telemetryPackageQueryWorkers, strconv.Itoa(packageQueryWorkersDefault*2))
+				t.Setenv(telemetryCmdTimeout, strconv.Itoa(cmdTimeoutDefault*2))
+				t.Setenv(telemetryRepoqueryTimeout, strconv.Itoa(repoqueryTimeoutDefault*3))
+				t.Setenv(telemetryWatch, "true")
+				t.Setenv(telemetryFlattenNested, "true")
+				t.Setenv(telemetryWatchDelay, strconv.Itoa(watchDelayDefault*2))
+				t.Setenv(telemetryMergeWindow, "3600")
+				t.Setenv(telemetryMaxMetricsAge, "7776000")
+				t.Setenv(telemetryHistoryCompress, "true")
+				t.Setenv(telemetryHistoryMaxSize, "100")
+				t.Setenv(telemetryHistoryMaxFiles, "50")
+				t.Setenv(telemetryDedupeWindow, "3600")
+				t.Setenv(telemetryKeepMetricsFiles, "true")
+				t.Setenv(telemetryRunOnStart, "true")
+				t.Setenv(telemetrySendWorkers, strconv.Itoa(sendWorkersDefault*4))
+				t.Setenv(telemetryMaxSendAttempts, strconv.Itoa(maxSendAttemptsDefault*2))
+				t.Setenv(telemetryBatchSize, strconv.Itoa(batchSizeDefault*5))
+				t.Setenv(telemetryMaxClockSkew, strconv.Itoa(maxClockSkewDefault*2))
+				t.Setenv(telemetrySendSpread, "3600")
+				t.Setenv(telemetryDisable, "1")
+				t.Setenv(telemetryDisableFile, "/tmp/telemetry_disabled")
+				t.Setenv(telemetryScrubRules, "ip,email")
+				t.Setenv(telemetryScrubPatterns, `customer-\d{1,3};secret`)
+				t.Setenv(telemetryScrubKeys, "*_host")
+				t.Setenv(telemetryHashKeys, "db_instance_id")
+				t.Setenv(telemetryHashSalt, "fleet-salt")
+				t.Setenv(telemetryUser, "percona-telemetry")
+				t.Setenv(telemetryGroup, "mysql")
+				t.Setenv(telemetryFixPermissions, "true")
+				t.Setenv(telemetrySelfReport, "true")
+				t.Setenv(telemetrySelfReportFamily, "PMM")
+				t.Setenv(telemetryOneshot, "true")
+				t.Setenv(telemetryDryRun, "true")
+				t.Setenv(telemetryHealthAddr, "127.0.0.1:8080")
+				t.Setenv(telemetryDebugPprofAddr, "localhost:6060")
+				t.Setenv(telemetryDebugRecordDir, "/tmp/percona/record")
+				t.Setenv(telemetryPushAddr, "127.0.0.1:8079")
+				t.Setenv(telemetryIngestSocket, "/tmp/percona/telemetry-ingest.sock")
+				t.Setenv(telemetrySecuritySandbox, "true")
+				t.Setenv(telemetryTracingEndpoint, "http://localhost:4318")
+				t.Setenv(telemetryKubernetes, "true")
+				t.Setenv(telemetryKubernetesPodInfoPath, "/etc/pod-metadata")
+				t.Setenv(telemetryLogFile, "/var/log/percona/telemetry-agent/telemetry-agent.log")
+				t.Setenv(telemetryAuditLog, "/var/log/percona/telemetry-agent/audit.log")
+				t.Setenv(telemetryRemoteConfigURL, "https://check.percona.com/v1/telemetry/RemoteConfig")
+				t.Setenv(telemetryRemoteConfigKey, "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+				t.Setenv(telemetryCompression, "zstd")
+				t.Setenv(telemetryCredentialCommand, "/usr/local/bin/percona-credentials --format json")
+				t.Setenv(telemetryLogMaxSize, "10")
+				t.Setenv(telemetryLogMaxBackups, "2")
+				t.Setenv(telemetryLogMaxAge, "7")
+				t.Setenv(telemetryLogSyslog, "true")
+				t.Setenv(telemetryLogSyslogFacility, "local3")
+				t.Setenv(telemetryLogSyslogTag, "telemetry")
+				t.Setenv(telemetryLogJournald, "true")
+				t.Setenv(telemetryLogRedactKeys, "*token*,x-api-key")
+				t.Setenv(telemetryLogSamplingFirst, "0")
+				t.Setenv(telemetryLogSamplingInterval, "300")
+				t.Setenv(telemetryLogColor, "never")
+				t.Setenv(telemetryLogTimeFormat, "epoch")
 			},
 			expectedConfig: Config{
 				Telemetry: TelemetryOpts{
@@ -82,18 +192,120 @@ func TestInitConfig(t *testing.T) { //nolint:paralleltest
 					PSMDBMongosMetricsPath: filepath.Join("/tmp", "percona", "psmdbs"),
 					PXCMetricsPath:         filepath.Join("/tmp", "percona", "pxc"),
 					PGMetricsPath:          filepath.Join("/tmp", "percona", "pg"),
+					PBMMetricsPath:         filepath.Join("/tmp", "percona", "pbm"),
+					PMMMetricsPath:         filepath.Join("/tmp", "percona", "pmm"),
 					CheckInterval:          telemetryCheckIntervalDefault * 2,
+					CheckIntervalSet:       true,
 					HistoryPath:            filepath.Join("/tmp", "percona", "history"),
+					SchemasPath:            filepath.Join("/tmp", "percona", "schemas"),
+					QuarantinePath:         filepath.Join("/tmp", "percona", "quarantine"),
+					StatusSocketPath:       filepath.Join("/tmp", "percona", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/tmp", "percona", "failed"),
+					ProcessingPath:         filepath.Join("/tmp", "percona", "processing"),
+					LastRunPath:            filepath.Join("/tmp", "percona", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/tmp", "percona", "error_counters.json"),
+					StateDBPath:            filepath.Join("/tmp", "percona", "state.db"),
+					HashSaltPath:           filepath.Join("/tmp", "percona", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/tmp", "percona", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/tmp", "percona", "instance_id_changes.json"),
 					HistoryKeepInterval:    historyKeepIntervalDefault * 4,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault * 2,
+					PackageQueryWorkers:    packageQueryWorkersDefault * 2,
+					CmdTimeout:             cmdTimeoutDefault * 2,
+					RepoqueryTimeout:       repoqueryTimeoutDefault * 3,
+					Watch:                  true,
+					FlattenNested:          true,
+					WatchDelay:             watchDelayDefault * 2,
+					SendWorkers:            sendWorkersDefault * 4,
+					MaxSendAttempts:        maxSendAttemptsDefault * 2,
+					BatchSize:              batchSizeDefault * 5,
+					MaxClockSkew:           maxClockSkewDefault * 2,
+					SendSpread:             3600,
+					Disable:                true,
+					DisableFile:            "/tmp/telemetry_disabled",
+					Group:                  "mysql",
+					FixPermissions:         true,
+					SelfReport:             true,
+					SelfReportFamily:       "PMM",
+					ScrubRules:             []string{"ip", "email"},
+					ScrubPatterns:          []string{`customer-\d{1,3}`, "secret"},
+					ScrubKeys:              []string{"*_host"},
+					HashKeys:               []string{"db_instance_id"},
+					HashSalt:               "fleet-salt",
+					User:                   "percona-telemetry",
+					MergeWindow:            3600,
+					MaxMetricsAge:          7776000,
+					HistoryCompress:        true,
+					HistoryMaxSize:         100,
+					HistoryMaxFiles:        50,
+					DedupeWindow:           3600,
+					KeepMetricsFiles:       true,
+					RunOnStart:             true,
+					Pillars:                expectedPillars(filepath.Join("/tmp", "percona")),
+
+					// self-report is enabled, so its product family is resolved.
+					SelfReportProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PMM,
 				},
 				Platform: PlatformOpts{
-					ResendTimeout: telemetryResendIntervalDefault * 3,
-					URL:           "https://check.percona.com/v1/telemetry/GenericReport2",
+					ResendTimeout:         telemetryResendIntervalDefault * 3,
+					URL:                   "https://check.percona.com/v1/telemetry/GenericReport2",
+					AuditLog:              "/var/log/percona/telemetry-agent/audit.log",
+					Compression:           "zstd",
+					RemoteConfigURL:       "https://check.percona.com/v1/telemetry/RemoteConfig",
+					RemoteConfigKey:       "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+					RemoteConfigPublicKey: make([]byte, 32),
+					CredentialCommand:     "/usr/local/bin/percona-credentials --format json",
 				},
 				Log: LogOpts{
-					Verbose: false,
-					DevMode: false,
+					Verbose:          false,
+					DevMode:          false,
+					File:             "/var/log/percona/telemetry-agent/telemetry-agent.log",
+					MaxSize:          10,
+					MaxBackups:       2,
+					MaxAge:           7,
+					Syslog:           true,
+					SyslogFacility:   "local3",
+					SyslogTag:        "telemetry",
+					Journald:         true,
+					RedactKeys:       []string{"*token*", "x-api-key"},
+					SamplingFirst:    0,
+					SamplingInterval: 300,
+					Color:            "never",
+					TimeFormat:       "epoch",
+				},
+				Health: HealthOpts{
+					Addr: "127.0.0.1:8080",
+				},
+				Debug: DebugOpts{
+					PprofAddr: "localhost:6060",
+					RecordDir: "/tmp/percona/record",
+				},
+				Security: SecurityOpts{
+					Sandbox: true,
+				},
+				Tracing: TracingOpts{
+					Endpoint: "http://localhost:4318",
+				},
+				Kubernetes: KubernetesOpts{
+					Enabled:     true,
+					PodInfoPath: "/etc/pod-metadata",
+				},
+				Push: PushOpts{
+					Addr: "127.0.0.1:8079",
+				},
+				Ingest: IngestOpts{
+					Socket: "/tmp/percona/telemetry-ingest.sock",
+				},
+				Oneshot: true,
+				DryRun:  true,
+				Packages: PackagesCmd{
+					Format: "json",
 				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				Command: CommandRun,
 			},
 		},
 		{
@@ -116,18 +328,415 @@ func TestInitConfig(t *testing.T) { //nolint:paralleltest
 					PSMDBMongosMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdbs"),
 					PXCMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pxc"),
 					PGMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "pg"),
+					PBMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbm"),
+					PMMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pmm"),
 					CheckInterval:          telemetryCheckIntervalDefault * 2,
+					CheckIntervalSet:       true,
 					HistoryPath:            filepath.Join("/usr", "local", "percona", "telemetry", "history"),
+					SchemasPath:            filepath.Join("/usr", "local", "percona", "telemetry", "schemas"),
+					QuarantinePath:         filepath.Join("/usr", "local", "percona", "telemetry", "quarantine"),
+					StatusSocketPath:       filepath.Join("/usr", "local", "percona", "telemetry", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/usr", "local", "percona", "telemetry", "failed"),
+					ProcessingPath:         filepath.Join("/usr", "local", "percona", "telemetry", "processing"),
+					LastRunPath:            filepath.Join("/usr", "local", "percona", "telemetry", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/usr", "local", "percona", "telemetry", "error_counters.json"),
+					StateDBPath:            filepath.Join("/usr", "local", "percona", "telemetry", "state.db"),
+					HashSaltPath:           filepath.Join("/usr", "local", "percona", "telemetry", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/usr", "local", "percona", "telemetry", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/usr", "local", "percona", "telemetry", "instance_id_changes.json"),
 					HistoryKeepInterval:    historyKeepIntervalDefault,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault,
+					PackageQueryWorkers:    packageQueryWorkersDefault,
+					CmdTimeout:             cmdTimeoutDefault,
+					RepoqueryTimeout:       repoqueryTimeoutDefault,
+					WatchDelay:             watchDelayDefault,
+					SendWorkers:            sendWorkersDefault,
+					MaxSendAttempts:        maxSendAttemptsDefault,
+					BatchSize:              batchSizeDefault,
+					MaxClockSkew:           maxClockSkewDefault,
+					DisableFile:            disableFileDefault,
+					Group:                  telemetryGroupDefault,
+					Pillars:                expectedPillars(filepath.Join("/usr", "local", "percona", "telemetry")),
 				},
 				Platform: PlatformOpts{
 					ResendTimeout: telemetryResendIntervalDefault * 3,
 					URL:           "https://check-dev.percona.com/v1/telemetry/GenericReport2",
+					Compression:   compressionDefault,
+				},
+				Log: LogOpts{
+					Verbose:          false,
+					DevMode:          false,
+					MaxSize:          logMaxSizeDefault,
+					MaxBackups:       logMaxBackupsDefault,
+					MaxAge:           logMaxAgeDefault,
+					SyslogFacility:   logSyslogFacilityDefault,
+					SyslogTag:        logSyslogTagDefault,
+					RedactKeys:       strings.Split(logRedactKeysDefault, ","),
+					SamplingFirst:    logSamplingFirstDefault,
+					SamplingInterval: logSamplingIntervalDefault,
+					Color:            logColorDefault,
+					TimeFormat:       logTimeFormatDefault,
+				},
+				Kubernetes: KubernetesOpts{
+					PodInfoPath: kubernetesPodInfoPathDefault,
+				},
+				Packages: PackagesCmd{
+					Format: "json",
+				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				Command: CommandRun,
+			},
+		},
+		{
+			name: "packages_command",
+			setupTestData: func(t *testing.T) {
+				t.Helper()
+
+				os.Args = []string{"", "packages", "--format", "table"}
+			},
+			expectedConfig: Config{
+				Telemetry: TelemetryOpts{
+					RootPath:               filepath.Join("/usr", "local", "percona", "telemetry"),
+					PSMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "ps"),
+					PBSMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbs"),
+					PSMDBMongodMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdb"),
+					PSMDBMongosMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdbs"),
+					PXCMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pxc"),
+					PGMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "pg"),
+					PBMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbm"),
+					PMMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pmm"),
+					CheckInterval:          telemetryCheckIntervalDefault,
+					HistoryPath:            filepath.Join("/usr", "local", "percona", "telemetry", "history"),
+					SchemasPath:            filepath.Join("/usr", "local", "percona", "telemetry", "schemas"),
+					QuarantinePath:         filepath.Join("/usr", "local", "percona", "telemetry", "quarantine"),
+					StatusSocketPath:       filepath.Join("/usr", "local", "percona", "telemetry", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/usr", "local", "percona", "telemetry", "failed"),
+					ProcessingPath:         filepath.Join("/usr", "local", "percona", "telemetry", "processing"),
+					LastRunPath:            filepath.Join("/usr", "local", "percona", "telemetry", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/usr", "local", "percona", "telemetry", "error_counters.json"),
+					StateDBPath:            filepath.Join("/usr", "local", "percona", "telemetry", "state.db"),
+					HashSaltPath:           filepath.Join("/usr", "local", "percona", "telemetry", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/usr", "local", "percona", "telemetry", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/usr", "local", "percona", "telemetry", "instance_id_changes.json"),
+					HistoryKeepInterval:    historyKeepIntervalDefault,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault,
+					PackageQueryWorkers:    packageQueryWorkersDefault,
+					CmdTimeout:             cmdTimeoutDefault,
+					RepoqueryTimeout:       repoqueryTimeoutDefault,
+					WatchDelay:             watchDelayDefault,
+					SendWorkers:            sendWorkersDefault,
+					MaxSendAttempts:        maxSendAttemptsDefault,
+					BatchSize:              batchSizeDefault,
+					MaxClockSkew:           maxClockSkewDefault,
+					DisableFile:            disableFileDefault,
+					Group:                  telemetryGroupDefault,
+					Pillars:                expectedPillars(filepath.Join("/usr", "local", "percona", "telemetry")),
+				},
+				Platform: PlatformOpts{
+					ResendTimeout: telemetryResendIntervalDefault,
+					URL:           perconaTelemetryURLDefault,
+					Compression:   compressionDefault,
+				},
+				Log: LogOpts{
+					Verbose:          false,
+					DevMode:          false,
+					MaxSize:          logMaxSizeDefault,
+					MaxBackups:       logMaxBackupsDefault,
+					MaxAge:           logMaxAgeDefault,
+					SyslogFacility:   logSyslogFacilityDefault,
+					SyslogTag:        logSyslogTagDefault,
+					RedactKeys:       strings.Split(logRedactKeysDefault, ","),
+					SamplingFirst:    logSamplingFirstDefault,
+					SamplingInterval: logSamplingIntervalDefault,
+					Color:            logColorDefault,
+					TimeFormat:       logTimeFormatDefault,
+				},
+				Kubernetes: KubernetesOpts{
+					PodInfoPath: kubernetesPodInfoPathDefault,
+				},
+				Packages: PackagesCmd{
+					Format: "table",
+				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				Command: CommandPackages,
+			},
+		},
+		{
+			name: "history_show_command",
+			setupTestData: func(t *testing.T) {
+				t.Helper()
+
+				os.Args = []string{"", "history", "show", "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json"}
+			},
+			expectedConfig: Config{
+				Telemetry: TelemetryOpts{
+					RootPath:               filepath.Join("/usr", "local", "percona", "telemetry"),
+					PSMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "ps"),
+					PBSMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbs"),
+					PSMDBMongodMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdb"),
+					PSMDBMongosMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdbs"),
+					PXCMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pxc"),
+					PGMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "pg"),
+					PBMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbm"),
+					PMMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pmm"),
+					CheckInterval:          telemetryCheckIntervalDefault,
+					HistoryPath:            filepath.Join("/usr", "local", "percona", "telemetry", "history"),
+					SchemasPath:            filepath.Join("/usr", "local", "percona", "telemetry", "schemas"),
+					QuarantinePath:         filepath.Join("/usr", "local", "percona", "telemetry", "quarantine"),
+					StatusSocketPath:       filepath.Join("/usr", "local", "percona", "telemetry", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/usr", "local", "percona", "telemetry", "failed"),
+					ProcessingPath:         filepath.Join("/usr", "local", "percona", "telemetry", "processing"),
+					LastRunPath:            filepath.Join("/usr", "local", "percona", "telemetry", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/usr", "local", "percona", "telemetry", "error_counters.json"),
+					StateDBPath:            filepath.Join("/usr", "local", "percona", "telemetry", "state.db"),
+					HashSaltPath:           filepath.Join("/usr", "local", "percona", "telemetry", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/usr", "local", "percona", "telemetry", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/usr", "local", "percona", "telemetry", "instance_id_changes.json"),
+					HistoryKeepInterval:    historyKeepIntervalDefault,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault,
+					PackageQueryWorkers:    packageQueryWorkersDefault,
+					CmdTimeout:             cmdTimeoutDefault,
+					RepoqueryTimeout:       repoqueryTimeoutDefault,
+					WatchDelay:             watchDelayDefault,
+					SendWorkers:            sendWorkersDefault,
+					MaxSendAttempts:        maxSendAttemptsDefault,
+					BatchSize:              batchSizeDefault,
+					MaxClockSkew:           maxClockSkewDefault,
+					DisableFile:            disableFileDefault,
+					Group:                  telemetryGroupDefault,
+					Pillars:                expectedPillars(filepath.Join("/usr", "local", "percona", "telemetry")),
+				},
+				Platform: PlatformOpts{
+					ResendTimeout: telemetryResendIntervalDefault,
+					URL:           perconaTelemetryURLDefault,
+					Compression:   compressionDefault,
+				},
+				Log: LogOpts{
+					Verbose:          false,
+					DevMode:          false,
+					MaxSize:          logMaxSizeDefault,
+					MaxBackups:       logMaxBackupsDefault,
+					MaxAge:           logMaxAgeDefault,
+					SyslogFacility:   logSyslogFacilityDefault,
+					SyslogTag:        logSyslogTagDefault,
+					RedactKeys:       strings.Split(logRedactKeysDefault, ","),
+					SamplingFirst:    logSamplingFirstDefault,
+					SamplingInterval: logSamplingIntervalDefault,
+					Color:            logColorDefault,
+					TimeFormat:       logTimeFormatDefault,
+				},
+				Kubernetes: KubernetesOpts{
+					PodInfoPath: kubernetesPodInfoPathDefault,
+				},
+				Packages: PackagesCmd{
+					Format: "json",
+				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				History: HistoryCmd{
+					Show: HistoryShowCmd{
+						File: "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json",
+					},
+				},
+				Command: CommandHistoryShow,
+			},
+		},
+		{
+			name: "extra_pillars_and_root_paths",
+			setupTestData: func(t *testing.T) {
+				t.Helper()
+
+				os.Args = []string{""}
+
+				t.Setenv(telemetryExtraPillars, "pxb=PXB,everest=PRODUCT_FAMILY_EVEREST")
+				t.Setenv(telemetryExtraRootPaths, "/tmp/tenant-1,/tmp/tenant-2")
+				t.Setenv(telemetryTags, "environment=prod,team=dba")
+			},
+			expectedConfig: Config{
+				Telemetry: TelemetryOpts{
+					RootPath:               filepath.Join("/usr", "local", "percona", "telemetry"),
+					PSMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "ps"),
+					PBSMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbs"),
+					PSMDBMongodMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdb"),
+					PSMDBMongosMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdbs"),
+					PXCMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pxc"),
+					PGMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "pg"),
+					PBMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbm"),
+					PMMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pmm"),
+					CheckInterval:          telemetryCheckIntervalDefault,
+					HistoryPath:            filepath.Join("/usr", "local", "percona", "telemetry", "history"),
+					SchemasPath:            filepath.Join("/usr", "local", "percona", "telemetry", "schemas"),
+					QuarantinePath:         filepath.Join("/usr", "local", "percona", "telemetry", "quarantine"),
+					StatusSocketPath:       filepath.Join("/usr", "local", "percona", "telemetry", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/usr", "local", "percona", "telemetry", "failed"),
+					ProcessingPath:         filepath.Join("/usr", "local", "percona", "telemetry", "processing"),
+					LastRunPath:            filepath.Join("/usr", "local", "percona", "telemetry", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/usr", "local", "percona", "telemetry", "error_counters.json"),
+					StateDBPath:            filepath.Join("/usr", "local", "percona", "telemetry", "state.db"),
+					HashSaltPath:           filepath.Join("/usr", "local", "percona", "telemetry", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/usr", "local", "percona", "telemetry", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/usr", "local", "percona", "telemetry", "instance_id_changes.json"),
+					HistoryKeepInterval:    historyKeepIntervalDefault,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault,
+					PackageQueryWorkers:    packageQueryWorkersDefault,
+					CmdTimeout:             cmdTimeoutDefault,
+					RepoqueryTimeout:       repoqueryTimeoutDefault,
+					WatchDelay:             watchDelayDefault,
+					SendWorkers:            sendWorkersDefault,
+					MaxSendAttempts:        maxSendAttemptsDefault,
+					BatchSize:              batchSizeDefault,
+					MaxClockSkew:           maxClockSkewDefault,
+					DisableFile:            disableFileDefault,
+					Group:                  telemetryGroupDefault,
+					ExtraPillars: map[string]string{
+						"pxb":     "PXB",
+						"everest": "PRODUCT_FAMILY_EVEREST",
+					},
+					ExtraRootPaths: []string{"/tmp/tenant-1", "/tmp/tenant-2"},
+					Tags: map[string]string{
+						"environment": "prod",
+						"team":        "dba",
+					},
+					Pillars: append(expectedPillars(filepath.Join("/usr", "local", "percona", "telemetry")),
+						PillarOpts{
+							Name:          "everest",
+							Path:          filepath.Join("/usr", "local", "percona", "telemetry", "everest"),
+							ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_EVEREST,
+						},
+						PillarOpts{
+							Name:          "pxb",
+							Path:          filepath.Join("/usr", "local", "percona", "telemetry", "pxb"),
+							ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PXB,
+						},
+					),
+				},
+				Platform: PlatformOpts{
+					ResendTimeout: telemetryResendIntervalDefault,
+					URL:           perconaTelemetryURLDefault,
+					Compression:   compressionDefault,
 				},
 				Log: LogOpts{
-					Verbose: false,
-					DevMode: false,
+					Verbose:          false,
+					DevMode:          false,
+					MaxSize:          logMaxSizeDefault,
+					MaxBackups:       logMaxBackupsDefault,
+					MaxAge:           logMaxAgeDefault,
+					SyslogFacility:   logSyslogFacilityDefault,
+					SyslogTag:        logSyslogTagDefault,
+					RedactKeys:       strings.Split(logRedactKeysDefault, ","),
+					SamplingFirst:    logSamplingFirstDefault,
+					SamplingInterval: logSamplingIntervalDefault,
+					Color:            logColorDefault,
+					TimeFormat:       logTimeFormatDefault,
 				},
+				Kubernetes: KubernetesOpts{
+					PodInfoPath: kubernetesPodInfoPathDefault,
+				},
+				Packages: PackagesCmd{
+					Format: "json",
+				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				Command: CommandRun,
+			},
+		},
+		{
+			name: "family_urls",
+			setupTestData: func(t *testing.T) {
+				t.Helper()
+
+				// check interval set to default value is not overridden by remote configuration either.
+				os.Args = []string{"", "--telemetry.check-interval", strconv.Itoa(telemetryCheckIntervalDefault)}
+
+				t.Setenv(telemetryFamilyURLs, "PMM=https://pmm.percona.com/v1/telemetry/GenericReport,PRODUCT_FAMILY_PS=https://ps.percona.com/v1/telemetry/GenericReport")
+			},
+			expectedConfig: Config{
+				Telemetry: TelemetryOpts{
+					RootPath:               filepath.Join("/usr", "local", "percona", "telemetry"),
+					PSMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "ps"),
+					PBSMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbs"),
+					PSMDBMongodMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdb"),
+					PSMDBMongosMetricsPath: filepath.Join("/usr", "local", "percona", "telemetry", "psmdbs"),
+					PXCMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pxc"),
+					PGMetricsPath:          filepath.Join("/usr", "local", "percona", "telemetry", "pg"),
+					PBMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pbm"),
+					PMMMetricsPath:         filepath.Join("/usr", "local", "percona", "telemetry", "pmm"),
+					CheckInterval:          telemetryCheckIntervalDefault,
+					CheckIntervalSet:       true,
+					HistoryPath:            filepath.Join("/usr", "local", "percona", "telemetry", "history"),
+					SchemasPath:            filepath.Join("/usr", "local", "percona", "telemetry", "schemas"),
+					QuarantinePath:         filepath.Join("/usr", "local", "percona", "telemetry", "quarantine"),
+					StatusSocketPath:       filepath.Join("/usr", "local", "percona", "telemetry", "telemetry-agent.sock"),
+					FailedPath:             filepath.Join("/usr", "local", "percona", "telemetry", "failed"),
+					ProcessingPath:         filepath.Join("/usr", "local", "percona", "telemetry", "processing"),
+					LastRunPath:            filepath.Join("/usr", "local", "percona", "telemetry", "last_run.json"),
+					ErrorCountersPath:      filepath.Join("/usr", "local", "percona", "telemetry", "error_counters.json"),
+					StateDBPath:            filepath.Join("/usr", "local", "percona", "telemetry", "state.db"),
+					HashSaltPath:           filepath.Join("/usr", "local", "percona", "telemetry", "hash_salt"),
+					FirstSeenPath:          filepath.Join("/usr", "local", "percona", "telemetry", "first_seen"),
+					InstanceIDChangesPath:  filepath.Join("/usr", "local", "percona", "telemetry", "instance_id_changes.json"),
+					HistoryKeepInterval:    historyKeepIntervalDefault,
+					QuarantineKeepInterval: quarantineKeepIntervalDefault,
+					PackageQueryWorkers:    packageQueryWorkersDefault,
+					CmdTimeout:             cmdTimeoutDefault,
+					RepoqueryTimeout:       repoqueryTimeoutDefault,
+					WatchDelay:             watchDelayDefault,
+					SendWorkers:            sendWorkersDefault,
+					MaxSendAttempts:        maxSendAttemptsDefault,
+					BatchSize:              batchSizeDefault,
+					MaxClockSkew:           maxClockSkewDefault,
+					DisableFile:            disableFileDefault,
+					Group:                  telemetryGroupDefault,
+					Pillars:                expectedPillars(filepath.Join("/usr", "local", "percona", "telemetry")),
+				},
+				Platform: PlatformOpts{
+					ResendTimeout: telemetryResendIntervalDefault,
+					URL:           perconaTelemetryURLDefault,
+					Compression:   compressionDefault,
+					FamilyURLs: map[string]string{
+						"PMM":               "https://pmm.percona.com/v1/telemetry/GenericReport",
+						"PRODUCT_FAMILY_PS": "https://ps.percona.com/v1/telemetry/GenericReport",
+					},
+					Routes: map[platformReporter.ProductFamily]string{
+						platformReporter.ProductFamily_PRODUCT_FAMILY_PMM: "https://pmm.percona.com/v1/telemetry/GenericReport",
+						platformReporter.ProductFamily_PRODUCT_FAMILY_PS:  "https://ps.percona.com/v1/telemetry/GenericReport",
+					},
+				},
+				Log: LogOpts{
+					Verbose:          false,
+					DevMode:          false,
+					MaxSize:          logMaxSizeDefault,
+					MaxBackups:       logMaxBackupsDefault,
+					MaxAge:           logMaxAgeDefault,
+					SyslogFacility:   logSyslogFacilityDefault,
+					SyslogTag:        logSyslogTagDefault,
+					RedactKeys:       strings.Split(logRedactKeysDefault, ","),
+					SamplingFirst:    logSamplingFirstDefault,
+					SamplingInterval: logSamplingIntervalDefault,
+					Color:            logColorDefault,
+					TimeFormat:       logTimeFormatDefault,
+				},
+				Kubernetes: KubernetesOpts{
+					PodInfoPath: kubernetesPodInfoPathDefault,
+				},
+				Packages: PackagesCmd{
+					Format: "json",
+				},
+				Bundle: BundleCmd{
+					Output: "telemetry-agent-bundle.tar.gz",
+					LogDir: "/var/log/percona/telemetry-agent",
+				},
+				Command: CommandRun,
 			},
 		},
 	}
@@ -141,3 +750,228 @@ func TestInitConfig(t *testing.T) { //nolint:paralleltest
 		})
 	}
 }
+
+func TestParseProductFamily(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		value         string
+		expected      platformReporter.ProductFamily
+		expectedValid bool
+	}{
+		{
+			name:          "short_name",
+			value:         "pxb",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_PXB,
+			expectedValid: true,
+		},
+		{
+			name:          "full_name",
+			value:         "PRODUCT_FAMILY_PERCONA_TOOLKIT",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_PERCONA_TOOLKIT,
+			expectedValid: true,
+		},
+		{
+			name:          "invalid_family",
+			value:         "INVALID",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_INVALID,
+			expectedValid: false,
+		},
+		{
+			name:          "unknown_family",
+			value:         "GENERIC",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_INVALID,
+			expectedValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			productFamily, valid := parseProductFamily(tt.value)
+			require.Equal(t, tt.expected, productFamily)
+			require.Equal(t, tt.expectedValid, valid)
+		})
+	}
+}
+
+func TestResolveProductFamily(t *testing.T) {
+	t.Parallel()
+
+	pillars := []PillarOpts{
+		{Name: "PG", Path: "/usr/local/percona/telemetry/pg", ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL},
+		{Name: "PSMDB (mongos)", Path: "/usr/local/percona/telemetry/psmdbs", ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB},
+	}
+
+	tests := []struct {
+		name          string
+		value         string
+		expected      platformReporter.ProductFamily
+		expectedValid bool
+	}{
+		{
+			name:          "pillar_directory",
+			value:         "pg",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL,
+			expectedValid: true,
+		},
+		{
+			name:          "pillar_directory_upper_case",
+			value:         "PSMDBS",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB,
+			expectedValid: true,
+		},
+		{
+			name:          "product_family",
+			value:         "POSTGRESQL",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL,
+			expectedValid: true,
+		},
+		{
+			name:          "unknown",
+			value:         "mysql",
+			expected:      platformReporter.ProductFamily_PRODUCT_FAMILY_INVALID,
+			expectedValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			productFamily, valid := resolveProductFamily(pillars, tt.value)
+			require.Equal(t, tt.expected, productFamily)
+			require.Equal(t, tt.expectedValid, valid)
+		})
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		addr     string
+		expected bool
+	}{
+		{name: "localhost", addr: "localhost:6060", expected: true},
+		{name: "ipv4_loopback", addr: "127.0.0.1:6060", expected: true},
+		{name: "ipv6_loopback", addr: "[::1]:6060", expected: true},
+		{name: "any_address", addr: ":6060", expected: false},
+		{name: "public_address", addr: "10.0.0.1:6060", expected: false},
+		{name: "hostname", addr: "example.com:6060", expected: false},
+		{name: "missing_port", addr: "127.0.0.1", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, isLoopbackAddr(tt.addr))
+		})
+	}
+}
+
+func TestIsPlatformURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		url      string
+		expected bool
+	}{
+		{name: "https", url: "https://check.percona.com/v1/telemetry/GenericReport", expected: true},
+		{name: "http_without_path", url: "http://localhost:8080", expected: true},
+		{name: "unix_socket", url: "unix:///var/run/telemetry-relay.sock", expected: true},
+		{name: "unix_socket_with_host", url: "unix://relay/var/run/telemetry-relay.sock", expected: false},
+		{name: "unix_without_path", url: "unix://", expected: false},
+		{name: "missing_scheme", url: "/v1/telemetry/GenericReport", expected: false},
+		{name: "missing_host", url: "https:///v1/telemetry/GenericReport", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, isPlatformURL(tt.url))
+		})
+	}
+}
+
+func TestConfigRoots(t *testing.T) {
+	t.Parallel()
+
+	rootPath := filepath.Join("/usr", "local", "percona", "telemetry")
+	tenantPath := filepath.Join("/srv", "tenant-1", "telemetry")
+
+	c := Config{
+		Telemetry: TelemetryOpts{
+			RootPath:          rootPath,
+			ExtraRootPaths:    []string{tenantPath},
+			StatusSocketPath:  filepath.Join(rootPath, "telemetry-agent.sock"),
+			ErrorCountersPath: filepath.Join(rootPath, "error_counters.json"),
+			CheckInterval:     telemetryCheckIntervalDefault,
+			Pillars: append(expectedPillars(rootPath), PillarOpts{
+				Name:          "pxb",
+				Path:          filepath.Join(rootPath, "pxb"),
+				ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PXB,
+			}),
+		},
+	}
+	c.Telemetry.setRootPaths()
+
+	roots := c.Roots()
+	require.Len(t, roots, 2)
+	require.Equal(t, c, roots[0])
+
+	expected := TelemetryOpts{
+		RootPath:               tenantPath,
+		PSMetricsPath:          filepath.Join(tenantPath, "ps"),
+		PBSMetricsPath:         filepath.Join(tenantPath, "pbs"),
+		PSMDBMongodMetricsPath: filepath.Join(tenantPath, "psmdb"),
+		PSMDBMongosMetricsPath: filepath.Join(tenantPath, "psmdbs"),
+		PXCMetricsPath:         filepath.Join(tenantPath, "pxc"),
+		PGMetricsPath:          filepath.Join(tenantPath, "pg"),
+		PBMMetricsPath:         filepath.Join(tenantPath, "pbm"),
+		PMMMetricsPath:         filepath.Join(tenantPath, "pmm"),
+		HistoryPath:            filepath.Join(tenantPath, "history"),
+		SchemasPath:            filepath.Join(tenantPath, "schemas"),
+		QuarantinePath:         filepath.Join(tenantPath, "quarantine"),
+		FailedPath:             filepath.Join(tenantPath, "failed"),
+		ProcessingPath:         filepath.Join(tenantPath, "processing"),
+		LastRunPath:            filepath.Join(tenantPath, "last_run.json"),
+		StateDBPath:            filepath.Join(tenantPath, "state.db"),
+		HashSaltPath:           filepath.Join(tenantPath, "hash_salt"),
+		FirstSeenPath:          filepath.Join(tenantPath, "first_seen"),
+		InstanceIDChangesPath:  filepath.Join(tenantPath, "instance_id_changes.json"),
+		InstanceFile:           filepath.Join(tenantPath, "telemetry_uuid"),
+		// status socket and error counters are shared by all root paths.
+		StatusSocketPath:  filepath.Join(rootPath, "telemetry-agent.sock"),
+		ErrorCountersPath: filepath.Join(rootPath, "error_counters.json"),
+		CheckInterval:     telemetryCheckIntervalDefault,
+		Pillars: append(expectedPillars(tenantPath), PillarOpts{
+			Name:          "pxb",
+			Path:          filepath.Join(tenantPath, "pxb"),
+			ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PXB,
+		}),
+	}
+	require.Equal(t, expected, roots[1].Telemetry)
+
+	// Pillars of telemetry root path are not changed.
+	require.Equal(t, filepath.Join(rootPath, "ps"), c.Telemetry.Pillars[0].Path)
+}
+
+func expectedPillars(rootPath string) []PillarOpts {
+	return []PillarOpts{
+		{Name: "PS", Path: filepath.Join(rootPath, "ps"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS},
+		{Name: "PBS", Path: filepath.Join(rootPath, "pbs"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PBS},
+		{Name: "PXC", Path: filepath.Join(rootPath, "pxc"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PXC},
+		{Name: "PSMDB (mongod)", Path: filepath.Join(rootPath, "psmdb"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB},
+		{Name: "PSMDB (mongos)", Path: filepath.Join(rootPath, "psmdbs"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB},
+		{Name: "PBM", Path: filepath.Join(rootPath, "pbm"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PBM},
+		{Name: "PMM Client", Path: filepath.Join(rootPath, "pmm"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PMM},
+		{Name: "PG", Path: filepath.Join(rootPath, "pg"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL},
+	}
+}
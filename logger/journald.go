@@ -0,0 +1,269 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// journaldSocket is the socket of journald native protocol.
+	journaldSocket = "/run/systemd/journal/socket"
+	// journalFieldPrefix is added to names of zap fields which have no dedicated journal field.
+	journalFieldPrefix = "PERCONA_"
+	// journalFieldMaxLen is the maximum length of journal field name.
+	journalFieldMaxLen = 64
+)
+
+// journalFieldNames maps zap field names to journal field names used instead of prefixed ones.
+var journalFieldNames = map[string]string{
+	"file": "FILE",
+}
+
+// journaldCore is zap core writing log entries into journald via native protocol,
+// so zap fields become journal fields and can be used for filtering by journalctl.
+type journaldCore struct {
+	zapcore.LevelEnabler
+
+	conn       *net.UnixConn
+	identifier string
+	fields     []zapcore.Field
+}
+
+// newJournaldCore checks that journald is available and creates core writing into it.
+func newJournaldCore(level zapcore.LevelEnabler, identifier string) (*journaldCore, error) {
+	if _, err := os.Stat(journaldSocket); err != nil {
+		return nil, fmt.Errorf("journald is not available: %w", err)
+	}
+
+	// unbound datagram socket, destination is given on each write.
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("can't create journald socket: %w", err)
+	}
+
+	return &journaldCore{
+		LevelEnabler: level,
+		conn:         conn,
+		identifier:   identifier,
+	}, nil
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...)
+
+	return &clone
+}
+
+func (c *journaldCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *journaldCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	var buf bytes.Buffer
+
+	writeJournalField(&buf, "MESSAGE", entry.Message)
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(journalPriority(entry.Level)))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", c.identifier)
+
+	if entry.LoggerName != "" {
+		writeJournalField(&buf, journalFieldPrefix+"LOGGER", entry.LoggerName)
+	}
+
+	if entry.Caller.Defined {
+		writeJournalField(&buf, "CODE_FILE", entry.Caller.File)
+		writeJournalField(&buf, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+		writeJournalField(&buf, "CODE_FUNC", entry.Caller.Function)
+	}
+
+	if entry.Stack != "" {
+		writeJournalField(&buf, journalFieldPrefix+"STACKTRACE", entry.Stack)
+	}
+
+	// fields are sorted, so the order of journal fields doesn't depend on map iteration.
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := journalFieldName(k)
+		if name == "" {
+			continue
+		}
+
+		writeJournalField(&buf, name, journalFieldValue(enc.Fields[k]))
+	}
+
+	return c.send(buf.Bytes())
+}
+
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+// send writes datagram into journald socket. Datagrams exceeding socket buffer are passed
+// via file descriptor of unlinked temporary file, as journald native protocol requires.
+func (c *journaldCore) send(data []byte) error {
+	addr := &net.UnixAddr{Name: journaldSocket, Net: "unixgram"}
+
+	_, _, err := c.conn.WriteMsgUnix(data, nil, addr)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+
+	f, err := os.CreateTemp("/dev/shm", "telemetry-agent-journal-")
+	if err != nil {
+		return err
+	}
+	// temporary file is already written into, so closing error can't lose data.
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	_, _, err = c.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), addr)
+
+	return err
+}
+
+// journalPriority returns syslog severity corresponding to zap level.
+func journalPriority(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel:
+		return 2
+	default:
+		return 5
+	}
+}
+
+// journalFieldName converts zap field name into valid journal field name:
+// uppercase letters, digits and underscores, not starting with underscore or digit.
+// Empty string is returned if name can't be converted.
+func journalFieldName(key string) string {
+	if name, ok := journalFieldNames[key]; ok {
+		return name
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(journalFieldPrefix)
+
+	for _, r := range strings.ToUpper(key) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+
+	name := sb.String()
+	if name == journalFieldPrefix {
+		return ""
+	}
+
+	if len(name) > journalFieldMaxLen {
+		name = name[:journalFieldMaxLen]
+	}
+
+	return name
+}
+
+// journalFieldValue formats value of zap field, non-string values are formatted as JSON.
+func journalFieldValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case fmt.Stringer:
+		return val.String()
+	case error:
+		return val.Error()
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
+
+// writeJournalField writes journal field in native protocol format. Values with newlines
+// are written in binary format with explicit length.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+
+		return
+	}
+
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
@@ -17,8 +17,26 @@
 package logger
 
 import (
+	"os"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log colors modes.
+const (
+	ColorAuto   = "auto"   // colors are used if output is a terminal and NO_COLOR environment variable is not set
+	ColorAlways = "always" // colors are always used
+	ColorNever  = "never"  // colors are never used
+)
+
+// Log timestamp formats.
+const (
+	TimeFormatISO8601 = "iso8601" // e.g. 2024-02-15T20:04:05.000Z
+	TimeFormatRFC3339 = "rfc3339" // e.g. 2024-02-15T20:04:05Z
+	TimeFormatEpoch   = "epoch"   // floating-point number of seconds since the Unix epoch
 )
 
 // GlobalOpts contains logger options.
@@ -26,6 +44,24 @@ type GlobalOpts struct {
 	LogDebug   bool   // enable debug level logging
 	LogDevMode bool   // enable development mode logging: text instead of JSON, DPanic panics instead of logging errors
 	LogName    string // global logger name
+	LogStderr  bool   // write logs to stderr instead of stdout, so stdout can be used for command output
+	// LogFile is the path of log file to write logs to instead of stdout/stderr, it is rotated by size.
+	LogFile       string
+	LogMaxSize    int // maximum size in MiB of log file before it is rotated
+	LogMaxBackups int // maximum number of rotated log files to keep, 0 keeps all of them
+	LogMaxAge     int // maximum number of days to keep rotated log files, 0 disables removing them by age
+	// LogSyslog enables writing logs into local syslog in addition to stdout/stderr or log file.
+	LogSyslog         bool
+	LogSyslogFacility string // syslog facility name, e.g. 'daemon' or 'local0'
+	LogSyslogTag      string // syslog tag and journald identifier, program name by default
+	// LogJournald enables writing logs into journald with zap fields mapped to journal fields.
+	LogJournald bool
+	// LogSamplingFirst is the number of log entries with the same level and message logged during LogSamplingInterval,
+	// the rest of them are suppressed and only their count is logged. 0 disables sampling.
+	LogSamplingFirst    int
+	LogSamplingInterval time.Duration
+	LogColor            string // colors mode of development mode console output, ColorAuto by default
+	LogTimeFormat       string // timestamps format, TimeFormatISO8601 by default
 }
 
 // SetupGlobal setups global zap logger.
@@ -43,7 +79,9 @@ func SetupGlobal(opts *GlobalOpts) {
 		OutputPaths:      []string{"stdout"},
 		ErrorOutputPaths: []string{"stderr"},
 	}
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if opts.LogStderr {
+		cfg.OutputPaths = []string{"stderr"}
+	}
 
 	if opts.LogDebug {
 		cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
@@ -55,10 +93,124 @@ func SetupGlobal(opts *GlobalOpts) {
 		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
 	}
 
-	l, err := cfg.Build()
+	cfg.EncoderConfig.EncodeTime = timeEncoder(opts.LogTimeFormat)
+
+	var buildOpts []zap.Option
+
+	encoder := zapcore.NewJSONEncoder(cfg.EncoderConfig)
+	if cfg.Encoding == "console" {
+		encoder = zapcore.NewConsoleEncoder(cfg.EncoderConfig)
+	}
+
+	// colors are used only in console output, log file and syslog are written without escape sequences.
+	if cfg.Encoding == "console" && opts.LogFile == "" && useColor(opts.LogColor, opts.LogStderr) {
+		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+
+	if opts.LogFile != "" {
+		// zap has no built-in rotation, so output core is replaced with the one writing into rotated log file.
+		logFile := &lumberjack.Logger{
+			Filename:   opts.LogFile,
+			MaxSize:    opts.LogMaxSize,
+			MaxBackups: opts.LogMaxBackups,
+			MaxAge:     opts.LogMaxAge,
+			LocalTime:  true,
+		}
+
+		buildOpts = append(buildOpts, zap.WrapCore(func(zapcore.Core) zapcore.Core {
+			return zapcore.NewCore(encoder, zapcore.AddSync(logFile), cfg.Level)
+		}))
+	}
+
+	var journaldErr error
+
+	if opts.LogJournald {
+		journaldC, err := newJournaldCore(cfg.Level, opts.LogSyslogTag)
+		if err != nil {
+			// not critical error, logs are still written into the main output.
+			journaldErr = err
+		} else {
+			buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+				if opts.LogFile != "" {
+					return zapcore.NewTee(c, journaldC)
+				}
+
+				// stdout of service is collected by journald as well, it is replaced to avoid duplicated entries.
+				return journaldC
+			}))
+		}
+	}
+
+	// syslog core is built after the others, so it is added to log file and journald outputs as well.
+	var syslogErr error
+
+	if opts.LogSyslog {
+		syslogC, err := newSyslogCore(encoder, cfg.Level, opts.LogSyslogFacility, opts.LogSyslogTag)
+		if err != nil {
+			// not critical error, logs are still written into the main output.
+			syslogErr = err
+		} else {
+			buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+				return zapcore.NewTee(c, syslogC)
+			}))
+		}
+	}
+
+	// sampling is applied to all outputs, debug logs are never sampled as they are used for troubleshooting.
+	if opts.LogSamplingFirst > 0 && opts.LogSamplingInterval > 0 && !opts.LogDebug {
+		buildOpts = append(buildOpts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return newSamplingCore(c, opts.LogSamplingInterval, opts.LogSamplingFirst)
+		}))
+	}
+
+	l, err := cfg.Build(buildOpts...)
 	if err != nil {
 		panic(err)
 	}
 
+	if syslogErr != nil {
+		l.Warn("failed to setup syslog output, logs are not written into syslog", zap.Error(syslogErr))
+	}
+
+	if journaldErr != nil {
+		l.Warn("failed to setup journald output, logs are not written into journald", zap.Error(journaldErr))
+	}
+
 	zap.ReplaceGlobals(l.Named(opts.LogName))
 }
+
+// timeEncoder returns encoder of timestamps in given format.
+func timeEncoder(format string) zapcore.TimeEncoder {
+	switch format {
+	case TimeFormatRFC3339:
+		return zapcore.RFC3339TimeEncoder
+	case TimeFormatEpoch:
+		return zapcore.EpochTimeEncoder
+	default:
+		return zapcore.ISO8601TimeEncoder
+	}
+}
+
+// useColor returns true if colors mode allows colored output into stdout or stderr.
+func useColor(mode string, stderr bool) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	// https://no-color.org
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	out := os.Stdout
+	if stderr {
+		out = os.Stderr
+	}
+
+	fi, err := out.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
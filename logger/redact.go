@@ -0,0 +1,139 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RedactedValue replaces values of sensitive keys in logs.
+const RedactedValue = "[REDACTED]"
+
+// Redactor masks values of keys matching configured patterns, so sensitive values
+// (tokens, passwords, etc.) are not written into logs.
+// Patterns are shell file name patterns (e.g. '*token*') matched case-insensitively.
+type Redactor struct {
+	patterns []string
+}
+
+// NewRedactor creates Redactor masking values of keys matching given patterns.
+func NewRedactor(patterns []string) *Redactor {
+	r := &Redactor{patterns: make([]string, 0, len(patterns))}
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			r.patterns = append(r.patterns, strings.ToLower(p))
+		}
+	}
+
+	return r
+}
+
+// Match returns true if value of the key has to be masked.
+// Invalid patterns never match, they are reported by configuration validation.
+func (r *Redactor) Match(key string) bool {
+	if r == nil {
+		return false
+	}
+
+	key = strings.ToLower(key)
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value returns copy of the value with masked values of matching keys on any nesting level,
+// so it can be logged with zap.Any. The value is converted via its JSON representation;
+// values which can't be converted are returned as is.
+func (r *Redactor) Value(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+
+	return r.redact(out)
+}
+
+// JSON returns JSON document with masked values of matching keys.
+// Data which is not a valid JSON is returned as is.
+func (r *Redactor) JSON(data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+
+	out, err := json.Marshal(r.redact(v))
+	if err != nil {
+		return data
+	}
+
+	return out
+}
+
+// Header returns copy of HTTP header with masked values of matching header names.
+func (r *Redactor) Header(h http.Header) http.Header {
+	out := h.Clone()
+	for name, values := range out {
+		if !r.Match(name) {
+			continue
+		}
+
+		for i := range values {
+			values[i] = RedactedValue
+		}
+	}
+
+	return out
+}
+
+func (r *Redactor) redact(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		// key-value pairs, e.g. metrics of telemetry report, are masked by the key name.
+		if k, ok := val["key"].(string); ok && len(val) == 2 && r.Match(k) {
+			if _, ok := val["value"]; ok {
+				val["value"] = RedactedValue
+				return v
+			}
+		}
+
+		for k, nested := range val {
+			if r.Match(k) {
+				val[k] = RedactedValue
+				continue
+			}
+
+			val[k] = r.redact(nested)
+		}
+	case []any:
+		for i, nested := range val {
+			val[i] = r.redact(nested)
+		}
+	}
+
+	return v
+}
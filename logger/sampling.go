@@ -0,0 +1,127 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// suppressedKey identifies repeated log messages.
+type suppressedKey struct {
+	level      zapcore.Level
+	loggerName string
+	message    string
+}
+
+// suppressedCounter counts log entries dropped by sampler and writes their counts
+// into the unsampled core once per interval, so repeated messages are collapsed into periodic counts.
+type suppressedCounter struct {
+	core     zapcore.Core
+	interval time.Duration
+
+	mu         sync.Mutex
+	suppressed map[suppressedKey]int
+	flushTimer *time.Timer
+}
+
+// samplingCore logs first entries with the same level and message during the interval
+// and suppresses the rest of them, counts of suppressed entries are logged at the end of the interval.
+type samplingCore struct {
+	zapcore.Core
+
+	counter *suppressedCounter
+}
+
+// newSamplingCore wraps core with sampling of repeated log entries.
+func newSamplingCore(core zapcore.Core, interval time.Duration, first int) *samplingCore {
+	counter := &suppressedCounter{
+		core:       core,
+		interval:   interval,
+		suppressed: make(map[suppressedKey]int),
+	}
+
+	// thereafter is 0, so all entries after the first ones are dropped until the end of the interval.
+	sampler := zapcore.NewSamplerWithOptions(core, interval, first, 0, zapcore.SamplerHook(counter.hook))
+
+	return &samplingCore{
+		Core:    sampler,
+		counter: counter,
+	}
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{
+		Core:    c.Core.With(fields),
+		counter: c.counter,
+	}
+}
+
+// Sync writes counts of entries suppressed so far, so they are not lost on exit.
+func (c *samplingCore) Sync() error {
+	c.counter.flush()
+
+	return c.Core.Sync()
+}
+
+// hook counts dropped entries, counts are flushed after the interval since the first dropped entry.
+func (s *suppressedCounter) hook(entry zapcore.Entry, decision zapcore.SamplingDecision) {
+	if decision&zapcore.LogDropped == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.suppressed[suppressedKey{level: entry.Level, loggerName: entry.LoggerName, message: entry.Message}]++
+
+	if s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(s.interval, s.flush)
+	}
+}
+
+// flush writes counts of suppressed entries with their original level and resets them.
+func (s *suppressedCounter) flush() {
+	s.mu.Lock()
+	suppressed := s.suppressed
+	s.suppressed = make(map[suppressedKey]int)
+
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	s.mu.Unlock()
+
+	for key, count := range suppressed {
+		entry := zapcore.Entry{
+			Level:      key.level,
+			Time:       time.Now(),
+			LoggerName: key.loggerName,
+			Message:    "repeated log messages were suppressed",
+		}
+
+		if ce := s.core.Check(entry, nil); ce != nil {
+			ce.Write(
+				zap.String("message", key.message),
+				zap.Int("suppressed", count),
+				zap.Duration("interval", s.interval),
+			)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+// Batch bounds the number of metrics files parsed by one call of ProcessPillarMetrics, so a large backlog
+// is processed in several batches instead of being held in memory at once. It remembers names of already
+// taken metrics files, so each subsequent batch continues with the rest of the files even if the taken ones
+// are left in place (e.g. failed to be sent or parsed).
+type Batch struct {
+	// Size is the maximum number of metrics files taken into one batch, it is not limited if it is not positive.
+	Size int
+
+	taken   map[string]struct{}
+	count   int
+	started bool
+}
+
+// NewBatch creates new Batch of given size.
+func NewBatch(size int) *Batch {
+	return &Batch{
+		Size:  size,
+		taken: make(map[string]struct{}),
+	}
+}
+
+// Next starts next batch. It returns false once the previous batch was not filled up, i.e. all metrics files
+// have been taken.
+func (b *Batch) Next() bool {
+	if b.started && (b.Size <= 0 || b.count < b.Size) {
+		return false
+	}
+
+	b.started = true
+	b.count = 0
+
+	return true
+}
+
+// Take reserves place for metrics file in current batch. It returns false if the batch is full
+// or the file has been already taken into this or previous batch.
+func (b *Batch) Take(fileName string) bool {
+	if b.full() {
+		return false
+	}
+
+	if _, ok := b.taken[fileName]; ok {
+		return false
+	}
+
+	b.taken[fileName] = struct{}{}
+	b.count++
+
+	return true
+}
+
+func (b *Batch) full() bool {
+	return b.Size > 0 && b.count >= b.Size
+}
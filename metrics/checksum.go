@@ -0,0 +1,97 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumFileExt is the extension of optional checksum sidecar file written by Pillar next to metrics file.
+// Example: '1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json.sha256'.
+const checksumFileExt = ".sha256"
+
+var errChecksumMismatch = errors.New("metrics file checksum mismatch")
+
+// verifyMetricsFileChecksum verifies metrics file content against SHA-256 checksum from its sidecar file.
+// Sidecar file is optional, content is considered valid if it is absent.
+// Sidecar file may contain just a hex encoded checksum or the line in 'sha256sum' output format.
+func verifyMetricsFileChecksum(fileName string, content []byte) error {
+	checksumFile := filepath.Clean(fileName + checksumFileExt)
+
+	checksumContent, err := os.ReadFile(checksumFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("can't read checksum file: %w", err)
+	}
+
+	fields := strings.Fields(string(checksumContent))
+	if len(fields) == 0 {
+		// sidecar file is being written.
+		return fmt.Errorf("%w: checksum file %s is empty", errChecksumMismatch, checksumFile)
+	}
+
+	expected, err := hex.DecodeString(fields[0])
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("%w: checksum file %s has invalid content", errChecksumMismatch, checksumFile)
+	}
+
+	actual := sha256.Sum256(content)
+	if !bytes.Equal(expected, actual[:]) {
+		return fmt.Errorf("%w: expected %x, actual %x", errChecksumMismatch, expected, actual)
+	}
+
+	return nil
+}
+
+// moveChecksumFile moves checksum sidecar file of metrics file along with the metrics file, if it exists.
+func moveChecksumFile(fileName, newFileName string) error {
+	err := os.Rename(fileName+checksumFileExt, newFileName+checksumFileExt)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveMetricsFile removes Pillar's metrics file and its checksum and send attempts sidecar files, if they exist.
+func RemoveMetricsFile(fileName string) error {
+	err := os.Remove(fileName)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(fileName + checksumFileExt)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("can't remove checksum file: %w", err)
+	}
+
+	err = os.Remove(fileName + sendAttemptsFileExt)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("can't remove send attempts file: %w", err)
+	}
+
+	return nil
+}
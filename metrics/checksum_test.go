@@ -0,0 +1,107 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetricsFileChecksum(t *testing.T) {
+	t.Parallel()
+
+	const (
+		fileName    = "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json"
+		fileContent = `{"db_instance_id": "1bed5f0d-cc3a-11ee-bd8a-c84bd64e0288"}`
+	)
+
+	checksum := sha256.Sum256([]byte(fileContent))
+
+	testCases := []struct {
+		name            string
+		checksumContent string // checksum file is not created if empty
+		wantErr         bool
+	}{
+		{
+			name: "no_checksum_file",
+		},
+		{
+			name:            "valid_checksum",
+			checksumContent: hex.EncodeToString(checksum[:]) + "\n",
+		},
+		{
+			name:            "valid_checksum_sha256sum_format",
+			checksumContent: hex.EncodeToString(checksum[:]) + "  " + fileName + "\n",
+		},
+		{
+			name:            "checksum_mismatch",
+			checksumContent: "0000000000000000000000000000000000000000000000000000000000000000",
+			wantErr:         true,
+		},
+		{
+			name:            "empty_checksum_file",
+			checksumContent: "\n",
+			wantErr:         true,
+		},
+		{
+			name:            "invalid_checksum_file",
+			checksumContent: "not a checksum",
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metricsFile := filepath.Join(t.TempDir(), fileName)
+			require.NoError(t, os.WriteFile(metricsFile, []byte(fileContent), 0o600))
+
+			if len(tt.checksumContent) != 0 {
+				require.NoError(t, os.WriteFile(metricsFile+checksumFileExt, []byte(tt.checksumContent), 0o600))
+			}
+
+			files, err := parseMetricsFile(t.Context(), metricsFile, ProcessOpts{}, nil)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errChecksumMismatch)
+				require.Empty(t, files)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+		})
+	}
+}
+
+func TestRemoveMetricsFile(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	writeTempFiles(t, tmpDir, "with_checksum.json", "with_checksum.json"+checksumFileExt, "without_checksum.json")
+
+	require.NoError(t, RemoveMetricsFile(filepath.Join(tmpDir, "with_checksum.json")))
+	require.NoError(t, RemoveMetricsFile(filepath.Join(tmpDir, "without_checksum.json")))
+	checkDirectoryContentCount(t, tmpDir, 0)
+
+	require.Error(t, RemoveMetricsFile(filepath.Join(tmpDir, "absent.json")))
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+)
+
+// ClockSkewKey is the metric key the measured skew of host clock in seconds is attached to reports with.
+const ClockSkewKey = "clock_skew_seconds"
+
+// ClockSkew tracks skew of host clock relative to reference clock (e.g. Percona Platform clock) and corrects
+// timestamps of reports created on hosts with badly skewed clock. It is safe for concurrent use,
+// nil ClockSkew measures and corrects nothing.
+type ClockSkew struct {
+	maxSkew  time.Duration
+	mu       sync.Mutex
+	skew     time.Duration
+	measured bool
+}
+
+// NewClockSkew creates ClockSkew correcting timestamps if the skew exceeds maxSkew.
+// Timestamps are never corrected if maxSkew is not positive, the skew is measured anyway.
+func NewClockSkew(maxSkew time.Duration) *ClockSkew {
+	return &ClockSkew{maxSkew: maxSkew}
+}
+
+// Observe records skew of host clock, local is the host time reference time was received at.
+// Reference time has one second precision (e.g. HTTP Date header), so the skew is measured in whole seconds,
+// zero reference time is ignored. It returns true if the skew exceeds the maximum while the previous one did not.
+func (c *ClockSkew) Observe(local, reference time.Time) bool {
+	if c == nil || reference.IsZero() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wasSkewed := c.measured && c.exceeds(c.skew)
+	c.skew = local.Truncate(time.Second).Sub(reference.Truncate(time.Second))
+	c.measured = true
+
+	return !wasSkewed && c.exceeds(c.skew)
+}
+
+// Skew returns the last measured skew of host clock, it is positive if host clock is ahead of reference one.
+// false is returned if the skew is not measured yet.
+func (c *ClockSkew) Skew() (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.skew, c.measured
+}
+
+// Correct returns timestamp ts taken from host clock (e.g. metrics file name) corrected for the measured skew
+// if it exceeds the maximum. Timestamps ahead of now (host wall clock) by more than the maximum are corrected
+// to now first, as they are produced by a clock skewed relative to host one.
+// The second value is true if ts is corrected.
+func (c *ClockSkew) Correct(ts, now time.Time) (time.Time, bool) {
+	if c == nil || c.maxSkew <= 0 {
+		return ts, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	corrected := false
+
+	if ts.Sub(now) > c.maxSkew {
+		ts, corrected = now, true
+	}
+
+	if c.measured && c.exceeds(c.skew) {
+		ts, corrected = ts.Add(-c.skew), true
+	}
+
+	return ts, corrected
+}
+
+// Metrics returns the last measured skew as Percona Platform report metrics, none if it is not measured yet.
+func (c *ClockSkew) Metrics() []*platformReporter.GenericReport_Metric {
+	skew, ok := c.Skew()
+	if !ok {
+		return nil
+	}
+
+	return []*platformReporter.GenericReport_Metric{
+		{Key: ClockSkewKey, Value: strconv.FormatInt(int64(skew/time.Second), 10)},
+	}
+}
+
+// exceeds returns true if skew exceeds the maximum, it is never exceeded if the maximum is not positive.
+func (c *ClockSkew) exceeds(skew time.Duration) bool {
+	return c.maxSkew > 0 && (skew > c.maxSkew || skew < -c.maxSkew)
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkewObserve(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 2, 15, 12, 0, 0, 400*int(time.Millisecond), time.UTC)
+
+	c := NewClockSkew(5 * time.Minute)
+	_, ok := c.Skew()
+	require.False(t, ok)
+	require.Empty(t, c.Metrics())
+
+	// response without Date header is ignored.
+	require.False(t, c.Observe(now, time.Time{}))
+	_, ok = c.Skew()
+	require.False(t, ok)
+
+	// sub-second difference is below Date header precision.
+	require.False(t, c.Observe(now, now.Truncate(time.Second)))
+	skew, ok := c.Skew()
+	require.True(t, ok)
+	require.Equal(t, time.Duration(0), skew)
+
+	// exceeding the maximum is reported once.
+	require.True(t, c.Observe(now, now.Add(-time.Hour)))
+	require.False(t, c.Observe(now, now.Add(-time.Hour)))
+
+	skew, ok = c.Skew()
+	require.True(t, ok)
+	require.Equal(t, time.Hour, skew)
+
+	require.Len(t, c.Metrics(), 1)
+	require.Equal(t, ClockSkewKey, c.Metrics()[0].GetKey())
+	require.Equal(t, "3600", c.Metrics()[0].GetValue())
+
+	// skew back within the maximum is reported again once it exceeds it.
+	require.False(t, c.Observe(now, now))
+	require.True(t, c.Observe(now, now.Add(time.Hour)))
+
+	var nilSkew *ClockSkew
+	require.False(t, nilSkew.Observe(now, now))
+	require.Empty(t, nilSkew.Metrics())
+}
+
+func TestClockSkewCorrect(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name              string
+		maxSkew           time.Duration
+		skew              time.Duration
+		ts                time.Time
+		expectedTS        time.Time
+		expectedCorrected bool
+	}{
+		{
+			name:       "within_max_skew",
+			maxSkew:    5 * time.Minute,
+			skew:       time.Minute,
+			ts:         now.Add(-time.Hour),
+			expectedTS: now.Add(-time.Hour),
+		},
+		{
+			name:              "host_clock_ahead",
+			maxSkew:           5 * time.Minute,
+			skew:              time.Hour,
+			ts:                now.Add(-time.Minute),
+			expectedTS:        now.Add(-time.Hour - time.Minute),
+			expectedCorrected: true,
+		},
+		{
+			name:              "host_clock_behind",
+			maxSkew:           5 * time.Minute,
+			skew:              -24 * time.Hour,
+			ts:                now,
+			expectedTS:        now.Add(24 * time.Hour),
+			expectedCorrected: true,
+		},
+		{
+			name:              "timestamp_in_future",
+			maxSkew:           5 * time.Minute,
+			ts:                now.Add(time.Hour),
+			expectedTS:        now,
+			expectedCorrected: true,
+		},
+		{
+			name:              "timestamp_in_future_host_clock_ahead",
+			maxSkew:           5 * time.Minute,
+			skew:              time.Hour,
+			ts:                now.Add(48 * time.Hour),
+			expectedTS:        now.Add(-time.Hour),
+			expectedCorrected: true,
+		},
+		{
+			name:       "disabled",
+			maxSkew:    0,
+			skew:       time.Hour,
+			ts:         now.Add(time.Hour),
+			expectedTS: now.Add(time.Hour),
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewClockSkew(tt.maxSkew)
+			c.Observe(now, now.Add(-tt.skew))
+
+			ts, corrected := c.Correct(tt.ts, now)
+			require.Equal(t, tt.expectedTS, ts)
+			require.Equal(t, tt.expectedCorrected, corrected)
+		})
+	}
+}
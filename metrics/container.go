@@ -0,0 +1,191 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+const (
+	containerImageTagDefault = "latest"
+)
+
+// Container represents a running container created from Percona image.
+type Container struct {
+	Image string `json:"image"`
+	Tag   string `json:"tag"`
+}
+
+// dockerContainer represents a container item returned by Docker (or Podman compatible) '/containers/json' API.
+type dockerContainer struct {
+	Image string `json:"Image"`
+	State string `json:"State"`
+}
+
+// getContainerSocketPaths returns list of Docker and Podman API socket locations.
+func getContainerSocketPaths() []string {
+	socketPaths := []string{
+		"/var/run/docker.sock",
+		"/run/podman/podman.sock",
+	}
+
+	if runtimeDir, found := os.LookupEnv("XDG_RUNTIME_DIR"); found {
+		socketPaths = append(socketPaths, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+
+	return socketPaths
+}
+
+// ScrapeRunningContainers returns list of running containers created from Percona images.
+// All accessible Docker and Podman sockets are checked.
+func ScrapeRunningContainers(ctx context.Context) []*Container {
+	toReturn := make([]*Container, 0, 1)
+
+	for _, socketPath := range getContainerSocketPaths() {
+		st, err := os.Stat(socketPath)
+		if err != nil || st.Mode().Type() != os.ModeSocket {
+			continue
+		}
+
+		containers, err := queryRunningContainers(ctx, socketPath)
+		if err != nil {
+			logger.FromContext(ctx).Sugar().Debugw("failed to get running containers", zap.String("socket", socketPath), zap.Error(err))
+			// go to next socket silently
+			continue
+		}
+
+		toReturn = append(toReturn, containers...)
+	}
+
+	return toReturn
+}
+
+func queryRunningContainers(ctx context.Context, socketPath string) ([]*Container, error) {
+	logger.FromContext(ctx).Sugar().Debugw("querying running containers", zap.String("socket", socketPath))
+
+	// the client is created on every scrape, so connections are not kept alive to not leak idle ones.
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, defaultCmdTimeout)
+	defer cancel()
+
+	// host part is ignored, connection is always established via unix socket.
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://localhost/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseContainersOutput(body)
+}
+
+func parseContainersOutput(containersOutput []byte) ([]*Container, error) {
+	var dockerContainers []dockerContainer
+
+	err := json.Unmarshal(containersOutput, &dockerContainers)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse containers list: %w", err)
+	}
+
+	toReturn := make([]*Container, 0, 1)
+
+	for _, c := range dockerContainers {
+		if len(c.State) != 0 && c.State != "running" {
+			continue
+		}
+
+		image, tag := parseContainerImage(c.Image)
+		if !isPerconaImage(image) {
+			continue
+		}
+
+		toReturn = append(toReturn, &Container{
+			Image: image,
+			Tag:   tag,
+		})
+	}
+
+	return toReturn, nil
+}
+
+// parseContainerImage splits image reference into image name and tag.
+// Example:
+// 'docker.io/percona/percona-server:8.0' -> 'percona/percona-server', '8.0'
+// 'percona/pmm-server@sha256:<digest>' -> 'percona/pmm-server', 'sha256:<digest>'
+// 'percona/percona-server-mongodb' -> 'percona/percona-server-mongodb', 'latest'.
+func parseContainerImage(imageRef string) (string, string) {
+	image, tag := imageRef, containerImageTagDefault
+
+	if pos := strings.Index(image, "@"); pos != -1 {
+		image, tag = image[:pos], image[pos+1:]
+	} else if pos := strings.LastIndex(image, ":"); pos != -1 && !strings.Contains(image[pos:], "/") {
+		// colon after the last slash separates the tag,
+		// otherwise it is registry port (e.g. 'registry:5000/percona/percona-server').
+		image, tag = image[:pos], image[pos+1:]
+	}
+
+	for _, registry := range []string{"docker.io/library/", "docker.io/", "index.docker.io/"} {
+		image = strings.TrimPrefix(image, registry)
+	}
+
+	return image, tag
+}
+
+func isPerconaImage(image string) bool {
+	for _, part := range strings.Split(image, "/") {
+		if part == "percona" || part == "perconalab" {
+			return true
+		}
+	}
+
+	return false
+}
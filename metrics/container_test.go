@@ -0,0 +1,80 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContainersOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name               string
+		containersOutput   []byte
+		expectedContainers []*Container
+		wantErr            bool
+	}{
+		{
+			name: "mixed_containers",
+			containersOutput: []byte(`[
+  {"Id": "1", "Image": "percona/percona-server:8.0.36", "State": "running"},
+  {"Id": "2", "Image": "docker.io/percona/pmm-server:2", "State": "running"},
+  {"Id": "3", "Image": "percona/percona-server-mongodb", "State": "running"},
+  {"Id": "4", "Image": "nginx:latest", "State": "running"},
+  {"Id": "5", "Image": "percona/percona-xtradb-cluster:8.0", "State": "exited"},
+  {"Id": "6", "Image": "registry.local:5000/percona/percona-distribution-postgresql:16", "State": "running"},
+  {"Id": "7", "Image": "percona@sha256:0123456789abcdef", "State": "running"}
+]`),
+			expectedContainers: []*Container{
+				{Image: "percona/percona-server", Tag: "8.0.36"},
+				{Image: "percona/pmm-server", Tag: "2"},
+				{Image: "percona/percona-server-mongodb", Tag: "latest"},
+				{Image: "registry.local:5000/percona/percona-distribution-postgresql", Tag: "16"},
+				{Image: "percona", Tag: "sha256:0123456789abcdef"},
+			},
+		},
+		{
+			name:               "no_containers",
+			containersOutput:   []byte(`[]`),
+			expectedContainers: []*Container{},
+		},
+		{
+			name:             "invalid_output",
+			containersOutput: []byte(`{"message": "permission denied"}`),
+			wantErr:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			containers, err := parseContainersOutput(tt.containersOutput)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Nil(t, containers)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedContainers, containers)
+		})
+	}
+}
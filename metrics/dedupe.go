@@ -0,0 +1,151 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"go.uber.org/zap"
+)
+
+// SentReports remembers hashes of reports sent within window, so identical reports are not sent again.
+// It is safe for concurrent use.
+type SentReports struct {
+	window time.Duration
+	mu     sync.Mutex
+	sent   map[string]time.Time
+}
+
+// NewSentReports creates empty SentReports remembering reports sent within window.
+// Reports are never considered duplicates if window is not positive.
+func NewSentReports(window time.Duration) *SentReports {
+	return &SentReports{
+		window: window,
+		sent:   make(map[string]time.Time),
+	}
+}
+
+// IsDuplicate returns true if report with the same hash was sent within window.
+func (s *SentReports) IsDuplicate(hash string) bool {
+	if s.window <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sentAt, ok := s.sent[hash]
+
+	return ok && time.Since(sentAt) <= s.window
+}
+
+// Add registers report with the hash as sent just now and forgets reports sent before window.
+func (s *SentReports) Add(hash string) {
+	s.addAt(hash, time.Now())
+}
+
+// LoadHistory registers reports sent within window according to history files in the history directory,
+// so duplicates are suppressed across agent restarts. Records of suppressed reports are skipped.
+func (s *SentReports) LoadHistory(historyDirectoryPath string) error {
+	if s.window <= 0 {
+		return nil
+	}
+
+	historyFiles, err := ListMetricsHistory(historyDirectoryPath)
+	if err != nil {
+		return fmt.Errorf("can't load sent reports from history: %w", err)
+	}
+
+	l := zap.L().Sugar()
+	threshold := time.Now().Add(-s.window)
+
+	for _, file := range historyFiles {
+		// history file is written after the request is sent, so it is not modified after the request older than window.
+		info, err := os.Stat(file.Name)
+		if err != nil || info.ModTime().Before(threshold) {
+			continue
+		}
+
+		report, delivery, err := ReadMetricsHistory(file.Name)
+		if err != nil {
+			l.Warnw("can't read history file, its reports are not deduplicated", zap.String("file", file.Name), zap.Error(err))
+			continue
+		}
+
+		// history files written before delivery metadata was recorded have no sending time.
+		if delivery == nil || delivery.Suppressed || delivery.SentAt.Before(threshold) {
+			continue
+		}
+
+		for _, r := range report.GetReports() {
+			s.addAt(ReportHash(r), delivery.SentAt)
+		}
+	}
+
+	return nil
+}
+
+// addAt registers report with the hash as sent at sentAt and forgets reports sent before window.
+func (s *SentReports) addAt(hash string, sentAt time.Time) {
+	if s.window <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for h, t := range s.sent {
+		if now.Sub(t) > s.window {
+			delete(s.sent, h)
+		}
+	}
+
+	if sentAt.After(s.sent[hash]) {
+		s.sent[hash] = sentAt
+	}
+}
+
+// ReportHash returns hash of report payload. Report ID, creation time, measured clock skew and installation age
+// are excluded (with or without host key prefix of namespaced reports), so the same metrics of the same instance
+// reported at different times have the same hash.
+func ReportHash(report *platformReporter.GenericReport) string {
+	lines := make([]string, 0, len(report.GetMetrics()))
+	for _, m := range report.GetMetrics() {
+		if key := strings.TrimPrefix(m.GetKey(), hostKeyPrefix); key == ClockSkewKey || key == InstallationAgeKey {
+			continue
+		}
+
+		lines = append(lines, m.GetKey()+"="+m.GetValue())
+	}
+
+	slices.Sort(lines)
+
+	h := sha256.New()
+	h.Write([]byte(report.GetProductFamily().String() + "\n"))
+	h.Write([]byte(report.GetInstanceId() + "\n"))
+	h.Write([]byte(strings.Join(lines, "\n")))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestReportHash(t *testing.T) {
+	t.Parallel()
+
+	newReport := func(instanceID string, reportMetrics ...*platformReporter.GenericReport_Metric) *platformReporter.GenericReport {
+		return &platformReporter.GenericReport{
+			Id:            uuid.New().String(),
+			CreateTime:    timestamppb.New(time.Now().Add(-time.Duration(len(reportMetrics)) * time.Hour)),
+			InstanceId:    instanceID,
+			ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS,
+			Metrics:       reportMetrics,
+		}
+	}
+
+	m1 := &platformReporter.GenericReport_Metric{Key: "uptime", Value: "112"}
+	m2 := &platformReporter.GenericReport_Metric{Key: "db_instance_id", Value: "instance-1"}
+	m3 := &platformReporter.GenericReport_Metric{Key: "uptime", Value: "212"}
+
+	// report ID, creation time and metrics order are ignored.
+	require.Equal(t, ReportHash(newReport("host-1", m1, m2)), ReportHash(newReport("host-1", m2, m1)))
+	require.NotEqual(t, ReportHash(newReport("host-1", m1, m2)), ReportHash(newReport("host-1", m3, m2)))
+	require.NotEqual(t, ReportHash(newReport("host-1", m1, m2)), ReportHash(newReport("host-2", m1, m2)))
+
+	// measured clock skew is ignored as well.
+	skew := &platformReporter.GenericReport_Metric{Key: ClockSkewKey, Value: "1"}
+	require.Equal(t, ReportHash(newReport("host-1", m1, m2)), ReportHash(newReport("host-1", m1, m2, skew)))
+	// and installation age growing every day.
+	age := &platformReporter.GenericReport_Metric{Key: InstallationAgeKey, Value: "3"}
+	require.Equal(t, ReportHash(newReport("host-1", m1, m2)), ReportHash(newReport("host-1", m1, m2, age)))
+
+	// host metrics of schema version 3 reports are namespaced.
+	hostM := &File{Metrics: map[string]string{InstallationAgeKey: "3", ClockSkewKey: "1", "os": "Ubuntu"}}
+	pillarM := &File{Metrics: map[string]string{"uptime": "112", ReportSchemaVersionKey: "3"}, ReportSchemaVersion: 3}
+	namespaced := newReport("host-1", ReportMetrics(hostM, pillarM)...)
+	hostM.Metrics[InstallationAgeKey] = "4"
+	hostM.Metrics[ClockSkewKey] = "2"
+	require.Equal(t, ReportHash(namespaced), ReportHash(newReport("host-1", ReportMetrics(hostM, pillarM)...)))
+}
+
+func TestSentReports(t *testing.T) {
+	t.Parallel()
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSentReports(time.Hour)
+		require.False(t, s.IsDuplicate("hash-1"))
+
+		s.Add("hash-1")
+		require.True(t, s.IsDuplicate("hash-1"))
+		require.False(t, s.IsDuplicate("hash-2"))
+
+		// reports sent before window are forgotten.
+		s.sent["hash-1"] = time.Now().Add(-2 * time.Hour)
+		require.False(t, s.IsDuplicate("hash-1"))
+
+		s.Add("hash-2")
+		require.NotContains(t, s.sent, "hash-1")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSentReports(0)
+		s.Add("hash-1")
+		require.False(t, s.IsDuplicate("hash-1"))
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// ErrorCategory is the category of Telemetry Agent failures.
+type ErrorCategory string
+
+// Error categories.
+const (
+	// ErrorCategoryNetwork is the category of failures to connect to Percona Platform or to receive its response.
+	ErrorCategoryNetwork ErrorCategory = "network"
+	// ErrorCategoryClient is the category of Percona Platform responses with 4xx status code.
+	ErrorCategoryClient ErrorCategory = "http_4xx"
+	// ErrorCategoryServer is the category of Percona Platform responses with 5xx status code.
+	ErrorCategoryServer ErrorCategory = "http_5xx"
+	// ErrorCategoryParse is the category of Pillars metrics files failed to be parsed or rejected by schema.
+	ErrorCategoryParse ErrorCategory = "parse"
+	// ErrorCategoryPermission is the category of failures to access files and directories.
+	ErrorCategoryPermission ErrorCategory = "permission"
+	// ErrorCategoryOther is the category of all other failures.
+	ErrorCategoryOther ErrorCategory = "other"
+)
+
+// ErrorCategories lists all error categories.
+var ErrorCategories = []ErrorCategory{
+	ErrorCategoryNetwork,
+	ErrorCategoryClient,
+	ErrorCategoryServer,
+	ErrorCategoryParse,
+	ErrorCategoryPermission,
+	ErrorCategoryOther,
+}
+
+const errorCountersFilePermissions = 0o644
+
+// ClassifyError returns the category of err. HTTP status code of Percona Platform response is taken from errors
+// implementing HTTPStatusCode method, so metrics package doesn't depend on Percona Platform client.
+func ClassifyError(err error) ErrorCategory {
+	var (
+		statusErr interface{ HTTPStatusCode() int }
+		netErr    net.Error
+	)
+
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorCategoryPermission
+	case errors.As(err, &statusErr):
+		switch code := statusErr.HTTPStatusCode(); {
+		case code >= http.StatusInternalServerError:
+			return ErrorCategoryServer
+		case code >= http.StatusBadRequest:
+			return ErrorCategoryClient
+		default:
+			return ErrorCategoryOther
+		}
+	case errors.As(err, &netErr):
+		// bare system call errors implement net.Error, but they aren't network errors.
+		if _, ok := netErr.(syscall.Errno); ok { //nolint:errorlint
+			return ErrorCategoryOther
+		}
+
+		return ErrorCategoryNetwork
+	case errors.Is(err, errMetricsFileRejected):
+		return ErrorCategoryParse
+	default:
+		return ErrorCategoryOther
+	}
+}
+
+// ErrorCounters counts Telemetry Agent failures by category. Counters are kept in file,
+// so they are not reset by agent restarts. It is safe for concurrent use, nil ErrorCounters counts nothing.
+type ErrorCounters struct {
+	mu     sync.Mutex
+	counts map[ErrorCategory]int64
+}
+
+// NewErrorCounters creates ErrorCounters with all counters set to zero.
+func NewErrorCounters() *ErrorCounters {
+	return &ErrorCounters{
+		counts: make(map[ErrorCategory]int64, len(ErrorCategories)),
+	}
+}
+
+// ReadErrorCounters reads error counters file.
+func ReadErrorCounters(fileName string) (*ErrorCounters, error) {
+	content, err := os.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewErrorCounters()
+	if err := json.Unmarshal(content, &c.counts); err != nil {
+		return nil, fmt.Errorf("can't parse error counters file: %w", err)
+	}
+
+	return c, nil
+}
+
+// Add increments counter of category.
+func (c *ErrorCounters) Add(category ErrorCategory) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[category]++
+}
+
+// AddError increments counter of err category.
+func (c *ErrorCounters) AddError(err error) {
+	c.Add(ClassifyError(err))
+}
+
+// Counts returns copy of counters, all categories are present.
+func (c *ErrorCounters) Counts() map[ErrorCategory]int64 {
+	counts := make(map[ErrorCategory]int64, len(ErrorCategories))
+	for _, category := range ErrorCategories {
+		counts[category] = 0
+	}
+
+	if c == nil {
+		return counts
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for category, count := range c.counts {
+		counts[category] = count
+	}
+
+	return counts
+}
+
+// Write writes error counters file atomically.
+func (c *ErrorCounters) Write(fileName string) error {
+	content, err := json.MarshalIndent(c.Counts(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal error counters: %w", err)
+	}
+
+	if err := writeFileAtomically(filepath.Clean(fileName), content, errorCountersFilePermissions); err != nil {
+		return fmt.Errorf("can't write error counters file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// httpStatusError is Percona Platform response error with status code.
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("status code %d", int(e))
+}
+
+func (e httpStatusError) HTTPStatusCode() int {
+	return int(e)
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		err      error
+		category ErrorCategory
+	}{
+		{
+			name:     "connection_refused",
+			err:      fmt.Errorf("failed to send telemetry data: %w", &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}),
+			category: ErrorCategoryNetwork,
+		},
+		{
+			name:     "bad_request",
+			err:      fmt.Errorf("failed to send telemetry data: %w", httpStatusError(400)),
+			category: ErrorCategoryClient,
+		},
+		{
+			name:     "service_unavailable",
+			err:      fmt.Errorf("failed to send telemetry data: %w", httpStatusError(503)),
+			category: ErrorCategoryServer,
+		},
+		{
+			name:     "permission_denied",
+			err:      &os.PathError{Op: "rename", Path: "/usr/local/percona/telemetry/ps/1.json", Err: syscall.EACCES},
+			category: ErrorCategoryPermission,
+		},
+		{
+			name:     "rejected_by_schema",
+			err:      fmt.Errorf("%w: missing properties", errMetricsFileRejected),
+			category: ErrorCategoryParse,
+		},
+		{
+			name:     "bare_errno",
+			err:      syscall.ENOSPC,
+			category: ErrorCategoryOther,
+		},
+		{
+			name:     "other",
+			err:      errors.New("unexpected failure"),
+			category: ErrorCategoryOther,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.category, ClassifyError(tc.err))
+		})
+	}
+}
+
+func TestErrorCounters(t *testing.T) {
+	t.Parallel()
+
+	fileName := filepath.Join(t.TempDir(), "error_counters.json")
+
+	_, err := ReadErrorCounters(fileName)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	counters := NewErrorCounters()
+	counters.Add(ErrorCategoryParse)
+	counters.AddError(httpStatusError(502))
+	counters.AddError(httpStatusError(500))
+
+	require.NoError(t, counters.Write(fileName))
+
+	// counters are kept across restarts.
+	readCounters, err := ReadErrorCounters(fileName)
+	require.NoError(t, err)
+	require.Equal(t, map[ErrorCategory]int64{
+		ErrorCategoryNetwork:    0,
+		ErrorCategoryClient:     0,
+		ErrorCategoryServer:     2,
+		ErrorCategoryParse:      1,
+		ErrorCategoryPermission: 0,
+		ErrorCategoryOther:      0,
+	}, readCounters.Counts())
+
+	// nil counters count nothing.
+	var nilCounters *ErrorCounters
+	nilCounters.Add(ErrorCategoryNetwork)
+	require.Zero(t, nilCounters.Counts()[ErrorCategoryNetwork])
+
+	// corrupt counters file is reported.
+	require.NoError(t, os.WriteFile(fileName, []byte("{"), 0o600))
+	_, err = ReadErrorCounters(fileName)
+	require.Error(t, err)
+}
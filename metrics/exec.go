@@ -0,0 +1,281 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+const (
+	recordedCommandFileExt    = ".json"
+	recordedCommandPermission = 0o600
+	recordDirPermissions      = 0o700
+	// recordedOSInfoFile is the file OS info external commands are chosen by is recorded into.
+	recordedOSInfoFile = "os-info.txt"
+)
+
+var (
+	errCommandNotRecorded     = errors.New("command output is not recorded")
+	errPackageDatabaseSkipped = errors.New("package database is not read while external commands are recorded or replayed")
+)
+
+// recordedCommand represents raw output of external command recorded into file.
+type recordedCommand struct {
+	// Args are the command arguments, the command is recorded by its base name.
+	Args   []string `json:"args"`
+	Output string   `json:"output"`
+	// ExitCode is the exit code of the command, -1 if it was not started or was killed.
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// replayedExitError is returned by replayed command which exited with non-zero code.
+type replayedExitError struct {
+	code int
+	msg  string
+}
+
+func (e *replayedExitError) Error() string {
+	return e.msg
+}
+
+// ExitCode returns exit code of replayed command.
+func (e *replayedExitError) ExitCode() int {
+	return e.code
+}
+
+// CommandRecorder records raw output of external commands (package managers, uname) run while scraping metrics
+// into directory, or replays it from there instead of running the commands, so field-reported parsing bugs
+// can be reproduced on any host. It is safe for concurrent use.
+type CommandRecorder struct {
+	dir    string
+	replay bool
+
+	mu       sync.Mutex
+	commands map[string]*recordedCommand
+	// names are base names of recorded commands, they are looked up in replay mode instead of PATH.
+	names  map[string]struct{}
+	osInfo string
+}
+
+type commandRecorderKey struct{}
+
+// NewCommandRecorder creates CommandRecorder recording output of external commands into dir.
+func NewCommandRecorder(dir string) (*CommandRecorder, error) {
+	cleanDir := filepath.Clean(dir)
+	if err := os.MkdirAll(cleanDir, os.ModeDir|recordDirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create record directory: %w", err)
+	}
+
+	return &CommandRecorder{dir: cleanDir}, nil
+}
+
+// NewCommandReplayer creates CommandRecorder replaying output of external commands recorded into dir
+// by CommandRecorder, commands which were not recorded fail as if they were not found.
+func NewCommandReplayer(dir string) (*CommandRecorder, error) {
+	cleanDir := filepath.Clean(dir)
+
+	files, err := os.ReadDir(cleanDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay directory: %w", err)
+	}
+
+	r := &CommandRecorder{
+		dir:      cleanDir,
+		replay:   true,
+		commands: make(map[string]*recordedCommand),
+		names:    make(map[string]struct{}),
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != recordedCommandFileExt {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(cleanDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recorded command: %w", err)
+		}
+
+		cmd := &recordedCommand{}
+		if err := json.Unmarshal(data, cmd); err != nil || len(cmd.Args) == 0 {
+			return nil, fmt.Errorf("invalid recorded command %s: %w", file.Name(), err)
+		}
+
+		r.commands[recordedCommandKey(cmd.Args)] = cmd
+		r.names[cmd.Args[0]] = struct{}{}
+	}
+
+	osInfo, err := os.ReadFile(filepath.Join(cleanDir, recordedOSInfoFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read recorded OS info: %w", err)
+	}
+
+	r.osInfo = strings.TrimSpace(string(osInfo))
+
+	return r, nil
+}
+
+// WithCommandRecorder returns copy of ctx external commands run with it are recorded or replayed by r.
+func WithCommandRecorder(ctx context.Context, r *CommandRecorder) context.Context {
+	return context.WithValue(ctx, commandRecorderKey{}, r)
+}
+
+func commandRecorderFromContext(ctx context.Context) *CommandRecorder {
+	r, _ := ctx.Value(commandRecorderKey{}).(*CommandRecorder)
+	return r
+}
+
+// recordedCommandKey returns key of command, path of the command binary differs between hosts,
+// so only its base name is used.
+func recordedCommandKey(args []string) string {
+	return filepath.Base(args[0]) + "\x00" + strings.Join(args[1:], "\x00")
+}
+
+// recordedCommandFile returns name of the file command output is recorded into, e.g. 'dpkg-query-1a2b3c4d5e6f.json'.
+func recordedCommandFile(args []string) string {
+	sum := sha256.Sum256([]byte(recordedCommandKey(args)))
+	return filepath.Base(args[0]) + "-" + hex.EncodeToString(sum[:6]) + recordedCommandFileExt
+}
+
+func (r *CommandRecorder) record(args []string, output []byte, cmdErr error) {
+	cmd := &recordedCommand{
+		Args:     append([]string{filepath.Base(args[0])}, args[1:]...),
+		Output:   string(output),
+		ExitCode: cmdExitCode(cmdErr),
+	}
+
+	if cmdErr == nil {
+		cmd.ExitCode = 0
+	} else {
+		cmd.Error = cmdErr.Error()
+	}
+
+	data, err := json.MarshalIndent(cmd, "", "  ")
+	if err != nil {
+		zap.L().Sugar().Warnw("failed to marshal recorded command", zap.Error(err))
+		return
+	}
+
+	fileName := filepath.Join(r.dir, recordedCommandFile(args))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.WriteFile(fileName, data, recordedCommandPermission); err != nil {
+		zap.L().Sugar().Warnw("failed to record command output", zap.String("file", fileName), zap.Error(err))
+	}
+}
+
+func (r *CommandRecorder) replayCommand(args []string) ([]byte, error) {
+	cmd, ok := r.commands[recordedCommandKey(args)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errCommandNotRecorded, strings.Join(args, " "))
+	}
+
+	switch {
+	case cmd.ExitCode > 0:
+		return []byte(cmd.Output), &replayedExitError{code: cmd.ExitCode, msg: cmd.Error}
+	case len(cmd.Error) != 0:
+		return []byte(cmd.Output), errors.New(cmd.Error)
+	default:
+		return []byte(cmd.Output), nil
+	}
+}
+
+// runCommand runs external command and returns its combined output. The output is recorded or replayed
+// if CommandRecorder is attached to ctx.
+func runCommand(ctx context.Context, args []string) ([]byte, error) {
+	r := commandRecorderFromContext(ctx)
+	if r != nil && r.replay {
+		return r.replayCommand(args)
+	}
+
+	output, err := newCommand(ctx, args).CombinedOutput()
+	if r != nil {
+		r.record(args, output, err)
+	}
+
+	return output, err
+}
+
+// lookPath searches for command binary in PATH, recorded commands are looked up in replay mode instead.
+func lookPath(ctx context.Context, name string) (string, error) {
+	if r := commandRecorderFromContext(ctx); r != nil && r.replay {
+		if _, ok := r.names[name]; ok {
+			return name, nil
+		}
+
+		return "", fmt.Errorf("%w: %s", errCommandNotRecorded, name)
+	}
+
+	return exec.LookPath(name)
+}
+
+// localOSInfo returns OS info package manager is chosen by. It is recorded along with commands output,
+// so the same package manager commands are replayed on any host.
+func localOSInfo(ctx context.Context) string {
+	r := commandRecorderFromContext(ctx)
+	if r != nil && r.replay && len(r.osInfo) != 0 {
+		return r.osInfo
+	}
+
+	osInfo := getOSInfo()
+
+	if r != nil && !r.replay {
+		fileName := filepath.Join(r.dir, recordedOSInfoFile)
+		if err := os.WriteFile(fileName, []byte(osInfo+"\n"), recordedCommandPermission); err != nil {
+			logger.FromContext(ctx).Sugar().Warnw("failed to record OS info", zap.String("file", fileName), zap.Error(err))
+		}
+	}
+
+	return osInfo
+}
+
+// newCommand returns command ready for execution with C locale forced,
+// so the command output doesn't depend on host locale settings and can be parsed reliably.
+func newCommand(ctx context.Context, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204
+	// LC_ALL has precedence over LANG and other LC_* variables.
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	return cmd
+}
+
+// cmdExitCode returns exit code of the command if err is caused by non-zero command exit status,
+// -1 otherwise.
+func cmdExitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
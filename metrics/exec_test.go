@@ -0,0 +1,120 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := newCommand(context.Background(), []string{"dpkg-query", "-W", "percona-*"})
+	require.Equal(t, []string{"dpkg-query", "-W", "percona-*"}, cmd.Args)
+	// the last value has precedence in case LC_ALL is already set in agent environment.
+	require.Equal(t, "LC_ALL=C", cmd.Env[len(cmd.Env)-1])
+}
+
+func TestCmdExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "exit_error",
+			err:      testExitError(1),
+			expected: 1,
+		},
+		{
+			name:     "wrapped_exit_error",
+			err:      fmt.Errorf("failed to query package: %w", testExitError(100)),
+			expected: 100,
+		},
+		{
+			name:     "other_error",
+			err:      errors.New("signal: killed"),
+			expected: -1,
+		},
+		{
+			name:     "no_error",
+			err:      nil,
+			expected: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, cmdExitCode(tt.err))
+		})
+	}
+}
+
+func TestRecordReplayCommand(t *testing.T) {
+	t.Parallel()
+
+	recordDir := t.TempDir()
+
+	recorder, err := NewCommandRecorder(recordDir)
+	require.NoError(t, err)
+
+	ctx := WithCommandRecorder(t.Context(), recorder)
+
+	output, err := runCommand(ctx, []string{"sh", "-c", "echo 'ii |percona-server-server|8.0.35-27-1.jammy|amd64'"})
+	require.NoError(t, err)
+	require.Equal(t, "ii |percona-server-server|8.0.35-27-1.jammy|amd64\n", string(output))
+
+	output, err = runCommand(ctx, []string{"sh", "-c", "echo 'not found'; exit 3"})
+	require.Equal(t, 3, cmdExitCode(err))
+	require.Equal(t, "not found\n", string(output))
+
+	osInfo := localOSInfo(ctx)
+
+	// recorded output is replayed without running the commands.
+	replayer, err := NewCommandReplayer(recordDir)
+	require.NoError(t, err)
+
+	ctx = WithCommandRecorder(t.Context(), replayer)
+
+	output, err = runCommand(ctx, []string{"sh", "-c", "echo 'ii |percona-server-server|8.0.35-27-1.jammy|amd64'"})
+	require.NoError(t, err)
+	require.Equal(t, "ii |percona-server-server|8.0.35-27-1.jammy|amd64\n", string(output))
+
+	output, err = runCommand(ctx, []string{"/bin/sh", "-c", "echo 'not found'; exit 3"})
+	require.Equal(t, 3, cmdExitCode(err))
+	require.Equal(t, "not found\n", string(output))
+
+	_, err = runCommand(ctx, []string{"sh", "-c", "echo 'not recorded'"})
+	require.ErrorIs(t, err, errCommandNotRecorded)
+
+	path, err := lookPath(ctx, "sh")
+	require.NoError(t, err)
+	require.Equal(t, "sh", path)
+
+	_, err = lookPath(ctx, "dpkg-query")
+	require.ErrorIs(t, err, errCommandNotRecorded)
+
+	require.Equal(t, osInfo, localOSInfo(ctx))
+}
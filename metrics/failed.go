@@ -0,0 +1,137 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+const (
+	// sendAttemptsFileExt is the extension of sidecar file tracking failed send attempts of metrics file.
+	// Example: '1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json.attempts'.
+	sendAttemptsFileExt = ".attempts"
+	// FailedErrorFileExt is the extension of file with the last send error written next to failed metrics file.
+	FailedErrorFileExt = ".error"
+)
+
+// sendAttempts is the content of send attempts sidecar file.
+type sendAttempts struct {
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	LastTime  time.Time `json:"last_time"`
+}
+
+// RegisterSendFailure registers failed attempt to send metrics file in its sidecar file, so attempts are counted
+// across agent restarts. Once the file fails maxAttempts times, it is moved into failedDir along with the last error,
+// so it is not retried anymore. Attempts are not limited if maxAttempts is not positive.
+// It returns true if the file is moved into failedDir.
+func RegisterSendFailure(ctx context.Context, fileName, failedDir string, sendErr error, maxAttempts int) (bool, error) {
+	if maxAttempts <= 0 {
+		return false, nil
+	}
+
+	attemptsFile := filepath.Clean(fileName + sendAttemptsFileExt)
+
+	var attempts sendAttempts
+
+	content, err := os.ReadFile(attemptsFile)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(content, &attempts); err != nil {
+			// start over, corrupt sidecar file must not block the metrics file.
+			logger.FromContext(ctx).Sugar().Warnw("failed to parse send attempts file, attempts are counted from scratch",
+				zap.String("file", attemptsFile),
+				zap.Error(err))
+
+			attempts = sendAttempts{}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return false, fmt.Errorf("can't read send attempts file: %w", err)
+	}
+
+	attempts.Attempts++
+	attempts.LastError = sendErr.Error()
+	attempts.LastTime = time.Now()
+
+	content, err = json.MarshalIndent(attempts, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("can't marshal send attempts: %w", err)
+	}
+
+	if attempts.Attempts < maxAttempts {
+		if err := writeFileAtomically(attemptsFile, content, metricsFilePermissions); err != nil {
+			return false, fmt.Errorf("can't write send attempts file: %w", err)
+		}
+
+		return false, nil
+	}
+
+	if err := moveFailedMetricsFile(ctx, fileName, failedDir, content); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// moveFailedMetricsFile moves metrics file into failedDir and writes failure details next to it.
+func moveFailedMetricsFile(ctx context.Context, fileName, failedDir string, failure []byte) error {
+	failedDir = filepath.Clean(failedDir)
+
+	if err := os.MkdirAll(failedDir, os.ModeDir|quarantineDirPermissions); err != nil {
+		return fmt.Errorf("can't create failed metrics directory: %w", err)
+	}
+
+	failedFile := filepath.Join(failedDir, filepath.Base(fileName))
+
+	if err := os.Rename(fileName, failedFile); err != nil {
+		return fmt.Errorf("can't move metrics file into failed metrics directory: %w", err)
+	}
+
+	l := logger.FromContext(ctx).Sugar().With(zap.String("file", fileName))
+
+	if err := moveChecksumFile(fileName, failedFile); err != nil {
+		l.Warnw("failed to move checksum file into failed metrics directory", zap.Error(err))
+	}
+
+	// retention is counted from the moment file is moved, as for quarantined files.
+	now := time.Now()
+	if err := os.Chtimes(failedFile, now, now); err != nil {
+		l.Warnw("failed to update failed metrics file modification time", zap.Error(err))
+	}
+
+	// checksum file is optional, so it may be absent.
+	_ = os.Chtimes(failedFile+checksumFileExt, now, now)
+
+	if err := os.WriteFile(failedFile+FailedErrorFileExt, failure, metricsFilePermissions); err != nil {
+		l.Warnw("failed to write last send error of failed metrics file", zap.Error(err))
+	}
+
+	if err := os.Remove(fileName + sendAttemptsFileExt); err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.Warnw("failed to remove send attempts file", zap.Error(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterSendFailure(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		maxAttempts      int
+		failures         int
+		expectedMoved    bool
+		expectedAttempts int
+	}{
+		{
+			name:             "below_limit",
+			maxAttempts:      3,
+			failures:         2,
+			expectedMoved:    false,
+			expectedAttempts: 2,
+		},
+		{
+			name:          "limit_reached",
+			maxAttempts:   3,
+			failures:      3,
+			expectedMoved: true,
+		},
+		{
+			name:          "limit_disabled",
+			maxAttempts:   0,
+			failures:      5,
+			expectedMoved: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			pillarDir := filepath.Join(rootDir, "ps")
+			failedDir := filepath.Join(rootDir, "failed", "ps")
+			fileName := filepath.Join(pillarDir, "1708026156-a.json")
+
+			require.NoError(t, os.MkdirAll(pillarDir, 0o700))
+			require.NoError(t, os.WriteFile(fileName, []byte(`{}`), 0o600))
+			require.NoError(t, os.WriteFile(fileName+checksumFileExt, []byte(`checksum`), 0o600))
+
+			var moved bool
+
+			for i := range tt.failures {
+				var err error
+
+				moved, err = RegisterSendFailure(t.Context(), fileName, failedDir, errors.New("send error"), tt.maxAttempts)
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedMoved && i == tt.failures-1, moved)
+			}
+
+			if !tt.expectedMoved {
+				require.FileExists(t, fileName)
+				require.NoDirExists(t, failedDir)
+
+				if tt.expectedAttempts == 0 {
+					require.NoFileExists(t, fileName+sendAttemptsFileExt)
+					return
+				}
+
+				content, err := os.ReadFile(fileName + sendAttemptsFileExt)
+				require.NoError(t, err)
+
+				var attempts sendAttempts
+				require.NoError(t, json.Unmarshal(content, &attempts))
+				require.Equal(t, tt.expectedAttempts, attempts.Attempts)
+				require.Equal(t, "send error", attempts.LastError)
+
+				// removing metrics file removes its attempts sidecar as well.
+				require.NoError(t, RemoveMetricsFile(fileName))
+				require.NoFileExists(t, fileName+sendAttemptsFileExt)
+
+				return
+			}
+
+			failedFile := filepath.Join(failedDir, filepath.Base(fileName))
+			require.NoFileExists(t, fileName)
+			require.NoFileExists(t, fileName+checksumFileExt)
+			require.NoFileExists(t, fileName+sendAttemptsFileExt)
+			require.FileExists(t, failedFile)
+			require.FileExists(t, failedFile+checksumFileExt)
+
+			content, err := os.ReadFile(failedFile + FailedErrorFileExt)
+			require.NoError(t, err)
+
+			var attempts sendAttempts
+			require.NoError(t, json.Unmarshal(content, &attempts))
+			require.Equal(t, tt.maxAttempts, attempts.Attempts)
+			require.Equal(t, "send error", attempts.LastError)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// InstallationAgeKey is the host metric key of the number of days since Telemetry Agent first ran on the host.
+const InstallationAgeKey = "installation_age_days"
+
+// firstSeenFilePermissions allows monitoring scripts running as other users to read first seen file.
+const firstSeenFilePermissions = 0o644
+
+// firstSeen represents the content of first seen file. It is owned by Telemetry Agent, so Percona telemetry file
+// shared with Pillars is not changed.
+type firstSeen struct {
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// ReadFirstSeen reads the time Telemetry Agent first ran on the host from first seen file.
+func ReadFirstSeen(fileName string) (time.Time, error) {
+	content, err := os.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var fs firstSeen
+	if err := json.Unmarshal(content, &fs); err != nil {
+		return time.Time{}, fmt.Errorf("can't parse first seen file: %w", err)
+	}
+
+	return fs.FirstSeen, nil
+}
+
+// WriteFirstSeen writes first seen file atomically, so readers never see partially written file.
+func WriteFirstSeen(fileName string, t time.Time) error {
+	content, err := json.Marshal(firstSeen{FirstSeen: t.UTC().Truncate(time.Second)})
+	if err != nil {
+		return fmt.Errorf("can't marshal first seen time: %w", err)
+	}
+
+	if err := writeFileAtomically(filepath.Clean(fileName), content, firstSeenFilePermissions); err != nil {
+		return fmt.Errorf("can't write first seen file: %w", err)
+	}
+
+	return nil
+}
+
+// installationAge returns the number of days since the time recorded in first seen file as a metric value.
+// Current time is recorded if the file is absent and record is true, false is returned otherwise.
+func installationAge(fileName string, record bool) (string, bool) {
+	l := zap.L().Sugar().With(zap.String("file", fileName))
+
+	t, err := ReadFirstSeen(fileName)
+	switch {
+	case err == nil:
+	case !errors.Is(err, os.ErrNotExist):
+		l.Warnw("failed to read first seen file, installation age is not reported", zap.Error(err))
+		return "", false
+	case !record:
+		// the file is not created in read-only mode.
+		return "", false
+	default:
+		t = time.Now()
+		if err := WriteFirstSeen(fileName, t); err != nil {
+			l.Warnw("failed to record first seen time, installation age is not reported", zap.Error(err))
+			return "", false
+		}
+	}
+
+	return strconv.Itoa(int(time.Since(t).Hours() / 24)), true
+}
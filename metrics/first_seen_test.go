@@ -0,0 +1,94 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallationAge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		content     *string
+		record      bool
+		expectedAge string
+		expectedOK  bool
+	}{
+		{
+			name:        "recorded",
+			content:     new(`{"first_seen":"` + time.Now().Add(-50*time.Hour).UTC().Format(time.RFC3339) + `"}`),
+			expectedAge: "2",
+			expectedOK:  true,
+		},
+		{
+			name:        "absent_recorded",
+			record:      true,
+			expectedAge: "0",
+			expectedOK:  true,
+		},
+		{
+			name: "absent_read_only",
+		},
+		{
+			name:    "invalid",
+			content: new("yesterday"),
+			record:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileName := filepath.Join(t.TempDir(), "first_seen")
+			if tt.content != nil {
+				require.NoError(t, os.WriteFile(fileName, []byte(*tt.content), firstSeenFilePermissions))
+			}
+
+			age, ok := installationAge(fileName, tt.record)
+			require.Equal(t, tt.expectedOK, ok)
+			require.Equal(t, tt.expectedAge, age)
+
+			if tt.content == nil && !tt.record {
+				// the file is not created in read-only mode.
+				require.NoFileExists(t, fileName)
+				return
+			}
+
+			if !ok {
+				return
+			}
+
+			// the first seen time is kept on next calls.
+			firstSeen, err := ReadFirstSeen(fileName)
+			require.NoError(t, err)
+
+			_, ok = installationAge(fileName, tt.record)
+			require.True(t, ok)
+
+			again, err := ReadFirstSeen(fileName)
+			require.NoError(t, err)
+			require.Equal(t, firstSeen, again)
+		})
+	}
+}
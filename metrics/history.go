@@ -16,26 +16,55 @@
 package metrics
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
 	platformReporter "github.com/percona/platform/gen/telemetry/generic"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/percona/telemetry-agent/logger"
 )
 
 const (
 	metricsFilePermissions = 0o755
+	// HistoryCompressedFileExt is appended to the name of gzip compressed history file.
+	// Example: '1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json.gz'.
+	HistoryCompressedFileExt = ".gz"
+	// tempFileExt is the extension of temporary files history files are written into before renaming.
+	tempFileExt = ".tmp"
+	// staleTempFileAge is the age temporary files left after crash are removed after.
+	staleTempFileAge = time.Hour
 )
 
+// historyDeliveryKey is the key of delivery metadata in history file JSON, next to the fields of telemetry request.
+const historyDeliveryKey = "delivery"
+
+// HistoryDelivery is delivery metadata of telemetry request sent to Percona Platform, so support can correlate
+// the report with server-side logs.
+type HistoryDelivery struct {
+	SentAt time.Time `json:"sent_at"`
+	// RequestID is the request ID assigned by Percona Platform, empty if its response has none.
+	RequestID string `json:"request_id,omitempty"`
+	// Suppressed is set if the report was not sent as a duplicate of recently sent one,
+	// SentAt is the time it was suppressed at then.
+	Suppressed bool `json:"suppressed,omitempty"`
+}
+
 // WriteMetricsToHistory creates a new telemetry history file and writes the content of
-// Percona Platform telemetry request into it. Content is written using JSON format.
-func WriteMetricsToHistory(historyFile string, platformReport *platformReporter.ReportRequest) error {
+// Percona Platform telemetry request and its delivery metadata (if not nil) into it. Content is written using JSON format,
+// it is gzip compressed if history file name has HistoryCompressedFileExt extension.
+func WriteMetricsToHistory(historyFile string, platformReport *platformReporter.ReportRequest, delivery *HistoryDelivery) error {
 	l := zap.L().Sugar()
 	if platformReport == nil || len(platformReport.GetReports()) == 0 {
 		l.Errorw("attempt to write invalid Percona Platform report into history file",
@@ -62,7 +91,22 @@ func WriteMetricsToHistory(historyFile string, platformReport *platformReporter.
 		return fmt.Errorf("can't marshal Percona Platform report into JSON: %w", err)
 	}
 
-	err = os.WriteFile(cleanFilePath, jsonBytes, metricsFilePermissions)
+	if delivery != nil {
+		jsonBytes, err = addHistoryDelivery(jsonBytes, delivery)
+		if err != nil {
+			return fmt.Errorf("can't add delivery metadata to Percona Platform report: %w", err)
+		}
+	}
+
+	if isCompressedHistoryFile(cleanFilePath) {
+		jsonBytes, err = compressHistory(jsonBytes)
+		if err != nil {
+			l.Errorw("failed to compress Percona Platform report", zap.Error(err))
+			return fmt.Errorf("can't compress Percona Platform report: %w", err)
+		}
+	}
+
+	err = writeFileAtomically(cleanFilePath, jsonBytes, metricsFilePermissions)
 	if err != nil {
 		l.Errorw("failed to write history file",
 			zap.String("file", historyFile),
@@ -74,47 +118,124 @@ func WriteMetricsToHistory(historyFile string, platformReport *platformReporter.
 	return nil
 }
 
-// CleanupMetricsHistory removes all telemetry files from history directory that are older than threshold.
+// writeFileAtomically writes data into temporary file in the same directory and renames it to fileName,
+// so fileName never has partially written content. Temporary file has tempFileExt extension,
+// so it is never taken for history file if it is left after crash.
+func writeFileAtomically(fileName string, data []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*"+tempFileExt)
+	if err != nil {
+		return err
+	}
+
+	tmpFileName := tmpFile.Name()
+	defer func() {
+		// no-op if temporary file is renamed already.
+		_ = os.Remove(tmpFileName)
+	}()
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+
+	if cErr := tmpFile.Close(); err == nil {
+		err = cErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmpFileName, perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFileName, fileName)
+}
+
+// HistoryFile represents telemetry history file.
+type HistoryFile struct {
+	Name string
+	// Timestamp is the creation time of the history file.
+	Timestamp time.Time
+	Size      int64
+}
+
+// ListMetricsHistory returns telemetry history files (both plain and compressed) sorted by creation time.
 // File creation time is taken from file name - it contains unixtime in format:
-// <unixtime>-<random token>.json.
-func CleanupMetricsHistory(historyDirectoryPath string, keepInterval int) error {
+// <unixtime>-<random token>.json, file modification time is used for files not following this format.
+func ListMetricsHistory(historyDirectoryPath string) ([]*HistoryFile, error) {
 	l := zap.L().Sugar()
 
 	cleanHistoryPath := filepath.Clean(historyDirectoryPath)
 	// check that directory exists
 	err := validateDirectory(cleanHistoryPath)
 	if err != nil {
-		return fmt.Errorf("can't read directory with history metrics files: %w", err)
+		return nil, fmt.Errorf("can't read directory with history metrics files: %w", err)
 	}
 
 	files, err := os.ReadDir(cleanHistoryPath)
 	if err != nil {
-		return fmt.Errorf("can't read directory with history metrics files: %w", err)
+		return nil, fmt.Errorf("can't read directory with history metrics files: %w", err)
 	}
 
-	timeThreshold := time.Now().Add(-time.Duration(keepInterval) * time.Second)
+	historyFiles := make([]*HistoryFile, 0, len(files))
 
 	for _, file := range files {
 		fl := l.With(zap.String("file", filepath.Join(cleanHistoryPath, file.Name())))
 
-		fileExt := filepath.Ext(file.Name())
-		if !file.Type().IsRegular() || fileExt != ".json" {
+		if !file.Type().IsRegular() || !isHistoryFile(file.Name()) {
 			fl.Debug("seems not a metrics file, skipping")
 			continue
 		}
 
-		fileCreationTime, err := strconv.Atoi(strings.Split(
-			strings.TrimSuffix(filepath.Base(file.Name()), fileExt),
-			"-")[0])
+		info, err := file.Info()
 		if err != nil {
-			fl.Warnw("can't convert filename into int, skipping", zap.Error(err))
+			fl.Warnw("can't get file info, skipping", zap.Error(err))
 			continue
 		}
 
-		t := time.Unix(int64(fileCreationTime), 0)
-		if t.After(timeThreshold) {
+		t, err := parseFileNameTimestamp(file.Name())
+		if err != nil {
+			// history file of metrics file without unixtime prefix in its name, use its modification time instead.
+			t = info.ModTime()
+		}
+
+		historyFiles = append(historyFiles, &HistoryFile{
+			Name:      filepath.Join(cleanHistoryPath, file.Name()),
+			Timestamp: t,
+			Size:      info.Size(),
+		})
+	}
+
+	slices.SortStableFunc(historyFiles, func(a, b *HistoryFile) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return historyFiles, nil
+}
+
+// CleanupMetricsHistory removes all telemetry files from history directory that are older than threshold.
+// File creation time is determined the same way as in ListMetricsHistory.
+func CleanupMetricsHistory(ctx context.Context, historyDirectoryPath string, keepInterval int) error {
+	l := logger.FromContext(ctx).Sugar()
+
+	historyFiles, err := ListMetricsHistory(historyDirectoryPath)
+	if err != nil {
+		return err
+	}
+
+	removeStaleTempFiles(ctx, filepath.Clean(historyDirectoryPath))
+
+	timeThreshold := time.Now().Add(-time.Duration(keepInterval) * time.Second)
+
+	for _, file := range historyFiles {
+		fl := l.With(zap.String("file", file.Name))
+
+		if file.Timestamp.After(timeThreshold) {
 			fl.Debugw("file age threshold is not reached, skipping",
-				zap.Time("creationTime", t),
+				zap.Time("creationTime", file.Timestamp),
 				zap.Time("threshold", timeThreshold))
 
 			continue
@@ -122,16 +243,233 @@ func CleanupMetricsHistory(historyDirectoryPath string, keepInterval int) error
 
 		fl.Debug("removing file")
 
-		err = os.Remove(filepath.Clean(filepath.Join(cleanHistoryPath, file.Name())))
+		err = os.Remove(file.Name)
+		if err != nil {
+			fl.Errorw("error removing metric file, skipping", zap.Error(err))
+			continue
+		}
+	}
+
+	return nil
+}
+
+// CleanupMetricsHistoryBySize removes the oldest telemetry files from history directory
+// until total size of history files doesn't exceed maxSize bytes.
+func CleanupMetricsHistoryBySize(ctx context.Context, historyDirectoryPath string, maxSize int64) error {
+	l := logger.FromContext(ctx).Sugar()
+
+	historyFiles, err := ListMetricsHistory(historyDirectoryPath)
+	if err != nil {
+		return err
+	}
+
+	var totalSize int64
+	for _, file := range historyFiles {
+		totalSize += file.Size
+	}
+
+	for _, file := range historyFiles {
+		if totalSize <= maxSize {
+			break
+		}
+
+		fl := l.With(zap.String("file", file.Name))
+		fl.Debugw("history directory size limit is exceeded, removing the oldest file",
+			zap.Int64("size", totalSize),
+			zap.Int64("limit", maxSize))
+
+		err = os.Remove(file.Name)
+		if err != nil {
+			fl.Errorw("error removing metric file, skipping", zap.Error(err))
+			continue
+		}
+
+		totalSize -= file.Size
+	}
+
+	return nil
+}
+
+// CleanupMetricsHistoryByCount removes the oldest telemetry files from history directory
+// until the number of history files doesn't exceed maxFiles.
+func CleanupMetricsHistoryByCount(ctx context.Context, historyDirectoryPath string, maxFiles int) error {
+	l := logger.FromContext(ctx).Sugar()
+
+	historyFiles, err := ListMetricsHistory(historyDirectoryPath)
+	if err != nil {
+		return err
+	}
+
+	filesCount := len(historyFiles)
+
+	for _, file := range historyFiles {
+		if filesCount <= maxFiles {
+			break
+		}
+
+		fl := l.With(zap.String("file", file.Name))
+		fl.Debugw("history directory files limit is exceeded, removing the oldest file",
+			zap.Int("count", filesCount),
+			zap.Int("limit", maxFiles))
+
+		err = os.Remove(file.Name)
 		if err != nil {
 			fl.Errorw("error removing metric file, skipping", zap.Error(err))
 			continue
 		}
+
+		filesCount--
 	}
 
 	return nil
 }
 
+// ReadMetricsHistory reads Percona Platform telemetry request and its delivery metadata from telemetry history file,
+// both plain and gzip compressed history files are supported. Delivery metadata is nil if the file has none.
+func ReadMetricsHistory(historyFile string) (*platformReporter.ReportRequest, *HistoryDelivery, error) {
+	cleanFilePath := filepath.Clean(historyFile)
+
+	content, err := os.ReadFile(cleanFilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't read history file: %w", err)
+	}
+
+	if isCompressedHistoryFile(cleanFilePath) {
+		content, err = decompressHistory(content)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't decompress history file: %w", err)
+		}
+	}
+
+	content, delivery, err := splitHistoryDelivery(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't unmarshal history file: %w", err)
+	}
+
+	platformReport := &platformReporter.ReportRequest{}
+
+	err = protojson.Unmarshal(content, platformReport)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't unmarshal history file: %w", err)
+	}
+
+	return platformReport, delivery, nil
+}
+
+// addHistoryDelivery adds delivery metadata to JSON of telemetry request.
+func addHistoryDelivery(content []byte, delivery *HistoryDelivery) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	deliveryJSON, err := json.Marshal(delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	fields[historyDeliveryKey] = deliveryJSON
+
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// splitHistoryDelivery removes delivery metadata from history file content, so the rest is telemetry request JSON.
+// Content is returned as is if it has no delivery metadata.
+func splitHistoryDelivery(content []byte) ([]byte, *HistoryDelivery, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	deliveryJSON, ok := fields[historyDeliveryKey]
+	if !ok {
+		return content, nil, nil
+	}
+
+	delivery := &HistoryDelivery{}
+	if err := json.Unmarshal(deliveryJSON, delivery); err != nil {
+		return nil, nil, err
+	}
+
+	delete(fields, historyDeliveryKey)
+
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return content, delivery, nil
+}
+
+// removeStaleTempFiles removes temporary files left in history directory after crash during writing history file.
+// Recent temporary files are kept, since they may be being written right now.
+func removeStaleTempFiles(ctx context.Context, historyDirectoryPath string) {
+	l := logger.FromContext(ctx).Sugar()
+
+	files, err := os.ReadDir(historyDirectoryPath)
+	if err != nil {
+		l.Warnw("can't read directory with history metrics files", zap.Error(err))
+		return
+	}
+
+	for _, file := range files {
+		if !file.Type().IsRegular() || filepath.Ext(file.Name()) != tempFileExt {
+			continue
+		}
+
+		fileName := filepath.Join(historyDirectoryPath, file.Name())
+		fl := l.With(zap.String("file", fileName))
+
+		info, err := file.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempFileAge {
+			continue
+		}
+
+		fl.Debug("removing stale temporary file")
+
+		if err := os.Remove(fileName); err != nil {
+			fl.Warnw("error removing stale temporary file", zap.Error(err))
+		}
+	}
+}
+
+// isHistoryFile returns true if file name is the name of plain or compressed history file.
+func isHistoryFile(fileName string) bool {
+	return filepath.Ext(strings.TrimSuffix(fileName, HistoryCompressedFileExt)) == ".json"
+}
+
+func isCompressedHistoryFile(fileName string) bool {
+	return filepath.Ext(fileName) == HistoryCompressedFileExt
+}
+
+func compressHistory(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompressHistory(content []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	return io.ReadAll(zr)
+}
+
 func validateDirectory(dirPath string) error {
 	info, err := os.Stat(dirPath)
 	if os.IsNotExist(err) {
@@ -16,6 +16,8 @@
 package metrics
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -203,7 +205,7 @@ func TestWriteMetricsToHistory(t *testing.T) {
 			historyFile := fmt.Sprintf("%d-history.json", currTime.Unix())
 			tt.setupTestData(t, tmpDir, token, currTime)
 
-			err := WriteMetricsToHistory(filepath.Join(tmpDir, historyFile), tt.request)
+			err := WriteMetricsToHistory(filepath.Join(tmpDir, historyFile), tt.request, nil)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -269,6 +271,71 @@ func TestCleanupMetricsHistory(t *testing.T) {
 			keepInterval: 3600,
 			wantErr:      false,
 		},
+		{
+			name: "compressed_files_beyond_keep_interval",
+			setupTestData: func(t *testing.T, tmpDir string) {
+				t.Helper()
+				writeTempFiles(t, tmpDir,
+					fmt.Sprintf("%d-%s.json.gz", currTime.Unix(), token),
+					fmt.Sprintf("%d-%s.json.gz", (currTime.Add(-2*time.Hour)).Unix(), token),
+					fmt.Sprintf("%d-%s.json", (currTime.Add(-24*time.Hour)).Unix(), token))
+			},
+			postCheckTestData: func(t *testing.T, tmpDir string) {
+				t.Helper()
+				// plain and compressed files are handled the same way
+				checkDirectoryContentCount(t, tmpDir, 1)
+				checkFilesExist(t, tmpDir, fmt.Sprintf("%d-%s.json.gz", currTime.Unix(), token))
+				checkFilesAbsent(t, tmpDir,
+					fmt.Sprintf("%d-%s.json.gz", (currTime.Add(-2*time.Hour)).Unix(), token),
+					fmt.Sprintf("%d-%s.json", (currTime.Add(-24*time.Hour)).Unix(), token))
+			},
+			keepInterval: 3600,
+			wantErr:      false,
+		},
+		{
+			name: "leftover_temp_files",
+			setupTestData: func(t *testing.T, tmpDir string) {
+				t.Helper()
+				writeTempFiles(t, tmpDir,
+					fmt.Sprintf("%d-%s.json", currTime.Unix(), token),
+					fmt.Sprintf("%d-%s.json.123.tmp", currTime.Unix(), token),
+					fmt.Sprintf("%d-%s.json.456.tmp", currTime.Unix(), token))
+
+				oldTime := currTime.Add(-2 * staleTempFileAge)
+				err := os.Chtimes(filepath.Join(tmpDir, fmt.Sprintf("%d-%s.json.456.tmp", currTime.Unix(), token)), oldTime, oldTime)
+				require.NoError(t, err)
+			},
+			postCheckTestData: func(t *testing.T, tmpDir string) {
+				t.Helper()
+				// only stale temp file shall be removed
+				checkDirectoryContentCount(t, tmpDir, 2)
+				checkFilesExist(t, tmpDir,
+					fmt.Sprintf("%d-%s.json", currTime.Unix(), token),
+					fmt.Sprintf("%d-%s.json.123.tmp", currTime.Unix(), token))
+			},
+			keepInterval: 3600,
+			wantErr:      false,
+		},
+		{
+			name: "files_without_unixtime_prefix",
+			setupTestData: func(t *testing.T, tmpDir string) {
+				t.Helper()
+				writeTempFiles(t, tmpDir, "new-metrics.json", "old-metrics.json")
+
+				oldTime := currTime.Add(-2 * time.Hour)
+				err := os.Chtimes(filepath.Join(tmpDir, "old-metrics.json"), oldTime, oldTime)
+				require.NoError(t, err)
+			},
+			postCheckTestData: func(t *testing.T, tmpDir string) {
+				t.Helper()
+				// file age is taken from its modification time
+				checkDirectoryContentCount(t, tmpDir, 1)
+				checkFilesExist(t, tmpDir, "new-metrics.json")
+				checkFilesAbsent(t, tmpDir, "old-metrics.json")
+			},
+			keepInterval: 3600,
+			wantErr:      false,
+		},
 		{
 			name: "empty_directory",
 			setupTestData: func(t *testing.T, _ string) {
@@ -306,7 +373,7 @@ func TestCleanupMetricsHistory(t *testing.T) {
 			tmpDir := t.TempDir()
 			tt.setupTestData(t, tmpDir)
 
-			err := CleanupMetricsHistory(tmpDir, tt.keepInterval)
+			err := CleanupMetricsHistory(t.Context(), tmpDir, tt.keepInterval)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -317,3 +384,164 @@ func TestCleanupMetricsHistory(t *testing.T) {
 		})
 	}
 }
+
+func TestReadMetricsHistory(t *testing.T) {
+	t.Parallel()
+
+	req := &platformReporter.ReportRequest{Reports: []*platformReporter.GenericReport{{
+		Id:            uuid.New().String(),
+		CreateTime:    timestamppb.New(time.Now()),
+		InstanceId:    uuid.New().String(),
+		ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS,
+		Metrics: []*platformReporter.GenericReport_Metric{
+			{Key: "test_metric_1", Value: "test_value_1"},
+		},
+	}}}
+
+	delivery := &HistoryDelivery{
+		SentAt:    time.Date(2024, 2, 15, 19, 42, 36, 0, time.UTC),
+		RequestID: "5f0c6a1e-6f2b-4d1a-9a57-0f3e0c2d7b11",
+	}
+
+	testCases := []struct {
+		name        string
+		historyFile string
+		delivery    *HistoryDelivery
+	}{
+		{
+			name:        "plain",
+			historyFile: "1708026156-history.json",
+		},
+		{
+			name:        "compressed",
+			historyFile: "1708026156-history.json" + HistoryCompressedFileExt,
+		},
+		{
+			name:        "plain_with_delivery",
+			historyFile: "1708026156-history.json",
+			delivery:    delivery,
+		},
+		{
+			name:        "compressed_with_delivery",
+			historyFile: "1708026156-history.json" + HistoryCompressedFileExt,
+			delivery:    delivery,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			historyFile := filepath.Join(t.TempDir(), tt.historyFile)
+			require.NoError(t, WriteMetricsToHistory(historyFile, req, tt.delivery))
+
+			content, err := os.ReadFile(filepath.Clean(historyFile))
+			require.NoError(t, err)
+
+			_, err = gzip.NewReader(bytes.NewReader(content))
+			if isCompressedHistoryFile(historyFile) {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			// no temporary files are left.
+			checkDirectoryContentCount(t, filepath.Dir(historyFile), 1)
+
+			result, resultDelivery, err := ReadMetricsHistory(historyFile)
+			require.NoError(t, err)
+			require.Equal(t, req, result)
+			require.Equal(t, tt.delivery, resultDelivery)
+		})
+	}
+}
+
+func TestCleanupMetricsHistoryBySize(t *testing.T) {
+	t.Parallel()
+
+	currTime, token := time.Now(), uuid.New().String()
+	// content of each file is its name, so all files have the same size.
+	newestFile := fmt.Sprintf("%d-%s.json", currTime.Unix(), token)
+	middleFile := fmt.Sprintf("%d-%s.json", currTime.Add(-10*time.Minute).Unix(), token)
+	oldestFile := fmt.Sprintf("%d-%s.json", currTime.Add(-20*time.Minute).Unix(), token)
+	fileSize := int64(len(newestFile))
+
+	testCases := []struct {
+		name          string
+		maxSize       int64
+		expectedFiles []string
+	}{
+		{
+			name:          "limit_not_exceeded",
+			maxSize:       3 * fileSize,
+			expectedFiles: []string{newestFile, middleFile, oldestFile},
+		},
+		{
+			name:          "limit_exceeded",
+			maxSize:       2*fileSize + 1,
+			expectedFiles: []string{newestFile, middleFile},
+		},
+		{
+			name:          "limit_less_than_single_file",
+			maxSize:       fileSize - 1,
+			expectedFiles: []string{},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			writeTempFiles(t, tmpDir, newestFile, middleFile, oldestFile)
+
+			require.NoError(t, CleanupMetricsHistoryBySize(t.Context(), tmpDir, tt.maxSize))
+			checkDirectoryContentCount(t, tmpDir, len(tt.expectedFiles))
+			checkFilesExist(t, tmpDir, tt.expectedFiles...)
+		})
+	}
+}
+
+func TestCleanupMetricsHistoryByCount(t *testing.T) {
+	t.Parallel()
+
+	currTime, token := time.Now(), uuid.New().String()
+	newestFile := fmt.Sprintf("%d-%s.json", currTime.Unix(), token)
+	middleFile := fmt.Sprintf("%d-%s.json", currTime.Add(-10*time.Minute).Unix(), token)
+	oldestFile := fmt.Sprintf("%d-%s.json", currTime.Add(-20*time.Minute).Unix(), token)
+
+	testCases := []struct {
+		name          string
+		maxFiles      int
+		expectedFiles []string
+	}{
+		{
+			name:          "limit_not_exceeded",
+			maxFiles:      3,
+			expectedFiles: []string{newestFile, middleFile, oldestFile},
+		},
+		{
+			name:          "limit_exceeded",
+			maxFiles:      1,
+			expectedFiles: []string{newestFile},
+		},
+		{
+			name:          "zero_limit",
+			maxFiles:      0,
+			expectedFiles: []string{},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			writeTempFiles(t, tmpDir, newestFile, middleFile, oldestFile)
+
+			require.NoError(t, CleanupMetricsHistoryByCount(t.Context(), tmpDir, tt.maxFiles))
+			checkDirectoryContentCount(t, tmpDir, len(tt.expectedFiles))
+			checkFilesExist(t, tmpDir, tt.expectedFiles...)
+		})
+	}
+}
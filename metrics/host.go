@@ -19,15 +19,17 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
 )
 
 const (
@@ -48,18 +50,75 @@ const (
 // - for instanceID it is random UUID
 // - for OS it is "unknown"
 
+// HostScrapeOpts represents the options for scraping host metrics.
+type HostScrapeOpts struct {
+	// CmdTimeout is the timeout for a single command executed for getting host info (uname, etc.).
+	CmdTimeout time.Duration
+	// ReadOnly disables creating and repairing Percona telemetry file, random instance ID is used if it is absent or invalid.
+	ReadOnly bool
+	// Pod is metadata of Kubernetes pod Telemetry Agent runs in as a sidecar, nil if it doesn't run in Kubernetes.
+	// Pod UID is used as instance ID then, Percona telemetry file is not read and written.
+	Pod *PodInfo
+	// InstanceFile is the file with instance ID read and written the same way as Percona telemetry file,
+	// e.g. of extra telemetry root path. Percona telemetry file is used if it is empty.
+	InstanceFile string
+	// FirstSeenFile is the file the time Telemetry Agent first ran on the host is recorded in,
+	// installation age is not reported if it is empty.
+	FirstSeenFile string
+}
+
+func (o HostScrapeOpts) instanceFile() string {
+	if o.InstanceFile == "" {
+		return telemetryFile
+	}
+
+	return o.InstanceFile
+}
+
+func (o HostScrapeOpts) cmdTimeout() time.Duration {
+	if o.CmdTimeout <= 0 {
+		return defaultCmdTimeout
+	}
+
+	return o.CmdTimeout
+}
+
 // ScrapeHostMetrics gathers metrics about host where Telemetry Agent is running.
 // In addition, it checks Percona telemetry file and extracts instanceId value from it.
-func ScrapeHostMetrics(ctx context.Context) *File {
+func ScrapeHostMetrics(ctx context.Context, opts HostScrapeOpts) *File {
 	f := &File{
 		Timestamp: time.Now(),
-		Filename:  telemetryFile,
+		Filename:  opts.instanceFile(),
 	}
 	f.Metrics = make(map[string]string)
-	f.Metrics[InstanceIDKey] = getInstanceID(telemetryFile)
+	switch {
+	case opts.Pod != nil && len(opts.Pod.UID) != 0:
+		f.Metrics[InstanceIDKey] = opts.Pod.UID
+	case opts.ReadOnly || opts.Pod != nil:
+		// Percona telemetry file is not changed in read-only mode and may be on read-only root filesystem of sidecar.
+		f.Metrics[InstanceIDKey] = readInstanceIDOrRandom(opts.instanceFile())
+	default:
+		f.Metrics[InstanceIDKey] = getInstanceID(opts.instanceFile())
+	}
+
+	if opts.FirstSeenFile != "" {
+		// the first seen time is not recorded in read-only mode.
+		if age, ok := installationAge(opts.FirstSeenFile, !opts.ReadOnly); ok {
+			f.Metrics[InstallationAgeKey] = age
+		}
+	}
+
 	f.Metrics["OS"] = getOSInfo()
+	if !isPackageManagerSupported(getDistroFamily(f.Metrics["OS"])) {
+		// make it clear why installed packages are not reported.
+		f.Metrics["package_manager"] = packageManagerUnsupported
+	}
 	f.Metrics["deployment"] = getDeploymentInfo()
-	f.Metrics["hardware_arch"] = getHardwareInfo(ctx)
+	f.Metrics["hardware_arch"] = getHardwareInfo(ctx, opts.cmdTimeout())
+
+	if opts.Pod != nil {
+		opts.Pod.addHostMetrics(f.Metrics)
+	}
 
 	return f
 }
@@ -73,7 +132,7 @@ func customSplitFunc(data []byte, atEOF bool) (int, []byte, error) {
 		return len(data), data, nil
 	}
 
-	if i := strings.Index(string(data), "\n"); i >= 0 {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
 		// skip the delimiter in advancing to the next pair
 		return i + 1, data[0:i], nil
 	}
@@ -81,6 +140,69 @@ func customSplitFunc(data []byte, atEOF bool) (int, []byte, error) {
 	return 0, nil, nil
 }
 
+// TelemetryFile returns the path of Percona telemetry file with host instance ID.
+func TelemetryFile() string {
+	return telemetryFile
+}
+
+// InitInstanceID returns host instance ID the same way it is scraped with host metrics,
+// Percona telemetry file is created if it is absent or invalid.
+func InitInstanceID() string {
+	return getInstanceID(telemetryFile)
+}
+
+// ErrInvalidInstanceID is returned when Percona telemetry file doesn't contain valid instance ID.
+var ErrInvalidInstanceID = errors.New("invalid Percona telemetry file")
+
+// ReadInstanceID reads host instance ID from Percona telemetry file. Unlike scraping host metrics,
+// the file is not created if it is absent or invalid, empty instance ID is returned in such case.
+func ReadInstanceID(instanceFile string) (string, error) {
+	instanceID, err := ParseInstanceIDFile(instanceFile)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrInvalidInstanceID) {
+		return "", nil
+	}
+
+	return instanceID, err
+}
+
+// ParseInstanceIDFile reads and validates host instance ID from Percona telemetry file.
+// ErrInvalidInstanceID is returned if instance ID is absent or invalid.
+func ParseInstanceIDFile(instanceFile string) (string, error) {
+	data, err := os.ReadFile(filepath.Clean(instanceFile))
+	if err != nil {
+		return "", err
+	}
+
+	for line := range strings.Lines(string(data)) {
+		if key, value, ok := strings.Cut(line, ":"); ok && key == InstanceIDKey {
+			instanceID := strings.TrimSpace(value)
+			if err := uuid.Validate(instanceID); err != nil {
+				return "", fmt.Errorf("%w: instance ID %q: %w", ErrInvalidInstanceID, instanceID, err)
+			}
+
+			return instanceID, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s is absent", ErrInvalidInstanceID, InstanceIDKey)
+}
+
+// WriteInstanceID replaces Percona telemetry file with the one containing given host instance ID.
+// The file is replaced atomically, so running Telemetry Agent never reads partially written file.
+func WriteInstanceID(instanceFile, instanceID string) error {
+	cleanInstanceFile := filepath.Clean(instanceFile)
+
+	if err := os.MkdirAll(filepath.Dir(cleanInstanceFile), os.ModePerm|0o775); err != nil {
+		return fmt.Errorf("can't create directory of Percona telemetry file: %w", err)
+	}
+
+	if err := writeFileAtomically(cleanInstanceFile, fmt.Appendf(nil, "%s:%s\n", InstanceIDKey, instanceID), metricsFilePermissions); err != nil {
+		return fmt.Errorf("can't write Percona telemetry file: %w", err)
+	}
+
+	return nil
+}
+
 func getInstanceID(instanceFile string) string {
 	cleanInstanceFile := filepath.Clean(instanceFile)
 	l := zap.L().Sugar().With(zap.String("file", cleanInstanceFile))
@@ -160,8 +282,8 @@ func getInstanceID(instanceFile string) string {
 	scanner.Split(customSplitFunc)
 
 	for scanner.Scan() {
-		if parts := strings.Split(scanner.Text(), ":"); len(parts) == 2 && parts[0] == InstanceIDKey {
-			instanceID = strings.TrimSpace(parts[1])
+		if key, value, found := strings.Cut(scanner.Text(), ":"); found && key == InstanceIDKey && !strings.Contains(value, ":") {
+			instanceID = strings.TrimSpace(value)
 			break
 		}
 	}
@@ -188,6 +310,25 @@ func getInstanceID(instanceFile string) string {
 	return instanceID
 }
 
+// readInstanceIDOrRandom returns instance ID from Percona telemetry file without changing it,
+// random UUID is returned if the file is absent or invalid.
+func readInstanceIDOrRandom(instanceFile string) string {
+	l := zap.L().Sugar().With(zap.String("file", instanceFile))
+
+	instanceID, err := ReadInstanceID(instanceFile)
+	if err != nil {
+		l.Warnw("failed to read Percona telemetry file, fallback to random UUID", zap.Error(err))
+		return getRandomUUID()
+	}
+
+	if instanceID == "" {
+		l.Info("Percona telemetry file is absent or invalid, fallback to random UUID, the file is not created")
+		return getRandomUUID()
+	}
+
+	return instanceID
+}
+
 func getRandomUUID() string {
 	return uuid.New().String()
 }
@@ -215,6 +356,10 @@ func getOSInfo() string {
 		}
 	}
 
+	if osInfo := getKernelOSInfo(); len(osInfo) != 0 {
+		return osInfo
+	}
+
 	filePath := filepath.Join("/etc", "os-release")
 
 	_, err := os.Stat(filePath)
@@ -250,26 +395,29 @@ func getOSInfo() string {
 	return unknownString
 }
 
-func getHardwareInfo(ctx context.Context) string {
+func getHardwareInfo(ctx context.Context, cmdTimeout time.Duration) string {
 	var (
 		unamePath string
 		err       error
 	)
 
-	unamePath, err = exec.LookPath("uname")
+	if hwInfo := getKernelHardwareInfo(); len(hwInfo) != 0 {
+		return hwInfo
+	}
+
+	unamePath, err = lookPath(ctx, "uname")
 	if err != nil {
-		zap.L().Sugar().Warnw("failed to get hardware info, uname binary is not found", zap.Error(err))
+		logger.FromContext(ctx).Sugar().Warnw("failed to get hardware info, uname binary is not found", zap.Error(err))
 		return fmt.Sprintf("%s %s", unknownString, unknownString)
 	}
 
 	args := []string{unamePath, "-mp"}
-	zap.L().Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
 
-	cmdCtx, cancel := context.WithTimeout(ctx, pkgResultTimeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, args[0], args[1:]...) // #nosec G204
-	outputB, err := cmd.CombinedOutput()
+	outputB, err := runCommand(cmdCtx, args)
 
 	return parseHardwareInfoOutput(outputB, err)
 }
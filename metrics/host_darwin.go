@@ -0,0 +1,63 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// getKernelOSInfo returns macOS product version, e.g. 'macOS 14.4.1'.
+// Darwin kernel name and release are returned if product version is not available, e.g. 'Darwin 23.4.0'.
+func getKernelOSInfo() string {
+	productVersion, err := unix.Sysctl("kern.osproductversion")
+	if err == nil && len(productVersion) != 0 {
+		return "macOS " + productVersion
+	}
+
+	osType, err := unix.Sysctl("kern.ostype")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get OS type", zap.Error(err))
+		return ""
+	}
+
+	osRelease, err := unix.Sysctl("kern.osrelease")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get OS release", zap.Error(err))
+		return osType
+	}
+
+	return fmt.Sprintf("%s %s", osType, osRelease)
+}
+
+// getKernelHardwareInfo returns machine type and processor brand, e.g. 'arm64 Apple M2'.
+func getKernelHardwareInfo() string {
+	machine, err := unix.Sysctl("hw.machine")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get machine type", zap.Error(err))
+		return ""
+	}
+
+	cpuBrand, err := unix.Sysctl("machdep.cpu.brand_string")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get processor brand", zap.Error(err))
+		return machine
+	}
+
+	return fmt.Sprintf("%s %s", machine, cpuBrand)
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// getKernelOSInfo returns OS name and release reported by kernel, e.g. 'FreeBSD 14.0-RELEASE-p3'.
+// BSD systems have no reliable os-release file, so sysctl is used instead.
+func getKernelOSInfo() string {
+	osType, err := unix.Sysctl("kern.ostype")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get OS type", zap.Error(err))
+		return ""
+	}
+
+	osRelease, err := unix.Sysctl("kern.osrelease")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get OS release", zap.Error(err))
+		return osType
+	}
+
+	return fmt.Sprintf("%s %s", osType, osRelease)
+}
+
+// getKernelHardwareInfo returns machine type and processor architecture in the same format as 'uname -mp' does,
+// e.g. 'amd64 amd64'.
+func getKernelHardwareInfo() string {
+	machine, err := unix.Sysctl("hw.machine")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get machine type", zap.Error(err))
+		return ""
+	}
+
+	machineArch, err := unix.Sysctl("hw.machine_arch")
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to get processor architecture", zap.Error(err))
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s", machine, machineArch)
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !freebsd && !darwin
+
+package metrics
+
+// getKernelOSInfo returns empty string, OS info is read from os-release and similar files.
+func getKernelOSInfo() string {
+	return ""
+}
+
+// getKernelHardwareInfo returns empty string, hardware info is got from 'uname -mp'.
+func getKernelHardwareInfo() string {
+	return ""
+}
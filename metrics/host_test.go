@@ -207,6 +207,140 @@ func TestGetInstanceID(t *testing.T) {
 }
 
 // TestReadOSReleaseFile tests the function readOSReleaseFile.
+func TestReadInstanceID(t *testing.T) {
+	t.Parallel()
+
+	instanceID := uuid.New().String()
+
+	tests := []struct {
+		name     string
+		content  *string
+		expected string
+	}{
+		{
+			name:     "non_existing_file",
+			expected: "",
+		},
+		{
+			name:     "valid_file",
+			content:  new(fmt.Sprintf("os:linux\n%s:%s\n", InstanceIDKey, instanceID)),
+			expected: instanceID,
+		},
+		{
+			name:     "value_corrupted",
+			content:  new(fmt.Sprintf("%s:%scorrupt\n", InstanceIDKey, instanceID)),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			instanceFile := filepath.Join(t.TempDir(), "telemetry_uuid")
+			if tt.content != nil {
+				require.NoError(t, os.WriteFile(instanceFile, []byte(*tt.content), metricsFilePermissions))
+			}
+
+			got, err := ReadInstanceID(instanceFile)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+			// file is never created.
+			if tt.content == nil {
+				require.NoFileExists(t, instanceFile)
+			}
+		})
+	}
+}
+
+func TestParseInstanceIDFile(t *testing.T) {
+	t.Parallel()
+
+	instanceID := uuid.New().String()
+
+	tests := []struct {
+		name        string
+		content     *string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:        "non_existing_file",
+			expectedErr: os.ErrNotExist,
+		},
+		{
+			name:     "valid_file",
+			content:  new(fmt.Sprintf("%s: %s\n", InstanceIDKey, instanceID)),
+			expected: instanceID,
+		},
+		{
+			name:        "value_corrupted",
+			content:     new(fmt.Sprintf("%s:%s-0\n", InstanceIDKey, instanceID)),
+			expectedErr: ErrInvalidInstanceID,
+		},
+		{
+			name:        "key_absent",
+			content:     new(fmt.Sprintf("instance:%s\n", instanceID)),
+			expectedErr: ErrInvalidInstanceID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			instanceFile := filepath.Join(t.TempDir(), "telemetry_uuid")
+			if tt.content != nil {
+				require.NoError(t, os.WriteFile(instanceFile, []byte(*tt.content), metricsFilePermissions))
+			}
+
+			got, err := ParseInstanceIDFile(instanceFile)
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestWriteInstanceID(t *testing.T) {
+	t.Parallel()
+
+	// directory is created if it is absent.
+	instanceFile := filepath.Join(t.TempDir(), "percona", "telemetry_uuid")
+
+	for range 2 {
+		instanceID := uuid.New().String()
+		require.NoError(t, WriteInstanceID(instanceFile, instanceID))
+
+		got, err := ParseInstanceIDFile(instanceFile)
+		require.NoError(t, err)
+		require.Equal(t, instanceID, got)
+		// file is compatible with Pillars reading it.
+		require.Equal(t, instanceID, getInstanceID(instanceFile))
+	}
+}
+
+func TestReadInstanceIDOrRandom(t *testing.T) {
+	t.Parallel()
+
+	instanceFile := filepath.Join(t.TempDir(), "telemetry_uuid")
+
+	// absent file is not created.
+	require.NoError(t, uuid.Validate(readInstanceIDOrRandom(instanceFile)))
+	require.NoFileExists(t, instanceFile)
+
+	// invalid file is not repaired.
+	require.NoError(t, os.WriteFile(instanceFile, []byte("instanceId:invalid\n"), metricsFilePermissions))
+	require.NoError(t, uuid.Validate(readInstanceIDOrRandom(instanceFile)))
+
+	content, err := os.ReadFile(instanceFile)
+	require.NoError(t, err)
+	require.Equal(t, "instanceId:invalid\n", string(content))
+
+	instanceID := uuid.New().String()
+	require.NoError(t, WriteInstanceID(instanceFile, instanceID))
+	require.Equal(t, instanceID, readInstanceIDOrRandom(instanceFile))
+}
+
 func TestReadOSReleaseFile(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,91 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// instanceIDChangesFilePermissions allows support scripts running as other users to read instance ID changes file.
+const instanceIDChangesFilePermissions = 0o644
+
+// InstanceIDChange is a single line of instance ID changes file describing one instance ID regeneration.
+type InstanceIDChange struct {
+	Time               time.Time `json:"time"`
+	PreviousInstanceID string    `json:"previous_instance_id"`
+	InstanceID         string    `json:"instance_id"`
+	ProductFamily      string    `json:"product_family"`
+}
+
+// AppendInstanceIDChange appends instance ID change to instance ID changes file, the file is created if it doesn't
+// exist. The file is append-only and is not touched by telemetry history retention, so the mapping of previous
+// instance ID to the new one is never lost.
+func AppendInstanceIDChange(fileName string, change InstanceIDChange) error {
+	change.Time = change.Time.UTC().Truncate(time.Second)
+
+	line, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("can't marshal instance ID change: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Clean(fileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, instanceIDChangesFilePermissions)
+	if err != nil {
+		return fmt.Errorf("can't open instance ID changes file: %w", err)
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("can't write instance ID changes file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// ReadInstanceIDChanges reads all instance ID changes from instance ID changes file, the oldest first.
+func ReadInstanceIDChanges(fileName string) ([]InstanceIDChange, error) {
+	content, err := os.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []InstanceIDChange
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var change InstanceIDChange
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			return nil, fmt.Errorf("can't parse instance ID changes file: %w", err)
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read instance ID changes file: %w", err)
+	}
+
+	return changes, nil
+}
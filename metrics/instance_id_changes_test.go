@@ -0,0 +1,71 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceIDChanges(t *testing.T) {
+	t.Parallel()
+
+	fileName := filepath.Join(t.TempDir(), "instance_id_changes.json")
+
+	_, err := ReadInstanceIDChanges(fileName)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	changed := time.Date(2024, 2, 15, 19, 42, 36, 500, time.UTC)
+	changes := []InstanceIDChange{
+		{
+			Time:               changed,
+			PreviousInstanceID: "7de0ee6b-a4c5-4fa5-a1f1-9e8c9e5b1c1a",
+			InstanceID:         "3a8e4c2d-6f1b-4e0a-9d7c-5b2f8e1a4c3d",
+			ProductFamily:      "PRODUCT_FAMILY_PMM",
+		},
+		{
+			Time:               changed.Add(time.Hour),
+			PreviousInstanceID: "3a8e4c2d-6f1b-4e0a-9d7c-5b2f8e1a4c3d",
+			InstanceID:         "c9d1e2f3-a4b5-4c6d-8e7f-901a2b3c4d5e",
+			ProductFamily:      "PRODUCT_FAMILY_PS",
+		},
+	}
+
+	for _, change := range changes {
+		require.NoError(t, AppendInstanceIDChange(fileName, change))
+	}
+
+	actual, err := ReadInstanceIDChanges(fileName)
+	require.NoError(t, err)
+	require.Len(t, actual, len(changes))
+
+	for i, change := range changes {
+		// the time is recorded with seconds precision.
+		require.True(t, change.Time.Truncate(time.Second).Equal(actual[i].Time))
+		require.Equal(t, change.PreviousInstanceID, actual[i].PreviousInstanceID)
+		require.Equal(t, change.InstanceID, actual[i].InstanceID)
+		require.Equal(t, change.ProductFamily, actual[i].ProductFamily)
+	}
+
+	require.NoError(t, os.WriteFile(fileName, []byte("not json\n"), instanceIDChangesFilePermissions))
+
+	_, err = ReadInstanceIDChanges(fileName)
+	require.Error(t, err)
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	deploymentKubernetes = "KUBERNETES"
+	// Names of downward API volume files pod metadata is read from.
+	podNamespaceFile       = "namespace"
+	podUIDFile             = "uid"
+	podLabelsFile          = "labels"
+	podOperatorVersionFile = "operator_version"
+)
+
+// PodInfo represents metadata of Kubernetes pod Telemetry Agent runs in as a sidecar container.
+type PodInfo struct {
+	Namespace       string
+	UID             string
+	Labels          map[string]string
+	OperatorVersion string
+}
+
+// ReadPodInfo reads pod metadata from downward API volume mounted into dir.
+// Absent or unreadable files are skipped, the corresponding fields are left empty.
+func ReadPodInfo(dir string) PodInfo {
+	pod := PodInfo{
+		Namespace:       readPodInfoFile(filepath.Join(dir, podNamespaceFile)),
+		UID:             readPodInfoFile(filepath.Join(dir, podUIDFile)),
+		OperatorVersion: readPodInfoFile(filepath.Join(dir, podOperatorVersionFile)),
+	}
+
+	labelsFile := filepath.Join(dir, podLabelsFile)
+
+	data, err := os.ReadFile(filepath.Clean(labelsFile))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			zap.L().Sugar().Warnw("failed to read pod labels", zap.String("file", labelsFile), zap.Error(err))
+		}
+
+		return pod
+	}
+
+	pod.Labels = parsePodLabels(data)
+
+	return pod
+}
+
+func readPodInfoFile(file string) string {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			zap.L().Sugar().Warnw("failed to read pod metadata", zap.String("file", file), zap.Error(err))
+		}
+
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// parsePodLabels parses labels (or annotations) file of downward API volume.
+// Each line of the file has format <key>="<value>", the value is a quoted Go string.
+func parsePodLabels(data []byte) map[string]string {
+	labels := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found || len(key) == 0 {
+			continue
+		}
+
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		labels[key] = value
+	}
+
+	return labels
+}
+
+// addHostMetrics adds pod metadata into host metrics, empty fields are skipped.
+func (p PodInfo) addHostMetrics(hostMetrics map[string]string) {
+	hostMetrics["deployment"] = deploymentKubernetes
+
+	if len(p.Namespace) != 0 {
+		hostMetrics["kubernetes_namespace"] = p.Namespace
+	}
+
+	if len(p.OperatorVersion) != 0 {
+		hostMetrics["operator_version"] = p.OperatorVersion
+	}
+
+	if len(p.Labels) != 0 {
+		jsonData, err := json.Marshal(p.Labels)
+		if err != nil {
+			zap.L().Sugar().Warnw("failed to marshal pod labels into JSON, skip them", zap.Error(err))
+			return
+		}
+
+		hostMetrics["kubernetes_labels"] = string(jsonData)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePodLabels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		data     string
+		expected map[string]string
+	}{
+		{
+			name: "downward_api_labels",
+			data: `app.kubernetes.io/component="mongod"
+app.kubernetes.io/instance="my-cluster-name"
+app.kubernetes.io/managed-by="percona-server-mongodb-operator"
+description="quoted \"value\""
+`,
+			expected: map[string]string{
+				"app.kubernetes.io/component":  "mongod",
+				"app.kubernetes.io/instance":   "my-cluster-name",
+				"app.kubernetes.io/managed-by": "percona-server-mongodb-operator",
+				"description":                  `quoted "value"`,
+			},
+		},
+		{
+			name: "invalid_lines",
+			data: "no separator\n=no key\nunquoted=value\n",
+			expected: map[string]string{
+				"unquoted": "value",
+			},
+		},
+		{
+			name:     "empty",
+			data:     "",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, parsePodLabels([]byte(tt.data)))
+		})
+	}
+}
+
+func TestReadPodInfo(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, podNamespaceFile), []byte("psmdb"), metricsFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, podUIDFile), []byte("0b7e9b5c-3f4e-4c11-9b3e-6d2b0f1c2a7d\n"), metricsFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, podLabelsFile), []byte(`app.kubernetes.io/name="percona-server-mongodb"`), metricsFilePermissions))
+
+	pod := ReadPodInfo(dir)
+	require.Equal(t, PodInfo{
+		Namespace: "psmdb",
+		UID:       "0b7e9b5c-3f4e-4c11-9b3e-6d2b0f1c2a7d",
+		Labels:    map[string]string{"app.kubernetes.io/name": "percona-server-mongodb"},
+	}, pod)
+
+	// absent files are skipped.
+	require.Equal(t, PodInfo{}, ReadPodInfo(filepath.Join(dir, "absent")))
+}
+
+func TestScrapeHostMetricsPod(t *testing.T) {
+	t.Parallel()
+
+	pod := &PodInfo{
+		Namespace:       "psmdb",
+		UID:             "0b7e9b5c-3f4e-4c11-9b3e-6d2b0f1c2a7d",
+		Labels:          map[string]string{"app.kubernetes.io/name": "percona-server-mongodb"},
+		OperatorVersion: "1.16.0",
+	}
+
+	f := ScrapeHostMetrics(t.Context(), HostScrapeOpts{Pod: pod})
+	require.Equal(t, pod.UID, f.Metrics[InstanceIDKey])
+	require.Equal(t, deploymentKubernetes, f.Metrics["deployment"])
+	require.Equal(t, "psmdb", f.Metrics["kubernetes_namespace"])
+	require.Equal(t, "1.16.0", f.Metrics["operator_version"])
+	require.JSONEq(t, `{"app.kubernetes.io/name":"percona-server-mongodb"}`, f.Metrics["kubernetes_labels"])
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runSummaryFilePermissions allows monitoring scripts running as other users to read run summary file.
+const runSummaryFilePermissions = 0o644
+
+// RunSummary represents the summary of the last metrics processing run, it is persisted for monitoring scripts.
+type RunSummary struct {
+	StartTime       time.Time `json:"start_time"`
+	FinishTime      time.Time `json:"finish_time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// CleanupDurationSeconds is the duration of history, quarantine and failed directories cleanup,
+	// it is 0 for runs triggered by new metrics files in watch mode.
+	CleanupDurationSeconds    float64 `json:"cleanup_duration_seconds"`
+	ProcessingDurationSeconds float64 `json:"processing_duration_seconds"`
+	// Disabled is true if collecting and sending telemetry is skipped by kill-switch.
+	Disabled       bool `json:"disabled"`
+	FilesProcessed int  `json:"files_processed"`
+	FilesSent      int  `json:"files_sent"`
+	// FilesSuppressed is the number of files removed without sending as duplicates of recently sent ones.
+	FilesSuppressed int `json:"files_suppressed"`
+	FilesFailed     int `json:"files_failed"`
+	// Error is the error of this run, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+	// LastError is the error of the last failed run, it is kept across successful runs and agent restarts.
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+	// LastSelfReportTime is the time Telemetry Agent self-telemetry was last sent, it is kept across runs and
+	// agent restarts, so self-telemetry is sent daily even if the agent is restarted more often.
+	LastSelfReportTime *time.Time `json:"last_self_report_time,omitempty"`
+	// Backlog is the number of metrics files left after the run.
+	Backlog
+}
+
+// Backlog holds the numbers of metrics files waiting for processing and kept after processing failures,
+// growing numbers mean telemetry is not delivered.
+type Backlog struct {
+	// PendingFiles maps Pillar name to the number of its metrics files waiting for processing.
+	PendingFiles map[string]int `json:"pending_files,omitempty"`
+	// FailedFiles is the number of metrics files moved into failed metrics directory after too many send failures.
+	FailedFiles int `json:"failed_files"`
+	// QuarantinedFiles is the number of corrupt metrics files moved into quarantine directory.
+	QuarantinedFiles int `json:"quarantined_files"`
+}
+
+// ReadRunSummary reads run summary file.
+func ReadRunSummary(fileName string) (RunSummary, error) {
+	var summary RunSummary
+
+	content, err := os.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return summary, err
+	}
+
+	if err := json.Unmarshal(content, &summary); err != nil {
+		return summary, fmt.Errorf("can't parse run summary file: %w", err)
+	}
+
+	return summary, nil
+}
+
+// WriteRunSummary writes run summary file atomically, so readers never see partially written file.
+func WriteRunSummary(fileName string, summary RunSummary) error {
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't marshal run summary: %w", err)
+	}
+
+	if err := writeFileAtomically(filepath.Clean(fileName), content, runSummaryFilePermissions); err != nil {
+		return fmt.Errorf("can't write run summary file: %w", err)
+	}
+
+	return nil
+}
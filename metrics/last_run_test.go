@@ -0,0 +1,69 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSummary(t *testing.T) {
+	t.Parallel()
+
+	fileName := filepath.Join(t.TempDir(), "last_run.json")
+
+	// absent summary file is reported, so caller can start from scratch.
+	_, err := ReadRunSummary(fileName)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	startTime := time.Date(2024, 2, 15, 19, 42, 36, 0, time.UTC)
+	errorTime := startTime.Add(-time.Hour)
+	summary := RunSummary{
+		StartTime:                 startTime,
+		FinishTime:                startTime.Add(3 * time.Second),
+		DurationSeconds:           3,
+		CleanupDurationSeconds:    0.5,
+		ProcessingDurationSeconds: 2.5,
+		FilesProcessed:            4,
+		FilesSent:                 2,
+		FilesSuppressed:           1,
+		FilesFailed:               1,
+		Error:                     "platform is unavailable",
+		LastError:                 "platform is unavailable",
+		LastErrorTime:             &errorTime,
+		Backlog: Backlog{
+			PendingFiles:     map[string]int{"PS": 2, "PG": 0},
+			FailedFiles:      1,
+			QuarantinedFiles: 3,
+		},
+	}
+
+	require.NoError(t, WriteRunSummary(fileName, summary))
+
+	readSummary, err := ReadRunSummary(fileName)
+	require.NoError(t, err)
+	require.Equal(t, summary, readSummary)
+
+	// corrupt summary file is reported.
+	require.NoError(t, os.WriteFile(fileName, []byte("{"), 0o600))
+
+	_, err = ReadRunSummary(fileName)
+	require.Error(t, err)
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// DBInstanceIDKey is the metric key Pillar reports its database instance ID with.
+const DBInstanceIDKey = "db_instance_id"
+
+// GroupMetricsFiles groups metrics files of the same product family and database instance created
+// within mergeWindow from the first file of the group, so they can be sent as one batched report.
+// Files without database instance ID are never merged, except documents of the same NDJSON file:
+// they are always put into the same group, so the file is sent at once.
+// Groups are ordered by creation time of their first file, so the oldest metrics are sent first.
+func GroupMetricsFiles(files []*File, mergeWindow time.Duration) [][]*File {
+	type groupKey struct {
+		productFamily string
+		dbInstanceID  string
+	}
+
+	sortedFiles := slices.Clone(files)
+	slices.SortStableFunc(sortedFiles, func(a, b *File) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	groups := make([][]*File, 0, len(files))
+	// index of the group in groups for each metrics file name.
+	fileGroups := make(map[string]int)
+	// index of the last group in groups for each database instance.
+	openGroups := make(map[groupKey]int)
+
+	for _, f := range sortedFiles {
+		if idx, ok := fileGroups[f.Filename]; ok {
+			groups[idx] = append(groups[idx], f)
+			continue
+		}
+
+		dbInstanceID := f.Metrics[DBInstanceIDKey]
+		if mergeWindow <= 0 || len(dbInstanceID) == 0 {
+			fileGroups[f.Filename] = len(groups)
+			groups = append(groups, []*File{f})
+
+			continue
+		}
+
+		key := groupKey{productFamily: f.ProductFamily.String(), dbInstanceID: dbInstanceID}
+
+		idx, ok := openGroups[key]
+		if ok && f.Timestamp.Sub(groups[idx][0].Timestamp) <= mergeWindow {
+			fileGroups[f.Filename] = idx
+			groups[idx] = append(groups[idx], f)
+
+			continue
+		}
+
+		openGroups[key] = len(groups)
+		fileGroups[f.Filename] = len(groups)
+		groups = append(groups, []*File{f})
+	}
+
+	return groups
+}
+
+// DeduplicateMetricsFiles returns files with unique metrics, only the earliest file
+// of identical metrics snapshots is kept.
+func DeduplicateMetricsFiles(files []*File) []*File {
+	uniqueFiles := make([]*File, 0, len(files))
+
+	for _, f := range files {
+		if slices.ContainsFunc(uniqueFiles, func(u *File) bool {
+			return u.ProductFamily == f.ProductFamily && maps.Equal(u.Metrics, f.Metrics)
+		}) {
+			continue
+		}
+
+		uniqueFiles = append(uniqueFiles, f)
+	}
+
+	return uniqueFiles
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupMetricsFiles(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1708026156, 0)
+	newFile := func(name string, family platformReporter.ProductFamily, dbInstanceID string, offset time.Duration) *File {
+		m := map[string]string{"uptime": name}
+		if len(dbInstanceID) != 0 {
+			m[DBInstanceIDKey] = dbInstanceID
+		}
+
+		return &File{
+			Filename:      name,
+			Timestamp:     start.Add(offset),
+			ProductFamily: family,
+			Metrics:       m,
+		}
+	}
+
+	ps1 := newFile("ps1", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "instance-1", 2*time.Minute)
+	ps2 := newFile("ps2", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "instance-1", 0)
+	ps3 := newFile("ps3", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "instance-1", 2*time.Hour)
+	ps4 := newFile("ps4", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "instance-2", time.Minute)
+	pxc1 := newFile("pxc1", platformReporter.ProductFamily_PRODUCT_FAMILY_PXC, "instance-1", time.Minute)
+	noID1 := newFile("noID1", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "", 3*time.Minute)
+	noID2 := newFile("noID2", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "", 4*time.Minute)
+
+	// documents of the same NDJSON file.
+	ndjson1 := newFile("ndjson", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "", 5*time.Minute)
+	ndjson2 := newFile("ndjson", platformReporter.ProductFamily_PRODUCT_FAMILY_PS, "", 5*time.Minute)
+
+	files := []*File{ps1, ps2, ps3, ps4, pxc1, noID1, noID2, ndjson1, ndjson2}
+
+	testCases := []struct {
+		name           string
+		mergeWindow    time.Duration
+		expectedGroups [][]*File
+	}{
+		{
+			name:           "merging_disabled",
+			mergeWindow:    0,
+			expectedGroups: [][]*File{{ps2}, {ps4}, {pxc1}, {ps1}, {noID1}, {noID2}, {ndjson1, ndjson2}, {ps3}},
+		},
+		{
+			name:           "merge_window_hour",
+			mergeWindow:    time.Hour,
+			expectedGroups: [][]*File{{ps2, ps1}, {ps4}, {pxc1}, {noID1}, {noID2}, {ndjson1, ndjson2}, {ps3}},
+		},
+		{
+			name:           "merge_window_day",
+			mergeWindow:    24 * time.Hour,
+			expectedGroups: [][]*File{{ps2, ps1, ps3}, {ps4}, {pxc1}, {noID1}, {noID2}, {ndjson1, ndjson2}},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expectedGroups, GroupMetricsFiles(files, tt.mergeWindow))
+		})
+	}
+}
+
+func TestDeduplicateMetricsFiles(t *testing.T) {
+	t.Parallel()
+
+	f1 := &File{Filename: "f1", Metrics: map[string]string{DBInstanceIDKey: "instance-1", "uptime": "1"}}
+	f2 := &File{Filename: "f2", Metrics: map[string]string{DBInstanceIDKey: "instance-1", "uptime": "2"}}
+	f3 := &File{Filename: "f3", Metrics: map[string]string{DBInstanceIDKey: "instance-1", "uptime": "1"}}
+
+	require.Equal(t, []*File{f1, f2}, DeduplicateMetricsFiles([]*File{f1, f2, f3}))
+	require.Equal(t, []*File{f3}, DeduplicateMetricsFiles([]*File{f3}))
+}
@@ -17,33 +17,104 @@
 package metrics
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+const (
+	// ReportSchemaVersionKey is the metric key Pillar may declare its report schema version with.
+	ReportSchemaVersionKey = "report_schema_version"
+	// PillarInstanceKey is the metric key the name of Pillar instance subdirectory is attached to metrics with.
+	PillarInstanceKey = "pillar_instance"
+	// flattenNestedSchemaVersion is the first report schema version expecting nested objects to be flattened.
+	flattenNestedSchemaVersion = 2
+	// namespacedKeysSchemaVersion is the first report schema version expecting host and Pillar metric keys
+	// to be namespaced with hostKeyPrefix and pillarKeyPrefix, so they never collide.
+	namespacedKeysSchemaVersion = 3
+	hostKeyPrefix               = "host."
+	pillarKeyPrefix             = "pillar."
+	// TagKeyPrefix is the prefix of static tags metric keys, so tags never collide with host and Pillar metrics.
+	TagKeyPrefix = "tag."
 )
 
+// ProcessOpts represents the options for processing Pillar's metrics files.
+type ProcessOpts struct {
+	// FlattenNested enables flattening nested JSON objects into dotted keys (e.g. "replication.role")
+	// instead of passing them as JSON strings.
+	FlattenNested bool
+	// SchemaDir is the directory with optional per product family JSON Schema files (e.g. 'ps.json').
+	// Metrics files not matching the schema are rejected.
+	SchemaDir string
+	// QuarantineDir is the directory metrics files rejected by the schema or repeatedly failed to be parsed
+	// are moved to. Such files are left in place if it is empty.
+	QuarantineDir string
+	// ParseFailures counts failed parsing attempts of metrics files between processing iterations.
+	// Files failed to be parsed are never quarantined if it is nil.
+	ParseFailures *ParseFailures
+	// MaxAge is the maximum age of metrics files, older files are removed without processing.
+	// Age is not limited if it is not positive.
+	MaxAge time.Duration
+	// ReadOnly disables moving aside, quarantining and removing metrics files,
+	// files failed to be parsed or older than MaxAge are just skipped.
+	ReadOnly bool
+	// ErrorCounters counts metrics files failed to be read or parsed, nothing is counted if it is nil.
+	ErrorCounters *ErrorCounters
+	// Batch limits the number of metrics files parsed at once, files not taken into the batch are skipped.
+	// All files are parsed if it is nil.
+	Batch *Batch
+	// StateDB records metrics files processed without removing them, such files are skipped until their content
+	// changes. No files are skipped if it is nil.
+	StateDB *StateDB
+}
+
 // File struct used for storing parsed Pillar's or host metrics.
-// One object hold info about of metrics file.
+// One object hold info about of metrics file, or about one document of NDJSON metrics file
+// (such objects share the same Filename).
 type File struct {
 	Filename      string
 	Timestamp     time.Time
 	ProductFamily platformReporter.ProductFamily
 	Metrics       map[string]string
+	// ReportSchemaVersion is the report schema version declared by Pillar, 0 if it is absent.
+	ReportSchemaVersion int
 }
 
-func processMetricsDirectory(path string, productFamily platformReporter.ProductFamily) ([]*File, error) {
-	l := zap.L().Sugar()
+func processMetricsDirectory(ctx context.Context, path string, productFamily platformReporter.ProductFamily, opts ProcessOpts) ([]*File, error) {
+	l := logger.FromContext(ctx).Sugar()
 
 	cleanMetricsDirectoryPath := filepath.Clean(path)
 
+	if PillarDisabled(cleanMetricsDirectoryPath) {
+		l.Infow("pillar telemetry is disabled by consent file, skipping",
+			zap.String("directory", cleanMetricsDirectoryPath),
+			zap.String("file", PillarDisabledFile))
+
+		return nil, nil
+	}
+
+	if opts.Batch != nil && opts.Batch.full() {
+		// the rest of Pillars metrics files are processed within next batch.
+		return nil, nil
+	}
+
 	files, err := os.ReadDir(cleanMetricsDirectoryPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -63,36 +134,292 @@ func processMetricsDirectory(path string, productFamily platformReporter.Product
 		return nil, nil
 	}
 
-	toReturn := make([]*File, 0, 1)
+	schema, err := loadMetricsSchema(opts.SchemaDir, productFamily)
+	if err != nil {
+		// schema is optional, process files without validation.
+		l.Errorw("failed to load metrics schema, files are processed without validation", zap.Error(err))
+	}
+
+	return processMetricsFiles(ctx, cleanMetricsDirectoryPath, listMetricsFiles(ctx, cleanMetricsDirectoryPath, files), productFamily, opts, schema), nil
+}
+
+// metricsFileEntry represents metrics file of Pillar directory or of its instance subdirectory.
+type metricsFileEntry struct {
+	// instance is the name of Pillar instance subdirectory, it is empty for files of Pillar directory itself.
+	instance  string
+	file      os.DirEntry
+	timestamp time.Time
+}
+
+// listMetricsFiles lists metrics files of Pillar directory and of its instance subdirectories
+// (e.g. '<root>/pg/<instance name>/'), which are listed one level deep only. Files are sorted oldest first
+// by timestamp embedded in their names (modification time of files without it), so that metrics files
+// are processed in the order they were created rather than in lexical order of their names.
+func listMetricsFiles(ctx context.Context, pillarDir string, files []os.DirEntry) []metricsFileEntry {
+	l := logger.FromContext(ctx).Sugar()
+
+	entries := make([]metricsFileEntry, 0, len(files))
+
+	var addFiles func(instance string, files []os.DirEntry)
+	addFiles = func(instance string, files []os.DirEntry) {
+		for _, file := range files {
+			fileName := filepath.Join(pillarDir, instance, file.Name())
+
+			if file.IsDir() && len(instance) == 0 {
+				instanceFiles, err := os.ReadDir(fileName)
+				if err != nil {
+					l.Errorw("failed to read pillar instance metric directory, skipping", zap.String("file", fileName), zap.Error(err))
+					continue
+				}
+
+				addFiles(file.Name(), instanceFiles)
+
+				continue
+			}
+
+			if !file.Type().IsRegular() || filepath.Ext(file.Name()) != ".json" {
+				l.Debugw("seems not a metrics file, skipping", zap.String("file", fileName))
+				continue
+			}
+
+			timestamp, err := parseFileNameTimestamp(file.Name())
+			if err != nil {
+				// hand-copied or renamed by Pillar file, its modification time is used the same way as in parseMetricsFile.
+				if info, iErr := file.Info(); iErr == nil {
+					timestamp = info.ModTime()
+				}
+			}
+
+			entries = append(entries, metricsFileEntry{instance: instance, file: file, timestamp: timestamp})
+		}
+	}
+
+	addFiles("", files)
+
+	slices.SortStableFunc(entries, func(a, b metricsFileEntry) int {
+		return a.timestamp.Compare(b.timestamp)
+	})
+
+	return entries
+}
+
+// CountPillarMetricsFiles returns the number of metrics files waiting for processing in Pillar directory,
+// including its instance subdirectories. Absent directory and directory of disabled Pillar have no metrics files.
+func CountPillarMetricsFiles(path string) (int, error) {
+	cleanMetricsDirectoryPath := filepath.Clean(path)
+
+	if PillarDisabled(cleanMetricsDirectoryPath) {
+		return 0, nil
+	}
+
+	files, err := os.ReadDir(cleanMetricsDirectoryPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("can't read directory with metric files: %w", err)
+	}
+
+	count := 0
 
 	for _, file := range files {
-		fileName := filepath.Join(cleanMetricsDirectoryPath, file.Name())
+		if file.IsDir() {
+			instanceFiles, err := os.ReadDir(filepath.Join(cleanMetricsDirectoryPath, file.Name()))
+			if err != nil {
+				return 0, fmt.Errorf("can't read instance directory with metric files: %w", err)
+			}
+
+			count += countMetricsFiles(instanceFiles)
+
+			continue
+		}
+
+		count += countMetricsFiles([]os.DirEntry{file})
+	}
+
+	return count, nil
+}
+
+func countMetricsFiles(files []os.DirEntry) int {
+	count := 0
+
+	for _, file := range files {
+		if file.Type().IsRegular() && filepath.Ext(file.Name()) == ".json" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// CountStoredMetricsFiles returns the number of metrics files in directory and all its subdirectories,
+// e.g. in quarantine or failed metrics directory. Absent directory has no metrics files.
+func CountStoredMetricsFiles(path string) (int, error) {
+	cleanPath := filepath.Clean(path)
+	count := 0
+
+	err := filepath.WalkDir(cleanPath, func(fileName string, file fs.DirEntry, err error) error {
+		if err != nil {
+			if fileName == cleanPath && errors.Is(err, os.ErrNotExist) {
+				return fs.SkipDir
+			}
+
+			return err
+		}
+
+		if file.Type().IsRegular() && filepath.Ext(fileName) == ".json" {
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't count metrics files in %s: %w", cleanPath, err)
+	}
+
+	return count, nil
+}
+
+// processMetricsFiles parses metrics files of Pillar directory listed by listMetricsFiles. Name of instance subdirectory
+// of the file is attached to its metrics as PillarInstanceKey metric.
+func processMetricsFiles(ctx context.Context, pillarDir string, entries []metricsFileEntry, productFamily platformReporter.ProductFamily,
+	opts ProcessOpts, schema *jsonschema.Schema,
+) []*File {
+	l := logger.FromContext(ctx).Sugar()
+
+	toReturn := make([]*File, 0, 1)
+
+	for _, entry := range entries {
+		if opts.Batch != nil && opts.Batch.full() {
+			break
+		}
+
+		instance := entry.instance
+		fileName := filepath.Join(pillarDir, instance, entry.file.Name())
 		fl := l.With(zap.String("file", fileName))
+		// metrics files are quarantined into '<quarantine dir>/<Pillar directory name>/<instance name>/'.
+		quarantineSubdir := filepath.Join(filepath.Base(pillarDir), instance)
 
-		fileExt := filepath.Ext(file.Name())
-		if !file.Type().IsRegular() || fileExt != ".json" {
-			fl.Debug("seems not a metrics file, skipping")
+		processed, err := opts.StateDB.IsProcessed(fileName)
+		if err != nil {
+			fl.Warnw("failed to check whether metrics file is already processed, processing it", zap.Error(err))
+		}
+
+		if processed {
+			fl.Debugw("metrics file is already processed, skipping")
+			continue
+		}
+
+		if opts.Batch != nil && !opts.Batch.Take(fileName) {
+			// the file is already taken into previous batch.
 			continue
 		}
 
 		fl.Debugw("parsing metrics file")
 
-		fileMetrics, err := parseMetricsFile(fileName)
+		fileMetrics, err := parseMetricsFile(ctx, fileName, opts, schema)
 		if err != nil {
 			fl.Errorw("error during parsing metrics file, skipping", zap.Error(err))
+
+			if !opts.ReadOnly {
+				handleMetricsFileFailure(ctx, fl, fileName, quarantineSubdir, err, opts)
+			}
+
 			continue
 		}
 
-		fileMetrics.ProductFamily = productFamily
-		toReturn = append(toReturn, fileMetrics)
+		if opts.ParseFailures != nil {
+			opts.ParseFailures.reset(fileName)
+		}
+
+		// all documents of the file share the same timestamp.
+		if opts.MaxAge > 0 && len(fileMetrics) != 0 && time.Since(fileMetrics[0].Timestamp) > opts.MaxAge {
+			// stale metrics would skew analytics with ancient creation time.
+			fl.Warnw("metrics file is older than max age, removing it without sending",
+				zap.Time("creationTime", fileMetrics[0].Timestamp),
+				zap.Duration("maxAge", opts.MaxAge))
+
+			if opts.ReadOnly {
+				continue
+			}
+
+			if err := RemoveMetricsFile(fileName); err != nil {
+				fl.Errorw("failed to remove expired metrics file", zap.Error(err))
+			}
+
+			continue
+		}
+
+		for _, f := range fileMetrics {
+			f.ProductFamily = productFamily
+
+			if len(instance) != 0 {
+				f.Metrics[PillarInstanceKey] = instance
+			}
+		}
+
+		toReturn = append(toReturn, fileMetrics...)
+	}
+
+	return toReturn
+}
+
+// handleMetricsFileFailure moves aside metrics file failed to be parsed, so it is not parsed (and logged) again.
+// Files rejected by the schema are moved immediately, corrupt files - once they fail parsing several times
+// in a row, since Pillar may still be writing them.
+func handleMetricsFileFailure(ctx context.Context, l *zap.SugaredLogger, fileName, quarantineSubdir string, parseErr error, opts ProcessOpts) {
+	rejected := errors.Is(parseErr, errMetricsFileRejected)
+
+	if errors.Is(parseErr, fs.ErrPermission) {
+		opts.ErrorCounters.Add(ErrorCategoryPermission)
+	} else {
+		opts.ErrorCounters.Add(ErrorCategoryParse)
+	}
+
+	if len(opts.QuarantineDir) == 0 {
+		if rejected {
+			// move file aside, so it is not partially processed and is not parsed again.
+			if err := rejectMetricsFile(fileName); err != nil {
+				l.Errorw("failed to move aside rejected metrics file", zap.Error(err))
+			}
+		}
+
+		return
+	}
+
+	if !rejected {
+		if opts.ParseFailures == nil {
+			return
+		}
+
+		failures := opts.ParseFailures.add(fileName)
+		if failures < quarantineFailuresThreshold {
+			l.Debugw("metrics file failed to be parsed, it will be quarantined if failure repeats",
+				zap.Int("failures", failures))
+
+			return
+		}
+	}
+
+	quarantineFile, err := quarantineMetricsFile(ctx, filepath.Join(opts.QuarantineDir, quarantineSubdir), fileName)
+	if err != nil {
+		l.Errorw("failed to quarantine metrics file", zap.Error(err))
+		return
+	}
+
+	if opts.ParseFailures != nil {
+		opts.ParseFailures.reset(fileName)
 	}
 
-	return toReturn, nil
+	l.Warnw("metrics file is moved into quarantine", zap.String("quarantine file", quarantineFile))
 }
 
-func parseMetricsFile(path string) (*File, error) {
+// parseMetricsFile parses metrics file. The file may contain several newline-delimited JSON documents (NDJSON),
+// each of them is returned as separate File sharing the file name and timestamp.
+func parseMetricsFile(ctx context.Context, path string, opts ProcessOpts, schema *jsonschema.Schema) ([]*File, error) {
 	cleanPath := filepath.Clean(path)
-	l := zap.L().Sugar().With(zap.String("file", cleanPath))
+	l := logger.FromContext(ctx).Sugar().With(zap.String("file", cleanPath))
 
 	var (
 		file *os.File
@@ -111,68 +438,246 @@ func parseMetricsFile(path string) (*File, error) {
 		}
 	}(l)
 
-	// file has content in JSON format but the structure is not well known beforehand.
-	var tmpMetrics map[string]any
+	info, err := file.Stat()
+	if err != nil {
+		l.Errorw("error during getting metrics file info", zap.Error(err))
+		return nil, err
+	}
+
+	// content is read once, so the same content is verified against checksum and parsed.
+	// Buffer is sized by the file, so it is not grown while reading.
+	contentBuf := bytes.NewBuffer(make([]byte, 0, info.Size()+bytes.MinRead))
+
+	_, err = contentBuf.ReadFrom(file)
+	if err != nil {
+		l.Errorw("error during reading metrics file", zap.Error(err))
+		return nil, err
+	}
+
+	content := contentBuf.Bytes()
 
-	err = json.NewDecoder(file).Decode(&tmpMetrics)
+	err = verifyMetricsFileChecksum(cleanPath, content)
 	if err != nil {
-		l.Errorw("error during parsing metrics file, skipping", zap.Error(err))
+		// the file may be still being written by Pillar.
+		l.Errorw("error during verifying metrics file checksum, skipping", zap.Error(err))
 		return nil, err
 	}
 
-	metrics := make(map[string]string)
+	// file content is parsed completely before returning anything, so it is never partially processed.
+	rawDocuments := make([]map[string]any, 0, 1)
+	decoder := json.NewDecoder(bytes.NewReader(content))
+
+	for {
+		// file has content in JSON format but the structure is not well known beforehand.
+		var tmpMetrics map[string]any
+
+		err = decoder.Decode(&tmpMetrics)
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(rawDocuments) != 0 {
+				break
+			}
+
+			l.Errorw("error during parsing metrics file, skipping", zap.Error(err))
+
+			return nil, err
+		}
+
+		err = validateMetrics(schema, tmpMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(rawDocuments)+1, err)
+		}
+
+		rawDocuments = append(rawDocuments, tmpMetrics)
+	}
+
+	fileCreationTime, err := parseFileNameTimestamp(file.Name())
+	if err != nil {
+		// hand-copied or renamed by Pillar file, use its modification time instead.
+		l.Debugw("filename has no unixtime prefix, using file modification time", zap.Error(err))
+		fileCreationTime = info.ModTime()
+	}
+
+	files := make([]*File, 0, len(rawDocuments))
+
+	for _, tmpMetrics := range rawDocuments {
+		reportSchemaVersion := getReportSchemaVersion(tmpMetrics)
+		// nested objects are flattened into dotted keys if it is enabled in options
+		// or if the Pillar declares report schema version that expects it.
+		flattenNested := opts.FlattenNested || reportSchemaVersion >= flattenNestedSchemaVersion
+
+		metrics := make(map[string]string, len(tmpMetrics))
+		convertMetrics(l, "", tmpMetrics, flattenNested, metrics)
+
+		files = append(files, &File{
+			Filename:            path,
+			Timestamp:           fileCreationTime,
+			Metrics:             metrics,
+			ReportSchemaVersion: reportSchemaVersion,
+		})
+	}
+
+	return files, nil
+}
+
+// parseFileNameTimestamp gets timestamp from filename.
+// filename has format: <timestamp>-<random token>.json
+// example: 1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json
+func parseFileNameTimestamp(fileName string) (time.Time, error) {
+	fileCreationTime, err := strconv.Atoi(strings.Split(
+		strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName)),
+		"-")[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("can't convert filename into int: %w", err)
+	}
+
+	return time.Unix(int64(fileCreationTime), 0), nil
+}
+
+// getReportSchemaVersion returns report schema version declared in metrics file, 0 if it is absent or invalid.
+func getReportSchemaVersion(rawMetrics map[string]any) int {
+	switch v := rawMetrics[ReportSchemaVersionKey].(type) {
+	case string:
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+
+		return version
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// convertMetrics converts raw metrics values into strings and puts them into metrics with keyPrefix.
+// If flattenNested is true, nested objects are flattened into dotted keys, otherwise they are marshalled back to JSON.
+func convertMetrics(l *zap.SugaredLogger, keyPrefix string, rawMetrics map[string]any, flattenNested bool, metrics map[string]string) {
+	for k, v := range rawMetrics {
+		key := keyPrefix + k
 
-	for k, v := range tmpMetrics {
 		switch v := v.(type) {
 		case string:
 			// handle special case when "true/false" are written as string
-			vb, err := strconv.ParseBool(v)
-			if err == nil {
+			if vb, ok := parseBoolString(v); ok {
 				if vb {
-					metrics[k] = "1"
+					metrics[key] = "1"
 				} else {
-					metrics[k] = "0"
+					metrics[key] = "0"
 				}
 
 				continue
 			}
 
-			metrics[k] = v
+			metrics[key] = v
 		case bool:
 			if v {
-				metrics[k] = "1"
+				metrics[key] = "1"
 			} else {
-				metrics[k] = "0"
+				metrics[key] = "0"
 			}
 
 			continue
-		default:
-			// the rest of types shall be marshalled back to JSON.
-			s, err := json.Marshal(v)
-			if err != nil {
-				l.Errorw("error during marshalling metric value to JSON, skipping",
-					zap.Any("key", k), zap.Any("value", v), zap.Error(err))
+		case float64:
+			// numbers are formatted the same way as JSON marshalling does for them, but without allocating
+			// an intermediate buffer. Very small and very large numbers are marshalled into exponent format.
+			if abs := math.Abs(v); abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+				metrics[key] = strconv.FormatFloat(v, 'f', -1, 64)
+				continue
+			}
 
+			marshalMetric(l, key, v, metrics)
+		case map[string]any:
+			if flattenNested && len(v) != 0 {
+				convertMetrics(l, key+".", v, flattenNested, metrics)
 				continue
 			}
 
-			metrics[k] = string(s)
+			marshalMetric(l, key, v, metrics)
+		default:
+			// the rest of types shall be marshalled back to JSON.
+			marshalMetric(l, key, v, metrics)
 		}
 	}
-	// get timestamp from filename.
-	// filename has format: <timestamp>-<random token>.json
-	// example: 1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json
-	fileCreationTime, err := strconv.Atoi(strings.Split(
-		strings.TrimSuffix(filepath.Base(file.Name()), filepath.Ext(file.Name())),
-		"-")[0])
+}
+
+// parseBoolString accepts the same values as strconv.ParseBool, but doesn't allocate error for the rest of values,
+// which are the vast majority of string metrics.
+func parseBoolString(s string) (bool, bool) {
+	switch s {
+	case "1", "t", "T", "true", "TRUE", "True":
+		return true, true
+	case "0", "f", "F", "false", "FALSE", "False":
+		return false, true
+	}
+
+	return false, false
+}
+
+func marshalMetric(l *zap.SugaredLogger, key string, value any, metrics map[string]string) {
+	s, err := json.Marshal(value)
 	if err != nil {
-		l.Errorw("can't convert filename into int, skipping", zap.Error(err))
-		return nil, err
+		l.Errorw("error during marshalling metric value to JSON, skipping",
+			zap.Any("key", key), zap.Any("value", value), zap.Error(err))
+
+		return
+	}
+
+	metrics[key] = string(s)
+}
+
+// ReportMetrics combines host and Pillar metrics into Percona Platform report metrics.
+// Since report schema version 3 declared by Pillar, host and Pillar metric keys are prefixed with 'host.'
+// and 'pillar.' respectively, so Pillar metrics never collide with host ones.
+// Report schema version metric itself is never prefixed, so the report can be interpreted.
+func ReportMetrics(hostMetrics, pillarMetrics *File) []*platformReporter.GenericReport_Metric {
+	hostPrefix, pillarPrefix := "", ""
+	if pillarMetrics.ReportSchemaVersion >= namespacedKeysSchemaVersion {
+		hostPrefix, pillarPrefix = hostKeyPrefix, pillarKeyPrefix
+	}
+
+	reportMetrics := make([]*platformReporter.GenericReport_Metric, 0, len(hostMetrics.Metrics)+len(pillarMetrics.Metrics))
+
+	// copy host metrics to Platform request
+	for k, v := range hostMetrics.Metrics {
+		reportMetrics = append(reportMetrics, &platformReporter.GenericReport_Metric{
+			Key:   hostPrefix + k,
+			Value: v,
+		})
+	}
+
+	// copy pillar metrics to Platform request
+	for k, v := range pillarMetrics.Metrics {
+		key := pillarPrefix + k
+		if k == ReportSchemaVersionKey {
+			key = k
+		}
+
+		reportMetrics = append(reportMetrics, &platformReporter.GenericReport_Metric{
+			Key:   key,
+			Value: v,
+		})
+	}
+
+	return reportMetrics
+}
+
+// TagMetrics converts static tags into Percona Platform report metrics sorted by tag name.
+func TagMetrics(tags map[string]string) []*platformReporter.GenericReport_Metric {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	tagMetrics := make([]*platformReporter.GenericReport_Metric, 0, len(names))
+	for _, name := range names {
+		tagMetrics = append(tagMetrics, &platformReporter.GenericReport_Metric{
+			Key:   TagKeyPrefix + name,
+			Value: tags[name],
+		})
 	}
 
-	return &File{
-		Filename:  path,
-		Timestamp: time.Unix(int64(fileCreationTime), 0),
-		Metrics:   metrics,
-	}, nil
+	return tagMetrics
 }
@@ -1,14 +1,19 @@
 package metrics
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestParseMetricsFile(t *testing.T) {
@@ -170,15 +175,625 @@ func TestParseMetricsFile(t *testing.T) {
 			metricsFile := fmt.Sprintf("%d-%s.json", currTime.Unix(), token)
 			tt.setupTestData(t, tmpDir, metricsFile)
 
-			f, err := parseMetricsFile(filepath.Join(tmpDir, metricsFile))
+			files, err := parseMetricsFile(t.Context(), filepath.Join(tmpDir, metricsFile), ProcessOpts{}, nil)
+
+			var f *File
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
 				require.NoError(t, err)
-				require.NotNil(t, f)
+				require.Len(t, files, 1)
+				f = files[0]
 			}
 
 			tt.postCheckTestData(t, tmpDir, metricsFile, f)
 		})
 	}
 }
+
+func TestParseMetricsFileNested(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		fileContent     string
+		opts            ProcessOpts
+		expectedMetrics map[string]string
+	}{
+		{
+			name: "nested_as_json_string",
+			fileContent: `{
+"pillar_version": "8.0.35-27",
+"replication": {"role": "primary", "members": 3, "arbiter": false}
+}`,
+			opts: ProcessOpts{},
+			expectedMetrics: map[string]string{
+				"pillar_version": "8.0.35-27",
+				"replication":    `{"arbiter":false,"members":3,"role":"primary"}`,
+			},
+		},
+		{
+			name: "nested_flattened_by_option",
+			fileContent: `{
+"pillar_version": "8.0.35-27",
+"replication": {"role": "primary", "members": 3, "arbiter": false, "tags": ["a", "b"], "storage": {"engine": "wiredTiger"}, "empty": {}}
+}`,
+			opts: ProcessOpts{FlattenNested: true},
+			expectedMetrics: map[string]string{
+				"pillar_version":             "8.0.35-27",
+				"replication.role":           "primary",
+				"replication.members":        "3",
+				"replication.arbiter":        "0",
+				"replication.tags":           `["a","b"]`,
+				"replication.storage.engine": "wiredTiger",
+				"replication.empty":          "{}",
+			},
+		},
+		{
+			name: "nested_flattened_by_schema_version",
+			fileContent: `{
+"report_schema_version": "2",
+"replication": {"role": "primary"}
+}`,
+			opts: ProcessOpts{},
+			expectedMetrics: map[string]string{
+				"report_schema_version": "2",
+				"replication.role":      "primary",
+			},
+		},
+		{
+			name: "old_schema_version",
+			fileContent: `{
+"report_schema_version": 1,
+"replication": {"role": "primary"}
+}`,
+			opts: ProcessOpts{},
+			expectedMetrics: map[string]string{
+				"report_schema_version": "1",
+				"replication":           `{"role":"primary"}`,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			metricsFile := filepath.Join(tmpDir, fmt.Sprintf("%d-%s.json", time.Now().Unix(), uuid.New().String()))
+			require.NoError(t, os.WriteFile(metricsFile, []byte(tt.fileContent), 0o600))
+
+			files, err := parseMetricsFile(t.Context(), metricsFile, tt.opts, nil)
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			require.Equal(t, tt.expectedMetrics, files[0].Metrics)
+		})
+	}
+}
+
+func TestParseMetricsFileTimestamp(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Unix(1700000000, 0)
+
+	testCases := []struct {
+		name              string
+		fileName          string
+		expectedTimestamp time.Time
+	}{
+		{
+			name:              "unixtime_prefix",
+			fileName:          "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json",
+			expectedTimestamp: time.Unix(1708026156, 0),
+		},
+		{
+			name:              "no_unixtime_prefix",
+			fileName:          "metrics.json",
+			expectedTimestamp: modTime,
+		},
+		{
+			name:              "non_unixtime_prefix",
+			fileName:          "copy-1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json",
+			expectedTimestamp: modTime,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metricsFile := filepath.Join(t.TempDir(), tt.fileName)
+			require.NoError(t, os.WriteFile(metricsFile, []byte(`{"uptime": "112"}`), 0o600))
+			require.NoError(t, os.Chtimes(metricsFile, modTime, modTime))
+
+			files, err := parseMetricsFile(t.Context(), metricsFile, ProcessOpts{}, nil)
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			require.True(t, tt.expectedTimestamp.Equal(files[0].Timestamp))
+		})
+	}
+}
+
+func TestParseMetricsFileNDJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		fileContent     string
+		expectedMetrics []map[string]string
+		wantErr         bool
+	}{
+		{
+			name:        "single_document",
+			fileContent: `{"db_instance_id": "instance-1", "uptime": "112"}`,
+			expectedMetrics: []map[string]string{
+				{"db_instance_id": "instance-1", "uptime": "112"},
+			},
+		},
+		{
+			name: "multiple_documents",
+			fileContent: `{"db_instance_id": "instance-1", "uptime": "112"}
+{"db_instance_id": "instance-1", "uptime": "212"}
+
+{"db_instance_id": "instance-1", "uptime": "312"}
+`,
+			expectedMetrics: []map[string]string{
+				{"db_instance_id": "instance-1", "uptime": "112"},
+				{"db_instance_id": "instance-1", "uptime": "212"},
+				{"db_instance_id": "instance-1", "uptime": "312"},
+			},
+		},
+		{
+			name: "corrupted_last_document",
+			fileContent: `{"db_instance_id": "instance-1", "uptime": "112"}
+{"db_instance_id": "instance-1", "uptime": `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			metricsFile := filepath.Join(t.TempDir(), "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json")
+			require.NoError(t, os.WriteFile(metricsFile, []byte(tt.fileContent), 0o600))
+
+			files, err := parseMetricsFile(t.Context(), metricsFile, ProcessOpts{}, nil)
+			if tt.wantErr {
+				// file is never partially processed.
+				require.Error(t, err)
+				require.Empty(t, files)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, files, len(tt.expectedMetrics))
+
+			for i, f := range files {
+				require.Equal(t, metricsFile, f.Filename)
+				require.Equal(t, time.Unix(1708026156, 0), f.Timestamp)
+				require.Equal(t, tt.expectedMetrics[i], f.Metrics)
+			}
+		})
+	}
+}
+
+func TestProcessMetricsDirectoryInstances(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	pillarDir := filepath.Join(rootDir, "pg")
+	writeMetricsFile := func(dir, name, content string) {
+		t.Helper()
+
+		require.NoError(t, os.MkdirAll(dir, 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	writeMetricsFile(pillarDir, "1708026156-a.json", `{"db_instance_id": "instance-0"}`)
+	writeMetricsFile(filepath.Join(pillarDir, "cluster1"), "1708026157-b.json", `{"db_instance_id": "instance-1"}`)
+	writeMetricsFile(filepath.Join(pillarDir, "cluster2"), "1708026158-c.json", `{"db_instance_id": "instance-2"}`)
+	writeMetricsFile(filepath.Join(pillarDir, "cluster2"), "1708026159-d.json", `{"db_instance_id": `)
+	// only one level of instance subdirectories is processed.
+	writeMetricsFile(filepath.Join(pillarDir, "cluster2", "nested"), "1708026160-e.json", `{"db_instance_id": "instance-3"}`)
+
+	opts := ProcessOpts{
+		QuarantineDir: filepath.Join(rootDir, "quarantine"),
+		ParseFailures: NewParseFailures(),
+	}
+
+	var files []*File
+
+	for range quarantineFailuresThreshold {
+		var err error
+
+		files, err = processMetricsDirectory(t.Context(), pillarDir, platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL, opts)
+		require.NoError(t, err)
+	}
+
+	instances := make(map[string]string)
+	for _, f := range files {
+		instances[f.Metrics["db_instance_id"]] = f.Metrics[PillarInstanceKey]
+	}
+
+	require.Equal(t, map[string]string{
+		"instance-0": "",
+		"instance-1": "cluster1",
+		"instance-2": "cluster2",
+	}, instances)
+
+	// corrupt instance file is quarantined into instance subdirectory.
+	require.FileExists(t, filepath.Join(rootDir, "quarantine", "pg", "cluster2", "1708026159-d.json"))
+	require.NoFileExists(t, filepath.Join(pillarDir, "cluster2", "1708026159-d.json"))
+}
+
+func TestCountPillarMetricsFiles(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	pillarDir := filepath.Join(rootDir, "pg")
+
+	for _, fileName := range []string{
+		filepath.Join(pillarDir, "1708026156-a.json"),
+		filepath.Join(pillarDir, "1708026156-a.json.sha256"),
+		filepath.Join(pillarDir, "1708026156-b.json.rejected"),
+		filepath.Join(pillarDir, "cluster1", "1708026157-c.json"),
+		// only one level of instance subdirectories is counted.
+		filepath.Join(pillarDir, "cluster1", "nested", "1708026158-d.json"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0o700))
+		require.NoError(t, os.WriteFile(fileName, []byte(`{}`), 0o600))
+	}
+
+	count, err := CountPillarMetricsFiles(pillarDir)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = CountPillarMetricsFiles(filepath.Join(rootDir, "absent"))
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestCountStoredMetricsFiles(t *testing.T) {
+	t.Parallel()
+
+	failedDir := filepath.Join(t.TempDir(), "failed")
+
+	for _, fileName := range []string{
+		filepath.Join(failedDir, "ps", "1708026156-a.json"),
+		filepath.Join(failedDir, "ps", "1708026156-a.json.error"),
+		filepath.Join(failedDir, "ps", "1708026156-a.json.sha256"),
+		filepath.Join(failedDir, "pg", "cluster1", "1708026157-b.json"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0o700))
+		require.NoError(t, os.WriteFile(fileName, []byte(`{}`), 0o600))
+	}
+
+	count, err := CountStoredMetricsFiles(failedDir)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = CountStoredMetricsFiles(filepath.Join(failedDir, "absent"))
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestProcessMetricsDirectoryMaxAge(t *testing.T) {
+	t.Parallel()
+
+	metricsDir := t.TempDir()
+	currTime := time.Now()
+	freshFile := fmt.Sprintf("%d-%s.json", currTime.Add(-time.Hour).Unix(), uuid.New().String())
+	staleFile := fmt.Sprintf("%d-%s.json", currTime.Add(-48*time.Hour).Unix(), uuid.New().String())
+
+	for _, fileName := range []string{freshFile, staleFile} {
+		require.NoError(t, os.WriteFile(filepath.Join(metricsDir, fileName), []byte(`{"uptime": "112"}`), 0o600))
+	}
+
+	files, err := processMetricsDirectory(t.Context(), metricsDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, ProcessOpts{MaxAge: 24 * time.Hour})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, filepath.Join(metricsDir, freshFile), files[0].Filename)
+
+	// stale file is removed.
+	checkFilesExist(t, metricsDir, freshFile)
+	checkFilesAbsent(t, metricsDir, staleFile)
+}
+
+func TestProcessMetricsDirectoryBatch(t *testing.T) {
+	t.Parallel()
+
+	pillarDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(pillarDir, "cluster1"), 0o700))
+
+	fileNames := []string{"1708026156-a.json", "1708026157-b.json", "1708026158-c.json", filepath.Join("cluster1", "1708026159-d.json")}
+	for _, fileName := range fileNames {
+		require.NoError(t, os.WriteFile(filepath.Join(pillarDir, fileName), []byte(`{"uptime": "112"}`), 0o600))
+	}
+
+	// files are left in place, each batch continues with files not taken yet.
+	batch := NewBatch(3)
+	opts := ProcessOpts{ReadOnly: true, Batch: batch}
+
+	var batches [][]string
+
+	for batch.Next() {
+		files, err := processMetricsDirectory(t.Context(), pillarDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, opts)
+		require.NoError(t, err)
+
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Filename)
+		}
+
+		batches = append(batches, names)
+	}
+
+	require.Equal(t, [][]string{
+		{
+			filepath.Join(pillarDir, "1708026156-a.json"),
+			filepath.Join(pillarDir, "1708026157-b.json"),
+			filepath.Join(pillarDir, "1708026158-c.json"),
+		},
+		{
+			filepath.Join(pillarDir, "cluster1", "1708026159-d.json"),
+		},
+	}, batches)
+}
+
+func TestProcessMetricsDirectoryOrder(t *testing.T) {
+	t.Parallel()
+
+	pillarDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(pillarDir, "cluster1"), 0o700))
+
+	// lexical order of file names differs from the order they were created in.
+	fileNames := []string{"1708026158-a.json", "999999999-b.json", filepath.Join("cluster1", "1708026157-c.json"), "1708026156-d.json"}
+	for _, fileName := range fileNames {
+		require.NoError(t, os.WriteFile(filepath.Join(pillarDir, fileName), []byte(`{"uptime": "112"}`), 0o600))
+	}
+
+	files, err := processMetricsDirectory(t.Context(), pillarDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, ProcessOpts{ReadOnly: true})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Filename)
+	}
+
+	require.Equal(t, []string{
+		filepath.Join(pillarDir, "999999999-b.json"),
+		filepath.Join(pillarDir, "1708026156-d.json"),
+		filepath.Join(pillarDir, "cluster1", "1708026157-c.json"),
+		filepath.Join(pillarDir, "1708026158-a.json"),
+	}, names)
+}
+
+func TestProcessMetricsDirectoryDisabled(t *testing.T) {
+	t.Parallel()
+
+	metricsDir := t.TempDir()
+	staleFile := fmt.Sprintf("%d-%s.json", time.Now().Add(-48*time.Hour).Unix(), uuid.New().String())
+	corruptFile := fmt.Sprintf("%d-%s.json", time.Now().Unix(), uuid.New().String())
+
+	require.NoError(t, os.WriteFile(filepath.Join(metricsDir, staleFile), []byte(`{"uptime": "112"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(metricsDir, corruptFile), []byte(`{"uptime": `), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(metricsDir, PillarDisabledFile), nil, 0o600))
+
+	opts := ProcessOpts{
+		QuarantineDir: t.TempDir(),
+		ParseFailures: NewParseFailures(),
+		MaxAge:        24 * time.Hour,
+	}
+
+	for range quarantineFailuresThreshold {
+		files, err := processMetricsDirectory(t.Context(), metricsDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, opts)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	}
+
+	// files of disabled Pillar are neither removed nor quarantined.
+	checkFilesExist(t, metricsDir, staleFile, corruptFile)
+
+	count, err := CountPillarMetricsFiles(metricsDir)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestProcessMetricsDirectoryReadOnly(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	metricsDir := filepath.Join(rootDir, "ps")
+	currTime := time.Now()
+	freshFile := fmt.Sprintf("%d-%s.json", currTime.Add(-time.Hour).Unix(), uuid.New().String())
+	staleFile := fmt.Sprintf("%d-%s.json", currTime.Add(-48*time.Hour).Unix(), uuid.New().String())
+	corruptFile := fmt.Sprintf("%d-%s.json", currTime.Unix(), uuid.New().String())
+
+	require.NoError(t, os.MkdirAll(metricsDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(metricsDir, freshFile), []byte(`{"uptime": "112"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(metricsDir, staleFile), []byte(`{"uptime": "113"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(metricsDir, corruptFile), []byte(`{"uptime": `), 0o600))
+
+	opts := ProcessOpts{
+		QuarantineDir: filepath.Join(rootDir, "quarantine"),
+		ParseFailures: NewParseFailures(),
+		MaxAge:        24 * time.Hour,
+		ReadOnly:      true,
+	}
+
+	for range quarantineFailuresThreshold {
+		files, err := processMetricsDirectory(t.Context(), metricsDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, opts)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, filepath.Join(metricsDir, freshFile), files[0].Filename)
+	}
+
+	// stale and corrupt files are skipped, but left in place.
+	checkFilesExist(t, metricsDir, freshFile, staleFile, corruptFile)
+	require.NoDirExists(t, filepath.Join(rootDir, "quarantine"))
+}
+
+func TestConvertMetricsNumbers(t *testing.T) {
+	t.Parallel()
+
+	// numbers are formatted exactly as JSON marshalling does.
+	for _, v := range []float64{0, 1, -1, 112, 0.75, 1e-6, 1e-7, 123456789012, 1e20, 1e21, -3.5e-9, math.MaxFloat64} {
+		expected, err := json.Marshal(v)
+		require.NoError(t, err)
+
+		metrics := make(map[string]string)
+		convertMetrics(zap.L().Sugar(), "", map[string]any{"value": v}, false, metrics)
+		require.Equal(t, string(expected), metrics["value"], "value %v", v)
+	}
+
+	metrics := make(map[string]string)
+	convertMetrics(zap.L().Sugar(), "", map[string]any{"enabled": "True", "disabled": "f", "name": "truely"}, false, metrics)
+	require.Equal(t, map[string]string{"enabled": "1", "disabled": "0", "name": "truely"}, metrics)
+}
+
+func TestReportMetrics(t *testing.T) {
+	t.Parallel()
+
+	hostMetrics := &File{Metrics: map[string]string{"OS": "Ubuntu 22.04", "deployment": "PACKAGE"}}
+
+	testCases := []struct {
+		name                string
+		reportSchemaVersion int
+		expectedMetrics     [][2]string
+	}{
+		{
+			name:                "legacy_keys",
+			reportSchemaVersion: 2,
+			expectedMetrics: [][2]string{
+				{"OS", "Ubuntu 22.04"},
+				{"deployment", "PACKAGE"},
+				{"deployment", "DOCKER"},
+				{"uptime", "112"},
+				{ReportSchemaVersionKey, "2"},
+			},
+		},
+		{
+			name:                "namespaced_keys",
+			reportSchemaVersion: 3,
+			expectedMetrics: [][2]string{
+				{"host.OS", "Ubuntu 22.04"},
+				{"host.deployment", "PACKAGE"},
+				{"pillar.deployment", "DOCKER"},
+				{"pillar.uptime", "112"},
+				{ReportSchemaVersionKey, "3"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pillarMetrics := &File{
+				Metrics: map[string]string{
+					"deployment":           "DOCKER",
+					"uptime":               "112",
+					ReportSchemaVersionKey: strconv.Itoa(tt.reportSchemaVersion),
+				},
+				ReportSchemaVersion: tt.reportSchemaVersion,
+			}
+
+			reportMetrics := make([][2]string, 0, len(tt.expectedMetrics))
+			for _, m := range ReportMetrics(hostMetrics, pillarMetrics) {
+				reportMetrics = append(reportMetrics, [2]string{m.GetKey(), m.GetValue()})
+			}
+
+			require.ElementsMatch(t, tt.expectedMetrics, reportMetrics)
+		})
+	}
+}
+
+func TestTagMetrics(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		tags            map[string]string
+		expectedMetrics [][2]string
+	}{
+		{
+			name:            "no_tags",
+			tags:            nil,
+			expectedMetrics: [][2]string{},
+		},
+		{
+			name: "sorted_by_name",
+			tags: map[string]string{"team": "dba", "environment": "prod", "cost.center": ""},
+			expectedMetrics: [][2]string{
+				{"tag.cost.center", ""},
+				{"tag.environment", "prod"},
+				{"tag.team", "dba"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tagMetrics := make([][2]string, 0, len(tt.expectedMetrics))
+			for _, m := range TagMetrics(tt.tags) {
+				tagMetrics = append(tagMetrics, [2]string{m.GetKey(), m.GetValue()})
+			}
+
+			require.Equal(t, tt.expectedMetrics, tagMetrics)
+		})
+	}
+}
+
+// benchmarkMetricsContent is a typical Pillar metrics file with flat and nested metrics.
+const benchmarkMetricsContent = `{
+	"db_instance_id": "8e2b1d4e-5c4f-11ee-8c99-0242ac120002",
+	"pillar_version": "8.0.35-27",
+	"active_plugins": ["binlog", "mysql_native_password", "sha256_password", "caching_sha2_password"],
+	"active_components": ["file://component_percona_telemetry"],
+	"uptime": 112233,
+	"databases_count": 12,
+	"databases_size": 1073741824,
+	"se_engines_in_use": ["InnoDB", "MyISAM"],
+	"replication_info": {"is_semisync_source": "false", "is_replica": "true", "replication_channels": 1},
+	"tls_enabled": true,
+	"ratio": 0.75
+}`
+
+func BenchmarkParseMetricsFile(b *testing.B) {
+	fileName := filepath.Join(b.TempDir(), fmt.Sprintf("%d-%s.json", time.Now().Unix(), uuid.New().String()))
+	require.NoError(b, os.WriteFile(fileName, []byte(benchmarkMetricsContent), 0o600))
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := parseMetricsFile(b.Context(), fileName, ProcessOpts{FlattenNested: true}, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessMetricsDirectory(b *testing.B) {
+	pillarDir := b.TempDir()
+	createTime := time.Now()
+
+	for i := range 100 {
+		fileName := fmt.Sprintf("%d-%s.json", createTime.Add(-time.Duration(i)*time.Second).Unix(), uuid.New().String())
+		require.NoError(b, os.WriteFile(filepath.Join(pillarDir, fileName), []byte(benchmarkMetricsContent), 0o600))
+	}
+
+	// files are left in place, so each iteration processes the same directory.
+	opts := ProcessOpts{ReadOnly: true}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		files, err := processMetricsDirectory(b.Context(), pillarDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, opts)
+		if err != nil || len(files) != 100 {
+			b.Fatalf("unexpected result: %d files, %v", len(files), err)
+		}
+	}
+}
@@ -18,23 +18,51 @@ package metrics
 import (
 	"context"
 	"errors"
+	"net/url"
+	"path/filepath"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/utils"
 )
 
 const (
-	pkgResultTimeout = 30 * time.Second
+	// defaultCmdTimeout is used when command timeout is not set in scrape options.
+	defaultCmdTimeout = 30 * time.Second
+)
+
+// unsupportedOSOccurrences counts package scrapes on OS with unsupported package system.
+var unsupportedOSOccurrences atomic.Int64
+
+// UnsupportedOSOccurrences returns the number of package scrapes on OS with unsupported package system
+// since Telemetry Agent start.
+func UnsupportedOSOccurrences() int64 {
+	return unsupportedOSOccurrences.Load()
+}
+
+// Package origins.
+const (
+	packageOriginPercona    = "percona"
+	packageOriginDistro     = "distro"
+	packageOriginThirdParty = "third-party"
 )
 
 const (
 	distroFamilyUnknown = iota
 	distroFamilyRhel
 	distroFamilyDebian
+	distroFamilyFreeBSD
+	distroFamilyDarwin
 )
 
+// packageManagerUnsupported is the value of 'package_manager' host metric on OS without supported package manager.
+const packageManagerUnsupported = "unsupported"
+
 var (
 	errPackageManagerNotFound    = errors.New("no package manager found")
 	errPackageNotFound           = errors.New("package is not found")
@@ -48,24 +76,62 @@ var (
 type PackageRepository struct {
 	Name      string `json:"name"`
 	Component string `json:"component"`
+	// URL is the repository base URL, it allows to distinguish mirrored/internal repositories from official ones.
+	URL string `json:"url,omitempty"`
 }
 
 // Package represents a software package with its name and version.
 type Package struct {
 	Name       string            `json:"name"`
 	Version    string            `json:"version"`
+	Arch       string            `json:"arch,omitempty"`
 	Repository PackageRepository `json:"repository"`
+	// InstallTime is package installation time in unixtime format, 0 if unknown.
+	InstallTime int64 `json:"install_time,omitempty"`
+	// UpdateAvailable is the newer package version available in configured repositories, empty if package is up-to-date.
+	UpdateAvailable string `json:"update_available,omitempty"`
+	// Origin is where the package build comes from: percona, distro or third-party. Empty if unknown.
+	Origin string `json:"origin,omitempty"`
+}
+
+// PackageScrapeOpts represents the options for scraping installed packages.
+type PackageScrapeOpts struct {
+	// Workers is the maximum number of package queries running concurrently.
+	Workers int
+	// CmdTimeout is the timeout for a single package manager command (dpkg-query, apt-cache, rpm, etc.).
+	CmdTimeout time.Duration
+	// RepoqueryTimeout is the timeout for a single repoquery/yum/dnf command.
+	// It is usually longer than CmdTimeout as repoquery may refresh repositories metadata on the first run.
+	RepoqueryTimeout time.Duration
+	// ExtraPatterns are package name patterns queried from package manager in addition to the built-in ones.
+	ExtraPatterns []string
+}
+
+func (o PackageScrapeOpts) cmdTimeout() time.Duration {
+	if o.CmdTimeout <= 0 {
+		return defaultCmdTimeout
+	}
+
+	return o.CmdTimeout
+}
+
+func (o PackageScrapeOpts) repoqueryTimeout() time.Duration {
+	if o.RepoqueryTimeout <= 0 {
+		return o.cmdTimeout()
+	}
+
+	return o.RepoqueryTimeout
 }
 
 // queryPkgFunc represents a function type for querying package information from particular package manager (dpkg or rpm).
-type queryPkgFunc func(ctx context.Context, localOS, packageName string) ([]*Package, error)
+type queryPkgFunc func(ctx context.Context, opts PackageScrapeOpts, localOS, packageName string) ([]*Package, error)
 
 // ScrapeInstalledPackages scrapes the installed packages on the host and returns a slice of Package structs along with any errors encountered.
 // The function uses the localOS variable to determine the package manager to use.
-func ScrapeInstalledPackages(ctx context.Context) []*Package {
+func ScrapeInstalledPackages(ctx context.Context, opts PackageScrapeOpts) []*Package {
 	pkgList := getCommonPerconaPackages()
 	pkgList = append(pkgList, getCommonExternalPackages()...)
-	localOS := getOSInfo()
+	localOS := localOSInfo(ctx)
 
 	toReturn := make([]*Package, 0, 1)
 
@@ -79,29 +145,169 @@ func ScrapeInstalledPackages(ctx context.Context) []*Package {
 		pkgList = append(pkgList, getDebianPerconaPackages()...)
 		pkgList = append(pkgList, getDebianExternalPackages()...)
 	case distroFamilyRhel:
-		pkgFunc = queryRhelPackage
+		pkgFunc = newRhelPackageQuery(ctx, opts, localOS)
 
 		pkgList = append(pkgList, getRhelExternalPackages()...)
+	case distroFamilyFreeBSD:
+		pkgFunc = queryFreeBSDPackage
+
+		pkgList = append(pkgList, getFreeBSDPerconaPackages()...)
+		pkgList = append(pkgList, getFreeBSDExternalPackages()...)
+	case distroFamilyDarwin:
+		// macOS is supported for development only, Percona software is not packaged for it.
+		logger.FromContext(ctx).Sugar().Debugw("package scraping is not supported on macOS", zap.String("OS", localOS))
+		unsupportedOSOccurrences.Add(1)
 	default:
-		zap.L().Sugar().Warnw("unsupported package system", zap.String("OS", localOS))
-		return toReturn
+		// package manager is unknown, but Percona software may still be installed from tarballs or pip.
+		logger.FromContext(ctx).Sugar().Warnw("unsupported package system", zap.String("OS", localOS))
+		unsupportedOSOccurrences.Add(1)
+	}
+
+	if pkgFunc != nil {
+		for _, pattern := range opts.ExtraPatterns {
+			// built-in patterns are not queried twice, so packages are not duplicated.
+			if !slices.Contains(pkgList, pattern) {
+				pkgList = append(pkgList, pattern)
+			}
+		}
+
+		toReturn = append(toReturn, queryPackagePatterns(ctx, pkgFunc, opts, localOS, pkgList)...)
+	}
+
+	// Percona software installed from tarballs or pip is not visible to package manager.
+	toReturn = append(toReturn, queryTarballPackages(ctx, distroFamily, opts.cmdTimeout())...)
+	toReturn = append(toReturn, queryPipPackages()...)
+
+	for _, pkg := range toReturn {
+		pkg.Origin = getPackageOrigin(pkg.Repository)
 	}
 
-	for _, pkgNamePattern := range pkgList {
-		pkgL, err := pkgFunc(ctx, localOS, pkgNamePattern)
+	return toReturn
+}
+
+// getDistroRepositoryHosts returns list of host suffixes of the official distribution repositories.
+func getDistroRepositoryHosts() []string {
+	return []string{
+		"ubuntu.com",
+		"debian.org",
+		"centos.org",
+		"rockylinux.org",
+		"almalinux.org",
+		"redhat.com",
+		"fedoraproject.org",
+		"oracle.com",
+		"amazonlinux.com",
+		"amazonaws.com",
+		"suse.com",
+		"opensuse.org",
+	}
+}
+
+// getDistroRepositoryNames returns list of repository name patterns of the official distribution repositories.
+// They are used when repository URL is unknown (e.g. package info is read from rpm database).
+func getDistroRepositoryNames() []string {
+	return []string{
+		"base",
+		"baseos",
+		"appstream",
+		"crb",
+		"powertools",
+		"extras",
+		"updates",
+		"ol[0-9]*",
+		"rhel-*",
+		"amzn*",
+		"freebsd",
+	}
+}
+
+// getPackageOrigin returns origin of the package build based on repository it is installed from.
+func getPackageOrigin(repo PackageRepository) string {
+	switch repo.Name {
+	case tarballRepositoryName:
+		// only Percona binaries are looked up in tarballs.
+		return packageOriginPercona
+	case pipRepositoryName:
+		return packageOriginThirdParty
+	}
+
+	if len(repo.URL) != 0 {
+		repoURL, err := url.Parse(repo.URL)
+		if err != nil || len(repoURL.Hostname()) == 0 {
+			return ""
+		}
+
+		host := strings.ToLower(repoURL.Hostname())
+		if host == perconaRepositoryHost {
+			return packageOriginPercona
+		}
+
+		for _, distroHost := range getDistroRepositoryHosts() {
+			if host == distroHost || strings.HasSuffix(host, "."+distroHost) {
+				return packageOriginDistro
+			}
+		}
+
+		return packageOriginThirdParty
+	}
+
+	// On some OSes (Centos 7, Amazon Linux 2) repository name may start from '@'.
+	repoName := strings.ToLower(strings.TrimPrefix(repo.Name, "@"))
+	switch repoName {
+	case "", "system", "commandline", "installed", "unknown-repository":
+		// package is installed manually from file, its origin is unknown.
+		return ""
+	}
+
+	// repository URL is unknown, so Percona repository can be detected by component only
+	// as it is parsed for Percona packages only.
+	if len(repo.Component) != 0 {
+		return packageOriginPercona
+	}
+
+	for _, pattern := range getDistroRepositoryNames() {
+		if matched, err := filepath.Match(pattern, repoName); err == nil && matched {
+			return packageOriginDistro
+		}
+	}
+
+	return packageOriginThirdParty
+}
+
+// queryPackagePatterns runs pkgFunc for each package pattern concurrently using not more than opts.Workers workers.
+// The result order is the same as the order of package patterns regardless of query completion order.
+func queryPackagePatterns(ctx context.Context, pkgFunc queryPkgFunc, opts PackageScrapeOpts, localOS string, pkgList []string) []*Package {
+	results := make([][]*Package, len(pkgList))
+
+	var pkgManagerNotFound atomic.Bool
+
+	utils.ForEachParallel(len(pkgList), opts.Workers, func(i int) {
+		if pkgManagerNotFound.Load() {
+			// no need to check the rest of package patterns.
+			return
+		}
+
+		pkgNamePattern := pkgList[i]
+
+		pkgL, err := pkgFunc(ctx, opts, localOS, pkgNamePattern)
 		if err != nil {
 			if errors.Is(err, errPackageManagerNotFound) {
-				// no need to check the rest of package patterns.
-				break
+				pkgManagerNotFound.Store(true)
+				return
 			}
 
 			if !errors.Is(err, errPackageNotFound) {
-				zap.L().Sugar().Warnw("failed to get package info", zap.Error(err), zap.String("package", pkgNamePattern))
+				logger.FromContext(ctx).Sugar().Warnw("failed to get package info", zap.Error(err), zap.String("package", pkgNamePattern))
 			}
 			// go to next package pattern silently
-			continue
+			return
 		}
 		// packages are installed
+		results[i] = pkgL
+	})
+
+	toReturn := make([]*Package, 0, 1)
+	for _, pkgL := range results {
 		toReturn = append(toReturn, pkgL...)
 	}
 
@@ -113,6 +319,14 @@ func getDistroFamily(name string) int {
 	debianPrefixes := []string{"debian", "ubuntu"} //nolint:goconst
 
 	nameL := strings.ToLower(name)
+	if strings.HasPrefix(nameL, "freebsd") {
+		return distroFamilyFreeBSD
+	}
+
+	if strings.HasPrefix(nameL, "darwin") || strings.HasPrefix(nameL, "macos") {
+		return distroFamilyDarwin
+	}
+
 	for _, prefix := range rhelPrefixes {
 		if strings.HasPrefix(nameL, prefix) {
 			return distroFamilyRhel
@@ -128,12 +342,23 @@ func getDistroFamily(name string) int {
 	return distroFamilyUnknown
 }
 
+// isPackageManagerSupported returns true if installed packages can be queried from package manager of the OS.
+func isPackageManagerSupported(distroFamily int) bool {
+	switch distroFamily {
+	case distroFamilyRhel, distroFamilyDebian, distroFamilyFreeBSD:
+		return true
+	default:
+		return false
+	}
+}
+
 func isPerconaPackage(packageNamePattern string) bool {
 	if len(packageNamePattern) == 0 {
 		return false
 	}
 
 	perconaPkgList := append(getCommonPerconaPackages(), getDebianPerconaPackages()...)
+	perconaPkgList = append(perconaPkgList, getFreeBSDPerconaPackages()...)
 
 	return slices.Contains(perconaPkgList, packageNamePattern)
 }
@@ -158,3 +383,22 @@ func getCommonExternalPackages() []string {
 		"postgis*",
 	}
 }
+
+// splitFields splits package manager output line s by sep into fields, reusing fields backing array,
+// so no slice is allocated per line. It returns false if the line has more than cap(fields) fields.
+func splitFields(fields []string, s, sep string) ([]string, bool) {
+	fields = fields[:0]
+
+	for len(fields) < cap(fields) {
+		field, rest, found := strings.Cut(s, sep)
+		fields = append(fields, field)
+
+		if !found {
+			return fields, true
+		}
+
+		s = rest
+	}
+
+	return fields, false
+}
@@ -5,13 +5,16 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"fmt"
 	"net/url"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	debVersion "github.com/knqyf263/go-deb-version"
 	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
 )
 
 var (
@@ -19,25 +22,38 @@ var (
 	errUnexpectedConfiguredRepoLine = errors.New("unexpected configured package repository line")
 )
 
-func queryDebianPackage(ctx context.Context, _, packageNamePattern string) ([]*Package, error) {
-	args := []string{"dpkg-query", "-f", "'${db:Status-Abbrev}|${binary:Package}|${source:Version}\n'", "-W", packageNamePattern}
-	zap.L().Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+func queryDebianPackage(ctx context.Context, opts PackageScrapeOpts, _, packageNamePattern string) ([]*Package, error) {
+	// read dpkg status database directly first, it works even in minimal containers
+	// without dpkg-query and doesn't require a fork per package pattern.
+	// It is skipped while external commands are recorded or replayed, so dpkg-query output is captured.
+	pkgL, err := []*Package(nil), errPackageDatabaseSkipped
+	if commandRecorderFromContext(ctx) == nil {
+		pkgL, err = queryDebianStatusDatabase(dpkgStatusFile, packageNamePattern)
+	}
 
-	cmdCtx, cancel := context.WithTimeout(ctx, pkgResultTimeout)
-	defer cancel()
+	if err != nil && !errors.Is(err, errPackageNotFound) {
+		logger.FromContext(ctx).Sugar().Debugw("failed to read dpkg status database, fallback to dpkg-query",
+			zap.Error(err), zap.String("package", packageNamePattern))
 
-	cmd := exec.CommandContext(cmdCtx, args[0], args[1:]...) // #nosec G204
-	outputB, err := cmd.CombinedOutput()
+		pkgL, err = queryDebianPackageCmd(ctx, opts.cmdTimeout(), packageNamePattern)
+	}
 
-	pkgL, err := parseDebianPackageOutput(outputB, err, isPerconaPackage(packageNamePattern))
 	if err != nil {
 		return nil, err
 	}
+
+	for _, pkg := range pkgL {
+		pkg.InstallTime = getDebianPackageInstallTime(dpkgInfoDir, pkg.Name)
+	}
 	// need extra processing - get package repository info.
+	// Packages are queried one by one, as package patterns are already queried concurrently by opts.Workers workers.
 	for _, pkg := range pkgL {
-		pkgRepository, repoErr := queryDebianRepository(ctx, pkg.Name, isPerconaPackage(packageNamePattern))
+		policyOutput, policyErr := queryDebianPolicy(ctx, opts.cmdTimeout(), pkg.Name)
+		pkg.UpdateAvailable = parseDebianCandidateVersion(policyOutput, policyErr, isPerconaPackage(packageNamePattern))
+
+		pkgRepository, repoErr := parseDebianRepositoryOutput(policyOutput, policyErr, isPerconaPackage(packageNamePattern))
 		if repoErr != nil {
-			zap.L().Sugar().Warnw("failed to get package repository info", zap.Error(repoErr), zap.String("package", pkg.Name))
+			logger.FromContext(ctx).Sugar().Warnw("failed to get package repository info", zap.Error(repoErr), zap.String("package", pkg.Name))
 			// go to next package silently
 			continue
 		}
@@ -48,9 +64,45 @@ func queryDebianPackage(ctx context.Context, _, packageNamePattern string) ([]*P
 	return pkgL, nil
 }
 
+// getDebianPackageInstallTime returns package installation time in unixtime format.
+// dpkg doesn't keep installation time, so the modification time of package files list
+// (<dpkg info dir>/<package name>[:<arch>].list) is used instead, it is rewritten on each package (re)installation.
+// 0 is returned in case of any error.
+func getDebianPackageInstallTime(infoDir, packageName string) int64 {
+	listFiles := []string{filepath.Join(infoDir, packageName+".list")}
+
+	archListFiles, err := filepath.Glob(filepath.Join(infoDir, packageName+":*.list"))
+	if err == nil {
+		listFiles = append(listFiles, archListFiles...)
+	}
+
+	for _, listFile := range listFiles {
+		st, err := os.Stat(filepath.Clean(listFile))
+		if err == nil {
+			return st.ModTime().Unix()
+		}
+	}
+
+	return 0
+}
+
+func queryDebianPackageCmd(ctx context.Context, cmdTimeout time.Duration, packageNamePattern string) ([]*Package, error) {
+	args := []string{"dpkg-query", "-f", "'${db:Status-Abbrev}|${binary:Package}|${source:Version}|${Architecture}\n'", "-W", packageNamePattern}
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	outputB, err := runCommand(cmdCtx, args)
+
+	return parseDebianPackageOutput(outputB, err, isPerconaPackage(packageNamePattern))
+}
+
 func parseDebianPackageOutput(dpkgOutput []byte, dpkgErr error, isPerconaPackage bool) ([]*Package, error) {
 	if dpkgErr != nil {
-		if strings.Contains(string(dpkgOutput), "no packages found matching") {
+		// dpkg-query exits with code 1 when no packages found matching pattern,
+		// the message check is kept in case exit code is not available.
+		if cmdExitCode(dpkgErr) == 1 || strings.Contains(string(dpkgOutput), "no packages found matching") {
 			// package is not installed
 			return nil, errPackageNotFound
 		}
@@ -60,24 +112,26 @@ func parseDebianPackageOutput(dpkgOutput []byte, dpkgErr error, isPerconaPackage
 		return nil, dpkgErr
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(dpkgOutput))
 	toReturn := make([]*Package, 0, 1)
 
-	for scanner.Scan() {
-		// trim spaces and single quote chars
-		line := strings.Trim(scanner.Text(), " '")
+	var tokensBuf [4]string
+
+	// output is converted into string once, so lines and their fields are not allocated one by one.
+	for line := range strings.Lines(string(dpkgOutput)) {
+		// trim spaces, line endings and single quote chars
+		line = strings.Trim(line, " '\r\n")
 		if len(line) == 0 {
 			continue
 		}
 
-		tokens := strings.Split(line, "|")
+		tokens, ok := splitFields(tokensBuf[:], line, "|")
 		// The successful line for package shall be in format:
-		// <status> |<package name>|[epoch:]<version>.
+		// <status> |<package name>|[epoch:]<version>[|<architecture>].
 		// Example:
-		// 'ii |percona-xtrabackup-81|8.1.0-1-1.jammy'
+		// 'ii |percona-xtrabackup-81|8.1.0-1-1.jammy|amd64'
 		// or with epoch:
-		// 'ii |percona-xtrabackup-81|2:8.1.0-1-1.jammy'
-		if len(tokens) != 3 {
+		// 'ii |percona-xtrabackup-81|2:8.1.0-1-1.jammy|amd64'
+		if !ok || (len(tokens) != 3 && len(tokens) != 4) {
 			continue
 		}
 
@@ -91,11 +145,15 @@ func parseDebianPackageOutput(dpkgOutput []byte, dpkgErr error, isPerconaPackage
 		}
 
 		// process package name
-		pkgName = parseDebianPackageName(pkgName)
+		pkgName, pkgArch := parseDebianPackageName(pkgName)
 		if len(pkgName) == 0 {
 			continue
 		}
 
+		if len(tokens) == 4 && len(strings.TrimSpace(tokens[3])) != 0 {
+			pkgArch = strings.TrimSpace(tokens[3])
+		}
+
 		// process package version
 		pkgVersion = parseDebianPackageVersion(pkgVersion, isPerconaPackage)
 		if len(pkgVersion) == 0 {
@@ -105,15 +163,10 @@ func parseDebianPackageOutput(dpkgOutput []byte, dpkgErr error, isPerconaPackage
 		toReturn = append(toReturn, &Package{
 			Name:    pkgName,
 			Version: pkgVersion,
+			Arch:    pkgArch,
 		})
 	}
 
-	err := scanner.Err()
-	if err != nil {
-		zap.L().Sugar().Warnw("failed to read output from dpkg-query", zap.Error(err))
-		return nil, err
-	}
-
 	if len(toReturn) == 0 {
 		// no installed packaged found matching pkgNamePattern
 		return nil, errPackageNotFound
@@ -122,14 +175,17 @@ func parseDebianPackageOutput(dpkgOutput []byte, dpkgErr error, isPerconaPackage
 	return toReturn, nil
 }
 
-func parseDebianPackageName(pkgName string) string {
+// parseDebianPackageName splits package name into name and architecture parts.
+func parseDebianPackageName(pkgName string) (string, string) {
 	pkgName = strings.TrimSpace(pkgName)
 	// pkgName may have format:
 	// <name>[:architecture]
 	// Example:
 	// 'percona-xtrabackup-81:amd64'
 	// Need to trim architecture part.
-	return strings.Split(pkgName, ":")[0]
+	name, arch, _ := strings.Cut(pkgName, ":")
+
+	return name, arch
 }
 
 func parseDebianPackageVersion(pkgVersion string, isPerconaPackage bool) string {
@@ -160,7 +216,7 @@ func parseDebianPackageVersion(pkgVersion string, isPerconaPackage bool) string
 			// special hack - replace all "." with "-" to unify version format
 			// for all Percona's packages.
 			revision := strings.ReplaceAll(v.Revision(), ".", "-")
-			return fmt.Sprintf("%s-%s", v.Version(), revision)
+			return v.Version() + "-" + revision
 		}
 
 		return v.Version()
@@ -181,17 +237,71 @@ func parseDebianPackageVersion(pkgVersion string, isPerconaPackage bool) string
 	return pkgVersion
 }
 
-func queryDebianRepository(ctx context.Context, packageName string, isPerconaPackage bool) (*PackageRepository, error) {
+func queryDebianPolicy(ctx context.Context, cmdTimeout time.Duration, packageName string) ([]byte, error) {
 	args := []string{"apt-cache", "-q=0", "policy", packageName}
-	zap.L().Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
 
-	cmdCtx, cancel := context.WithTimeout(ctx, pkgResultTimeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, args[0], args[1:]...) // #nosec G204
-	outputB, err := cmd.CombinedOutput()
+	return runCommand(cmdCtx, args)
+}
+
+// parseDebianCandidateVersion returns candidate version from 'apt-cache policy' output
+// if it is newer than installed one, otherwise empty string is returned.
+func parseDebianCandidateVersion(policyOutput []byte, policyErr error, isPerconaPackage bool) string {
+	if policyErr != nil {
+		return ""
+	}
+
+	// the output example:
+	// percona-server-server:
+	//  Installed: 8.0.35-27-1.jammy
+	//  Candidate: 8.0.36-28-1.jammy
+	//  Version table:
+	// ...
+	// Field names are translated on non-C locales, so the fields are taken by position:
+	// the first indented field is installed version, the second one is candidate version.
+	values := make([]string, 0, 2)
+
+	scanner := bufio.NewScanner(bytes.NewReader(policyOutput))
+	for scanner.Scan() && len(values) < 2 {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			// package name header or notice line
+			continue
+		}
+
+		_, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		values = append(values, strings.TrimSpace(value))
+	}
+
+	if len(values) != 2 {
+		return ""
+	}
+
+	installed, candidate := values[0], values[1]
+
+	if len(candidate) == 0 || candidate == "(none)" || candidate == installed {
+		return ""
+	}
 
-	return parseDebianRepositoryOutput(outputB, err, isPerconaPackage)
+	candidateV, err := debVersion.NewVersion(candidate)
+	if err != nil {
+		return ""
+	}
+
+	installedV, err := debVersion.NewVersion(installed)
+	if err == nil && !candidateV.GreaterThan(installedV) {
+		// candidate may be older than installed one in case of pinning or manually installed package.
+		return ""
+	}
+
+	return parseDebianPackageVersion(candidate, isPerconaPackage)
 }
 
 func parseDebianRepositoryOutput(repoOutput []byte, repoErr error, isPerconaPackage bool) (*PackageRepository, error) {
@@ -214,11 +324,9 @@ func parseDebianRepositoryOutput(repoOutput []byte, repoErr error, isPerconaPack
 	for scanner.Scan() {
 		// trim spaces and single quote chars
 		line := strings.Trim(scanner.Text(), " '\t")
-		if strings.Contains(line, "Unable to locate package") {
-			// package is not installed
-			return nil, errPackageRepositoryNotFound
-		}
-		// need to find line that refers to installed package
+		// need to find line that refers to installed package.
+		// Note: in case of package is not found apt-cache prints only notice
+		// ('N: Unable to locate package ...' in C locale), so no such line presents.
 		if len(line) == 0 || !strings.HasPrefix(line, "***") {
 			continue
 		}
@@ -289,6 +397,7 @@ func parseDebianPackageRepositoryLine(repositoryLine string, isPerconaPackage bo
 	return &PackageRepository{
 		Name:      repoName,
 		Component: repoComponent,
+		URL:       repoAddr,
 	}, nil
 }
 
@@ -0,0 +1,225 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	dpkgStatusFile = "/var/lib/dpkg/status"
+	dpkgInfoDir    = "/var/lib/dpkg/info"
+	// dpkg status database may contain long lines (e.g. Description, Conffiles),
+	// so the default bufio.Scanner buffer size is not enough.
+	dpkgStatusMaxLineSize = 1024 * 1024
+)
+
+// dpkgStatusEntry represents a single package stanza from dpkg status database.
+type dpkgStatusEntry struct {
+	pkgName    string
+	status     string
+	version    string
+	sourceVers string
+	arch       string
+}
+
+// queryDebianStatusDatabase reads installed packages matching packageNamePattern
+// directly from dpkg status database file.
+func queryDebianStatusDatabase(statusFile, packageNamePattern string) ([]*Package, error) {
+	cleanStatusFile := filepath.Clean(statusFile)
+	l := zap.L().Sugar().With(zap.String("file", cleanStatusFile))
+	l.Debugw("reading dpkg status database", zap.String("package", packageNamePattern))
+
+	file, err := os.Open(cleanStatusFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func(l *zap.SugaredLogger) {
+		fErr := file.Close()
+		if fErr != nil {
+			l.Errorw("failed to close file", zap.Error(fErr))
+		}
+	}(l)
+
+	return parseDebianStatusDatabase(file, packageNamePattern, isPerconaPackage(packageNamePattern))
+}
+
+func parseDebianStatusDatabase(r io.Reader, packageNamePattern string, isPerconaPackage bool) ([]*Package, error) {
+	// The status database consists of stanzas separated by empty lines.
+	// Example:
+	// Package: percona-xtrabackup-81
+	// Status: install ok installed
+	// Priority: extra
+	// Architecture: amd64
+	// Source: percona-xtrabackup-81
+	// Version: 8.1.0-1-1.jammy
+	// Description: Open source backup tool for InnoDB and XtraDB
+	//  Percona XtraBackup is an open-source hot backup utility for MySQL.
+	//
+	// Package: percona-server-client
+	// ...
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), dpkgStatusMaxLineSize)
+
+	toReturn := make([]*Package, 0, 1)
+
+	var entry dpkgStatusEntry
+
+	flushEntry := func() {
+		defer func() {
+			entry = dpkgStatusEntry{}
+		}()
+
+		if len(entry.pkgName) == 0 {
+			return
+		}
+
+		if matched, err := filepath.Match(packageNamePattern, entry.pkgName); err != nil || !matched {
+			return
+		}
+
+		// the same statuses are accepted as for 'dpkg-query' output.
+		pkgStatus := parseDebianStatusAbbrev(entry.status)
+		if pkgStatus != "ii" && pkgStatus != "iHR" {
+			// package is not installed, skip it.
+			return
+		}
+
+		pkgName, pkgArch := parseDebianPackageName(entry.pkgName)
+		if len(pkgName) == 0 {
+			return
+		}
+
+		if len(entry.arch) != 0 {
+			pkgArch = entry.arch
+		}
+
+		pkgVersion := parseDebianPackageVersion(entry.getSourceVersion(), isPerconaPackage)
+		if len(pkgVersion) == 0 {
+			return
+		}
+
+		toReturn = append(toReturn, &Package{
+			Name:    pkgName,
+			Version: pkgVersion,
+			Arch:    pkgArch,
+		})
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) == 0 {
+			flushEntry()
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			// continuation of multiline field, not needed.
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "Package":
+			entry.pkgName = value
+		case "Status":
+			entry.status = value
+		case "Version":
+			entry.version = value
+		case "Architecture":
+			entry.arch = value
+		case "Source":
+			// Source field may contain source version in brackets:
+			// Source: percona-server (8.0.36-28-1.jammy)
+			_, entry.sourceVers, _ = strings.Cut(value, " ")
+			entry.sourceVers = strings.Trim(entry.sourceVers, "()")
+		}
+	}
+
+	// the last stanza may be not followed by an empty line.
+	flushEntry()
+
+	err := scanner.Err()
+	if err != nil {
+		zap.L().Sugar().Warnw("failed to read dpkg status database", zap.Error(err))
+		return nil, err
+	}
+
+	if len(toReturn) == 0 {
+		// no installed packaged found matching pkgNamePattern
+		return nil, errPackageNotFound
+	}
+
+	return toReturn, nil
+}
+
+// getSourceVersion returns the same value as '${source:Version}' dpkg-query format does.
+func (e dpkgStatusEntry) getSourceVersion() string {
+	if len(e.sourceVers) != 0 {
+		return e.sourceVers
+	}
+
+	return e.version
+}
+
+// parseDebianStatusAbbrev converts Status field value into the abbreviated form
+// used by '${db:Status-Abbrev}' dpkg-query format.
+// Example:
+// 'install ok installed' -> 'ii'
+// 'install reinstreq half-installed' -> 'iHR'.
+func parseDebianStatusAbbrev(status string) string {
+	tokens := strings.Fields(status)
+	if len(tokens) != 3 {
+		return ""
+	}
+
+	wantAbbrev := map[string]string{
+		"unknown":   "u",
+		"install":   "i",
+		"hold":      "h",
+		"deinstall": "r",
+		"purge":     "p",
+	}
+	statusAbbrev := map[string]string{
+		"not-installed":    "n",
+		"config-files":     "c",
+		"half-installed":   "H",
+		"unpacked":         "U",
+		"half-configured":  "F",
+		"triggers-awaited": "W",
+		"triggers-pending": "t",
+		"installed":        "i",
+	}
+
+	var errAbbrev string
+	if tokens[1] == "reinstreq" {
+		errAbbrev = "R"
+	}
+
+	return wantAbbrev[tokens[0]] + statusAbbrev[tokens[2]] + errAbbrev
+}
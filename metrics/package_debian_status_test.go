@@ -0,0 +1,267 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDpkgStatusDatabase = `Package: percona-backup-mongodb
+Status: install ok installed
+Priority: optional
+Section: database
+Installed-Size: 98765
+Maintainer: Percona Development Team <info@percona.com>
+Architecture: amd64
+Version: 2.3.1-1.jammy
+Description: Percona Backup for MongoDB
+ Percona Backup for MongoDB is a distributed, low-impact solution for achieving
+ consistent backups of MongoDB sharded clusters and replica sets.
+
+Package: percona-mysql-shell
+Status: install ok installed
+Architecture: amd64
+Multi-Arch: same
+Version: 8.2.0-1-1.jammy
+
+Package: percona-pg-stat-monitor16
+Status: install ok installed
+Architecture: amd64
+Source: percona-pg-stat-monitor (1:2.0.4-2.jammy)
+Version: 2.0.4-2.jammy
+
+Package: percona-pgbouncer
+Status: install reinstreq half-installed
+Architecture: amd64
+Version: 1:1.22.0-1.jammy
+
+Package: percona-server-client-5.7
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 5.7.44-48-1.jammy
+
+Package: percona-xtrabackup-81
+Status: install ok installed
+Architecture: amd64
+Version: 8.1.0-1-1.jammy
+Conffiles:
+ /etc/xtrabackup.cnf 3c4e7c4fd7d1e4e6b1e3a7e1fd7c0d9b
+
+Package: proxysql2
+Status: install ok installed
+Architecture: amd64
+Version: 2:2.5.5-1.2.jammy
+
+Package: etcd
+Status: install ok installed
+Architecture: amd64
+Version: 1:3.3.25+dfsg-7ubuntu0.22.04.1
+
+Package: pmm-client
+Status: purge ok not-installed
+Architecture: amd64`
+
+func TestParseDebianStatusDatabase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		packageNamePattern  string
+		statusDatabase      []byte
+		expectedPackageList []*Package
+		expectErr           error
+	}{
+		{
+			name:               "pattern_percona_full_output",
+			packageNamePattern: "percona-*",
+			statusDatabase:     []byte(testDpkgStatusDatabase),
+			expectedPackageList: []*Package{
+				{
+					Name:       "percona-backup-mongodb",
+					Version:    "2.3.1-1",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+				{
+					Name:       "percona-mysql-shell",
+					Version:    "8.2.0-1-1",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+				{
+					Name:       "percona-pg-stat-monitor16",
+					Version:    "2.0.4-2",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+				{
+					Name:       "percona-pgbouncer",
+					Version:    "1.22.0-1",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+				{
+					Name:       "percona-xtrabackup-81",
+					Version:    "8.1.0-1-1",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+			},
+		},
+		{
+			name:               "pattern_percona_proxysql_installed_full_output",
+			packageNamePattern: "proxysql*",
+			statusDatabase:     []byte(testDpkgStatusDatabase),
+			expectedPackageList: []*Package{
+				{
+					Name:       "proxysql2",
+					Version:    "2.5.5-1-2",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+			},
+		},
+		{
+			name:               "exact_external_installed_full_version_with_epoch_with_dfsg",
+			packageNamePattern: "etcd*",
+			statusDatabase:     []byte(testDpkgStatusDatabase),
+			expectedPackageList: []*Package{
+				{
+					Name:       "etcd",
+					Version:    "3.3.25",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+			},
+		},
+		{
+			name:               "pattern_percona_pmm_not_installed",
+			packageNamePattern: "pmm*",
+			statusDatabase:     []byte(testDpkgStatusDatabase),
+			expectErr:          errPackageNotFound,
+		},
+		{
+			name:               "percona_not_found",
+			packageNamePattern: "percona2-*",
+			statusDatabase:     []byte(testDpkgStatusDatabase),
+			expectErr:          errPackageNotFound,
+		},
+		{
+			name:               "empty_database",
+			packageNamePattern: "percona-*",
+			statusDatabase:     []byte(``),
+			expectErr:          errPackageNotFound,
+		},
+		{
+			name:               "invalid_database",
+			packageNamePattern: "percona-*",
+			statusDatabase:     []byte(`Package: percona-xtrabackup-81`),
+			expectErr:          errPackageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pkg, err := parseDebianStatusDatabase(bytes.NewReader(tt.statusDatabase), tt.packageNamePattern, isPerconaPackage(tt.packageNamePattern))
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				require.Nil(t, pkg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedPackageList, pkg)
+		})
+	}
+}
+
+func TestQueryDebianStatusDatabase(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	// absent database shall return error to trigger fallback to dpkg-query.
+	_, err := queryDebianStatusDatabase(filepath.Join(tmpDir, "status"), "percona-*")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = os.WriteFile(filepath.Join(tmpDir, "status"), []byte(`Package: percona-xtrabackup-81
+Status: install ok installed
+Architecture: amd64
+Version: 8.1.0-1-1.jammy
+`), metricsFilePermissions)
+	require.NoError(t, err)
+
+	pkgL, err := queryDebianStatusDatabase(filepath.Join(tmpDir, "status"), "percona-*")
+	require.NoError(t, err)
+	require.Equal(t, []*Package{
+		{
+			Name:    "percona-xtrabackup-81",
+			Version: "8.1.0-1-1",
+			Arch:    "amd64",
+		},
+	}, pkgL)
+}
+
+func TestParseDebianStatusAbbrev(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status   string
+		expected string
+	}{
+		{status: "install ok installed", expected: "ii"},
+		{status: "install reinstreq half-installed", expected: "iHR"},
+		{status: "hold ok installed", expected: "hi"},
+		{status: "deinstall ok config-files", expected: "rc"},
+		{status: "purge ok not-installed", expected: "pn"},
+		{status: "", expected: ""},
+		{status: "install ok", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, parseDebianStatusAbbrev(tt.status))
+		})
+	}
+}
+
+func TestGetDebianPackageInstallTime(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	installTime := time.Unix(1708026156, 0)
+
+	for _, listFile := range []string{"percona-server-server.list", "percona-mysql-shell:amd64.list"} {
+		filePath := filepath.Join(tmpDir, listFile)
+		require.NoError(t, os.WriteFile(filePath, []byte("/."), metricsFilePermissions))
+		require.NoError(t, os.Chtimes(filePath, installTime, installTime))
+	}
+
+	require.Equal(t, installTime.Unix(), getDebianPackageInstallTime(tmpDir, "percona-server-server"))
+	require.Equal(t, installTime.Unix(), getDebianPackageInstallTime(tmpDir, "percona-mysql-shell"))
+	require.Equal(t, int64(0), getDebianPackageInstallTime(tmpDir, "percona-toolkit"))
+}
@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -78,6 +80,7 @@ un |percona-xtradb-server-5.0|
 				{
 					Name:       "percona-mysql-shell",
 					Version:    "8.2.0-1-1",
+					Arch:       "amd64",
 					Repository: PackageRepository{},
 				},
 				{
@@ -222,6 +225,22 @@ ii |pmm2-client|2.41.2-6.1.jammy
 				{
 					Name:       "etcd",
 					Version:    "3.3.25",
+					Arch:       "amd64",
+					Repository: PackageRepository{},
+				},
+			},
+			expectErr: nil,
+		},
+		{
+			name:             "exact_external_installed_with_arch_column",
+			isPerconaPackage: isPerconaPackage("etcd"),
+			packageOutput:    []byte(`ii |etcd|1:3.3.25+dfsg-7ubuntu0.22.04.1|arm64`),
+			packageErr:       nil,
+			expectedPackageList: []*Package{
+				{
+					Name:       "etcd",
+					Version:    "3.3.25",
+					Arch:       "arm64",
 					Repository: PackageRepository{},
 				},
 			},
@@ -243,6 +262,14 @@ ii |pmm2-client|2.41.2-6.1.jammy
 			expectedPackageList: nil,
 			expectErr:           errPackageNotFound,
 		},
+		{
+			name:                "percona_not_found_localized",
+			isPerconaPackage:    isPerconaPackage("percona-*"),
+			packageOutput:       []byte(`dpkg-query: no se encontró ningún paquete que corresponda con percona2-*`),
+			packageErr:          testExitError(1),
+			expectedPackageList: nil,
+			expectErr:           errPackageNotFound,
+		},
 		{
 			name:                "dpkg_error",
 			isPerconaPackage:    isPerconaPackage("percona-*"),
@@ -309,6 +336,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "pbm",
 				Component: "release",
+				URL:       "http://repo.percona.com/pbm/apt",
 			},
 			expectErr: nil,
 		},
@@ -331,6 +359,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "ps-80",
 				Component: "release",
+				URL:       "http://repo.percona.com/ps-80/apt",
 			},
 			expectErr: nil,
 		},
@@ -351,6 +380,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "ps-80",
 				Component: "testing",
+				URL:       "http://repo.percona.com/ps-80/apt",
 			},
 			expectErr: nil,
 		},
@@ -371,6 +401,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "ppg-16",
 				Component: "release",
+				URL:       "http://repo.percona.com/ppg-16/apt",
 			},
 			expectErr: nil,
 		},
@@ -389,6 +420,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "prel",
 				Component: "release",
+				URL:       "http://repo.percona.com/prel/apt",
 			},
 			expectErr: nil,
 		},
@@ -409,6 +441,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "percona",
 				Component: "release",
+				URL:       "http://repo.percona.com/percona/apt",
 			},
 			expectErr: nil,
 		},
@@ -428,6 +461,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "percona",
 				Component: "release",
+				URL:       "http://repo.percona.com/percona/apt",
 			},
 			expectErr: nil,
 		},
@@ -449,6 +483,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "ubuntu",
 				Component: "universe",
+				URL:       "http://archive.ubuntu.com/ubuntu",
 			},
 			expectErr: nil,
 		},
@@ -471,6 +506,7 @@ Version table:
 			expectedRepository: &PackageRepository{
 				Name:      "ubuntu",
 				Component: "main",
+				URL:       "http://archive.ubuntu.com/ubuntu",
 			},
 			expectErr: nil,
 		},
@@ -482,6 +518,14 @@ Version table:
 			expectedRepository: nil,
 			expectErr:          errPackageRepositoryNotFound,
 		},
+		{
+			name:               "unknown_package_localized",
+			isPerconaPackage:   isPerconaPackage("unknown"),
+			repositoryOutput:   []byte(`N: No se ha podido localizar el paquete non_existing`),
+			repositoryErr:      nil,
+			expectedRepository: nil,
+			expectErr:          errPackageRepositoryNotFound,
+		},
 		{
 			name:             "package_not_installed",
 			isPerconaPackage: isPerconaPackage("percona-*"),
@@ -574,3 +618,131 @@ Version table:
 		})
 	}
 }
+
+func TestParseDebianCandidateVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		isPerconaPackage bool
+		policyOutput     []byte
+		policyErr        error
+		expected         string
+	}{
+		{
+			name:             "percona_update_available",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput: []byte(`percona-server-server:
+  Installed: 8.0.35-27-1.jammy
+  Candidate: 8.0.36-28-1.jammy
+  Version table:
+     8.0.36-28-1.jammy 500
+        500 http://repo.percona.com/ps-80/apt jammy/main amd64 Packages
+ *** 8.0.35-27-1.jammy 500
+        500 http://repo.percona.com/ps-80/apt jammy/main amd64 Packages
+        100 /var/lib/dpkg/status
+`),
+			expected: "8.0.36-28-1",
+		},
+		{
+			name:             "external_update_available",
+			isPerconaPackage: isPerconaPackage("etcd"),
+			policyOutput: []byte(`etcd:
+  Installed: 3.3.25+dfsg-7ubuntu0.22.04.1
+  Candidate: 3.3.25+dfsg-7ubuntu0.22.04.2
+`),
+			expected: "3.3.25",
+		},
+		{
+			name:             "up_to_date",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput: []byte(`percona-server-server:
+  Installed: 8.0.36-28-1.jammy
+  Candidate: 8.0.36-28-1.jammy
+`),
+			expected: "",
+		},
+		{
+			name:             "candidate_older_than_installed",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput: []byte(`percona-server-server:
+  Installed: 8.0.36-28-1.jammy
+  Candidate: 8.0.35-27-1.jammy
+`),
+			expected: "",
+		},
+		{
+			name:             "no_candidate",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput: []byte(`percona-server-server:
+  Installed: 8.0.36-28-1.jammy
+  Candidate: (none)
+`),
+			expected: "",
+		},
+		{
+			name:             "update_available_localized",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput: []byte(`percona-server-server:
+  Instalados: 8.0.35-27-1.jammy
+  Candidato: 8.0.36-28-1.jammy
+  Tabla de versión:
+     8.0.36-28-1.jammy 500
+        500 http://repo.percona.com/ps-80/apt jammy/main amd64 Packages
+`),
+			expected: "8.0.36-28-1",
+		},
+		{
+			name:             "no_candidate_localized",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput: []byte(`percona-server-server:
+  Instalados: 8.0.36-28-1.jammy
+  Candidato: (ninguno)
+`),
+			expected: "",
+		},
+		{
+			name:             "cmd_error",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			policyOutput:     []byte(``),
+			policyErr:        errors.New("exit status 100"),
+			expected:         "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, parseDebianCandidateVersion(tt.policyOutput, tt.policyErr, tt.isPerconaPackage))
+		})
+	}
+}
+
+// testExitError imitates error returned by command exited with non-zero code.
+type testExitError int
+
+func (e testExitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e testExitError) ExitCode() int {
+	return int(e)
+}
+
+func BenchmarkParseDebianPackageOutput(b *testing.B) {
+	var output strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&output, "'ii |percona-server-server-%d:amd64|8.0.35-27-1.jammy|amd64'\n", i)
+	}
+
+	dpkgOutput := []byte(output.String())
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := parseDebianPackageOutput(dpkgOutput, nil, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	pipRepositoryName = "pip"
+)
+
+// getPipPackages returns list of Python package names (normalized, see normalizePipPackageName)
+// that are reported when installed via pip.
+func getPipPackages() []string {
+	return []string{
+		"patroni",
+	}
+}
+
+// getPipSearchPaths returns list of glob patterns for Python site-packages directories,
+// including the ones of virtual environments in common locations.
+func getPipSearchPaths() []string {
+	return []string{
+		"/usr/lib/python3*/site-packages",
+		"/usr/lib/python3*/dist-packages",
+		"/usr/lib64/python3*/site-packages",
+		"/usr/local/lib/python3*/site-packages",
+		"/usr/local/lib/python3*/dist-packages",
+		"/usr/local/lib64/python3*/site-packages",
+		// virtual environments
+		"/opt/*/lib/python3*/site-packages",
+		"/opt/*/*/lib/python3*/site-packages",
+		"/usr/local/*/lib/python3*/site-packages",
+		"/var/lib/*/venv/lib/python3*/site-packages",
+		// pip --user installations
+		"/home/*/.local/lib/python3*/site-packages",
+		"/var/lib/*/.local/lib/python3*/site-packages",
+		"/root/.local/lib/python3*/site-packages",
+	}
+}
+
+// queryPipPackages scans Python site-packages directories for packages installed via pip.
+// It reads the same package metadata (*.dist-info) as Python importlib.metadata does.
+func queryPipPackages() []*Package {
+	toReturn := make([]*Package, 0, 1)
+	// the same site-packages directory may be matched by several patterns (symlinks, lib -> lib64).
+	seen := make(map[string]struct{})
+
+	for _, pattern := range getPipSearchPaths() {
+		dirs, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+
+		for _, dir := range dirs {
+			realDir, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				continue
+			}
+
+			if _, found := seen[realDir]; found {
+				continue
+			}
+
+			seen[realDir] = struct{}{}
+
+			toReturn = append(toReturn, queryPipSitePackages(realDir)...)
+		}
+	}
+
+	return toReturn
+}
+
+func queryPipSitePackages(sitePackagesDir string) []*Package {
+	l := zap.L().Sugar().With(zap.String("directory", sitePackagesDir))
+
+	distInfoDirs, err := filepath.Glob(filepath.Join(sitePackagesDir, "*.dist-info"))
+	if err != nil {
+		return nil
+	}
+
+	toReturn := make([]*Package, 0, 1)
+
+	for _, distInfoDir := range distInfoDirs {
+		// directory name has format: <name>-<version>.dist-info
+		pkgName, _, _ := strings.Cut(filepath.Base(distInfoDir), "-")
+		if !slices.Contains(getPipPackages(), normalizePipPackageName(pkgName)) {
+			continue
+		}
+
+		if !isInstalledByPip(distInfoDir) {
+			// installed by OS package manager, already reported by it.
+			continue
+		}
+
+		pkg, err := readPipPackageMetadata(filepath.Join(distInfoDir, "METADATA"))
+		if err != nil {
+			l.Debugw("failed to read pip package metadata", zap.String("package", pkgName), zap.Error(err))
+			continue
+		}
+
+		toReturn = append(toReturn, pkg)
+	}
+
+	return toReturn
+}
+
+// isInstalledByPip checks INSTALLER file in package metadata directory.
+// OS packages either do not have this file or contain other value (e.g. 'debian', 'rpm').
+func isInstalledByPip(distInfoDir string) bool {
+	installer, err := os.ReadFile(filepath.Clean(filepath.Join(distInfoDir, "INSTALLER")))
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(string(installer)) == pipRepositoryName
+}
+
+func readPipPackageMetadata(metadataFile string) (*Package, error) {
+	cleanMetadataFile := filepath.Clean(metadataFile)
+	l := zap.L().Sugar().With(zap.String("file", cleanMetadataFile))
+
+	file, err := os.Open(cleanMetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func(l *zap.SugaredLogger) {
+		fErr := file.Close()
+		if fErr != nil {
+			l.Errorw("failed to close file", zap.Error(fErr))
+		}
+	}(l)
+
+	return parsePipPackageMetadata(file)
+}
+
+func parsePipPackageMetadata(r io.Reader) (*Package, error) {
+	// METADATA file has email header like format, the body (package description) is separated by an empty line.
+	// Example:
+	// Metadata-Version: 2.1
+	// Name: patroni
+	// Version: 3.2.2
+	// Summary: PostgreSQL High-Available orchestrator and CLI
+	var pkgName, pkgVersion string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) == 0 {
+			// end of headers
+			break
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		switch field {
+		case "Name":
+			pkgName = normalizePipPackageName(strings.TrimSpace(value))
+		case "Version":
+			pkgVersion = strings.TrimSpace(value)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgName) == 0 || len(pkgVersion) == 0 {
+		return nil, errPackageNotFound
+	}
+
+	return &Package{
+		Name:    pkgName,
+		Version: pkgVersion,
+		Repository: PackageRepository{
+			Name: pipRepositoryName,
+		},
+	}, nil
+}
+
+// normalizePipPackageName normalizes Python package name according to PEP 503:
+// lowercase with runs of '-', '_' and '.' replaced by single '-'.
+func normalizePipPackageName(name string) string {
+	name = strings.ToLower(name)
+
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	}), "-")
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePipPackageMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		metadata        string
+		expectedPackage *Package
+		expectErr       error
+	}{
+		{
+			name: "patroni",
+			metadata: `Metadata-Version: 2.1
+Name: patroni
+Version: 3.2.2
+Summary: PostgreSQL High-Available orchestrator and CLI
+
+Version: 1.0.0 is mentioned in description
+`,
+			expectedPackage: &Package{
+				Name:       "patroni",
+				Version:    "3.2.2",
+				Repository: PackageRepository{Name: pipRepositoryName},
+			},
+		},
+		{
+			name: "not_normalized_name",
+			metadata: `Metadata-Version: 2.1
+Name: Pg_Activity
+Version: 3.4.2
+`,
+			expectedPackage: &Package{
+				Name:       "pg-activity",
+				Version:    "3.4.2",
+				Repository: PackageRepository{Name: pipRepositoryName},
+			},
+		},
+		{
+			name: "no_version",
+			metadata: `Metadata-Version: 2.1
+Name: patroni
+`,
+			expectErr: errPackageNotFound,
+		},
+		{
+			name:      "empty_metadata",
+			metadata:  ``,
+			expectErr: errPackageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pkg, err := parsePipPackageMetadata(strings.NewReader(tt.metadata))
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				require.Nil(t, pkg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedPackage, pkg)
+		})
+	}
+}
+
+func TestQueryPipSitePackages(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	writeDistInfo := func(dirName, installer, metadata string) {
+		distInfoDir := filepath.Join(tmpDir, dirName)
+		require.NoError(t, os.MkdirAll(distInfoDir, 0o755))
+
+		if len(installer) != 0 {
+			require.NoError(t, os.WriteFile(filepath.Join(distInfoDir, "INSTALLER"), []byte(installer), metricsFilePermissions))
+		}
+
+		require.NoError(t, os.WriteFile(filepath.Join(distInfoDir, "METADATA"), []byte(metadata), metricsFilePermissions))
+	}
+
+	// installed via pip
+	writeDistInfo("patroni-3.2.2.dist-info", "pip\n", "Name: patroni\nVersion: 3.2.2\n")
+	// not interesting package
+	writeDistInfo("requests-2.31.0.dist-info", "pip\n", "Name: requests\nVersion: 2.31.0\n")
+
+	pkgL := queryPipSitePackages(tmpDir)
+	require.Equal(t, []*Package{
+		{
+			Name:       "patroni",
+			Version:    "3.2.2",
+			Repository: PackageRepository{Name: pipRepositoryName},
+		},
+	}, pkgL)
+
+	// installed via OS package manager
+	require.NoError(t, os.RemoveAll(filepath.Join(tmpDir, "patroni-3.2.2.dist-info")))
+	writeDistInfo("patroni-3.0.2.dist-info", "debian\n", "Name: patroni\nVersion: 3.0.2\n")
+
+	require.Empty(t, queryPipSitePackages(tmpDir))
+}
+
+func TestNormalizePipPackageName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "patroni", normalizePipPackageName("Patroni"))
+	require.Equal(t, "pg-activity", normalizePipPackageName("pg_activity"))
+	require.Equal(t, "zope-interface", normalizePipPackageName("zope.interface"))
+	require.Equal(t, "a-b", normalizePipPackageName("A--_.b"))
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+func queryFreeBSDPackage(ctx context.Context, opts PackageScrapeOpts, _, packageNamePattern string) ([]*Package, error) {
+	if _, err := lookPath(ctx, "pkg"); err != nil {
+		return nil, errPackageManagerNotFound
+	}
+
+	return queryFreeBSDPackageCmd(ctx, opts.cmdTimeout(), packageNamePattern)
+}
+
+func queryFreeBSDPackageCmd(ctx context.Context, cmdTimeout time.Duration, packageNamePattern string) ([]*Package, error) {
+	// '-N' - do not bootstrap pkg(8) and do not create package database if they are absent.
+	// '-g' - treat package name as shell glob pattern.
+	args := []string{"pkg", "-N", "query", "-g", "%n|%v|%q|%R|%t", packageNamePattern}
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	outputB, err := runCommand(cmdCtx, args)
+
+	return parseFreeBSDPackageOutput(outputB, err)
+}
+
+func parseFreeBSDPackageOutput(pkgOutput []byte, pkgErr error) ([]*Package, error) {
+	if pkgErr != nil {
+		// pkg bootstrap stub is installed on FreeBSD base system, the real pkg(8) may be not installed yet.
+		if strings.Contains(string(pkgOutput), "not installed") || strings.Contains(string(pkgOutput), "not yet installed") {
+			return nil, errPackageManagerNotFound
+		}
+
+		// 'pkg query' exits with code 1 and empty output when no packages found matching pattern.
+		if cmdExitCode(pkgErr) == 1 && len(bytes.TrimSpace(pkgOutput)) == 0 {
+			// package is not installed
+			return nil, errPackageNotFound
+		}
+
+		zap.L().Sugar().Debugw("cmd output", zap.ByteString("output", pkgOutput))
+
+		return nil, pkgErr
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(pkgOutput))
+	toReturn := make([]*Package, 0, 1)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		tokens := strings.Split(line, "|")
+		// The successful line for package shall be in format:
+		// <package name>|<version>|<ABI>|<repository>|<install time>.
+		// Example:
+		// 'percona-server-mongodb70|7.0.5_1|FreeBSD:14:amd64|FreeBSD|1707999999'
+		if len(tokens) != 5 {
+			continue
+		}
+
+		pkgName := strings.TrimSpace(tokens[0])
+		pkgVersion := parseFreeBSDPackageVersion(tokens[1])
+
+		if len(pkgName) == 0 || len(pkgVersion) == 0 {
+			continue
+		}
+
+		pkg := &Package{
+			Name:       pkgName,
+			Version:    pkgVersion,
+			Arch:       parseFreeBSDPackageArch(tokens[2]),
+			Repository: PackageRepository{Name: strings.TrimSpace(tokens[3])},
+		}
+
+		if installTime, err := strconv.ParseInt(strings.TrimSpace(tokens[4]), 10, 64); err == nil {
+			pkg.InstallTime = installTime
+		}
+
+		toReturn = append(toReturn, pkg)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		zap.L().Sugar().Warnw("failed to read output from pkg query", zap.Error(err))
+		return nil, err
+	}
+
+	if len(toReturn) == 0 {
+		// no installed packaged found matching pkgNamePattern
+		return nil, errPackageNotFound
+	}
+
+	return toReturn, nil
+}
+
+func parseFreeBSDPackageVersion(pkgVersion string) string {
+	// FreeBSD package version has format:
+	// upstream_version[_portrevision][,portepoch]
+	// Example:
+	// '7.0.5', '7.0.5_1', '8.0.35_1,1'
+	// port revision and epoch are FreeBSD ports specific, so only upstream version is reported.
+	pkgVersion = strings.TrimSpace(pkgVersion)
+	pkgVersion, _, _ = strings.Cut(pkgVersion, ",")
+
+	if pos := strings.LastIndex(pkgVersion, "_"); pos != -1 {
+		pkgVersion = pkgVersion[0:pos]
+	}
+
+	return pkgVersion
+}
+
+func parseFreeBSDPackageArch(pkgABI string) string {
+	// package ABI has format <OS>:<OS major version>:<architecture>, e.g. 'FreeBSD:14:amd64'.
+	// Architecture independent packages have '*' architecture.
+	pkgABI = strings.TrimSpace(pkgABI)
+	if pos := strings.LastIndex(pkgABI, ":"); pos != -1 {
+		pkgABI = pkgABI[pos+1:]
+	}
+
+	if pkgABI == "*" {
+		return ""
+	}
+
+	return pkgABI
+}
+
+// getFreeBSDPerconaPackages returns list of Percona package patterns that are unique for FreeBSD systems.
+// FreeBSD ports have major version in package name, e.g. 'percona57-server'.
+func getFreeBSDPerconaPackages() []string {
+	return []string{
+		"percona[0-9]*",
+		"xtrabackup*",
+	}
+}
+
+// getFreeBSDExternalPackages returns list of external package patterns that are unique for FreeBSD systems.
+func getFreeBSDExternalPackages() []string {
+	return []string{
+		"mongodb[0-9]*",
+		"postgresql[0-9]*-server",
+	}
+}
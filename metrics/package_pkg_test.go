@@ -0,0 +1,104 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFreeBSDPackageOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		packageOutput       []byte
+		packageErr          error
+		expectedPackageList []*Package
+		expectErr           error
+	}{
+		{
+			name: "packages_found",
+			packageOutput: []byte(`percona-server-mongodb70|7.0.5_1|FreeBSD:14:amd64|FreeBSD|1707999999
+percona57-server|5.7.44_2,1|FreeBSD:14:amd64|FreeBSD|1708000000
+percona-toolkit|3.5.7|FreeBSD:14:*|unknown-repository|1708000001
+broken line
+`),
+			expectedPackageList: []*Package{
+				{
+					Name:        "percona-server-mongodb70",
+					Version:     "7.0.5",
+					Arch:        "amd64",
+					Repository:  PackageRepository{Name: "FreeBSD"},
+					InstallTime: 1707999999,
+				},
+				{
+					Name:        "percona57-server",
+					Version:     "5.7.44",
+					Arch:        "amd64",
+					Repository:  PackageRepository{Name: "FreeBSD"},
+					InstallTime: 1708000000,
+				},
+				{
+					Name:        "percona-toolkit",
+					Version:     "3.5.7",
+					Repository:  PackageRepository{Name: "unknown-repository"},
+					InstallTime: 1708000001,
+				},
+			},
+		},
+		{
+			name:          "package_not_found",
+			packageOutput: []byte(""),
+			packageErr:    testExitError(1),
+			expectErr:     errPackageNotFound,
+		},
+		{
+			name:          "pkg_not_bootstrapped",
+			packageOutput: []byte("pkg: pkg is not installed\n"),
+			packageErr:    testExitError(1),
+			expectErr:     errPackageManagerNotFound,
+		},
+		{
+			name:          "no_valid_lines",
+			packageOutput: []byte("something unexpected\n"),
+			expectErr:     errPackageNotFound,
+		},
+		{
+			name:          "pkg_failure",
+			packageOutput: []byte("pkg: sqlite error while executing query\n"),
+			packageErr:    testExitError(70),
+			expectErr:     testExitError(70),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pkgList, err := parseFreeBSDPackageOutput(tt.packageOutput, tt.packageErr)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				require.Nil(t, pkgList)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedPackageList, pkgList)
+		})
+	}
+}
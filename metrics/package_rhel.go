@@ -1,36 +1,93 @@
 package metrics
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"fmt"
-	"os/exec"
+	"errors"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	rpmdb "github.com/knqyf263/go-rpmdb/pkg"
 	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
 )
 
-func queryRhelPackage(ctx context.Context, localOS, packageNamePattern string) ([]*Package, error) {
-	pkgMngCmd, err := getRhelPackageManagerCmd(localOS)
+// newRhelPackageQuery returns queryPkgFunc of RHEL based systems. rpm database, repositories installed packages
+// are from and repository URLs are read once on the first query and shared by all package patterns of the scrape.
+func newRhelPackageQuery(ctx context.Context, opts PackageScrapeOpts, localOS string) queryPkgFunc {
+	readDatabase := sync.OnceValues(readRhelPackageDatabase)
+	readRepositories := sync.OnceValues(func() (map[string]string, map[string]string) {
+		installedRepos, err := queryRhelInstalledRepositories(ctx, opts.repoqueryTimeout(), localOS)
+		if err != nil {
+			logger.FromContext(ctx).Sugar().Debugw("failed to get repositories of installed packages", zap.Error(err))
+			// not critical error, repository info is just left empty
+		}
+
+		return installedRepos, queryYumRepositoryURLs(yumReposDir)
+	})
+
+	return func(ctx context.Context, opts PackageScrapeOpts, localOS, packageNamePattern string) ([]*Package, error) {
+		// read rpm database directly first, it doesn't depend on repoquery/yum/dnf presence and versions.
+		// It is skipped while external commands are recorded or replayed, so package manager output is captured.
+		pkgInfoL, err := []*rpmdb.PackageInfo(nil), errPackageDatabaseSkipped
+		if commandRecorderFromContext(ctx) == nil {
+			pkgInfoL, err = readDatabase()
+		}
+
+		var pkgL []*Package
+		if err == nil {
+			installedRepos, repoURLs := readRepositories()
+			pkgL, err = filterRhelPackages(pkgInfoL, packageNamePattern, isPerconaPackage(packageNamePattern), installedRepos, repoURLs)
+		}
+
+		if err != nil && !errors.Is(err, errPackageNotFound) {
+			logger.FromContext(ctx).Sugar().Debugw("failed to read rpm database, fallback to package manager",
+				zap.Error(err), zap.String("package", packageNamePattern))
+
+			pkgL, err = queryRhelPackageCmd(ctx, opts.repoqueryTimeout(), localOS, packageNamePattern)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// need extra processing - get available package updates info.
+		updates, err := queryRhelUpdates(ctx, opts.repoqueryTimeout(), localOS, packageNamePattern)
+		if err != nil {
+			logger.FromContext(ctx).Sugar().Debugw("failed to get available package updates info", zap.Error(err), zap.String("package", packageNamePattern))
+			return pkgL, nil
+		}
+
+		for _, pkg := range pkgL {
+			pkg.UpdateAvailable = updates[pkg.Name]
+		}
+
+		return pkgL, nil
+	}
+}
+
+func queryRhelPackageCmd(ctx context.Context, cmdTimeout time.Duration, localOS, packageNamePattern string) ([]*Package, error) {
+	pkgMngCmd, err := getRhelPackageManagerCmd(ctx, localOS)
 	if err != nil {
 		return nil, err
 	}
 
 	pkgMngCmd = append(pkgMngCmd, packageNamePattern)
-	zap.L().Sugar().Debugw("executing command", zap.String("cmd", strings.Join(pkgMngCmd, " ")))
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(pkgMngCmd, " ")))
 
-	cmdCtx, cancel := context.WithTimeout(ctx, pkgResultTimeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, pkgMngCmd[0], pkgMngCmd[1:]...) // #nosec G204
-	outputB, err := cmd.CombinedOutput()
+	outputB, err := runCommand(cmdCtx, pkgMngCmd)
 
-	return parseRhelPackageOutput(outputB, err, isPerconaPackage(packageNamePattern))
+	// package manager reports repository ID only, base URL is taken from repository files.
+	return parseRhelPackageOutput(outputB, err, isPerconaPackage(packageNamePattern), queryYumRepositoryURLs(yumReposDir))
 }
 
-func getRhelPackageManagerCmd(localOS string) ([]string, error) {
-	const newQueryFormat = "'%{name}|%{version}|%{release}|%{from_repo}'"
+func getRhelPackageManagerCmd(ctx context.Context, localOS string) ([]string, error) {
+	const newQueryFormat = "'%{name}|%{version}|%{release}|%{from_repo}|%{installtime}|%{arch}'"
 
 	//nolint:goconst
 	newPkgMngCmds := [][]string{
@@ -39,9 +96,31 @@ func getRhelPackageManagerCmd(localOS string) ([]string, error) {
 		{"dnf", "repoquery", "--qf", newQueryFormat, "--installed"},
 	}
 	oldPkgMngCmds := [][]string{
-		{"repoquery", "--qf", "'%{name}|%{version}|%{release}|%{ui_from_repo}'", "--installed"},
+		{"repoquery", "--qf", "'%{name}|%{version}|%{release}|%{ui_from_repo}|%{installtime}|%{arch}'", "--installed"},
 	}
 
+	return lookupRhelPackageManagerCmd(ctx, localOS, newPkgMngCmds, oldPkgMngCmds)
+}
+
+func getRhelUpdatesCmd(ctx context.Context, localOS string) ([]string, error) {
+	const queryFormat = "'%{name}|%{version}|%{release}'"
+
+	// '-C' - use metadata cache only, do not refresh it from network.
+	//nolint:goconst
+	newPkgMngCmds := [][]string{
+		{"repoquery", "-C", "--qf", queryFormat, "--upgrades"},
+		{"yum", "repoquery", "-C", "--qf", queryFormat, "--upgrades"},
+		{"dnf", "repoquery", "-C", "--qf", queryFormat, "--upgrades"},
+	}
+	oldPkgMngCmds := [][]string{
+		{"repoquery", "-C", "--qf", queryFormat, "--pkgnarrow=updates"},
+	}
+
+	return lookupRhelPackageManagerCmd(ctx, localOS, newPkgMngCmds, oldPkgMngCmds)
+}
+
+// lookupRhelPackageManagerCmd chooses the command suitable for localOS and returns the first one available on host.
+func lookupRhelPackageManagerCmd(ctx context.Context, localOS string, newPkgMngCmds, oldPkgMngCmds [][]string) ([]string, error) {
 	var pkgMngCmds [][]string
 
 	switch localOSLower := strings.ToLower(localOS); {
@@ -57,7 +136,7 @@ func getRhelPackageManagerCmd(localOS string) ([]string, error) {
 	}
 
 	for _, pkgMngCmd := range pkgMngCmds {
-		_, err := exec.LookPath(pkgMngCmd[0])
+		_, err := lookPath(ctx, pkgMngCmd[0])
 		if err == nil {
 			return pkgMngCmd, nil
 		}
@@ -66,7 +145,99 @@ func getRhelPackageManagerCmd(localOS string) ([]string, error) {
 	return nil, errPackageManagerNotFound
 }
 
-func parseRhelPackageOutput(packageOutput []byte, rpmErr error, isPerconaPackage bool) ([]*Package, error) {
+// queryRhelInstalledRepositories returns map of installed package name to repository ID it is installed from.
+// All installed packages are queried at once.
+func queryRhelInstalledRepositories(ctx context.Context, cmdTimeout time.Duration, localOS string) (map[string]string, error) {
+	pkgMngCmd, err := getRhelPackageManagerCmd(ctx, localOS)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(pkgMngCmd, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	outputB, err := runCommand(cmdCtx, pkgMngCmd)
+
+	return parseRhelInstalledRepositoriesOutput(outputB, err)
+}
+
+// parseRhelInstalledRepositoriesOutput parses package manager output of all installed packages
+// into map of package name to repository ID.
+func parseRhelInstalledRepositoriesOutput(packageOutput []byte, pkgErr error) (map[string]string, error) {
+	if pkgErr != nil {
+		zap.L().Sugar().Debugw("cmd output", zap.ByteString("output", packageOutput))
+		return nil, pkgErr
+	}
+
+	toReturn := make(map[string]string)
+
+	var tokensBuf [6]string
+
+	for line := range strings.Lines(string(packageOutput)) {
+		line = strings.Trim(line, " '\t\r\n")
+		// The line shall be in format:
+		// <package name>|<version>|<release>|<package repository>[|<install time>[|<architecture>]].
+		tokens, ok := splitFields(tokensBuf[:], line, "|")
+		if !ok || len(tokens) < 4 {
+			continue
+		}
+
+		toReturn[tokens[0]] = tokens[3]
+	}
+
+	return toReturn, nil
+}
+
+func queryRhelUpdates(ctx context.Context, cmdTimeout time.Duration, localOS, packageNamePattern string) (map[string]string, error) {
+	pkgMngCmd, err := getRhelUpdatesCmd(ctx, localOS)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgMngCmd = append(pkgMngCmd, packageNamePattern)
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(pkgMngCmd, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	outputB, err := runCommand(cmdCtx, pkgMngCmd)
+
+	return parseRhelUpdatesOutput(outputB, err, isPerconaPackage(packageNamePattern))
+}
+
+// parseRhelUpdatesOutput returns map of package name to the newest available package version.
+func parseRhelUpdatesOutput(updatesOutput []byte, updatesErr error, isPerconaPackage bool) (map[string]string, error) {
+	if updatesErr != nil {
+		zap.L().Sugar().Debugw("cmd output", zap.ByteString("output", updatesOutput))
+		return nil, updatesErr
+	}
+
+	toReturn := make(map[string]string)
+
+	var tokensBuf [3]string
+
+	for line := range strings.Lines(string(updatesOutput)) {
+		line = strings.Trim(line, " '\t\r\n")
+		// The line for package update shall be in format:
+		// <package name>|<version>|<release>.
+		// Example:
+		// 'percona-xtrabackup-81|8.1.0|2.1.el8'
+		// Note: several versions of the same package may be listed, the last one is the newest.
+		tokens, ok := splitFields(tokensBuf[:], line, "|")
+		if !ok || len(tokens) != 3 {
+			continue
+		}
+
+		toReturn[tokens[0]] = parseRhelPackageVersion(tokens[1], tokens[2], isPerconaPackage)
+	}
+
+	return toReturn, nil
+}
+
+// parseRhelPackageOutput parses package manager output, repoURLs is a map of repository ID to its base URL.
+func parseRhelPackageOutput(packageOutput []byte, rpmErr error, isPerconaPackage bool, repoURLs map[string]string) ([]*Package, error) {
 	if rpmErr != nil {
 		// in case of package not found, rpm doesn't return error.
 		// So if error is returned - something went wrong.
@@ -74,40 +245,48 @@ func parseRhelPackageOutput(packageOutput []byte, rpmErr error, isPerconaPackage
 		return nil, rpmErr
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(packageOutput))
-
 	toReturn := make([]*Package, 0, 1)
 
-	for scanner.Scan() {
-		line := strings.Trim(scanner.Text(), " '\t")
+	var tokensBuf [6]string
+
+	// output is converted into string once, so lines and their fields are not allocated one by one.
+	for line := range strings.Lines(string(packageOutput)) {
+		line = strings.Trim(line, " '\t\r\n")
 		if len(line) == 0 {
 			continue
 		}
 
-		tokens := strings.Split(line, "|")
+		tokens, ok := splitFields(tokensBuf[:], line, "|")
 		// The successful line for package shall be in format:
-		// <package name>|<version>|<release>|<package repository>.
+		// <package name>|<version>|<release>|<package repository>[|<install time>[|<architecture>]].
 		// Example:
-		// 'percona-xtrabackup-81|8.1.0|1.1.el8|tools-release-x86_64'
+		// 'percona-xtrabackup-81|8.1.0|1.1.el8|tools-release-x86_64|1708026156|x86_64'
 		// Note:
 		// if package presents in 'packageOutput' it means it is installed,
 		// no need to check package status.
-		if len(tokens) != 4 {
+		if !ok || len(tokens) < 4 {
 			continue
 		}
 
 		pkgName, pkgVersion, pkgRelease, pkgRepository := tokens[0], tokens[1], tokens[2], tokens[3]
-		toReturn = append(toReturn, &Package{
+		pkg := &Package{
 			Name:       pkgName,
 			Version:    parseRhelPackageVersion(pkgVersion, pkgRelease, isPerconaPackage),
-			Repository: parseRhelPackageRegistry(pkgRepository, isPerconaPackage),
-		})
-	}
+			Repository: parseRhelPackageRepositoryWithURL(pkgRepository, isPerconaPackage, repoURLs),
+		}
 
-	err := scanner.Err()
-	if err != nil {
-		zap.L().Sugar().Warnw("failed to read output from rhel package manager", zap.Error(err))
-		return nil, err
+		if len(tokens) >= 5 {
+			// install time is optional, ignore it if it can't be parsed.
+			if installTime, err := strconv.ParseInt(tokens[4], 10, 64); err == nil {
+				pkg.InstallTime = installTime
+			}
+		}
+
+		if len(tokens) == 6 {
+			pkg.Arch = tokens[5]
+		}
+
+		toReturn = append(toReturn, pkg)
 	}
 
 	if len(toReturn) == 0 {
@@ -140,12 +319,24 @@ func parseRhelPackageVersion(packageVersion, packageRelease string, isPerconaPac
 	if isPerconaPackage && len(packageRelease) != 0 {
 		packageRelease = strings.ReplaceAll(packageRelease, ".", "-")
 		// need to join them with '-' separator.
-		return fmt.Sprintf("%s-%s", packageVersion, packageRelease)
+		return packageVersion + "-" + packageRelease
 	}
 
 	return packageVersion
 }
 
+// parseRhelPackageRepositoryWithURL parses package repository ID and sets its base URL from repoURLs,
+// a map of repository ID to its base URL.
+func parseRhelPackageRepositoryWithURL(packageRepository string, isPerconaPackage bool, repoURLs map[string]string) PackageRepository {
+	toReturn := parseRhelPackageRegistry(packageRepository, isPerconaPackage)
+	if len(toReturn.Name) != 0 {
+		// On some OSes (Centos 7, Amazon Linux 2) repository ID may start from '@'.
+		toReturn.URL = repoURLs[strings.TrimPrefix(packageRepository, "@")]
+	}
+
+	return toReturn
+}
+
 func parseRhelPackageRegistry(packageRepository string, isPerconaPackage bool) PackageRepository {
 	// packageRepository contains info about package repository name where package comes from.
 	// Example:
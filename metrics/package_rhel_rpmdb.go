@@ -0,0 +1,115 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	rpmdb "github.com/knqyf263/go-rpmdb/pkg"
+	"go.uber.org/zap"
+)
+
+var errRpmDatabaseNotFound = errors.New("rpm database is not found")
+
+// getRpmDatabasePaths returns list of known rpm database locations.
+// The order matters: newer formats are checked first.
+func getRpmDatabasePaths() []string {
+	return []string{
+		// SQLite format (RHEL 9+, Fedora 33+).
+		"/var/lib/rpm/rpmdb.sqlite",
+		// SQLite format in new location (Fedora 36+, DNF5 based systems).
+		"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+		// NDB format (SUSE).
+		"/var/lib/rpm/Packages.db",
+		"/usr/lib/sysimage/rpm/Packages.db",
+		// Berkeley DB format (RHEL 7, RHEL 8, Amazon Linux 2).
+		"/var/lib/rpm/Packages",
+	}
+}
+
+// readRhelPackageDatabase reads all installed packages directly from the first found rpm database.
+func readRhelPackageDatabase() ([]*rpmdb.PackageInfo, error) {
+	for _, dbPath := range getRpmDatabasePaths() {
+		cleanDBPath := filepath.Clean(dbPath)
+		if _, err := os.Stat(cleanDBPath); err != nil {
+			continue
+		}
+
+		l := zap.L().Sugar().With(zap.String("file", cleanDBPath))
+		l.Debugw("reading rpm database")
+
+		pkgInfoL, err := readRpmDatabase(cleanDBPath)
+		if err != nil {
+			l.Debugw("failed to read rpm database", zap.Error(err))
+			return nil, err
+		}
+
+		return pkgInfoL, nil
+	}
+
+	return nil, errRpmDatabaseNotFound
+}
+
+func readRpmDatabase(dbPath string) ([]*rpmdb.PackageInfo, error) {
+	db, err := rpmdb.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		dbErr := db.Close()
+		if dbErr != nil {
+			zap.L().Sugar().Errorw("failed to close rpm database", zap.String("file", dbPath), zap.Error(dbErr))
+		}
+	}()
+
+	return db.ListPackages()
+}
+
+// filterRhelPackages returns packages of rpm database matching packageNamePattern. rpm database doesn't keep info
+// about repository package was installed from, so it is taken from installedRepos map of package name to repository ID,
+// repoURLs is a map of repository ID to its base URL.
+func filterRhelPackages(pkgInfoL []*rpmdb.PackageInfo, packageNamePattern string, isPerconaPackage bool,
+	installedRepos, repoURLs map[string]string,
+) ([]*Package, error) {
+	toReturn := make([]*Package, 0, 1)
+
+	for _, pkgInfo := range pkgInfoL {
+		if pkgInfo == nil {
+			continue
+		}
+
+		if matched, err := filepath.Match(packageNamePattern, pkgInfo.Name); err != nil || !matched {
+			continue
+		}
+
+		toReturn = append(toReturn, &Package{
+			Name:        pkgInfo.Name,
+			Version:     parseRhelPackageVersion(pkgInfo.Version, pkgInfo.Release, isPerconaPackage),
+			Repository:  parseRhelPackageRepositoryWithURL(installedRepos[pkgInfo.Name], isPerconaPackage, repoURLs),
+			Arch:        pkgInfo.Arch,
+			InstallTime: int64(pkgInfo.InstallTime),
+		})
+	}
+
+	if len(toReturn) == 0 {
+		// no installed packaged found matching pkgNamePattern
+		return nil, errPackageNotFound
+	}
+
+	return toReturn, nil
+}
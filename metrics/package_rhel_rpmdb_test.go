@@ -0,0 +1,125 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+
+	rpmdb "github.com/knqyf263/go-rpmdb/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterRhelPackages(t *testing.T) {
+	t.Parallel()
+
+	pkgInfoL := []*rpmdb.PackageInfo{
+		{Name: "percona-server-server", Version: "8.0.36", Release: "28.1.el9", Arch: "x86_64", InstallTime: 1708026156},
+		{Name: "percona-xtrabackup-81", Version: "8.1.0", Release: "1.1.el9", Arch: "x86_64"},
+		{Name: "proxysql2", Version: "2.5.5", Release: "1.1.el9", Arch: "x86_64"},
+		{Name: "etcd", Version: "3.5.12", Release: "1.el9", Arch: "x86_64"},
+		{Name: "bash", Version: "5.1.8", Release: "6.el9", Arch: "x86_64"},
+		nil,
+	}
+
+	installedRepos := map[string]string{
+		"percona-server-server": "ps-80-release-x86_64",
+		"percona-xtrabackup-81": "@System",
+		"etcd":                  "epel",
+	}
+	repoURLs := map[string]string{
+		"ps-80-release-x86_64": "http://repo.percona.com/ps-80/yum/release/$releasever/RPMS/x86_64",
+		"epel":                 "https://mirror.example.com/epel/$releasever/Everything/$basearch/",
+	}
+
+	tests := []struct {
+		name                string
+		packageNamePattern  string
+		expectedPackageList []*Package
+		expectErr           error
+	}{
+		{
+			name:               "pattern_percona",
+			packageNamePattern: "percona-*",
+			expectedPackageList: []*Package{
+				{
+					Name:    "percona-server-server",
+					Version: "8.0.36-28-1",
+					Repository: PackageRepository{
+						Name:      "ps-80",
+						Component: "release",
+						URL:       "http://repo.percona.com/ps-80/yum/release/$releasever/RPMS/x86_64",
+					},
+					Arch:        "x86_64",
+					InstallTime: 1708026156,
+				},
+				{
+					// package installed from local rpm file has no repository.
+					Name:    "percona-xtrabackup-81",
+					Version: "8.1.0-1-1",
+					Arch:    "x86_64",
+				},
+			},
+		},
+		{
+			name:               "pattern_proxysql",
+			packageNamePattern: "proxysql*",
+			expectedPackageList: []*Package{
+				{
+					Name:    "proxysql2",
+					Version: "2.5.5-1-1",
+					Arch:    "x86_64",
+				},
+			},
+		},
+		{
+			name:               "pattern_external",
+			packageNamePattern: "etcd*",
+			expectedPackageList: []*Package{
+				{
+					Name:    "etcd",
+					Version: "3.5.12",
+					Repository: PackageRepository{
+						Name: "epel",
+						URL:  "https://mirror.example.com/epel/$releasever/Everything/$basearch/",
+					},
+					Arch: "x86_64",
+				},
+			},
+		},
+		{
+			name:               "pattern_not_installed",
+			packageNamePattern: "pmm*",
+			expectErr:          errPackageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pkgL, err := filterRhelPackages(pkgInfoL, tt.packageNamePattern, isPerconaPackage(tt.packageNamePattern), installedRepos, repoURLs)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				require.Nil(t, pkgL)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedPackageList, pkgL)
+		})
+	}
+}
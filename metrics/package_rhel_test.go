@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -295,6 +297,35 @@ proxysql2|2.5.5|1.2.el9|proxysql-release-x86_64`),
 			expectedPackageList: nil,
 			expectErr:           rpmError,
 		},
+		{
+			name:             "pattern_percona_output_with_install_time",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			packageOutput: []byte(`percona-server-server|8.0.36|28.1.el9|ps-80-release-x86_64|1708026156|x86_64
+percona-toolkit|3.5.7|1.el9|pt-release-x86_64|(none)
+`),
+			packageErr: nil,
+			expectedPackageList: []*Package{
+				{
+					Name:    "percona-server-server",
+					Version: "8.0.36-28-1",
+					Arch:    "x86_64",
+					Repository: PackageRepository{
+						Name:      "ps-80",
+						Component: "release",
+					},
+					InstallTime: 1708026156,
+				},
+				{
+					Name:    "percona-toolkit",
+					Version: "3.5.7-1",
+					Repository: PackageRepository{
+						Name:      "pt",
+						Component: "release",
+					},
+				},
+			},
+			expectErr: nil,
+		},
 		{
 			name:                "invalid_rpm_output",
 			isPerconaPackage:    isPerconaPackage("percona-xtrabackup-81"),
@@ -309,7 +340,7 @@ proxysql2|2.5.5|1.2.el9|proxysql-release-x86_64`),
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			pkg, err := parseRhelPackageOutput(tt.packageOutput, tt.packageErr, tt.isPerconaPackage)
+			pkg, err := parseRhelPackageOutput(tt.packageOutput, tt.packageErr, tt.isPerconaPackage, nil)
 			if tt.expectErr != nil {
 				require.ErrorIs(t, err, tt.expectErr)
 			}
@@ -320,3 +351,122 @@ proxysql2|2.5.5|1.2.el9|proxysql-release-x86_64`),
 		})
 	}
 }
+
+func TestParseRhelUpdatesOutput(t *testing.T) {
+	t.Parallel()
+
+	updatesErr := errors.New("Error: Cache-only enabled but no cache for 'ps-80-release-x86_64'")
+
+	tests := []struct {
+		name             string
+		isPerconaPackage bool
+		updatesOutput    []byte
+		updatesErr       error
+		expected         map[string]string
+		expectErr        error
+	}{
+		{
+			name:             "percona_updates",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			updatesOutput: []byte(`percona-server-server|8.0.36|28.1.el9
+percona-xtrabackup-81|8.1.0|2.1.el9
+percona-xtrabackup-81|8.1.0|3.1.el9
+`),
+			expected: map[string]string{
+				"percona-server-server": "8.0.36-28-1",
+				"percona-xtrabackup-81": "8.1.0-3-1",
+			},
+		},
+		{
+			name:             "no_updates",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			updatesOutput:    []byte(``),
+			expected:         map[string]string{},
+		},
+		{
+			name:             "cmd_error",
+			isPerconaPackage: isPerconaPackage("percona-*"),
+			updatesOutput:    []byte(``),
+			updatesErr:       updatesErr,
+			expectErr:        updatesErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			updates, err := parseRhelUpdatesOutput(tt.updatesOutput, tt.updatesErr, tt.isPerconaPackage)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				require.Nil(t, updates)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, updates)
+		})
+	}
+}
+
+func TestParseRhelInstalledRepositoriesOutput(t *testing.T) {
+	t.Parallel()
+
+	repos, err := parseRhelInstalledRepositoriesOutput([]byte(`'percona-server-server|8.0.36|28.1.el9|ps-80-release-x86_64|1708026156|x86_64'
+'haproxy|2.4.22|3.el9_3|@appstream|1708026156|x86_64'
+'percona-toolkit|3.5.7|1.el9|@System'
+'broken line'
+`), nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"percona-server-server": "ps-80-release-x86_64",
+		"haproxy":               "@appstream",
+		"percona-toolkit":       "@System",
+	}, repos)
+
+	cmdErr := errors.New("repoquery failed")
+	repos, err = parseRhelInstalledRepositoriesOutput(nil, cmdErr)
+	require.ErrorIs(t, err, cmdErr)
+	require.Nil(t, repos)
+}
+
+func TestParseRhelPackageOutputRepositoryURL(t *testing.T) {
+	t.Parallel()
+
+	repoURLs := map[string]string{
+		"ps-80-release-x86_64": "http://mirror.example.com/percona/ps-80/yum/release/$releasever/RPMS/x86_64",
+		"appstream":            "https://mirror.example.com/rocky/$releasever/AppStream/$basearch/os/",
+	}
+
+	pkgL, err := parseRhelPackageOutput([]byte(`percona-server-server|8.0.36|28.1.el9|ps-80-release-x86_64
+percona-toolkit|3.5.7|1.el9|@System
+`), nil, isPerconaPackage("percona-*"), repoURLs)
+	require.NoError(t, err)
+	require.Len(t, pkgL, 2)
+	require.Equal(t, repoURLs["ps-80-release-x86_64"], pkgL[0].Repository.URL)
+	require.Empty(t, pkgL[1].Repository.URL)
+
+	pkgL, err = parseRhelPackageOutput([]byte(`haproxy|2.4.22|3.el9_3|@appstream`), nil, isPerconaPackage("haproxy*"), repoURLs)
+	require.NoError(t, err)
+	require.Len(t, pkgL, 1)
+	require.Equal(t, repoURLs["appstream"], pkgL[0].Repository.URL)
+}
+
+func BenchmarkParseRhelPackageOutput(b *testing.B) {
+	var output strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&output, "percona-server-server-%d|8.0.35|27.1.el9|ps-80-release-x86_64|1708026156|x86_64\n", i)
+	}
+
+	packageOutput := []byte(output.String())
+	repoURLs := map[string]string{"ps-80-release-x86_64": "http://repo.percona.com/ps-80/yum/release/9/RPMS/x86_64"}
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		if _, err := parseRhelPackageOutput(packageOutput, nil, true, repoURLs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+const (
+	tarballRepositoryName = "tarball"
+)
+
+// tarballBinary describes Percona binary that may be installed from tarball.
+type tarballBinary struct {
+	// name of the executable file.
+	name string
+	// versionRe extracts version from '<binary> --version' output.
+	// The first capturing group shall contain version.
+	versionRe *regexp.Regexp
+	// isPercona reports whether '<binary> --version' output belongs to Percona build.
+	isPercona func(versionOutput string) bool
+}
+
+// getTarballBinaries returns list of Percona binaries that are looked up on filesystem.
+func getTarballBinaries() []tarballBinary {
+	return []tarballBinary{
+		{
+			// Example:
+			// /opt/percona/bin/mysqld  Ver 8.0.36-28 for Linux on x86_64 (Percona Server (GPL), Release 28, Revision 47601f19)
+			name:      "mysqld",
+			versionRe: regexp.MustCompile(`\sVer\s+(\S+)`),
+			isPercona: func(versionOutput string) bool {
+				return strings.Contains(versionOutput, "Percona")
+			},
+		},
+		{
+			// Example:
+			// db version v7.0.5-3
+			// Build Info: {...}
+			// Percona Server for MongoDB version always has '-<release>' suffix, upstream one does not.
+			name:      "mongod",
+			versionRe: regexp.MustCompile(`db version v(\S+-\d+)`),
+			isPercona: func(string) bool {
+				return true
+			},
+		},
+		{
+			// Example:
+			// Version:   2.4.1
+			// Platform:  linux/amd64
+			name:      "pbm-agent",
+			versionRe: regexp.MustCompile(`Version:\s+(\S+)`),
+			isPercona: func(string) bool {
+				return true
+			},
+		},
+		{
+			// Example:
+			// xtrabackup version 8.0.35-30 based on MySQL server 8.0.35 Linux (x86_64) (revision id: 6beb4b49)
+			name:      "xtrabackup",
+			versionRe: regexp.MustCompile(`xtrabackup version\s+(\S+)`),
+			isPercona: func(string) bool {
+				return true
+			},
+		},
+	}
+}
+
+// getTarballSearchPaths returns list of directories where tarball binaries are looked up.
+// It contains directories from PATH environment variable and well-known tarball extraction locations.
+func getTarballSearchPaths() []string {
+	searchPaths := filepath.SplitList(os.Getenv("PATH"))
+
+	for _, pattern := range []string{
+		"/opt/*/bin",
+		"/opt/percona/*/bin",
+		"/usr/local/*/bin",
+		"/usr/local/percona/*/bin",
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+
+		searchPaths = append(searchPaths, matches...)
+	}
+
+	return searchPaths
+}
+
+// queryTarballPackages looks up Percona binaries that are not owned by any package
+// and returns them as packages from "tarball" repository.
+func queryTarballPackages(ctx context.Context, distroFamily int, cmdTimeout time.Duration) []*Package {
+	toReturn := make([]*Package, 0, 1)
+	// the same binary may be reachable via several search paths (symlinks, duplicated PATH entries).
+	seen := make(map[string]struct{})
+
+	for _, dir := range getTarballSearchPaths() {
+		if len(dir) == 0 {
+			continue
+		}
+
+		for _, bin := range getTarballBinaries() {
+			binPath := filepath.Join(dir, bin.name)
+
+			realPath, err := filepath.EvalSymlinks(binPath)
+			if err != nil {
+				continue
+			}
+
+			if _, found := seen[realPath]; found {
+				continue
+			}
+
+			seen[realPath] = struct{}{}
+
+			st, err := os.Stat(realPath)
+			if err != nil || !st.Mode().IsRegular() || st.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+
+			if isFileOwnedByPackage(ctx, distroFamily, realPath, cmdTimeout) {
+				// installed from package, already reported by package manager.
+				continue
+			}
+
+			pkg, err := queryTarballBinary(ctx, realPath, bin, cmdTimeout)
+			if err != nil {
+				logger.FromContext(ctx).Sugar().Debugw("failed to get tarball binary info", zap.Error(err), zap.String("binary", realPath))
+				continue
+			}
+
+			toReturn = append(toReturn, pkg)
+		}
+	}
+
+	return toReturn
+}
+
+func queryTarballBinary(ctx context.Context, binPath string, bin tarballBinary, cmdTimeout time.Duration) (*Package, error) {
+	args := []string{binPath, "--version"}
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	outputB, err := runCommand(cmdCtx, args)
+
+	return parseTarballVersionOutput(outputB, err, bin)
+}
+
+func parseTarballVersionOutput(versionOutput []byte, versionErr error, bin tarballBinary) (*Package, error) {
+	if versionErr != nil {
+		zap.L().Sugar().Debugw("cmd output", zap.ByteString("output", versionOutput))
+		return nil, versionErr
+	}
+
+	output := string(versionOutput)
+	if !bin.isPercona(output) {
+		return nil, errPackageNotFound
+	}
+
+	matches := bin.versionRe.FindStringSubmatch(output)
+	if len(matches) < 2 {
+		return nil, errPackageNotFound
+	}
+
+	return &Package{
+		Name:    bin.name,
+		Version: matches[1],
+		Repository: PackageRepository{
+			Name: tarballRepositoryName,
+		},
+	}, nil
+}
+
+// isFileOwnedByPackage checks if file on filesystem belongs to any installed package.
+// In case package manager is absent or fails, file is treated as not owned.
+func isFileOwnedByPackage(ctx context.Context, distroFamily int, filePath string, cmdTimeout time.Duration) bool {
+	var args []string
+
+	switch distroFamily {
+	case distroFamilyDebian:
+		args = []string{"dpkg-query", "-S", filePath}
+	case distroFamilyRhel:
+		args = []string{"rpm", "-qf", filePath}
+	case distroFamilyFreeBSD:
+		args = []string{"pkg", "-N", "which", "-q", filePath}
+	default:
+		return false
+	}
+
+	if _, err := lookPath(ctx, args[0]); err != nil {
+		return false
+	}
+
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	_, err := runCommand(cmdCtx, args)
+
+	// 'dpkg-query -S', 'rpm -qf' and 'pkg which' exit with non-zero code when file is not owned by any package.
+	return err == nil
+}
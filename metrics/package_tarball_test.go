@@ -0,0 +1,137 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTarballVersionOutput(t *testing.T) {
+	t.Parallel()
+
+	binaries := make(map[string]tarballBinary)
+	for _, bin := range getTarballBinaries() {
+		binaries[bin.name] = bin
+	}
+
+	cmdErr := errors.New("exit status 1")
+	tests := []struct {
+		name            string
+		binary          string
+		versionOutput   []byte
+		versionErr      error
+		expectedPackage *Package
+		expectErr       error
+	}{
+		{
+			name:          "percona_mysqld",
+			binary:        "mysqld",
+			versionOutput: []byte(`/opt/percona/bin/mysqld  Ver 8.0.36-28 for Linux on x86_64 (Percona Server (GPL), Release 28, Revision 47601f19)`),
+			expectedPackage: &Package{
+				Name:       "mysqld",
+				Version:    "8.0.36-28",
+				Repository: PackageRepository{Name: tarballRepositoryName},
+			},
+		},
+		{
+			name:          "oracle_mysqld",
+			binary:        "mysqld",
+			versionOutput: []byte(`/usr/local/mysql/bin/mysqld  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)`),
+			expectErr:     errPackageNotFound,
+		},
+		{
+			name:   "percona_mongod",
+			binary: "mongod",
+			versionOutput: []byte(`db version v7.0.5-3
+Build Info: {
+    "version": "7.0.5-3",
+    "gitVersion": "0d0b8a6e6ed7ec8c6fb9a65d0f1a2f5c8f4fa2b5"
+}`),
+			expectedPackage: &Package{
+				Name:       "mongod",
+				Version:    "7.0.5-3",
+				Repository: PackageRepository{Name: tarballRepositoryName},
+			},
+		},
+		{
+			name:   "upstream_mongod",
+			binary: "mongod",
+			versionOutput: []byte(`db version v7.0.5
+Build Info: {
+    "version": "7.0.5"
+}`),
+			expectErr: errPackageNotFound,
+		},
+		{
+			name:   "pbm_agent",
+			binary: "pbm-agent",
+			versionOutput: []byte(`Version:   2.4.1
+Platform:  linux/amd64
+GitCommit: 8a3b9e8b4a0e
+GitBranch: release-2.4.1
+BuildTime: 2024-03-20_14:01_UTC
+GoVersion: go1.22.1`),
+			expectedPackage: &Package{
+				Name:       "pbm-agent",
+				Version:    "2.4.1",
+				Repository: PackageRepository{Name: tarballRepositoryName},
+			},
+		},
+		{
+			name:          "xtrabackup",
+			binary:        "xtrabackup",
+			versionOutput: []byte(`xtrabackup version 8.0.35-30 based on MySQL server 8.0.35 Linux (x86_64) (revision id: 6beb4b49)`),
+			expectedPackage: &Package{
+				Name:       "xtrabackup",
+				Version:    "8.0.35-30",
+				Repository: PackageRepository{Name: tarballRepositoryName},
+			},
+		},
+		{
+			name:          "unexpected_output",
+			binary:        "xtrabackup",
+			versionOutput: []byte(`some unexpected output`),
+			expectErr:     errPackageNotFound,
+		},
+		{
+			name:          "cmd_error",
+			binary:        "mysqld",
+			versionOutput: []byte(`error while loading shared libraries: libssl.so.3`),
+			versionErr:    cmdErr,
+			expectErr:     cmdErr,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pkg, err := parseTarballVersionOutput(tt.versionOutput, tt.versionErr, binaries[tt.binary])
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				require.Nil(t, pkg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedPackage, pkg)
+		})
+	}
+}
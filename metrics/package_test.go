@@ -16,7 +16,12 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -81,9 +86,24 @@ var osNames = []struct {
 		osName:   "AlmaLinux 8.9 (Midnight Oncilla)",
 		expected: distroFamilyRhel,
 	},
+	{
+		name:     "FreeBSD 14.0-RELEASE",
+		osName:   "FreeBSD 14.0-RELEASE-p3",
+		expected: distroFamilyFreeBSD,
+	},
 	{
 		name:     "MacOS",
 		osName:   "Darwin",
+		expected: distroFamilyDarwin,
+	},
+	{
+		name:     "macOS 14.4.1",
+		osName:   "macOS 14.4.1",
+		expected: distroFamilyDarwin,
+	},
+	{
+		name:     "Unknown",
+		osName:   "Plan 9",
 		expected: distroFamilyUnknown,
 	},
 }
@@ -288,10 +308,15 @@ percona-backup-mongodb|2.4.1|1.el9|pbm-release-x86_64
 				}
 			}
 
+			// repository URL is distribution specific (apt vs yum repository), so it is not compared.
+			for _, pkg := range debianPkgList {
+				pkg.Repository.URL = ""
+			}
+
 			require.Equal(t, tt.expectedPackageList, debianPkgList)
 
 			// rpm
-			rhelPkgList, err := parseRhelPackageOutput(tt.rhelPackageOutput, tt.rhelExpectedErr, tt.isPerconaPackage)
+			rhelPkgList, err := parseRhelPackageOutput(tt.rhelPackageOutput, tt.rhelExpectedErr, tt.isPerconaPackage, nil)
 			if tt.rhelExpectedErr == nil {
 				require.NoError(t, err)
 				require.NotNil(t, rhelPkgList)
@@ -304,3 +329,190 @@ percona-backup-mongodb|2.4.1|1.el9|pbm-release-x86_64
 		})
 	}
 }
+
+func TestQueryPackagePatterns(t *testing.T) {
+	t.Parallel()
+
+	pkgList := []string{"percona-*", "proxysql*", "pmm*", "etcd*", "haproxy"}
+	pkgFunc := func(_ context.Context, _ PackageScrapeOpts, _, packageNamePattern string) ([]*Package, error) {
+		switch packageNamePattern {
+		case "percona-*":
+			// make the first pattern the slowest one to check result ordering.
+			time.Sleep(50 * time.Millisecond)
+
+			return []*Package{{Name: "percona-server-server"}, {Name: "percona-toolkit"}}, nil
+		case "proxysql*":
+			return []*Package{{Name: "proxysql2"}}, nil
+		case "etcd*":
+			return nil, errors.New("unexpected error")
+		case "haproxy":
+			return []*Package{{Name: "haproxy"}}, nil
+		default:
+			return nil, errPackageNotFound
+		}
+	}
+
+	expected := []*Package{
+		{Name: "percona-server-server"},
+		{Name: "percona-toolkit"},
+		{Name: "proxysql2"},
+		{Name: "haproxy"},
+	}
+
+	for _, workers := range []int{0, 1, 3, 10} {
+		t.Run(fmt.Sprintf("workers_%d", workers), func(t *testing.T) {
+			t.Parallel()
+
+			pkgL := queryPackagePatterns(t.Context(), pkgFunc, PackageScrapeOpts{Workers: workers}, "", pkgList)
+			require.Equal(t, expected, pkgL)
+		})
+	}
+}
+
+func TestQueryPackagePatternsNoPackageManager(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	pkgFunc := func(_ context.Context, _ PackageScrapeOpts, _, _ string) ([]*Package, error) {
+		calls.Add(1)
+		return nil, errPackageManagerNotFound
+	}
+
+	pkgL := queryPackagePatterns(t.Context(), pkgFunc, PackageScrapeOpts{Workers: 1}, "", []string{"percona-*", "proxysql*", "pmm*"})
+	require.Empty(t, pkgL)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestPackageScrapeOptsTimeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                     string
+		opts                     PackageScrapeOpts
+		expectedCmdTimeout       time.Duration
+		expectedRepoqueryTimeout time.Duration
+	}{
+		{
+			name:                     "not_set",
+			opts:                     PackageScrapeOpts{},
+			expectedCmdTimeout:       defaultCmdTimeout,
+			expectedRepoqueryTimeout: defaultCmdTimeout,
+		},
+		{
+			name:                     "cmd_timeout_only",
+			opts:                     PackageScrapeOpts{CmdTimeout: 10 * time.Second},
+			expectedCmdTimeout:       10 * time.Second,
+			expectedRepoqueryTimeout: 10 * time.Second,
+		},
+		{
+			name:                     "both_set",
+			opts:                     PackageScrapeOpts{CmdTimeout: 10 * time.Second, RepoqueryTimeout: 2 * time.Minute},
+			expectedCmdTimeout:       10 * time.Second,
+			expectedRepoqueryTimeout: 2 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expectedCmdTimeout, tt.opts.cmdTimeout())
+			require.Equal(t, tt.expectedRepoqueryTimeout, tt.opts.repoqueryTimeout())
+		})
+	}
+}
+
+func TestGetPackageOrigin(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		repo     PackageRepository
+		expected string
+	}{
+		{
+			name:     "percona_apt",
+			repo:     PackageRepository{Name: "ppg-16", Component: "release", URL: "http://repo.percona.com/ppg-16/apt"},
+			expected: packageOriginPercona,
+		},
+		{
+			name:     "distro_apt",
+			repo:     PackageRepository{Name: "ubuntu", Component: "main", URL: "http://archive.ubuntu.com/ubuntu"},
+			expected: packageOriginDistro,
+		},
+		{
+			name:     "distro_debian_security",
+			repo:     PackageRepository{Name: "debian-security", Component: "main", URL: "http://security.debian.org/debian-security"},
+			expected: packageOriginDistro,
+		},
+		{
+			name:     "third_party_apt",
+			repo:     PackageRepository{Name: "pub", Component: "main", URL: "https://apt.postgresql.org/pub/repos/apt"},
+			expected: packageOriginThirdParty,
+		},
+		{
+			name:     "percona_yum_without_url",
+			repo:     PackageRepository{Name: "ps-80", Component: "release"},
+			expected: packageOriginPercona,
+		},
+		{
+			name:     "distro_yum_without_url",
+			repo:     PackageRepository{Name: "@appstream"},
+			expected: packageOriginDistro,
+		},
+		{
+			name:     "third_party_yum_without_url",
+			repo:     PackageRepository{Name: "pgdg16"},
+			expected: packageOriginThirdParty,
+		},
+		{
+			name:     "installed_from_file",
+			repo:     PackageRepository{Name: "@System"},
+			expected: "",
+		},
+		{
+			name:     "distro_freebsd",
+			repo:     PackageRepository{Name: "FreeBSD"},
+			expected: packageOriginDistro,
+		},
+		{
+			name:     "installed_from_file_freebsd",
+			repo:     PackageRepository{Name: "unknown-repository"},
+			expected: "",
+		},
+		{
+			name:     "tarball",
+			repo:     PackageRepository{Name: tarballRepositoryName},
+			expected: packageOriginPercona,
+		},
+		{
+			name:     "pip",
+			repo:     PackageRepository{Name: pipRepositoryName},
+			expected: packageOriginThirdParty,
+		},
+		{
+			name:     "unknown",
+			repo:     PackageRepository{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, getPackageOrigin(tt.repo))
+		})
+	}
+}
+
+func TestIsPackageManagerSupported(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, isPackageManagerSupported(distroFamilyRhel))
+	require.True(t, isPackageManagerSupported(distroFamilyDebian))
+	require.True(t, isPackageManagerSupported(distroFamilyFreeBSD))
+	require.False(t, isPackageManagerSupported(distroFamilyDarwin))
+	require.False(t, isPackageManagerSupported(distroFamilyUnknown))
+}
@@ -16,35 +16,79 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
 	platformReporter "github.com/percona/platform/gen/telemetry/generic"
 )
 
+// PillarDisabledFile is the consent marker file Pillar opt-out mechanisms write into Pillar metrics directory.
+// Files in the directory are neither read nor removed while it exists.
+const PillarDisabledFile = ".disabled"
+
+// PillarDisabled returns true if telemetry of the Pillar is disabled by consent marker file in its metrics directory.
+// The marker that can't be checked is considered present, so opt-out is never ignored.
+func PillarDisabled(path string) bool {
+	_, err := os.Lstat(filepath.Join(filepath.Clean(path), PillarDisabledFile))
+
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// ProcessPillarMetrics processes metrics of the Pillar reported with productFamily and returns slice of *File.
+// Each File corresponds to a separate metrics file.
+func ProcessPillarMetrics(ctx context.Context, path string, productFamily platformReporter.ProductFamily, opts ProcessOpts) ([]*File, error) {
+	return processMetricsDirectory(ctx, path, productFamily, opts)
+}
+
+// ParseMetricsFile parses single metrics file of the Pillar reported with productFamily, e.g. the file recovered
+// from quarantine or failed metrics directory. The file is validated against schema of the product family
+// if it exists, but it is never moved or removed.
+func ParseMetricsFile(ctx context.Context, path string, productFamily platformReporter.ProductFamily, opts ProcessOpts) ([]*File, error) {
+	schema, err := loadMetricsSchema(opts.SchemaDir, productFamily)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := parseMetricsFile(ctx, path, opts, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		f.ProductFamily = productFamily
+	}
+
+	return files, nil
+}
+
 // ProcessPSMetrics processes PS metrics and returns slice of *File.
 // Each File corresponds to a separate metrics file.
 func ProcessPSMetrics(path string) ([]*File, error) {
-	return processMetricsDirectory(path, platformReporter.ProductFamily_PRODUCT_FAMILY_PS)
+	return processMetricsDirectory(context.Background(), path, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, ProcessOpts{})
 }
 
 // ProcessPBSMetrics processes PBS metrics and returns slice of *File.
 // Each File corresponds to a separate metrics file.
 func ProcessPBSMetrics(path string) ([]*File, error) {
-	return processMetricsDirectory(path, platformReporter.ProductFamily_PRODUCT_FAMILY_PBS)
+	return processMetricsDirectory(context.Background(), path, platformReporter.ProductFamily_PRODUCT_FAMILY_PBS, ProcessOpts{})
 }
 
 // ProcessPXCMetrics processes PXC metrics and returns slice of *File.
 // Each File corresponds to a separate metrics file.
 func ProcessPXCMetrics(path string) ([]*File, error) {
-	return processMetricsDirectory(path, platformReporter.ProductFamily_PRODUCT_FAMILY_PXC)
+	return processMetricsDirectory(context.Background(), path, platformReporter.ProductFamily_PRODUCT_FAMILY_PXC, ProcessOpts{})
 }
 
 // ProcessPSMDBMetrics processes PSMDB metrics and returns slice of *File.
 // Each File corresponds to a separate metrics file.
 func ProcessPSMDBMetrics(path string) ([]*File, error) {
-	return processMetricsDirectory(path, platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB)
+	return processMetricsDirectory(context.Background(), path, platformReporter.ProductFamily_PRODUCT_FAMILY_PSMDB, ProcessOpts{})
 }
 
 // ProcessPGMetrics processes PG metrics and returns slice of *File.
 // Each File corresponds to a separate metrics file.
 func ProcessPGMetrics(path string) ([]*File, error) {
-	return processMetricsDirectory(path, platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL)
+	return processMetricsDirectory(context.Background(), path, platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL, ProcessOpts{})
 }
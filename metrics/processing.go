@@ -0,0 +1,125 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// MoveMetricsFile moves metrics file along with its checksum and send attempts sidecar files,
+// creating destination directory if needed.
+func MoveMetricsFile(fileName, newFileName string) error {
+	if err := os.MkdirAll(filepath.Dir(newFileName), os.ModeDir|quarantineDirPermissions); err != nil {
+		return fmt.Errorf("can't create directory: %w", err)
+	}
+
+	if err := os.Rename(fileName, newFileName); err != nil {
+		return err
+	}
+
+	if err := moveChecksumFile(fileName, newFileName); err != nil {
+		return fmt.Errorf("can't move checksum file: %w", err)
+	}
+
+	err := os.Rename(fileName+sendAttemptsFileExt, newFileName+sendAttemptsFileExt)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("can't move send attempts file: %w", err)
+	}
+
+	return nil
+}
+
+// RecoverProcessingFiles handles metrics files left in processingDir by the agent terminated during processing.
+// Files are kept in processingDir under their path relative to rootDir. Files already saved into historyDir
+// were sent, so they are removed, the rest are moved back to their original location to be sent again.
+func RecoverProcessingFiles(processingDir, rootDir, historyDir string) error {
+	l := zap.L().Sugar()
+
+	cleanProcessingDir := filepath.Clean(processingDir)
+
+	if _, err := os.Stat(cleanProcessingDir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("can't read processing directory: %w", err)
+	}
+
+	return filepath.WalkDir(cleanProcessingDir, func(fileName string, file fs.DirEntry, err error) error {
+		fl := l.With(zap.String("file", fileName))
+
+		if err != nil {
+			if fileName == cleanProcessingDir {
+				return fmt.Errorf("can't read processing directory: %w", err)
+			}
+
+			fl.Errorw("error reading processing directory, skipping", zap.Error(err))
+
+			return nil
+		}
+
+		// sidecar files are moved along with metrics file.
+		if !file.Type().IsRegular() || filepath.Ext(fileName) != ".json" {
+			return nil
+		}
+
+		if isSentMetricsFile(historyDir, fileName) {
+			fl.Infow("metrics file is already sent, removing it from processing directory")
+
+			if err := RemoveMetricsFile(fileName); err != nil {
+				fl.Errorw("failed to remove sent metrics file", zap.Error(err))
+			}
+
+			return nil
+		}
+
+		relName, err := filepath.Rel(cleanProcessingDir, fileName)
+		if err != nil {
+			fl.Errorw("failed to get metrics file path relative to processing directory", zap.Error(err))
+			return nil
+		}
+
+		originalFile := filepath.Join(rootDir, relName)
+
+		fl.Infow("recovering metrics file left in processing directory", zap.String("original file", originalFile))
+
+		if err := MoveMetricsFile(fileName, originalFile); err != nil {
+			fl.Errorw("failed to recover metrics file", zap.Error(err))
+		}
+
+		return nil
+	})
+}
+
+// isSentMetricsFile returns true if history file with metrics file name exists,
+// history is written after telemetry is sent successfully.
+func isSentMetricsFile(historyDir, fileName string) bool {
+	historyFile := filepath.Join(historyDir, filepath.Base(fileName))
+
+	for _, f := range []string{historyFile, historyFile + HistoryCompressedFileExt} {
+		if _, err := os.Stat(f); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveMetricsFile(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	fileName := filepath.Join(rootDir, "ps", "1708026156-a.json")
+	newFileName := filepath.Join(rootDir, "processing", "ps", "1708026156-a.json")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0o700))
+	require.NoError(t, os.WriteFile(fileName, []byte(`{}`), 0o600))
+	require.NoError(t, os.WriteFile(fileName+sendAttemptsFileExt, []byte(`{"attempts": 1}`), 0o600))
+
+	require.NoError(t, MoveMetricsFile(fileName, newFileName))
+
+	require.NoFileExists(t, fileName)
+	require.NoFileExists(t, fileName+sendAttemptsFileExt)
+	require.FileExists(t, newFileName)
+	require.FileExists(t, newFileName+sendAttemptsFileExt)
+	// checksum file is optional.
+	require.NoFileExists(t, newFileName+checksumFileExt)
+}
+
+func TestRecoverProcessingFiles(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	processingDir := filepath.Join(rootDir, "processing")
+	historyDir := filepath.Join(rootDir, "history")
+
+	sentFile := filepath.Join(processingDir, "ps", "1708026156-a.json")
+	sentCompressedFile := filepath.Join(processingDir, "ps", "1708026157-b.json")
+	unsentFile := filepath.Join(processingDir, "pg", "cluster1", "1708026158-c.json")
+
+	for _, fileName := range []string{sentFile, sentCompressedFile, unsentFile, unsentFile + checksumFileExt} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0o700))
+		require.NoError(t, os.WriteFile(fileName, []byte(`{}`), 0o600))
+	}
+
+	require.NoError(t, os.MkdirAll(historyDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(historyDir, "1708026156-a.json"), []byte(`{}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(historyDir, "1708026157-b.json"+HistoryCompressedFileExt), []byte(`{}`), 0o600))
+
+	require.NoError(t, RecoverProcessingFiles(processingDir, rootDir, historyDir))
+
+	// sent files are removed.
+	require.NoFileExists(t, sentFile)
+	require.NoFileExists(t, sentCompressedFile)
+	require.NoFileExists(t, filepath.Join(rootDir, "ps", "1708026156-a.json"))
+	require.NoFileExists(t, filepath.Join(rootDir, "ps", "1708026157-b.json"))
+
+	// unsent file is moved back with its sidecar file.
+	require.NoFileExists(t, unsentFile)
+	require.FileExists(t, filepath.Join(rootDir, "pg", "cluster1", "1708026158-c.json"))
+	require.FileExists(t, filepath.Join(rootDir, "pg", "cluster1", "1708026158-c.json"+checksumFileExt))
+
+	// absent processing directory is not an error.
+	require.NoError(t, RecoverProcessingFiles(filepath.Join(rootDir, "absent"), rootDir, historyDir))
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+)
+
+const pushedFilePermissions = 0o644
+
+var (
+	// ErrInvalidPushedMetrics is returned when metrics pushed by Pillar can't be parsed or don't match schema.
+	ErrInvalidPushedMetrics = errors.New("invalid metrics")
+	// ErrPillarDisabled is returned when metrics are pushed for Pillar disabled by consent marker file.
+	ErrPillarDisabled = errors.New("pillar telemetry is disabled")
+)
+
+// SavePushedMetrics validates metrics content pushed by Pillar and saves it into Pillar metrics directory dir
+// as new '<unixtime>-<random token>.json' metrics file, so pushed metrics are processed exactly as metrics files
+// written by Pillars. createTime is used as unixtime of the file name, current time is used if it is zero.
+// The file is written under temporary name first, so it is never processed partially written.
+// Name of the created file is returned.
+func SavePushedMetrics(
+	ctx context.Context, dir string, productFamily platformReporter.ProductFamily, content []byte, createTime time.Time, opts ProcessOpts,
+) (string, error) {
+	cleanDir := filepath.Clean(dir)
+
+	if PillarDisabled(cleanDir) {
+		return "", ErrPillarDisabled
+	}
+
+	if err := os.MkdirAll(cleanDir, os.ModeDir|quarantineDirPermissions); err != nil {
+		return "", fmt.Errorf("can't create pillar metrics directory: %w", err)
+	}
+
+	// temporary file has no '.json' extension, so it is never picked up by processing.
+	tmpFile, err := os.CreateTemp(cleanDir, ".push-*.json.tmp")
+	if err != nil {
+		return "", fmt.Errorf("can't create metrics file: %w", err)
+	}
+
+	tmpName := tmpFile.Name()
+	defer func() {
+		// the file is already renamed on success.
+		_ = os.Remove(tmpName)
+	}()
+
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("can't write metrics file: %w", err)
+	}
+
+	// pushed metrics are rejected right away instead of being quarantined later.
+	opts.ReadOnly = true
+
+	if _, err := ParseMetricsFile(ctx, tmpName, productFamily, opts); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidPushedMetrics, err)
+	}
+
+	if err := os.Chmod(tmpName, pushedFilePermissions); err != nil {
+		return "", fmt.Errorf("can't change metrics file mode: %w", err)
+	}
+
+	if createTime.IsZero() {
+		createTime = time.Now()
+	}
+
+	fileName := filepath.Join(cleanDir, fmt.Sprintf("%d-%s.json", createTime.Unix(), uuid.NewString()))
+	if err := os.Rename(tmpName, fileName); err != nil {
+		return "", fmt.Errorf("can't save metrics file: %w", err)
+	}
+
+	return fileName, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavePushedMetrics(t *testing.T) {
+	t.Parallel()
+
+	productFamily := platformReporter.ProductFamily_PRODUCT_FAMILY_PS
+
+	tests := []struct {
+		name      string
+		content   string
+		disabled  bool
+		expectErr error
+	}{
+		{
+			name:    "valid_metrics",
+			content: `{"db_instance_id":"11111111-1111-1111-1111-111111111111","pillar_version":"8.0.35"}`,
+		},
+		{
+			name:      "invalid_json",
+			content:   `{"db_instance_id":`,
+			expectErr: ErrInvalidPushedMetrics,
+		},
+		{
+			name:      "pillar_disabled",
+			content:   `{"db_instance_id":"11111111-1111-1111-1111-111111111111"}`,
+			disabled:  true,
+			expectErr: ErrPillarDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Pillar directory is created if it is absent.
+			dir := filepath.Join(t.TempDir(), "ps")
+
+			if tt.disabled {
+				require.NoError(t, os.MkdirAll(dir, 0o775))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, PillarDisabledFile), nil, 0o644))
+			}
+
+			fileName, err := SavePushedMetrics(t.Context(), dir, productFamily, []byte(tt.content), time.Time{}, ProcessOpts{})
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				// rejected metrics leave no files behind.
+				count, countErr := CountPillarMetricsFiles(dir)
+				require.NoError(t, countErr)
+				require.Zero(t, count)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, dir, filepath.Dir(fileName))
+			require.Regexp(t, `^\d+-[0-9a-f-]{36}\.json$`, filepath.Base(fileName))
+
+			files, err := ProcessPillarMetrics(t.Context(), dir, productFamily, ProcessOpts{ReadOnly: true})
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			require.Equal(t, "8.0.35", files[0].Metrics["pillar_version"])
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+const (
+	// quarantineFailuresThreshold is the number of failed parsing attempts
+	// after which metrics file is moved into quarantine directory.
+	quarantineFailuresThreshold = 3
+	quarantineDirPermissions    = 0o775
+)
+
+// ParseFailures counts failed parsing attempts of metrics files between processing iterations.
+// It is safe for concurrent use.
+type ParseFailures struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewParseFailures creates empty ParseFailures.
+func NewParseFailures() *ParseFailures {
+	return &ParseFailures{
+		counts: make(map[string]int),
+	}
+}
+
+// add registers failed parsing attempt of the file and returns the number of failed attempts so far.
+func (f *ParseFailures) add(fileName string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.counts[fileName]++
+
+	return f.counts[fileName]
+}
+
+// reset forgets failed parsing attempts of the file.
+func (f *ParseFailures) reset(fileName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.counts, fileName)
+}
+
+// quarantineMetricsFile moves metrics file into pillarQuarantineDir,
+// so it is not processed again and the Pillar directory stays clean.
+func quarantineMetricsFile(ctx context.Context, pillarQuarantineDir, fileName string) (string, error) {
+	pillarQuarantineDir = filepath.Clean(pillarQuarantineDir)
+
+	err := os.MkdirAll(pillarQuarantineDir, os.ModeDir|quarantineDirPermissions)
+	if err != nil {
+		return "", fmt.Errorf("can't create quarantine directory: %w", err)
+	}
+
+	quarantineFile := filepath.Join(pillarQuarantineDir, filepath.Base(fileName))
+
+	err = os.Rename(fileName, quarantineFile)
+	if err != nil {
+		return "", fmt.Errorf("can't move metrics file into quarantine directory: %w", err)
+	}
+
+	err = moveChecksumFile(fileName, quarantineFile)
+	if err != nil {
+		logger.FromContext(ctx).Sugar().Warnw("failed to move checksum file into quarantine directory",
+			zap.String("file", fileName),
+			zap.Error(err))
+	}
+
+	// retention is counted from the moment file is quarantined.
+	now := time.Now()
+	if err := os.Chtimes(quarantineFile, now, now); err != nil {
+		logger.FromContext(ctx).Sugar().Warnw("failed to update quarantined file modification time",
+			zap.String("file", quarantineFile),
+			zap.Error(err))
+	}
+
+	// checksum file is optional, so it may be absent.
+	_ = os.Chtimes(quarantineFile+checksumFileExt, now, now)
+
+	return quarantineFile, nil
+}
+
+// CleanupQuarantine removes all files from quarantine directory and its subdirectories
+// that were quarantined earlier than threshold.
+// Unlike history files, quarantined file names may be arbitrary, so file modification time is used.
+func CleanupQuarantine(ctx context.Context, quarantineDirectoryPath string, keepInterval int) error {
+	l := logger.FromContext(ctx).Sugar()
+
+	cleanQuarantinePath := filepath.Clean(quarantineDirectoryPath)
+
+	if _, err := os.Stat(cleanQuarantinePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// nothing has been quarantined yet.
+			return nil
+		}
+
+		return fmt.Errorf("can't read quarantine directory: %w", err)
+	}
+
+	timeThreshold := time.Now().Add(-time.Duration(keepInterval) * time.Second)
+
+	return filepath.WalkDir(cleanQuarantinePath, func(fileName string, file fs.DirEntry, err error) error {
+		fl := l.With(zap.String("file", fileName))
+
+		if err != nil {
+			if fileName == cleanQuarantinePath {
+				return fmt.Errorf("can't read quarantine directory: %w", err)
+			}
+
+			fl.Errorw("error reading quarantine directory, skipping", zap.Error(err))
+
+			return nil
+		}
+
+		if !file.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			fl.Warnw("can't get file info, skipping", zap.Error(err))
+			return nil
+		}
+
+		if info.ModTime().After(timeThreshold) {
+			fl.Debugw("file age threshold is not reached, skipping",
+				zap.Time("quarantineTime", info.ModTime()),
+				zap.Time("threshold", timeThreshold))
+
+			return nil
+		}
+
+		fl.Debug("removing quarantined file")
+
+		if err := os.Remove(fileName); err != nil {
+			fl.Errorw("error removing quarantined file, skipping", zap.Error(err))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessMetricsDirectoryQuarantine(t *testing.T) {
+	t.Parallel()
+
+	const fileName = "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json"
+
+	testCases := []struct {
+		name                string
+		schema              string
+		fileContent         string
+		iterations          int
+		expectedQuarantined bool
+	}{
+		{
+			name:        "corrupt_file_single_failure",
+			fileContent: `{"db_instance_id": `,
+			iterations:  1,
+		},
+		{
+			name:                "corrupt_file_repeated_failures",
+			fileContent:         `{"db_instance_id": `,
+			iterations:          quarantineFailuresThreshold,
+			expectedQuarantined: true,
+		},
+		{
+			name:                "rejected_file",
+			schema:              `{"required": ["db_instance_id"]}`,
+			fileContent:         `{"uptime": "112"}`,
+			iterations:          1,
+			expectedQuarantined: true,
+		},
+		{
+			name:        "valid_file",
+			fileContent: `{"db_instance_id": "1bed5f0d-cc3a-11ee-bd8a-c84bd64e0288"}`,
+			iterations:  quarantineFailuresThreshold,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schemaDir := t.TempDir()
+			if len(tt.schema) != 0 {
+				require.NoError(t, os.WriteFile(filepath.Join(schemaDir, "ps.json"), []byte(tt.schema), 0o600))
+			}
+
+			rootDir := t.TempDir()
+			metricsDir := filepath.Join(rootDir, "ps")
+			require.NoError(t, os.Mkdir(metricsDir, 0o700))
+
+			metricsFile := filepath.Join(metricsDir, fileName)
+			require.NoError(t, os.WriteFile(metricsFile, []byte(tt.fileContent), 0o600))
+
+			opts := ProcessOpts{
+				SchemaDir:     schemaDir,
+				QuarantineDir: filepath.Join(rootDir, "quarantine"),
+				ParseFailures: NewParseFailures(),
+			}
+
+			for range tt.iterations {
+				_, err := processMetricsDirectory(t.Context(), metricsDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, opts)
+				require.NoError(t, err)
+			}
+
+			quarantineFile := filepath.Join(rootDir, "quarantine", "ps", fileName)
+			if tt.expectedQuarantined {
+				require.NoFileExists(t, metricsFile)
+				require.FileExists(t, quarantineFile)
+
+				return
+			}
+
+			require.FileExists(t, metricsFile)
+			require.NoFileExists(t, quarantineFile)
+		})
+	}
+}
+
+func TestCleanupQuarantine(t *testing.T) {
+	t.Parallel()
+
+	quarantineDir := t.TempDir()
+	pillarDir := filepath.Join(quarantineDir, "ps")
+	require.NoError(t, os.Mkdir(pillarDir, 0o700))
+
+	oldFile := filepath.Join(pillarDir, "old.json")
+	newFile := filepath.Join(pillarDir, "new.json")
+
+	require.NoError(t, os.WriteFile(oldFile, []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(newFile, []byte("{"), 0o600))
+
+	oldTime := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(oldFile, oldTime, oldTime))
+
+	require.NoError(t, CleanupQuarantine(t.Context(), quarantineDir, 60*60))
+	require.NoFileExists(t, oldFile)
+	require.FileExists(t, newFile)
+
+	// absent quarantine directory is not an error.
+	require.NoError(t, CleanupQuarantine(t.Context(), filepath.Join(quarantineDir, "absent"), 60*60))
+}
@@ -0,0 +1,343 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	perconaRepositoryHost = "repo.percona.com"
+	aptSourcesDir         = "/etc/apt/sources.list.d"
+	yumReposDir           = "/etc/yum.repos.d"
+)
+
+// ScrapePerconaRepositories returns list of enabled Percona repositories configured on the host
+// (e.g. by percona-release tool), regardless of whether any package is installed from them.
+func ScrapePerconaRepositories() []*PackageRepository {
+	toReturn := make([]*PackageRepository, 0, 1)
+
+	toReturn = append(toReturn, scrapeRepositoryFiles(aptSourcesDir, ".list", parseAptSourcesList)...)
+	toReturn = append(toReturn, scrapeRepositoryFiles(aptSourcesDir, ".sources", parseAptSourcesDeb822)...)
+	toReturn = append(toReturn, scrapeRepositoryFiles(yumReposDir, ".repo", parseYumRepoFile)...)
+
+	// the same repository may be configured for several architectures or in several files.
+	slices.SortFunc(toReturn, func(a, b *PackageRepository) int {
+		return strings.Compare(a.Name+"/"+a.Component, b.Name+"/"+b.Component)
+	})
+
+	return slices.CompactFunc(toReturn, func(a, b *PackageRepository) bool {
+		return *a == *b
+	})
+}
+
+func scrapeRepositoryFiles(dir, fileExt string, parseFunc func(r io.Reader) []*PackageRepository) []*PackageRepository {
+	files, err := filepath.Glob(filepath.Join(filepath.Clean(dir), "*"+fileExt))
+	if err != nil {
+		return nil
+	}
+
+	toReturn := make([]*PackageRepository, 0, 1)
+
+	for _, fileName := range files {
+		repoL, err := readRepositoryFile(fileName, parseFunc)
+		if err != nil {
+			zap.L().Sugar().Debugw("failed to read repository file, skipping", zap.String("file", fileName), zap.Error(err))
+			// go to next repository file silently
+			continue
+		}
+
+		toReturn = append(toReturn, repoL...)
+	}
+
+	return toReturn
+}
+
+func readRepositoryFile[T any](fileName string, parseFunc func(r io.Reader) T) (T, error) {
+	cleanFileName := filepath.Clean(fileName)
+	l := zap.L().Sugar().With(zap.String("file", cleanFileName))
+
+	file, err := os.Open(cleanFileName)
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+	defer func(l *zap.SugaredLogger) {
+		fErr := file.Close()
+		if fErr != nil {
+			l.Errorw("failed to close file", zap.Error(fErr))
+		}
+	}(l)
+
+	return parseFunc(file), nil
+}
+
+// parseAptSourcesList parses APT sources file in one-line-style format.
+func parseAptSourcesList(r io.Reader) []*PackageRepository {
+	// Example:
+	// deb http://repo.percona.com/ps-80/apt jammy main
+	// deb [arch=amd64 signed-by=/usr/share/keyrings/percona.gpg] http://repo.percona.com/ps-80/apt jammy testing
+	// # deb-src http://repo.percona.com/ps-80/apt jammy main
+	toReturn := make([]*PackageRepository, 0, 1)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "deb ") {
+			// commented out, empty or source repository line
+			continue
+		}
+
+		// remove options part
+		if start := strings.Index(line, "["); start != -1 {
+			if end := strings.Index(line, "]"); end > start {
+				line = line[:start] + line[end+1:]
+			}
+		}
+
+		tokens := strings.Fields(line)
+		// deb <url> <suite> <component> [<component>...]
+		if len(tokens) < 4 {
+			continue
+		}
+
+		for _, component := range tokens[3:] {
+			if repo := parsePerconaRepositoryURL(tokens[1], component); repo != nil {
+				toReturn = append(toReturn, repo)
+			}
+		}
+	}
+
+	return toReturn
+}
+
+// parseAptSourcesDeb822 parses APT sources file in deb822-style format.
+func parseAptSourcesDeb822(r io.Reader) []*PackageRepository {
+	// Example:
+	// Types: deb
+	// URIs: http://repo.percona.com/ps-80/apt
+	// Suites: jammy
+	// Components: main
+	// Enabled: yes
+	toReturn := make([]*PackageRepository, 0, 1)
+
+	var (
+		types, uris, components []string
+		enabled                 = true
+	)
+
+	flushStanza := func() {
+		if enabled && slices.Contains(types, "deb") {
+			for _, uri := range uris {
+				for _, component := range components {
+					if repo := parsePerconaRepositoryURL(uri, component); repo != nil {
+						toReturn = append(toReturn, repo)
+					}
+				}
+			}
+		}
+
+		types, uris, components, enabled = nil, nil, nil, true
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			flushStanza()
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if !found || strings.HasPrefix(field, "#") {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "types":
+			types = strings.Fields(value)
+		case "uris":
+			uris = strings.Fields(value)
+		case "components":
+			components = strings.Fields(value)
+		case "enabled":
+			enabled = strings.TrimSpace(value) != "no"
+		}
+	}
+
+	flushStanza()
+
+	return toReturn
+}
+
+// yumRepoSection represents a repository defined in YUM/DNF repository file.
+type yumRepoSection struct {
+	id      string
+	baseURL string
+	enabled bool
+}
+
+// parseYumRepoFile parses YUM/DNF repository file in INI format.
+func parseYumRepoFile(r io.Reader) []*PackageRepository {
+	toReturn := make([]*PackageRepository, 0, 1)
+
+	for _, section := range parseYumRepoSections(r) {
+		if !section.enabled || len(section.baseURL) == 0 {
+			continue
+		}
+
+		// YUM repository URL has format:
+		// <scheme>://repo.percona.com/<name>/yum/<component>/...
+		if repo := parsePerconaRepositoryURL(section.baseURL, ""); repo != nil {
+			toReturn = append(toReturn, repo)
+		}
+	}
+
+	return toReturn
+}
+
+// parseYumRepoSections parses YUM/DNF repository file in INI format and returns all repositories defined in it.
+func parseYumRepoSections(r io.Reader) []yumRepoSection {
+	// Example:
+	// [ps-80-release-x86_64]
+	// name = Percona Server 8.0 release/x86_64 YUM repository
+	// baseurl = http://repo.percona.com/ps-80/yum/release/$releasever/RPMS/x86_64
+	// gpgcheck = 1
+	// enabled = 1
+	toReturn := make([]yumRepoSection, 0, 1)
+
+	var section *yumRepoSection
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if section != nil {
+				toReturn = append(toReturn, *section)
+			}
+
+			// repository is enabled by default.
+			section = &yumRepoSection{
+				id:      strings.TrimSpace(line[1 : len(line)-1]),
+				enabled: true,
+			}
+
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found || section == nil {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "baseurl":
+			// several URLs may be listed, the first one is used.
+			if urls := strings.Fields(value); len(urls) != 0 {
+				section.baseURL = urls[0]
+			}
+		case "enabled":
+			section.enabled = parseDnfBool(value, section.enabled)
+		}
+	}
+
+	if section != nil {
+		toReturn = append(toReturn, *section)
+	}
+
+	return toReturn
+}
+
+// parseDnfBool parses boolean option value of YUM/DNF repository file, which is 1/0, yes/no, true/false or on/off
+// in any case. Fallback value is returned if the value isn't recognized.
+func parseDnfBool(value string, fallback bool) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "yes", "true", "on":
+		return true
+	case "0", "no", "false", "off":
+		return false
+	default:
+		return fallback
+	}
+}
+
+// queryYumRepositoryURLs returns map of YUM/DNF repository ID to its base URL
+// for all repositories configured in repository files in reposDir.
+func queryYumRepositoryURLs(reposDir string) map[string]string {
+	files, err := filepath.Glob(filepath.Join(filepath.Clean(reposDir), "*.repo"))
+	if err != nil {
+		return nil
+	}
+
+	toReturn := make(map[string]string)
+
+	for _, fileName := range files {
+		sections, err := readRepositoryFile(fileName, parseYumRepoSections)
+		if err != nil {
+			zap.L().Sugar().Debugw("failed to read repository file, skipping", zap.String("file", fileName), zap.Error(err))
+			// go to next repository file silently
+			continue
+		}
+
+		for _, section := range sections {
+			if len(section.baseURL) != 0 {
+				toReturn[section.id] = section.baseURL
+			}
+		}
+	}
+
+	return toReturn
+}
+
+// parsePerconaRepositoryURL returns repository info if URL points to Percona repository, nil otherwise.
+// If component is empty, it is taken from YUM repository URL path.
+func parsePerconaRepositoryURL(repoAddr, component string) *PackageRepository {
+	repoURL, err := url.Parse(repoAddr)
+	if err != nil || repoURL.Host != perconaRepositoryHost {
+		return nil
+	}
+
+	pathTokens := strings.Split(strings.Trim(repoURL.Path, "/"), "/")
+	if len(pathTokens) == 0 || len(pathTokens[0]) == 0 {
+		return nil
+	}
+
+	if len(component) == 0 && len(pathTokens) >= 3 && pathTokens[1] == "yum" {
+		component = pathTokens[2]
+	}
+
+	// the same naming is used as for installed packages repository.
+	if component == "main" {
+		component = "release"
+	}
+
+	return &PackageRepository{
+		Name:      pathTokens[0],
+		Component: component,
+	}
+}
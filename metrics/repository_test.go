@@ -0,0 +1,183 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepositoryFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		parseFunc     func(r io.Reader) []*PackageRepository
+		content       string
+		expectedRepoL []*PackageRepository
+	}{
+		{
+			name:      "apt_list",
+			parseFunc: parseAptSourcesList,
+			content: `# Percona repository
+deb http://repo.percona.com/ps-80/apt jammy main
+deb-src http://repo.percona.com/ps-80/apt jammy main
+# deb http://repo.percona.com/ps-80/apt jammy testing
+deb [arch=amd64 signed-by=/usr/share/keyrings/percona.gpg] https://repo.percona.com/pbm/apt jammy main experimental
+deb http://archive.ubuntu.com/ubuntu jammy main
+`,
+			expectedRepoL: []*PackageRepository{
+				{Name: "ps-80", Component: "release"},
+				{Name: "pbm", Component: "release"},
+				{Name: "pbm", Component: "experimental"},
+			},
+		},
+		{
+			name:      "apt_deb822",
+			parseFunc: parseAptSourcesDeb822,
+			content: `Types: deb
+URIs: http://repo.percona.com/pdps-8.0/apt
+Suites: jammy
+Components: main
+
+Types: deb deb-src
+URIs: http://repo.percona.com/tools/apt
+Suites: jammy
+Components: testing
+Enabled: no
+
+Types: deb-src
+URIs: http://repo.percona.com/ppg-16/apt
+Suites: jammy
+Components: main
+`,
+			expectedRepoL: []*PackageRepository{
+				{Name: "pdps-8.0", Component: "release"},
+			},
+		},
+		{
+			name:      "yum_repo",
+			parseFunc: parseYumRepoFile,
+			content: `[ps-80-release-x86_64]
+name = Percona Server 8.0 release/x86_64 YUM repository
+baseurl = http://repo.percona.com/ps-80/yum/release/$releasever/RPMS/x86_64
+gpgcheck = 1
+enabled = 1
+
+[ps-80-release-sources]
+name = Percona Server 8.0 release/sources YUM repository
+baseurl = http://repo.percona.com/ps-80/yum/release/$releasever/SRPMS
+gpgcheck = 1
+enabled = 0
+
+[tools-testing-noarch]
+name = Percona Tools testing/noarch YUM repository
+baseurl = http://repo.percona.com/tools/yum/testing/$releasever/RPMS/noarch
+gpgcheck = 1
+
+[epel]
+baseurl = https://download.example/pub/epel/$releasever/Everything/$basearch/
+enabled = 1
+`,
+			expectedRepoL: []*PackageRepository{
+				{Name: "ps-80", Component: "release"},
+				{Name: "tools", Component: "testing"},
+			},
+		},
+		{
+			name:          "empty",
+			parseFunc:     parseYumRepoFile,
+			content:       "",
+			expectedRepoL: []*PackageRepository{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repoL := tt.parseFunc(strings.NewReader(tt.content))
+			require.Equal(t, tt.expectedRepoL, repoL)
+		})
+	}
+}
+
+func TestParseYumRepoSections(t *testing.T) {
+	t.Parallel()
+
+	content := `# comment
+[ps-80-release-x86_64]
+name = Percona Server 8.0 release/x86_64 YUM repository
+baseurl = http://repo.percona.com/ps-80/yum/release/$releasever/RPMS/x86_64
+enabled = 1
+
+[ps-80-release-sources]
+baseurl = http://repo.percona.com/ps-80/yum/release/$releasever/SRPMS http://mirror.example.com/ps-80/yum/release/$releasever/SRPMS
+enabled = 0
+
+[appstream]
+mirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch&repo=AppStream-$releasever
+
+[pxc-80-release-x86_64]
+baseurl = http://repo.percona.com/pxc-80/yum/release/$releasever/RPMS/x86_64
+enabled = False
+
+[pbm-release-x86_64]
+baseurl = http://repo.percona.com/pbm/yum/release/$releasever/RPMS/x86_64
+enabled=no
+
+[ppg-16-release-x86_64]
+baseurl = http://repo.percona.com/ppg-16/yum/release/$releasever/RPMS/x86_64
+enabled = YES
+`
+
+	expected := []yumRepoSection{
+		{
+			id:      "ps-80-release-x86_64",
+			baseURL: "http://repo.percona.com/ps-80/yum/release/$releasever/RPMS/x86_64",
+			enabled: true,
+		},
+		{
+			id:      "ps-80-release-sources",
+			baseURL: "http://repo.percona.com/ps-80/yum/release/$releasever/SRPMS",
+			enabled: false,
+		},
+		{
+			id:      "appstream",
+			enabled: true,
+		},
+		{
+			id:      "pxc-80-release-x86_64",
+			baseURL: "http://repo.percona.com/pxc-80/yum/release/$releasever/RPMS/x86_64",
+			enabled: false,
+		},
+		{
+			id:      "pbm-release-x86_64",
+			baseURL: "http://repo.percona.com/pbm/yum/release/$releasever/RPMS/x86_64",
+			enabled: false,
+		},
+		{
+			id:      "ppg-16-release-x86_64",
+			baseURL: "http://repo.percona.com/ppg-16/yum/release/$releasever/RPMS/x86_64",
+			enabled: true,
+		},
+	}
+
+	require.Equal(t, expected, parseYumRepoSections(strings.NewReader(content)))
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// rejectedFileExt is appended to the name of metrics file rejected by schema validation,
+// so it is moved aside and not processed again.
+const rejectedFileExt = ".rejected"
+
+var errMetricsFileRejected = errors.New("metrics file doesn't match schema")
+
+// getSchemaFileName returns name of JSON Schema file for the product family.
+// Example: 'ps.json' for PRODUCT_FAMILY_PS, 'postgresql.json' for PRODUCT_FAMILY_POSTGRESQL.
+func getSchemaFileName(productFamily platformReporter.ProductFamily) string {
+	return strings.ToLower(strings.TrimPrefix(productFamily.String(), "PRODUCT_FAMILY_")) + ".json"
+}
+
+// loadMetricsSchema loads and compiles JSON Schema for the product family from schemaDir.
+// Schema is optional, so nil is returned without error if schema file is absent.
+func loadMetricsSchema(schemaDir string, productFamily platformReporter.ProductFamily) (*jsonschema.Schema, error) {
+	if len(schemaDir) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	schemaFile := filepath.Join(filepath.Clean(schemaDir), getSchemaFileName(productFamily))
+	if _, err := os.Stat(schemaFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil //nolint:nilnil
+		}
+
+		return nil, err
+	}
+
+	schema, err := jsonschema.NewCompiler().Compile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't compile schema file %s: %w", schemaFile, err)
+	}
+
+	return schema, nil
+}
+
+// validateMetrics validates raw metrics file content against schema.
+func validateMetrics(schema *jsonschema.Schema, rawMetrics map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+
+	err := schema.Validate(rawMetrics)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errMetricsFileRejected, err)
+	}
+
+	return nil
+}
+
+// rejectMetricsFile moves metrics file aside, so it is not processed again.
+func rejectMetricsFile(fileName string) error {
+	err := os.Rename(fileName, fileName+rejectedFileExt)
+	if err != nil {
+		return err
+	}
+
+	return moveChecksumFile(fileName, fileName+rejectedFileExt)
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSchemaFileName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "ps.json", getSchemaFileName(platformReporter.ProductFamily_PRODUCT_FAMILY_PS))
+	require.Equal(t, "postgresql.json", getSchemaFileName(platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL))
+}
+
+func TestProcessMetricsDirectorySchema(t *testing.T) {
+	t.Parallel()
+
+	const psSchema = `{
+"type": "object",
+"properties": {
+  "db_instance_id": {"type": "string", "minLength": 1},
+  "uptime": {"type": "string", "pattern": "^[0-9]+$"}
+},
+"required": ["db_instance_id"]
+}`
+
+	testCases := []struct {
+		name             string
+		schema           string
+		fileContent      string
+		expectedRejected bool
+	}{
+		{
+			name:        "no_schema",
+			fileContent: `{"uptime": "abc"}`,
+		},
+		{
+			name:        "valid_file",
+			schema:      psSchema,
+			fileContent: `{"db_instance_id": "1bed5f0d-cc3a-11ee-bd8a-c84bd64e0288", "uptime": "112"}`,
+		},
+		{
+			name:             "missing_required_metric",
+			schema:           psSchema,
+			fileContent:      `{"uptime": "112"}`,
+			expectedRejected: true,
+		},
+		{
+			name:             "invalid_metric_value",
+			schema:           psSchema,
+			fileContent:      `{"db_instance_id": "1bed5f0d-cc3a-11ee-bd8a-c84bd64e0288", "uptime": "abc"}`,
+			expectedRejected: true,
+		},
+		{
+			name:        "invalid_schema",
+			schema:      `{"type": "unknown_type"}`,
+			fileContent: `{"uptime": "112"}`,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schemaDir := t.TempDir()
+			if len(tt.schema) != 0 {
+				require.NoError(t, os.WriteFile(filepath.Join(schemaDir, "ps.json"), []byte(tt.schema), 0o600))
+			}
+
+			metricsDir := t.TempDir()
+			metricsFile := filepath.Join(metricsDir, "1708026156-d7664a58-d855-45c9-b017-50678cf620bb.json")
+			require.NoError(t, os.WriteFile(metricsFile, []byte(tt.fileContent), 0o600))
+
+			files, err := processMetricsDirectory(t.Context(), metricsDir, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, ProcessOpts{SchemaDir: schemaDir})
+			require.NoError(t, err)
+
+			if tt.expectedRejected {
+				require.Empty(t, files)
+				// file is moved aside.
+				require.NoFileExists(t, metricsFile)
+				require.FileExists(t, metricsFile+rejectedFileExt)
+
+				return
+			}
+
+			require.Len(t, files, 1)
+			require.FileExists(t, metricsFile)
+		})
+	}
+}
+
+func TestParseMetricsFileSchema(t *testing.T) {
+	t.Parallel()
+
+	schemaDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(schemaDir, "postgresql.json"), []byte(`{"required": ["db_instance_id"]}`), 0o600))
+
+	metricsDir := t.TempDir()
+	validFile := filepath.Join(metricsDir, "1708026156-a.json")
+	require.NoError(t, os.WriteFile(validFile, []byte(`{"db_instance_id": "1bed5f0d-cc3a-11ee-bd8a-c84bd64e0288"}`), 0o600))
+
+	files, err := ParseMetricsFile(t.Context(), validFile, platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL, ProcessOpts{SchemaDir: schemaDir})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL, files[0].ProductFamily)
+
+	// rejected file is reported, but left in place.
+	rejectedFile := filepath.Join(metricsDir, "1708026156-b.json")
+	require.NoError(t, os.WriteFile(rejectedFile, []byte(`{"uptime": "112"}`), 0o600))
+
+	_, err = ParseMetricsFile(t.Context(), rejectedFile, platformReporter.ProductFamily_PRODUCT_FAMILY_POSTGRESQL, ProcessOpts{SchemaDir: schemaDir})
+	require.ErrorIs(t, err, errMetricsFileRejected)
+	require.FileExists(t, rejectedFile)
+}
@@ -0,0 +1,236 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	procRootDefault = "/proc"
+	// tcpListenState is the state of listening sockets in /proc/<pid>/net/tcp files.
+	tcpListenState = "0A"
+)
+
+// serviceProcessNames are names of Percona server processes reported as running services.
+var serviceProcessNames = []string{"mysqld", "mongod", "mongos", "postgres", "pbm-agent", "proxysql"}
+
+// Service represents a running Percona server process and TCP ports it listens on.
+type Service struct {
+	Name string `json:"name"`
+	// Ports are empty if sockets of the process are not accessible (e.g. it is run by another user).
+	Ports []int `json:"ports,omitempty"`
+}
+
+// serviceProcess represents a Percona server process found in procfs.
+type serviceProcess struct {
+	name  string
+	ppid  int
+	ports []int
+}
+
+// ScrapeRunningServices returns list of running Percona server processes and their listening TCP ports.
+// Processes forked by the process of the same name (e.g. PostgreSQL backends) are reported as one service.
+func ScrapeRunningServices() []*Service {
+	return scrapeRunningServices(procRootDefault)
+}
+
+func scrapeRunningServices(procRoot string) []*Service {
+	l := zap.L().Sugar()
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		l.Debugw("failed to read procfs, skip scraping running services", zap.String("path", procRoot), zap.Error(err))
+		// not critical error, services are just not reported
+		return nil
+	}
+
+	processes := make(map[int]*serviceProcess)
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		pidDir := filepath.Join(procRoot, entry.Name())
+
+		name, ppid, err := readProcessStat(filepath.Join(pidDir, "stat"))
+		if err != nil {
+			// process may exit while procfs is scanned.
+			l.Debugw("failed to read process status, skip it", zap.Int("pid", pid), zap.Error(err))
+			continue
+		}
+
+		if !slices.Contains(serviceProcessNames, name) {
+			continue
+		}
+
+		processes[pid] = &serviceProcess{name: name, ppid: ppid, ports: readProcessListeningPorts(pidDir)}
+	}
+
+	// ports of forked processes are attributed to the topmost process of the same name.
+	roots := make(map[int]*Service)
+
+	for pid, p := range processes {
+		rootPID := pid
+		for {
+			ppid := processes[rootPID].ppid
+
+			parent, ok := processes[ppid]
+			if !ok || parent.name != p.name || ppid == rootPID {
+				break
+			}
+
+			rootPID = ppid
+		}
+
+		s, ok := roots[rootPID]
+		if !ok {
+			s = &Service{Name: p.name}
+			roots[rootPID] = s
+		}
+
+		s.Ports = append(s.Ports, p.ports...)
+	}
+
+	toReturn := make([]*Service, 0, len(roots))
+	for _, s := range roots {
+		slices.Sort(s.Ports)
+		s.Ports = slices.Compact(s.Ports)
+		toReturn = append(toReturn, s)
+	}
+
+	slices.SortFunc(toReturn, func(a, b *Service) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), slices.Compare(a.Ports, b.Ports))
+	})
+
+	return toReturn
+}
+
+// readProcessStat returns name and parent process ID from /proc/<pid>/stat file.
+func readProcessStat(fileName string) (string, int, error) {
+	data, err := os.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return "", 0, err
+	}
+
+	// process name is enclosed in parentheses and may contain spaces and parentheses itself.
+	stat := string(data)
+
+	start, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return "", 0, strconv.ErrSyntax
+	}
+
+	// fields after name: state, ppid, ...
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return "", 0, strconv.ErrSyntax
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, err
+	}
+
+	return stat[start+1 : end], ppid, nil
+}
+
+// readProcessListeningPorts returns TCP ports the process with procfs directory pidDir listens on.
+// Sockets are looked up in network namespace of the process, so ports of containerized processes are found as well.
+func readProcessListeningPorts(pidDir string) []int {
+	fds, err := os.ReadDir(filepath.Join(pidDir, "fd"))
+	if err != nil {
+		zap.L().Sugar().Debugw("failed to read process file descriptors, its ports are not reported",
+			zap.String("path", pidDir), zap.Error(err))
+
+		return nil
+	}
+
+	socketInodes := make(map[string]struct{})
+
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(pidDir, "fd", fd.Name()))
+		if err != nil {
+			continue
+		}
+
+		if inode, ok := strings.CutPrefix(target, "socket:["); ok {
+			socketInodes[strings.TrimSuffix(inode, "]")] = struct{}{}
+		}
+	}
+
+	if len(socketInodes) == 0 {
+		return nil
+	}
+
+	var ports []int
+
+	for _, netFile := range []string{"tcp", "tcp6"} {
+		f, err := os.Open(filepath.Join(pidDir, "net", netFile))
+		if err != nil {
+			continue
+		}
+
+		for inode, port := range parseListeningSockets(f) {
+			if _, ok := socketInodes[inode]; ok {
+				ports = append(ports, port)
+			}
+		}
+
+		_ = f.Close()
+	}
+
+	return ports
+}
+
+// parseListeningSockets parses /proc/<pid>/net/tcp (or tcp6) file and returns ports of listening sockets by inode.
+func parseListeningSockets(r io.Reader) map[string]int {
+	sockets := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListenState {
+			continue
+		}
+
+		_, portHex, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+
+		port, err := strconv.ParseUint(portHex, 16, 16)
+		if err != nil {
+			continue
+		}
+
+		sockets[fields[9]] = int(port)
+	}
+
+	return sockets
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testProcess is a process written into fake procfs.
+type testProcess struct {
+	pid     int
+	ppid    int
+	name    string
+	inodes  []string
+	netTCP  string
+	netTCP6 string
+}
+
+func writeTestProcfs(t *testing.T, procRoot string, processes ...testProcess) {
+	t.Helper()
+
+	for _, p := range processes {
+		pidDir := filepath.Join(procRoot, strconv.Itoa(p.pid))
+		require.NoError(t, os.MkdirAll(filepath.Join(pidDir, "fd"), 0o700))
+		require.NoError(t, os.MkdirAll(filepath.Join(pidDir, "net"), 0o700))
+
+		stat := strconv.Itoa(p.pid) + " (" + p.name + ") S " + strconv.Itoa(p.ppid) + " 1 1 0 -1 4194560"
+		require.NoError(t, os.WriteFile(filepath.Join(pidDir, "stat"), []byte(stat), 0o600))
+
+		for i, inode := range p.inodes {
+			require.NoError(t, os.Symlink("socket:["+inode+"]", filepath.Join(pidDir, "fd", strconv.Itoa(i+3))))
+		}
+
+		require.NoError(t, os.Symlink("/dev/null", filepath.Join(pidDir, "fd", "0")))
+		require.NoError(t, os.WriteFile(filepath.Join(pidDir, "net", "tcp"), []byte(p.netTCP), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(pidDir, "net", "tcp6"), []byte(p.netTCP6), 0o600))
+	}
+}
+
+func TestScrapeRunningServices(t *testing.T) {
+	t.Parallel()
+
+	const (
+		netTCPHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+		netTCP       = netTCPHeader +
+			"   0: 00000000:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000    27        0 1001 1 0000000000000000 100 0 0 10 0\n" +
+			"   1: 0100007F:1538 00000000:0000 0A 00000000:00000000 00:00000000 00000000    26        0 2001 1 0000000000000000 100 0 0 10 0\n" +
+			"   2: 0100007F:1538 0100007F:D431 01 00000000:00000000 00:00000000 00000000    26        0 2002 1 0000000000000000 20 4 30 10 -1\n" +
+			"   3: 00000000:1A85 00000000:0000 0A 00000000:00000000 00:00000000 00000000   997        0 3001 1 0000000000000000 100 0 0 10 0\n" +
+			"   4: 00000000:1A86 00000000:0000 0A 00000000:00000000 00:00000000 00000000   997        0 3002 1 0000000000000000 100 0 0 10 0\n"
+		netTCP6 = netTCPHeader +
+			"   0: 00000000000000000000000000000000:8124 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000    27        0 1002 1 0000000000000000 100 0 0 10 0\n"
+	)
+
+	procRoot := t.TempDir()
+	writeTestProcfs(t, procRoot,
+		testProcess{pid: 1, ppid: 0, name: "systemd", netTCP: netTCP, netTCP6: netTCP6},
+		testProcess{pid: 100, ppid: 1, name: "mysqld", inodes: []string{"1001", "1002"}, netTCP: netTCP, netTCP6: netTCP6},
+		// PostgreSQL backends and forked processes are reported within postmaster service.
+		testProcess{pid: 200, ppid: 1, name: "postgres", inodes: []string{"2001"}, netTCP: netTCP, netTCP6: netTCP6},
+		testProcess{pid: 201, ppid: 200, name: "postgres", inodes: []string{"2002"}, netTCP: netTCP, netTCP6: netTCP6},
+		testProcess{pid: 202, ppid: 200, name: "postgres", netTCP: netTCP, netTCP6: netTCP6},
+		// ProxySQL child process holds listening sockets.
+		testProcess{pid: 300, ppid: 1, name: "proxysql", netTCP: netTCP, netTCP6: netTCP6},
+		testProcess{pid: 301, ppid: 300, name: "proxysql", inodes: []string{"3001", "3002"}, netTCP: netTCP, netTCP6: netTCP6},
+		// sockets of processes of other users are not accessible.
+		testProcess{pid: 400, ppid: 1, name: "mongod", netTCP: netTCP, netTCP6: netTCP6},
+		testProcess{pid: 500, ppid: 1, name: "sshd", inodes: []string{"1001"}, netTCP: netTCP, netTCP6: netTCP6},
+	)
+
+	// not a process directory.
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "net"), 0o700))
+	// process exited while procfs is scanned.
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "600"), 0o700))
+
+	require.Equal(t, []*Service{
+		{Name: "mongod"},
+		{Name: "mysqld", Ports: []int{3306, 33060}},
+		{Name: "postgres", Ports: []int{5432}},
+		{Name: "proxysql", Ports: []int{6789, 6790}},
+	}, scrapeRunningServices(procRoot))
+
+	require.Nil(t, scrapeRunningServices(filepath.Join(procRoot, "absent")))
+}
+
+func TestReadProcessStat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		content      string
+		expectedName string
+		expectedPPID int
+		expectedErr  bool
+	}{
+		{
+			name:         "valid",
+			content:      "1234 (mysqld) S 1 1234 1234 0 -1 4194560 12345",
+			expectedName: "mysqld",
+			expectedPPID: 1,
+		},
+		{
+			name:         "name_with_spaces_and_parentheses",
+			content:      "1234 (my (odd) name) R 42 1234 1234 0 -1",
+			expectedName: "my (odd) name",
+			expectedPPID: 42,
+		},
+		{
+			name:        "truncated",
+			content:     "1234 (mysqld) S",
+			expectedErr: true,
+		},
+		{
+			name:        "no_name",
+			content:     "1234 mysqld S 1",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileName := filepath.Join(t.TempDir(), "stat")
+			require.NoError(t, os.WriteFile(fileName, []byte(tt.content), 0o600))
+
+			name, ppid, err := readProcessStat(fileName)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedName, name)
+			require.Equal(t, tt.expectedPPID, ppid)
+		})
+	}
+}
+
+func TestParseListeningSockets(t *testing.T) {
+	t.Parallel()
+
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000    27        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0CEA 0100007F:D431 01 00000000:00000000 00:00000000 00000000    27        0 1003 1 0000000000000000 20 4 30 10 -1
+   2: 00000000:ZZZZ 00000000:0000 0A 00000000:00000000 00:00000000 00000000    27        0 1004 1 0000000000000000 100 0 0 10 0
+   3: truncated
+`
+
+	require.Equal(t, map[string]int{"1001": 3306}, parseListeningSockets(strings.NewReader(content)))
+}
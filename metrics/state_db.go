@@ -0,0 +1,228 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	stateDBFilePermissions = 0o600
+	// stateDBOpenTimeout limits waiting for the lock of state database held by another Telemetry Agent process.
+	stateDBOpenTimeout = 5 * time.Second
+)
+
+// stateDBProcessedBucket is the bucket of processed metrics files records keyed by file name.
+var stateDBProcessedBucket = []byte("processed")
+
+// processedFile is the record of metrics file processed without removing it.
+type processedFile struct {
+	// SHA256 is the hex encoded checksum of the file content, the file is processed again once its content changes.
+	SHA256      string    `json:"sha256"`
+	ProcessedAt time.Time `json:"processed_at"`
+	// Size and ModTime of the file are compared first, so the checksum is computed only if they are changed.
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time,omitzero"`
+}
+
+// StateDB records processed Pillars metrics files, so files which can't be removed (e.g. on read-only or shared
+// volumes) are skipped on later iterations instead of being sent again. It is safe for concurrent use.
+// Methods of nil StateDB do nothing and report all files as not processed.
+type StateDB struct {
+	db *bolt.DB
+}
+
+// OpenStateDB opens state database file, it is created if it is absent.
+func OpenStateDB(fileName string) (*StateDB, error) {
+	db, err := bolt.Open(filepath.Clean(fileName), stateDBFilePermissions, &bolt.Options{Timeout: stateDBOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("can't open state database %s: %w", fileName, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(stateDBProcessedBucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("can't initialize state database %s: %w", fileName, err), db.Close())
+	}
+
+	return &StateDB{db: db}, nil
+}
+
+// Close closes state database file.
+func (s *StateDB) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+// IsProcessed returns true if metrics file with the same name and content is already processed.
+func (s *StateDB) IsProcessed(fileName string) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	var record processedFile
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(stateDBProcessedBucket).Get([]byte(fileName))
+		if value == nil {
+			return nil
+		}
+
+		return json.Unmarshal(value, &record)
+	})
+	if err != nil {
+		return false, fmt.Errorf("can't read state of %s: %w", fileName, err)
+	}
+
+	if record.SHA256 == "" {
+		return false, nil
+	}
+
+	st, err := os.Stat(fileName)
+	if err != nil {
+		return false, err
+	}
+
+	if st.Size() == record.Size && st.ModTime().Equal(record.ModTime) {
+		return true, nil
+	}
+
+	checksum, err := fileChecksum(fileName)
+	if err != nil {
+		return false, err
+	}
+
+	return checksum == record.SHA256, nil
+}
+
+// MarkProcessed records metrics files as processed along with checksums of their current content.
+func (s *StateDB) MarkProcessed(fileNames ...string) error {
+	if s == nil {
+		return nil
+	}
+
+	records := make(map[string][]byte, len(fileNames))
+
+	for _, fileName := range fileNames {
+		st, err := os.Stat(fileName)
+		if err != nil {
+			return err
+		}
+
+		checksum, err := fileChecksum(fileName)
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(processedFile{
+			SHA256:      checksum,
+			ProcessedAt: time.Now().UTC(),
+			Size:        st.Size(),
+			ModTime:     st.ModTime().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		records[fileName] = value
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(stateDBProcessedBucket)
+		for fileName, value := range records {
+			if err := bucket.Put([]byte(fileName), value); err != nil {
+				return fmt.Errorf("can't record state of %s: %w", fileName, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Prune forgets processed metrics files which no longer exist, so the database doesn't grow
+// as Pillars replace their files. It returns the number of forgotten files.
+func (s *StateDB) Prune() (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+
+	var pruned int
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(stateDBProcessedBucket)
+
+		// keys are deleted after iteration, as deleting at cursor skips the next key.
+		var staleKeys [][]byte
+
+		err := bucket.ForEach(func(key, _ []byte) error {
+			if _, err := os.Stat(string(key)); errors.Is(err, os.ErrNotExist) {
+				staleKeys = append(staleKeys, slices.Clone(key))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range staleKeys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		pruned = len(staleKeys)
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("can't prune state database: %w", err)
+	}
+
+	return pruned, nil
+}
+
+// fileChecksum returns hex encoded SHA-256 checksum of file content.
+func fileChecksum(fileName string) (string, error) {
+	file, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("can't read %s: %w", fileName, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateDB(t *testing.T) {
+	t.Parallel()
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		dbFile := filepath.Join(dir, "state.db")
+		file1 := filepath.Join(dir, "1.json")
+		file2 := filepath.Join(dir, "2.json")
+		require.NoError(t, os.WriteFile(file1, []byte(`{"uptime": "1"}`), 0o600))
+		require.NoError(t, os.WriteFile(file2, []byte(`{"uptime": "2"}`), 0o600))
+
+		s, err := OpenStateDB(dbFile)
+		require.NoError(t, err)
+
+		processed, err := s.IsProcessed(file1)
+		require.NoError(t, err)
+		require.False(t, processed)
+
+		require.NoError(t, s.MarkProcessed(file1, file2))
+
+		processed, err = s.IsProcessed(file1)
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		// records are kept after reopening.
+		require.NoError(t, s.Close())
+		s, err = OpenStateDB(dbFile)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, s.Close()) })
+
+		processed, err = s.IsProcessed(file2)
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		// file with changed content is processed again.
+		require.NoError(t, os.WriteFile(file2, []byte(`{"uptime": "3"}`), 0o600))
+		modTime := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(file2, modTime, modTime))
+		processed, err = s.IsProcessed(file2)
+		require.NoError(t, err)
+		require.False(t, processed)
+
+		// file with the same content is still processed after its modification time is changed.
+		require.NoError(t, s.MarkProcessed(file2))
+		require.NoError(t, os.Chtimes(file2, modTime.Add(time.Minute), modTime.Add(time.Minute)))
+		processed, err = s.IsProcessed(file2)
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		// records of removed files are forgotten, including consecutive ones.
+		require.NoError(t, os.Remove(file1))
+		require.NoError(t, os.Remove(file2))
+		pruned, err := s.Prune()
+		require.NoError(t, err)
+		require.Equal(t, 2, pruned)
+
+		require.NoError(t, os.WriteFile(file1, []byte(`{"uptime": "1"}`), 0o600))
+		processed, err = s.IsProcessed(file1)
+		require.NoError(t, err)
+		require.False(t, processed)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+
+		var s *StateDB
+		require.NoError(t, s.MarkProcessed("1.json"))
+
+		processed, err := s.IsProcessed("1.json")
+		require.NoError(t, err)
+		require.False(t, processed)
+
+		pruned, err := s.Prune()
+		require.NoError(t, err)
+		require.Zero(t, pruned)
+		require.NoError(t, s.Close())
+	})
+}
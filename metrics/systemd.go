@@ -0,0 +1,163 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+// systemdUnitPatterns are patterns of Percona services systemd unit names.
+var systemdUnitPatterns = []string{
+	"mysql.service", "mysqld.service", "mysql@*.service", "mysqlrouter.service",
+	"mongod.service", "mongos.service", "pbm-agent.service",
+	"postgresql*.service", "patroni.service", "pgbouncer.service",
+	"proxysql.service", "pmm-agent.service", "percona-*.service",
+}
+
+// systemdUnitProperties are properties of systemd units queried with 'systemctl show'.
+var systemdUnitProperties = []string{"Id", "LoadState", "ActiveState", "SubState", "UnitFileState", "NRestarts"}
+
+// SystemdUnit represents state of systemd unit of Percona service.
+type SystemdUnit struct {
+	Name string `json:"name"`
+	// ActiveState is e.g. "active", "inactive" or "failed", SubState details it, e.g. "running" or "dead".
+	ActiveState string `json:"active_state"`
+	SubState    string `json:"sub_state"`
+	// UnitFileState is e.g. "enabled", "disabled" or "static", empty for instances of template units.
+	UnitFileState string `json:"unit_file_state,omitempty"`
+	// Restarts is the number of automatic restarts of the service since it was loaded.
+	Restarts int `json:"restarts"`
+}
+
+// ScrapeSystemdUnits returns states of installed and loaded systemd units of Percona services.
+// Nothing is returned if systemd is not available on the host.
+func ScrapeSystemdUnits(ctx context.Context, cmdTimeout time.Duration) []*SystemdUnit {
+	l := logger.FromContext(ctx).Sugar()
+
+	systemctlPath, err := lookPath(ctx, "systemctl")
+	if err != nil {
+		l.Debugw("systemctl binary is not found, skip scraping systemd units", zap.Error(err))
+		// not critical error, units are just not reported
+		return nil
+	}
+
+	// unit files cover installed but not loaded units, loaded units cover instances of template units.
+	listUnitFiles := append([]string{systemctlPath, "list-unit-files", "--type=service", "--no-legend", "--no-pager"},
+		systemdUnitPatterns...)
+	listUnits := append([]string{systemctlPath, "list-units", "--all", "--type=service", "--plain", "--no-legend", "--no-pager"},
+		systemdUnitPatterns...)
+
+	var unitNames []string
+
+	for _, args := range [][]string{listUnitFiles, listUnits} {
+		// listing fails if no unit matches the patterns, so units found by the other listing are queried anyway.
+		outputB, _ := runSystemctl(ctx, args, cmdTimeout)
+		unitNames = append(unitNames, parseSystemdUnitNames(outputB)...)
+	}
+
+	slices.Sort(unitNames)
+	unitNames = slices.Compact(unitNames)
+
+	if len(unitNames) == 0 {
+		return nil
+	}
+
+	show := append([]string{systemctlPath, "show", "--no-pager", "--property=" + strings.Join(systemdUnitProperties, ",")},
+		unitNames...)
+
+	outputB, err := runSystemctl(ctx, show, cmdTimeout)
+	if err != nil {
+		return nil
+	}
+
+	return parseSystemdShowOutput(outputB)
+}
+
+func runSystemctl(ctx context.Context, args []string, cmdTimeout time.Duration) ([]byte, error) {
+	logger.FromContext(ctx).Sugar().Debugw("executing command", zap.String("cmd", strings.Join(args, " ")))
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cmdTimeout)
+	defer cancel()
+
+	outputB, err := runCommand(cmdCtx, args)
+	if err != nil {
+		// systemctl fails if the host is not booted with systemd (e.g. in container).
+		logger.FromContext(ctx).Sugar().Debugw("failed to query systemd units", zap.ByteString("output", outputB), zap.Error(err))
+	}
+
+	return outputB, err
+}
+
+// parseSystemdUnitNames parses output of 'systemctl list-unit-files' or 'systemctl list-units --plain'
+// and returns unit names, template units are skipped as they have no state.
+func parseSystemdUnitNames(output []byte) []string {
+	var unitNames []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasSuffix(fields[0], ".service") || strings.HasSuffix(fields[0], "@.service") {
+			continue
+		}
+
+		unitNames = append(unitNames, fields[0])
+	}
+
+	return unitNames
+}
+
+// parseSystemdShowOutput parses output of 'systemctl show' with properties of several units separated
+// by empty lines. Units which are not found are skipped.
+func parseSystemdShowOutput(output []byte) []*SystemdUnit {
+	toReturn := make([]*SystemdUnit, 0, 1)
+
+	for block := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n\n") {
+		properties := make(map[string]string)
+
+		for line := range strings.SplitSeq(block, "\n") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+				properties[key] = value
+			}
+		}
+
+		if properties["Id"] == "" || properties["LoadState"] == "not-found" {
+			continue
+		}
+
+		// NRestarts is not reported by systemd older than 235.
+		restarts, _ := strconv.Atoi(properties["NRestarts"])
+
+		toReturn = append(toReturn, &SystemdUnit{
+			Name:          properties["Id"],
+			ActiveState:   properties["ActiveState"],
+			SubState:      properties["SubState"],
+			UnitFileState: properties["UnitFileState"],
+			Restarts:      restarts,
+		})
+	}
+
+	return toReturn
+}
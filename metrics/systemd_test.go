@@ -0,0 +1,103 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSystemdUnitNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		output        string
+		expectedNames []string
+	}{
+		{
+			name: "list_unit_files",
+			output: `mysql.service                 enabled  enabled
+mysql@.service                disabled enabled
+percona-telemetry-agent.service enabled enabled
+`,
+			expectedNames: []string{"mysql.service", "percona-telemetry-agent.service"},
+		},
+		{
+			name: "list_units",
+			output: `mysql@bootstrap.service loaded inactive dead Percona XtraDB Cluster with config /etc/sysconfig/mysql.bootstrap
+postgresql-16.service   loaded active   running PostgreSQL 16 database server
+`,
+			expectedNames: []string{"mysql@bootstrap.service", "postgresql-16.service"},
+		},
+		{
+			name:   "not_booted_with_systemd",
+			output: "System has not been booted with systemd as init system (PID 1). Can't operate.\n",
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expectedNames, parseSystemdUnitNames([]byte(tt.output)))
+		})
+	}
+}
+
+func TestParseSystemdShowOutput(t *testing.T) {
+	t.Parallel()
+
+	output := `Id=mysql.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+NRestarts=2
+
+Id=mysql@bootstrap.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+UnitFileState=
+NRestarts=0
+
+Id=absent.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+UnitFileState=
+
+Id=mongod.service
+LoadState=loaded
+ActiveState=inactive
+SubState=dead
+UnitFileState=disabled
+`
+
+	require.Equal(t, []*SystemdUnit{
+		{Name: "mysql.service", ActiveState: "active", SubState: "running", UnitFileState: "enabled", Restarts: 2},
+		{Name: "mysql@bootstrap.service", ActiveState: "failed", SubState: "failed"},
+		// NRestarts is not reported by older systemd.
+		{Name: "mongod.service", ActiveState: "inactive", SubState: "dead", UnitFileState: "disabled"},
+	}, parseSystemdShowOutput([]byte(output)))
+
+	require.Empty(t, parseSystemdShowOutput(nil))
+}
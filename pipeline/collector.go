@@ -0,0 +1,305 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// Names of host metrics collectors which may be disabled by remote configuration.
+const (
+	CollectorPackages     = "packages"
+	CollectorContainers   = "containers"
+	CollectorRepositories = "repositories"
+	CollectorServices     = "services"
+	CollectorSystemd      = "systemd"
+)
+
+// CollectorNames returns names of all host metrics collectors which may be disabled.
+func CollectorNames() []string {
+	return []string{CollectorPackages, CollectorContainers, CollectorRepositories, CollectorServices, CollectorSystemd}
+}
+
+// CollectorOverrides adjust host metrics collecting on top of Telemetry Agent configuration.
+type CollectorOverrides struct {
+	// DisabledCollectors are names of host metrics collectors which are skipped.
+	DisabledCollectors []string
+	// ExtraPackagePatterns are package name patterns queried in addition to the built-in ones.
+	ExtraPackagePatterns []string
+}
+
+// LocalCollector collects metrics files of Pillars configured in Telemetry Agent configuration and local host metrics.
+type LocalCollector struct {
+	c config.Config
+	// parseFailures counts failed parsing attempts between calls for quarantining corrupt metrics files.
+	parseFailures *metrics.ParseFailures
+	errorCounters *metrics.ErrorCounters
+	// stateDB records metrics files processed without removing them, so they are not collected again.
+	stateDB *metrics.StateDB
+
+	// overrides may be replaced from the main loop while watch iteration is collecting metrics.
+	overridesMu sync.Mutex
+	overrides   CollectorOverrides
+}
+
+// NewLocalCollector creates new LocalCollector counting failed to be read or parsed metrics files
+// in errorCounters and skipping metrics files recorded as processed in stateDB, they may be nil.
+func NewLocalCollector(c config.Config, errorCounters *metrics.ErrorCounters, stateDB *metrics.StateDB) *LocalCollector {
+	return &LocalCollector{
+		c:             c,
+		parseFailures: metrics.NewParseFailures(),
+		errorCounters: errorCounters,
+		stateDB:       stateDB,
+	}
+}
+
+// SetOverrides replaces overrides of host metrics collecting, they are used since the next collecting.
+func (lc *LocalCollector) SetOverrides(o CollectorOverrides) {
+	lc.overridesMu.Lock()
+	defer lc.overridesMu.Unlock()
+
+	lc.overrides = o
+}
+
+func (lc *LocalCollector) getOverrides() CollectorOverrides {
+	lc.overridesMu.Lock()
+	defer lc.overridesMu.Unlock()
+
+	return lc.overrides
+}
+
+// CollectPillarsMetrics parses metrics files of all Pillars taken into batch, all files are parsed if it is nil.
+func (lc *LocalCollector) CollectPillarsMetrics(ctx context.Context, batch *metrics.Batch) []*metrics.File {
+	l := logger.FromContext(ctx).Sugar()
+
+	pillarMetrics := make([]*metrics.File, 0, 1)
+
+	for _, pillar := range lc.c.Telemetry.Pillars {
+		l.Infow("processing "+pillar.Name+" metrics", zap.String("directory", pillar.Path))
+
+		_, span := startSpan(ctx, "collect "+pillar.Name+" metrics", trace.WithAttributes(attribute.String("directory", pillar.Path)))
+
+		pMetrics, err := metrics.ProcessPillarMetrics(ctx, pillar.Path, pillar.ProductFamily, metrics.ProcessOpts{
+			FlattenNested: lc.c.Telemetry.FlattenNested,
+			SchemaDir:     lc.c.Telemetry.SchemasPath,
+			QuarantineDir: lc.c.Telemetry.QuarantinePath,
+			ParseFailures: lc.parseFailures,
+			MaxAge:        time.Duration(lc.c.Telemetry.MaxMetricsAge) * time.Second,
+			// Pillars metrics files are left untouched when telemetry is only collected and printed
+			// or when processed files are recorded in state database instead of being removed.
+			ReadOnly:      lc.c.ReadOnly() || lc.stateDB != nil,
+			ErrorCounters: lc.errorCounters,
+			Batch:         batch,
+			StateDB:       lc.stateDB,
+		})
+		span.SetAttributes(attribute.Int("files", len(pMetrics)))
+		EndSpan(span, err)
+
+		if err != nil {
+			lc.errorCounters.AddError(err)
+			l.Warnw("failed to process "+pillar.Name+" metrics", zap.Error(err))
+			continue
+		}
+
+		pillarMetrics = append(pillarMetrics, pMetrics...)
+	}
+
+	return pillarMetrics
+}
+
+// CollectHostMetrics scrapes host metrics, installed Percona packages, running Percona containers
+// and enabled Percona repositories.
+func (lc *LocalCollector) CollectHostMetrics(ctx context.Context) (string, *metrics.File) {
+	l := logger.FromContext(ctx).Sugar()
+
+	l.Info("scraping host metrics")
+
+	ctx, span := startSpan(ctx, "collect host metrics")
+	defer span.End()
+
+	hostOpts := metrics.HostScrapeOpts{
+		CmdTimeout:   time.Duration(lc.c.Telemetry.CmdTimeout) * time.Second,
+		ReadOnly:     lc.c.ReadOnly(),
+		InstanceFile: lc.c.Telemetry.InstanceFile,
+	}
+	if lc.c.Kubernetes.Enabled {
+		// pod metadata is re-read every time as labels may be changed while pod is running.
+		pod := metrics.ReadPodInfo(lc.c.Kubernetes.PodInfoPath)
+		hostOpts.Pod = &pod
+	} else {
+		// sidecar lives as long as its pod, so installation age is not reported in Kubernetes.
+		hostOpts.FirstSeenFile = lc.c.Telemetry.FirstSeenPath
+	}
+
+	hostMetrics := metrics.ScrapeHostMetrics(ctx, hostOpts)
+	hostInstanceID := hostMetrics.Metrics[metrics.InstanceIDKey]
+	// instanceId is not needed in main metrics set
+	delete(hostMetrics.Metrics, metrics.InstanceIDKey)
+
+	if lc.c.Kubernetes.Enabled {
+		// packages and repositories of sidecar container are not related to Pillars running in other containers.
+		return hostInstanceID, hostMetrics
+	}
+
+	overrides := lc.getOverrides()
+
+	if slices.Contains(overrides.DisabledCollectors, CollectorPackages) {
+		l.Info("scraping installed Percona packages is disabled by remote configuration")
+	} else {
+		lc.collectInstalledPackages(ctx, hostMetrics, overrides.ExtraPackagePatterns)
+	}
+
+	if slices.Contains(overrides.DisabledCollectors, CollectorContainers) {
+		l.Info("scraping running Percona containers is disabled by remote configuration")
+	} else {
+		collectRunningContainers(ctx, hostMetrics)
+	}
+
+	if slices.Contains(overrides.DisabledCollectors, CollectorRepositories) {
+		l.Info("scraping enabled Percona repositories is disabled by remote configuration")
+	} else {
+		collectEnabledRepositories(ctx, hostMetrics)
+	}
+
+	if slices.Contains(overrides.DisabledCollectors, CollectorServices) {
+		l.Info("scraping running Percona services is disabled by remote configuration")
+	} else {
+		collectRunningServices(ctx, hostMetrics)
+	}
+
+	if slices.Contains(overrides.DisabledCollectors, CollectorSystemd) {
+		l.Info("scraping systemd units of Percona services is disabled by remote configuration")
+	} else {
+		lc.collectSystemdUnits(ctx, hostMetrics)
+	}
+
+	return hostInstanceID, hostMetrics
+}
+
+// collectInstalledPackages adds installed Percona packages to host metrics.
+func (lc *LocalCollector) collectInstalledPackages(ctx context.Context, hostMetrics *metrics.File, extraPatterns []string) {
+	l := logger.FromContext(ctx).Sugar()
+
+	l.Info("scraping installed Percona packages")
+
+	packagesCtx, packagesSpan := startSpan(ctx, "collect installed packages")
+	installedPackages := metrics.ScrapeInstalledPackages(packagesCtx, metrics.PackageScrapeOpts{
+		Workers:          lc.c.Telemetry.PackageQueryWorkers,
+		CmdTimeout:       time.Duration(lc.c.Telemetry.CmdTimeout) * time.Second,
+		RepoqueryTimeout: time.Duration(lc.c.Telemetry.RepoqueryTimeout) * time.Second,
+		ExtraPatterns:    extraPatterns,
+	})
+	packagesSpan.SetAttributes(attribute.Int("packages", len(installedPackages)))
+	packagesSpan.End()
+
+	if len(installedPackages) != 0 {
+		// add info about installed packages to host metrics.
+		jsonData, err := json.Marshal(installedPackages)
+		if err != nil {
+			l.Warnw("failed to marshal installed Percona packages into JSON, skip it", zap.Error(err))
+		} else {
+			hostMetrics.Metrics["installed_packages"] = string(jsonData)
+		}
+	}
+}
+
+// collectRunningContainers adds running Percona containers to host metrics.
+func collectRunningContainers(ctx context.Context, hostMetrics *metrics.File) {
+	l := logger.FromContext(ctx).Sugar()
+
+	l.Info("scraping running Percona containers")
+
+	runningContainers := metrics.ScrapeRunningContainers(ctx)
+	if len(runningContainers) != 0 {
+		// add info about running Percona containers to host metrics.
+		jsonData, err := json.Marshal(runningContainers)
+		if err != nil {
+			l.Warnw("failed to marshal running Percona containers into JSON, skip it", zap.Error(err))
+		} else {
+			hostMetrics.Metrics["running_containers"] = string(jsonData)
+		}
+	}
+}
+
+// collectEnabledRepositories adds enabled Percona repositories to host metrics.
+func collectEnabledRepositories(ctx context.Context, hostMetrics *metrics.File) {
+	l := logger.FromContext(ctx).Sugar()
+
+	l.Info("scraping enabled Percona repositories")
+
+	enabledRepositories := metrics.ScrapePerconaRepositories()
+	if len(enabledRepositories) != 0 {
+		// add info about enabled Percona repositories to host metrics.
+		jsonData, err := json.Marshal(enabledRepositories)
+		if err != nil {
+			l.Warnw("failed to marshal enabled Percona repositories into JSON, skip it", zap.Error(err))
+		} else {
+			hostMetrics.Metrics["enabled_repositories"] = string(jsonData)
+		}
+	}
+}
+
+// collectRunningServices adds running Percona server processes and their listening ports to host metrics,
+// as installed packages don't mean the servers are used.
+func collectRunningServices(ctx context.Context, hostMetrics *metrics.File) {
+	l := logger.FromContext(ctx).Sugar()
+
+	l.Info("scraping running Percona services")
+
+	runningServices := metrics.ScrapeRunningServices()
+	if len(runningServices) != 0 {
+		// add info about running Percona services to host metrics.
+		jsonData, err := json.Marshal(runningServices)
+		if err != nil {
+			l.Warnw("failed to marshal running Percona services into JSON, skip it", zap.Error(err))
+		} else {
+			hostMetrics.Metrics["running_services"] = string(jsonData)
+		}
+	}
+}
+
+// collectSystemdUnits adds states of systemd units of Percona services to host metrics.
+func (lc *LocalCollector) collectSystemdUnits(ctx context.Context, hostMetrics *metrics.File) {
+	l := logger.FromContext(ctx).Sugar()
+
+	l.Info("scraping systemd units of Percona services")
+
+	unitsCtx, unitsSpan := startSpan(ctx, "collect systemd units")
+	systemdUnits := metrics.ScrapeSystemdUnits(unitsCtx, time.Duration(lc.c.Telemetry.CmdTimeout)*time.Second)
+	unitsSpan.End()
+
+	if len(systemdUnits) != 0 {
+		// add info about systemd units of Percona services to host metrics.
+		jsonData, err := json.Marshal(systemdUnits)
+		if err != nil {
+			l.Warnw("failed to marshal systemd units of Percona services into JSON, skip it", zap.Error(err))
+		} else {
+			hostMetrics.Metrics["systemd_units"] = string(jsonData)
+		}
+	}
+}
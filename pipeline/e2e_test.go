@@ -0,0 +1,631 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/config"
+	"github.com/percona/telemetry-agent/metrics"
+	"github.com/percona/telemetry-agent/platform"
+	"github.com/percona/telemetry-agent/platform/platformtest"
+)
+
+const e2ePodUID = "0c2f6f2e-1a4b-4c6e-9f5d-3b8a7e6d5c4b"
+
+// newE2EProcessor creates Processor the same way Telemetry Agent service does, sending telemetry
+// to mock Percona Platform. Host metrics are collected in Kubernetes mode, so the test doesn't depend
+// on packages installed on the host and doesn't touch Percona telemetry file: pod UID is the host instance ID.
+func newE2EProcessor(t *testing.T, rootDir string, srv *platformtest.Server, clientOpts ...platform.Option) *Processor {
+	t.Helper()
+
+	return newE2EStateProcessor(t, rootDir, srv, nil, clientOpts...)
+}
+
+// newE2EStateProcessor creates Processor the same way newE2EProcessor does, processed metrics files are recorded
+// in stateDB instead of being removed if it is not nil.
+func newE2EStateProcessor(t *testing.T, rootDir string, srv *platformtest.Server, stateDB *metrics.StateDB,
+	clientOpts ...platform.Option,
+) *Processor {
+	t.Helper()
+
+	podInfoDir := filepath.Join(rootDir, "podinfo")
+	require.NoError(t, os.MkdirAll(podInfoDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(podInfoDir, "uid"), []byte(e2ePodUID), 0o600))
+
+	c := config.Config{
+		Telemetry: config.TelemetryOpts{
+			RootPath:       rootDir,
+			HistoryPath:    filepath.Join(rootDir, "history"),
+			ProcessingPath: filepath.Join(rootDir, "processing"),
+			FailedPath:     filepath.Join(rootDir, "failed"),
+			CmdTimeout:     5,
+			Pillars: []config.PillarOpts{
+				{Name: "PS", Path: filepath.Join(rootDir, "ps"), ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS},
+			},
+		},
+		Kubernetes: config.KubernetesOpts{Enabled: true, PodInfoPath: podInfoDir},
+	}
+
+	// telemetry directories are created by Telemetry Agent at startup.
+	for _, dir := range []string{c.Telemetry.HistoryPath, c.Telemetry.ProcessingPath, c.Telemetry.FailedPath} {
+		require.NoError(t, os.MkdirAll(dir, 0o700))
+	}
+
+	client := platform.New(append([]platform.Option{platform.WithBaseURL(srv.URL)}, clientOpts...)...)
+
+	return New(NewLocalCollector(c, nil, stateDB), client, NewHistoryDir(c.Telemetry.HistoryPath, false), Opts{
+		RootPath:        c.Telemetry.RootPath,
+		ProcessingPath:  c.Telemetry.ProcessingPath,
+		FailedPath:      c.Telemetry.FailedPath,
+		Workers:         1,
+		MaxSendAttempts: 10,
+		StateDB:         stateDB,
+	})
+}
+
+func writeE2EMetricsFile(t *testing.T, rootDir string, createTime time.Time, dbInstanceID string) string {
+	t.Helper()
+
+	dir := filepath.Join(rootDir, "ps")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+
+	fileName := filepath.Join(dir, fmt.Sprintf("%d-%s.json", createTime.Unix(), dbInstanceID))
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"db_instance_id": "`+dbInstanceID+`", "pillar_version": "8.0.35-27"}`), 0o600))
+
+	return fileName
+}
+
+func TestProcessE2E(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		responses  []platformtest.Response
+		clientOpts []platform.Option
+		// requests is the number of requests received by Percona Platform.
+		requests int
+		expected Result
+	}{
+		{
+			name:     "success",
+			requests: 2,
+			expected: Result{Processed: 2, Sent: 2},
+		},
+		{
+			name:       "server_error_retried",
+			responses:  []platformtest.Response{platformtest.ServerError(http.StatusServiceUnavailable)},
+			clientOpts: []platform.Option{platform.WithRetryCount(1), platform.WithResendTimeout(10 * time.Millisecond)},
+			requests:   3,
+			expected:   Result{Processed: 2, Sent: 2},
+		},
+		{
+			name:      "server_error",
+			responses: []platformtest.Response{platformtest.ServerError(http.StatusInternalServerError)},
+			requests:  2,
+			expected:  Result{Processed: 2, Sent: 1, Failed: 1},
+		},
+		{
+			name:      "too_many_requests",
+			responses: []platformtest.Response{platformtest.TooManyRequests(60)},
+			requests:  2,
+			expected:  Result{Processed: 2, Sent: 1, Failed: 1},
+		},
+		{
+			name:       "slow",
+			responses:  []platformtest.Response{platformtest.Slow(time.Second)},
+			clientOpts: []platform.Option{platform.WithClientTimeout(100 * time.Millisecond)},
+			requests:   1,
+			expected:   Result{Processed: 2, Sent: 1, Failed: 1},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := platformtest.NewServer()
+			defer srv.Close()
+
+			srv.Respond(tt.responses...)
+
+			rootDir := t.TempDir()
+			createTime := time.Now().Add(-time.Hour)
+			files := []string{
+				writeE2EMetricsFile(t, rootDir, createTime, "instance-1"),
+				writeE2EMetricsFile(t, rootDir, createTime.Add(time.Minute), "instance-2"),
+			}
+
+			res, err := newE2EProcessor(t, rootDir, srv, tt.clientOpts...).Process(t.Context())
+			require.Equal(t, tt.expected, res)
+			require.Len(t, srv.Requests(), tt.requests)
+
+			if tt.expected.Failed != 0 {
+				require.Error(t, err)
+				// the oldest file is sent first, so it is the failed one kept for next iteration.
+				require.FileExists(t, files[0])
+				require.NoFileExists(t, files[1])
+			} else {
+				require.NoError(t, err)
+			}
+
+			reports := srv.Reports()
+			require.Len(t, reports, tt.expected.Sent)
+
+			for _, report := range reports {
+				require.Equal(t, e2ePodUID, report.GetInstanceId())
+				require.Equal(t, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, report.GetProductFamily())
+			}
+
+			// request IDs of successful requests are saved into history delivery metadata.
+			var requestIDs []string
+
+			for _, req := range srv.Requests() {
+				if req.StatusCode == http.StatusOK {
+					requestIDs = append(requestIDs, req.RequestID)
+				}
+			}
+
+			// sent telemetry is saved into history under the name of metrics file, which is removed.
+			for _, f := range files {
+				historyFile := filepath.Join(rootDir, "history", filepath.Base(f))
+
+				if _, statErr := os.Stat(f); statErr == nil {
+					require.NoFileExists(t, historyFile)
+					continue
+				}
+
+				_, delivery, err := metrics.ReadMetricsHistory(historyFile)
+				require.NoError(t, err)
+				require.NotNil(t, delivery)
+				require.Contains(t, requestIDs, delivery.RequestID)
+			}
+		})
+	}
+}
+
+func TestFetchRemoteConfigE2E(t *testing.T) {
+	t.Parallel()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	_, untrustedKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	rc := platform.RemoteConfig{
+		CheckInterval:        3600,
+		DisabledCollectors:   []string{CollectorContainers},
+		ExtraPackagePatterns: []string{"percona-toolkit*"},
+	}
+
+	signed, err := platform.SignRemoteConfig(rc, privateKey)
+	require.NoError(t, err)
+
+	untrusted, err := platform.SignRemoteConfig(rc, untrustedKey)
+	require.NoError(t, err)
+
+	tampered := signed
+	tampered.Config = []byte(`{"check_interval":60}`)
+
+	testCases := []struct {
+		name     string
+		signed   *platform.SignedRemoteConfig
+		expected *platform.RemoteConfig
+		// errStatusCode is HTTP status code of expected Percona Platform response error, 0 if no such error is expected.
+		errStatusCode int
+		errIs         error
+	}{
+		{
+			name:     "signed",
+			signed:   &signed,
+			expected: &rc,
+		},
+		{
+			name:   "tampered",
+			signed: &tampered,
+			errIs:  platform.ErrInvalidSignature,
+		},
+		{
+			name:   "untrusted_key",
+			signed: &untrusted,
+			errIs:  platform.ErrInvalidSignature,
+		},
+		{
+			name:          "not_published",
+			errStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := platformtest.NewServer()
+			defer srv.Close()
+
+			if tt.signed != nil {
+				srv.SetRemoteConfig(*tt.signed)
+			}
+
+			client := platform.New(platform.WithBaseURL(srv.URL))
+
+			fetched, err := client.FetchRemoteConfig(t.Context(), platformtest.RemoteConfigPath, publicKey)
+
+			switch {
+			case tt.errIs != nil:
+				require.ErrorIs(t, err, tt.errIs)
+				require.Nil(t, fetched)
+			case tt.errStatusCode != 0:
+				var respErr *platform.ResponseError
+				require.ErrorAs(t, err, &respErr)
+				require.Equal(t, tt.errStatusCode, respErr.HTTPStatusCode())
+				require.Nil(t, fetched)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, fetched)
+			}
+		})
+	}
+}
+
+func TestProcessE2ECredentials(t *testing.T) {
+	t.Parallel()
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/percona-telemetry":
+			_, _ = w.Write([]byte(`{"lease_duration":0,"data":{"data":{"token":"kv2-token"},"metadata":{"version":3}}}`))
+		case "/v1/kv/percona-telemetry":
+			_, _ = w.Write([]byte(`{"lease_duration":3600,"data":{"token":"kv1-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	// subtests are run in parallel after the test function returns.
+	t.Cleanup(vault.Close)
+
+	testCases := []struct {
+		name   string
+		source platform.CredentialSource
+		// authorization is the expected Authorization header, empty if credentials can't be fetched.
+		authorization string
+	}{
+		{
+			name:          "command",
+			source:        platform.CommandCredentialSource{Args: []string{"echo", `{"token": "command-token"}`}},
+			authorization: "Bearer command-token",
+		},
+		{
+			name:   "command_failed",
+			source: platform.CommandCredentialSource{Args: []string{"false"}},
+		},
+		{
+			name:          "vault_kv2",
+			source:        platform.VaultCredentialSource{Addr: vault.URL, SecretPath: "secret/data/percona-telemetry", Token: "vault-token"},
+			authorization: "Bearer kv2-token",
+		},
+		{
+			name:          "vault_kv1",
+			source:        platform.VaultCredentialSource{Addr: vault.URL, SecretPath: "kv/percona-telemetry", Token: "vault-token"},
+			authorization: "Bearer kv1-token",
+		},
+		{
+			name:   "vault_permission_denied",
+			source: platform.VaultCredentialSource{Addr: vault.URL, SecretPath: "kv/percona-telemetry", Token: "wrong-token"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := platformtest.NewServer()
+			defer srv.Close()
+
+			rootDir := t.TempDir()
+			writeE2EMetricsFile(t, rootDir, time.Now().Add(-time.Hour), "instance-1")
+
+			p := newE2EProcessor(t, rootDir, srv, platform.WithCredentials(platform.NewCredentialProvider(tt.source)))
+
+			res, err := p.Process(t.Context())
+
+			if tt.authorization == "" {
+				// request is not sent without credentials, the file is kept for the next iteration.
+				require.Error(t, err)
+				require.Equal(t, Result{Processed: 1, Failed: 1}, res)
+				require.Empty(t, srv.Requests())
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+
+			requests := srv.Requests()
+			require.Len(t, requests, 1)
+			require.Equal(t, tt.authorization, requests[0].Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestCredentialProviderRefresh(t *testing.T) {
+	t.Parallel()
+
+	var fetches int
+
+	provider := platform.NewCredentialProvider(platform.CredentialSourceFunc(func(context.Context) (platform.Credentials, error) {
+		fetches++
+		if fetches > 2 {
+			return platform.Credentials{}, errors.New("secrets storage is unavailable")
+		}
+
+		// credentials expiring in a second are refreshed in half a second.
+		return platform.Credentials{Token: fmt.Sprintf("token-%d", fetches), ExpiresAt: time.Now().Add(time.Second)}, nil
+	}))
+
+	token, err := provider.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+
+	token, err = provider.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.Equal(t, 1, fetches)
+
+	time.Sleep(600 * time.Millisecond)
+
+	token, err = provider.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+
+	// unexpired credentials are kept while refreshing fails.
+	time.Sleep(600 * time.Millisecond)
+
+	token, err = provider.Token(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+
+	// expired credentials are not used.
+	time.Sleep(600 * time.Millisecond)
+
+	_, err = provider.Token(t.Context())
+	require.Error(t, err)
+}
+
+func TestProcessE2EKeepMetricsFiles(t *testing.T) {
+	t.Parallel()
+
+	srv := platformtest.NewServer()
+	defer srv.Close()
+
+	rootDir := t.TempDir()
+
+	stateDB, err := metrics.OpenStateDB(filepath.Join(rootDir, "state.db"))
+	require.NoError(t, err)
+
+	defer stateDB.Close() //nolint:errcheck
+
+	createTime := time.Now().Add(-time.Hour)
+	files := []string{
+		writeE2EMetricsFile(t, rootDir, createTime, "instance-1"),
+		writeE2EMetricsFile(t, rootDir, createTime.Add(time.Minute), "instance-2"),
+	}
+
+	p := newE2EStateProcessor(t, rootDir, srv, stateDB)
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 2, Sent: 2}, res)
+
+	// sent metrics files are saved into history and kept in place.
+	for _, f := range files {
+		require.FileExists(t, f)
+		require.FileExists(t, filepath.Join(rootDir, "history", filepath.Base(f)))
+	}
+
+	// recorded metrics files are not sent again.
+	res, err = p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{}, res)
+	require.Len(t, srv.Reports(), 2)
+
+	// metrics file rewritten by Pillar is sent again.
+	require.NoError(t, os.WriteFile(files[1], []byte(`{"db_instance_id": "instance-2", "pillar_version": "8.0.36-28"}`), 0o600))
+
+	res, err = p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+	require.Len(t, srv.Reports(), 3)
+}
+
+func TestProcessE2EClockSkew(t *testing.T) {
+	t.Parallel()
+
+	srv := platformtest.NewServer()
+	defer srv.Close()
+
+	// host clock is an hour ahead of Percona Platform one.
+	srv.SetClockOffset(-time.Hour)
+
+	rootDir := t.TempDir()
+	createTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	p := newE2EProcessor(t, rootDir, srv)
+	p.opts.ClockSkew = metrics.NewClockSkew(5 * time.Minute)
+
+	// the skew is unknown before the first response, so the first report is sent as is.
+	writeE2EMetricsFile(t, rootDir, createTime, "instance-1")
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+
+	skew, ok := p.opts.ClockSkew.Skew()
+	require.True(t, ok)
+	require.InDelta(t, time.Hour.Seconds(), skew.Seconds(), 1)
+
+	writeE2EMetricsFile(t, rootDir, createTime, "instance-2")
+
+	res, err = p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+
+	reports := srv.Reports()
+	require.Len(t, reports, 2)
+	require.WithinDuration(t, createTime, reports[0].GetCreateTime().AsTime(), 0)
+	require.WithinDuration(t, createTime.Add(-skew), reports[1].GetCreateTime().AsTime(), 0)
+
+	reportMetrics := make(map[string]string)
+	for _, m := range reports[1].GetMetrics() {
+		reportMetrics[m.GetKey()] = m.GetValue()
+	}
+
+	require.Equal(t, strconv.FormatInt(int64(skew/time.Second), 10), reportMetrics[metrics.ClockSkewKey])
+}
+
+func TestProcessE2EUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	socketPath := filepath.Join(rootDir, "relay.sock")
+
+	srv, err := platformtest.NewUnixServer(socketPath)
+	require.NoError(t, err)
+
+	defer srv.Close()
+
+	writeE2EMetricsFile(t, rootDir, time.Now().Add(-time.Hour), "instance-1")
+
+	p := newE2EProcessor(t, rootDir, srv, platform.WithBaseURL("http://localhost"), platform.WithUnixSocket(socketPath))
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+
+	reports := srv.Reports()
+	require.Len(t, reports, 1)
+	require.Equal(t, e2ePodUID, reports[0].GetInstanceId())
+}
+
+func TestSendTelemetryCompression(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		encodings      []string
+		acceptEncoding []string
+		// rejectAfter is the number of requests after which Percona Platform stops accepting any encoding.
+		rejectAfter int
+		// expected are Content-Encoding headers of received requests.
+		expected []string
+	}{
+		{
+			name:           "zstd_preferred",
+			encodings:      []string{platform.EncodingZstd, platform.EncodingGzip},
+			acceptEncoding: []string{platform.EncodingGzip, platform.EncodingZstd},
+			expected:       []string{"", platform.EncodingZstd, platform.EncodingZstd},
+		},
+		{
+			name:           "gzip_only",
+			encodings:      []string{platform.EncodingGzip},
+			acceptEncoding: []string{platform.EncodingGzip, platform.EncodingZstd},
+			expected:       []string{"", platform.EncodingGzip, platform.EncodingGzip},
+		},
+		{
+			name:           "not_advertised",
+			encodings:      []string{platform.EncodingZstd, platform.EncodingGzip},
+			acceptEncoding: nil,
+			expected:       []string{"", "", ""},
+		},
+		{
+			name:           "disabled",
+			acceptEncoding: []string{platform.EncodingZstd},
+			expected:       []string{"", "", ""},
+		},
+		{
+			name:           "rejected",
+			encodings:      []string{platform.EncodingZstd},
+			acceptEncoding: []string{platform.EncodingZstd},
+			rejectAfter:    1,
+			// rejected request is resent uncompressed, the encoding is not used anymore.
+			expected: []string{"", platform.EncodingZstd, "", ""},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := platformtest.NewServer()
+			defer srv.Close()
+
+			srv.SetAcceptEncoding(tt.acceptEncoding...)
+
+			var clientOpts []platform.Option
+			if len(tt.encodings) != 0 {
+				clientOpts = append(clientOpts, platform.WithCompression(tt.encodings...))
+			}
+
+			client := platform.New(append([]platform.Option{platform.WithBaseURL(srv.URL)}, clientOpts...)...)
+
+			for i := range 3 {
+				if tt.rejectAfter != 0 && i == tt.rejectAfter {
+					// client keeps using previously advertised encoding until it is rejected.
+					srv.SetAcceptEncoding()
+				}
+
+				report := &platformReporter.ReportRequest{Reports: []*platformReporter.GenericReport{{
+					Id:            fmt.Sprintf("report-%d", i),
+					InstanceId:    e2ePodUID,
+					ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS,
+				}}}
+				require.NoError(t, client.SendTelemetry(t.Context(), "", report))
+			}
+
+			requests := srv.Requests()
+			encodings := make([]string, 0, len(requests))
+
+			for _, req := range requests {
+				encodings = append(encodings, req.Header.Get("Content-Encoding"))
+			}
+
+			require.Equal(t, tt.expected, encodings)
+			require.Len(t, srv.Reports(), 3)
+		})
+	}
+}
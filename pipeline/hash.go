@@ -0,0 +1,147 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+)
+
+const (
+	// hashedValuePrefix is added to hashed metric values, so they are distinguishable from raw ones.
+	hashedValuePrefix = "sha256:"
+	// hashSaltSize is the size in bytes of generated salt.
+	hashSaltSize            = 32
+	hashSaltFilePermissions = 0o600
+)
+
+// Hasher replaces values of identifying metrics with salted SHA-256 hashes (HMAC-SHA256),
+// so the same value is always replaced with the same hash and trend analysis is preserved,
+// while raw identifiers are never sent.
+type Hasher struct {
+	keyPatterns []string
+	salt        []byte
+}
+
+// NewHasher creates Hasher of metrics with keys matching given case-insensitive shell patterns.
+func NewHasher(keyPatterns []string, salt []byte) (*Hasher, error) {
+	if len(salt) == 0 {
+		return nil, errors.New("hash salt is empty")
+	}
+
+	h := &Hasher{salt: salt}
+
+	for _, p := range keyPatterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid hash key pattern %q: %w", p, err)
+		}
+
+		h.keyPatterns = append(h.keyPatterns, p)
+	}
+
+	return h, nil
+}
+
+// Hash replaces values of matching metrics with their hashes in place. Nil Hasher doesn't change metrics.
+func (h *Hasher) Hash(reportMetrics []*platformReporter.GenericReport_Metric) {
+	if h == nil {
+		return
+	}
+
+	for _, m := range reportMetrics {
+		key := strings.ToLower(m.GetKey())
+		for _, p := range h.keyPatterns {
+			if ok, _ := path.Match(p, key); ok {
+				m.Value = h.hashValue(m.GetValue())
+				break
+			}
+		}
+	}
+}
+
+func (h *Hasher) hashValue(value string) string {
+	mac := hmac.New(sha256.New, h.salt)
+	_, _ = mac.Write([]byte(value))
+
+	return hashedValuePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadOrCreateHashSalt reads hash salt from the file, random salt is generated and written into the file
+// if it doesn't exist, so hashes stay the same across agent restarts.
+func LoadOrCreateHashSalt(saltFile string) ([]byte, error) {
+	cleanSaltFile := filepath.Clean(saltFile)
+
+	salt, err := ReadHashSalt(cleanSaltFile)
+	if !errors.Is(err, os.ErrNotExist) {
+		return salt, err
+	}
+
+	salt, err = RandomHashSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	// salt is written into temporary file and renamed, so partially written salt is never used.
+	tmpFile := cleanSaltFile + ".tmp"
+	if err := os.WriteFile(tmpFile, append(salt, '\n'), hashSaltFilePermissions); err != nil {
+		return nil, fmt.Errorf("failed to write hash salt file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, cleanSaltFile); err != nil {
+		_ = os.Remove(tmpFile)
+		return nil, fmt.Errorf("failed to write hash salt file: %w", err)
+	}
+
+	return salt, nil
+}
+
+// ReadHashSalt reads hash salt from the file, error wrapping os.ErrNotExist is returned if it doesn't exist.
+func ReadHashSalt(saltFile string) ([]byte, error) {
+	cleanSaltFile := filepath.Clean(saltFile)
+
+	data, err := os.ReadFile(cleanSaltFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hash salt file: %w", err)
+	}
+
+	salt := strings.TrimSpace(string(data))
+	if salt == "" {
+		return nil, fmt.Errorf("hash salt file %s is empty", cleanSaltFile)
+	}
+
+	return []byte(salt), nil
+}
+
+// RandomHashSalt generates random hash salt.
+func RandomHashSalt() ([]byte, error) {
+	b := make([]byte, hashSaltSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate hash salt: %w", err)
+	}
+
+	return []byte(hex.EncodeToString(b)), nil
+}
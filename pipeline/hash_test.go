@@ -0,0 +1,92 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasher(t *testing.T) {
+	t.Parallel()
+
+	hashMetrics := func(h *Hasher) map[string]string {
+		m := []*platformReporter.GenericReport_Metric{
+			{Key: "db_instance_id", Value: "11ee1b8b-8b0e-4f6c-9bc8-7f5d3c3e0c39"},
+			{Key: "pillar_version", Value: "8.0.36-28"},
+		}
+		h.Hash(m)
+
+		values := make(map[string]string, len(m))
+		for _, metric := range m {
+			values[metric.GetKey()] = metric.GetValue()
+		}
+
+		return values
+	}
+
+	h, err := NewHasher([]string{"DB_INSTANCE_ID"}, []byte("salt"))
+	require.NoError(t, err)
+
+	values := hashMetrics(h)
+	require.True(t, strings.HasPrefix(values["db_instance_id"], hashedValuePrefix))
+	require.Len(t, values["db_instance_id"], len(hashedValuePrefix)+64)
+	require.Equal(t, "8.0.36-28", values["pillar_version"])
+
+	// hashing is deterministic for the same salt.
+	require.Equal(t, values, hashMetrics(h))
+
+	other, err := NewHasher([]string{"db_instance_id"}, []byte("other salt"))
+	require.NoError(t, err)
+	require.NotEqual(t, values["db_instance_id"], hashMetrics(other)["db_instance_id"])
+
+	// nil Hasher doesn't change metrics.
+	require.Equal(t, "11ee1b8b-8b0e-4f6c-9bc8-7f5d3c3e0c39", hashMetrics(nil)["db_instance_id"])
+
+	_, err = NewHasher([]string{"db_instance_id"}, nil)
+	require.Error(t, err)
+
+	_, err = NewHasher([]string{"["}, []byte("salt"))
+	require.Error(t, err)
+}
+
+func TestLoadOrCreateHashSalt(t *testing.T) {
+	t.Parallel()
+
+	saltFile := filepath.Join(t.TempDir(), "hash_salt")
+
+	// salt is generated on first call and reused later.
+	salt, err := LoadOrCreateHashSalt(saltFile)
+	require.NoError(t, err)
+	require.Len(t, salt, 2*hashSaltSize)
+
+	again, err := LoadOrCreateHashSalt(saltFile)
+	require.NoError(t, err)
+	require.Equal(t, salt, again)
+
+	fi, err := os.Stat(saltFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(hashSaltFilePermissions), fi.Mode().Perm())
+
+	require.NoError(t, os.WriteFile(saltFile, []byte("\n"), 0o600))
+	_, err = LoadOrCreateHashSalt(saltFile)
+	require.Error(t, err)
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// suppressedHistoryMarker is inserted before the extension of history file recording suppressed reports,
+// so it doesn't replace history file of request sent for the same metrics file.
+const suppressedHistoryMarker = ".suppressed"
+
+// HistoryDir saves sent telemetry into history directory, one file per request.
+type HistoryDir struct {
+	path     string
+	compress bool
+}
+
+// NewHistoryDir creates new HistoryDir saving history files into path, gzip compressed if compress is true.
+func NewHistoryDir(path string, compress bool) *HistoryDir {
+	return &HistoryDir{
+		path:     path,
+		compress: compress,
+	}
+}
+
+// Save writes sent request and its delivery metadata into history file named after metrics file.
+// Name of history file of suppressed reports has suppressedHistoryMarker before the extension.
+func (h *HistoryDir) Save(ctx context.Context, metricsFile string, report *platformReporter.ReportRequest, delivery *metrics.HistoryDelivery) error {
+	historyFile := filepath.Join(h.path, filepath.Base(metricsFile))
+	if delivery != nil && delivery.Suppressed {
+		ext := filepath.Ext(historyFile)
+		historyFile = strings.TrimSuffix(historyFile, ext) + suppressedHistoryMarker + ext
+	}
+
+	if h.compress {
+		historyFile += metrics.HistoryCompressedFileExt
+	}
+
+	logger.FromContext(ctx).Sugar().Infow("writing metrics to history file",
+		zap.String("file", metricsFile),
+		zap.String("history file", historyFile))
+
+	if err := metrics.WriteMetricsToHistory(historyFile, report, delivery); err != nil {
+		return fmt.Errorf("can't write history file %s: %w", historyFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,771 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+// Package pipeline provides processing of Percona Pillars metrics files: collecting them along with host metrics,
+// sending them to Percona Platform, saving sent telemetry into history and removing the files.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	platformLogger "github.com/percona/platform/pkg/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/percona/telemetry-agent/logger"
+	"github.com/percona/telemetry-agent/metrics"
+	"github.com/percona/telemetry-agent/platform"
+	"github.com/percona/telemetry-agent/utils"
+)
+
+// Collector collects metrics to be sent to Percona Platform.
+type Collector interface {
+	// CollectPillarsMetrics parses metrics files of all Pillars taken into batch, all files are parsed if it is nil.
+	CollectPillarsMetrics(ctx context.Context, batch *metrics.Batch) []*metrics.File
+	// CollectHostMetrics scrapes host metrics, host instance ID is returned separately from the metrics.
+	CollectHostMetrics(ctx context.Context) (string, *metrics.File)
+}
+
+// Sender sends telemetry to Percona Platform.
+type Sender interface {
+	SendTelemetry(ctx context.Context, token string, report *platformReporter.ReportRequest) error
+}
+
+// HistoryStore saves telemetry sent to Percona Platform.
+type HistoryStore interface {
+	// Save saves sent request and its delivery metadata under the name of metrics file.
+	Save(ctx context.Context, metricsFile string, report *platformReporter.ReportRequest, delivery *metrics.HistoryDelivery) error
+}
+
+// Opts represents the options of metrics files processing.
+type Opts struct {
+	// RootPath is the telemetry root path Pillars metrics directories are located in.
+	RootPath string
+	// ProcessingPath is the directory metrics files are kept in while they are sent.
+	ProcessingPath string
+	// FailedPath is the directory metrics files failed to be sent MaxSendAttempts times are moved to.
+	FailedPath string
+	// MergeWindow is the interval within which metrics files of the same database instance are sent as one request.
+	MergeWindow time.Duration
+	// DedupeWindow is the interval within which report identical to already sent one is not sent again.
+	DedupeWindow time.Duration
+	// Workers is the maximum number of requests processed concurrently.
+	Workers int
+	// SendSpread is the window the requests of one call are evenly spaced out over instead of being sent back-to-back.
+	// Only the first batch is paced, the rest of a large backlog is sent back-to-back.
+	SendSpread time.Duration
+	// BatchSize is the maximum number of metrics files parsed and held in memory at once, larger backlogs
+	// are processed in several batches within one call. It is not limited if it is not positive.
+	BatchSize int
+	// MaxSendAttempts is the number of failed attempts to send metrics file after which it is moved into FailedPath.
+	MaxSendAttempts int
+	// DryRun enables printing requests into DryRunOutput instead of sending them, metrics files are left untouched.
+	DryRun       bool
+	DryRunOutput io.Writer
+	// Scrubber strips personal data from metric values before reports are sent and saved into history, nil disables scrubbing.
+	Scrubber *Scrubber
+	// Hasher replaces values of identifying metrics with their hashes before scrubbing, nil disables hashing.
+	Hasher *Hasher
+	// ErrorCounters counts failures of sending, moving and saving metrics files by category, nil disables counting.
+	ErrorCounters *metrics.ErrorCounters
+	// StateDB records processed metrics files instead of moving and removing them, e.g. for read-only or shared
+	// Pillars volumes. Metrics files are removed if it is nil.
+	StateDB *metrics.StateDB
+	// Tags are static tags attached to every report as "tag."-prefixed metrics after hashing and scrubbing,
+	// as they are set by the owner of the fleet.
+	Tags map[string]string
+	// ClockSkew measures skew of host clock with Percona Platform responses and corrects creation time of reports
+	// if it is too large, nil disables both.
+	ClockSkew *metrics.ClockSkew
+}
+
+// Result represents the numbers of Pillars metrics files processed by Processor.
+// Documents of NDJSON metrics file are counted as one file.
+type Result struct {
+	// Processed is the number of metrics files found.
+	Processed int
+	// Sent is the number of metrics files sent to Percona Platform (printed in dry-run mode).
+	Sent int
+	// Suppressed is the number of metrics files removed without sending as duplicates of recently sent ones.
+	Suppressed int
+	// Failed is the number of metrics files failed to be sent or saved, they are retried on next call.
+	Failed int
+}
+
+// Option is an option for Processor returned by constructor.
+type Option func(*Processor)
+
+// WithSendObserver sets function called with the result of each request to Percona Platform.
+// It is not called for requests interrupted by context cancellation.
+func WithSendObserver(fn func(err error)) Option {
+	return func(p *Processor) {
+		p.onSend = fn
+	}
+}
+
+// WithProgressObserver sets function called each time a group of metrics files or a batch is processed,
+// e.g. to tell a slow but progressing call from a hung one.
+func WithProgressObserver(fn func()) Option {
+	return func(p *Processor) {
+		p.onProgress = fn
+	}
+}
+
+// WithSentReports sets recently sent reports duplicates are suppressed against, e.g. loaded from history,
+// empty SentReports remembering reports sent within DedupeWindow is used by default.
+func WithSentReports(sentReports *metrics.SentReports) Option {
+	return func(p *Processor) {
+		p.sentReports = sentReports
+	}
+}
+
+// WithTracerProvider sets OpenTelemetry tracer provider processing is traced with,
+// globally registered one is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(p *Processor) {
+		p.tracer = tp.Tracer(TracerName)
+	}
+}
+
+// Processor processes Pillars metrics files and sends them to Percona Platform.
+type Processor struct {
+	opts        Opts
+	collector   Collector
+	sender      Sender
+	history     HistoryStore
+	sentReports *metrics.SentReports
+	onSend      func(err error)
+	onProgress  func()
+	tracer      trace.Tracer
+}
+
+// New creates new Processor. Sender and history store are not used in dry-run mode, so they may be nil.
+func New(collector Collector, sender Sender, history HistoryStore, opts Opts, options ...Option) *Processor {
+	p := &Processor{
+		opts:      opts,
+		collector: collector,
+		sender:    sender,
+		history:   history,
+		// sent reports are remembered for suppressing identical reports.
+		sentReports: metrics.NewSentReports(opts.DedupeWindow),
+		onSend:      func(error) {},
+		onProgress:  func() {},
+		tracer:      otel.Tracer(TracerName),
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	return p
+}
+
+// Process processes Pillar's telemetry and sends it to Percona Platform.
+// Returned error reports telemetry that was not sent or saved, such telemetry is retried on next call.
+func (p *Processor) Process(ctx context.Context) (res Result, err error) {
+	l := logger.FromContext(ctx).Sugar()
+
+	ctx, span := p.tracer.Start(ctx, "process metrics")
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("files.processed", res.Processed),
+			attribute.Int("files.sent", res.Sent),
+			attribute.Int("files.suppressed", res.Suppressed),
+			attribute.Int("files.failed", res.Failed))
+		EndSpan(span, err)
+	}()
+
+	var (
+		hostInstanceID string
+		hostMetrics    *metrics.File
+		errs           []error
+	)
+
+	// records of metrics files removed by Pillars are forgotten.
+	if pruned, err := p.opts.StateDB.Prune(); err != nil {
+		l.Errorw("failed to prune state database", zap.Error(err))
+	} else if pruned != 0 {
+		l.Debugw("removed metrics files are forgotten by state database", zap.Int("files", pruned))
+	}
+
+	sendSpread := p.opts.SendSpread
+	batch := metrics.NewBatch(p.opts.BatchSize)
+
+	// metrics files are parsed, sent and released batch by batch, so a large backlog is not held in memory at once.
+	for batch.Next() {
+		pillarMetrics := p.collector.CollectPillarsMetrics(ctx, batch)
+		if len(pillarMetrics) == 0 {
+			continue
+		}
+
+		if hostMetrics == nil {
+			// host metrics are scraped once per call and shared by all batches.
+			hostInstanceID, hostMetrics = p.collector.CollectHostMetrics(ctx)
+		}
+
+		batchRes, err := p.processBatch(ctx, pillarMetrics, hostInstanceID, hostMetrics, sendSpread)
+		res.Processed += batchRes.Processed
+		res.Sent += batchRes.Sent
+		res.Suppressed += batchRes.Suppressed
+		res.Failed += batchRes.Failed
+		errs = append(errs, err)
+
+		p.onProgress()
+
+		if ctx.Err() != nil {
+			// main process loop is terminated, the rest of metrics files are sent on next start.
+			break
+		}
+
+		// the rest of the backlog is sent back-to-back.
+		sendSpread = 0
+	}
+
+	if hostMetrics == nil {
+		l.Info("no Pillar metrics files found, skip scraping host metrics and sending telemetry")
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// processBatch sends batch of Pillars metrics files, evenly spacing the requests out over sendSpread.
+func (p *Processor) processBatch(ctx context.Context, pillarMetrics []*metrics.File, hostInstanceID string,
+	hostMetrics *metrics.File, sendSpread time.Duration,
+) (Result, error) {
+	var res Result
+
+	groups := metrics.GroupMetricsFiles(pillarMetrics, p.opts.MergeWindow)
+
+	workers := p.opts.Workers
+	if p.opts.DryRun {
+		// requests are printed one by one.
+		workers = 1
+	}
+
+	// requests are paced to avoid outbound traffic bursts, i-th request is sent not earlier than its slot.
+	var sendInterval time.Duration
+	if sendSpread > 0 && !p.opts.DryRun {
+		sendInterval = sendSpread / time.Duration(len(groups))
+	}
+
+	start := time.Now()
+
+	// each group is sent, saved and removed independently, so groups are processed concurrently.
+	errs := make([]error, len(groups))
+	suppressed := make([]bool, len(groups))
+	groupFiles := make([][]string, len(groups))
+
+	utils.ForEachParallel(len(groups), workers, func(i int) {
+		groupFiles[i] = groupFileNames(groups[i])
+
+		if err := waitUntil(ctx, start.Add(time.Duration(i)*sendInterval)); err != nil {
+			// main process loop is terminated, the rest of metrics files are sent on next start.
+			errs[i] = err
+			return
+		}
+
+		suppressed[i], errs[i] = p.processGroup(ctx, groups[i], groupFiles[i], hostInstanceID, hostMetrics)
+		p.onProgress()
+	})
+
+	for i := range groups {
+		res.Processed += len(groupFiles[i])
+
+		switch {
+		case errs[i] != nil:
+			res.Failed += len(groupFiles[i])
+		case suppressed[i]:
+			res.Suppressed += len(groupFiles[i])
+		default:
+			res.Sent += len(groupFiles[i])
+		}
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// Collect collects Pillars and host metrics and assembles reports the same way Process does,
+// but returns them within one request instead of sending. Reports identical to already sent ones are kept.
+func (p *Processor) Collect(ctx context.Context) *platformReporter.ReportRequest {
+	report := &platformReporter.ReportRequest{}
+
+	pillarMetrics := p.collector.CollectPillarsMetrics(ctx, nil)
+	if len(pillarMetrics) == 0 {
+		logger.FromContext(ctx).Sugar().Info("no Pillar metrics files found, skip scraping host metrics")
+		return report
+	}
+
+	hostInstanceID, hostMetrics := p.collector.CollectHostMetrics(ctx)
+
+	for _, group := range metrics.GroupMetricsFiles(pillarMetrics, p.opts.MergeWindow) {
+		for _, pillarM := range metrics.DeduplicateMetricsFiles(group) {
+			report.Reports = append(report.Reports, p.createReport(hostInstanceID, hostMetrics, pillarM))
+		}
+	}
+
+	return report
+}
+
+// SendMetricsFile sends reports of parsed metrics file to Percona Platform within one request, attaching
+// host metrics, and saves the request into history. Unlike Process, the metrics file is neither moved nor removed,
+// so files recovered from quarantine or failed metrics directories may be replayed manually.
+func (p *Processor) SendMetricsFile(ctx context.Context, fileMetrics []*metrics.File) (err error) {
+	if len(fileMetrics) == 0 {
+		return errors.New("metrics file has no metrics")
+	}
+
+	fileName := fileMetrics[0].Filename
+	metricsLogger := logger.FromContext(ctx).Sugar().With(zap.String("file", fileName))
+
+	ctx, span := p.tracer.Start(ctx, "send metrics file", trace.WithAttributes(attribute.String("file", fileName)))
+	defer func() { EndSpan(span, err) }()
+
+	hostInstanceID, hostMetrics := p.collector.CollectHostMetrics(ctx)
+
+	uniqueFiles := metrics.DeduplicateMetricsFiles(fileMetrics)
+	report := &platformReporter.ReportRequest{
+		Reports: make([]*platformReporter.GenericReport, 0, len(uniqueFiles)),
+	}
+
+	for _, pillarM := range uniqueFiles {
+		report.Reports = append(report.Reports, p.createReport(hostInstanceID, hostMetrics, pillarM))
+	}
+
+	if p.opts.DryRun {
+		return WriteReport(p.opts.DryRunOutput, report)
+	}
+
+	delivery := &platform.Delivery{}
+
+	sendCtx, sendSpan := startSpan(ctx, "send")
+	platformCtx := platform.WithDelivery(platformLogger.GetContextWithLogger(sendCtx, metricsLogger.Desugar()), delivery)
+	err = p.sender.SendTelemetry(platformCtx, "", report)
+	EndSpan(sendSpan, err)
+
+	if !errors.Is(err, context.Canceled) {
+		p.onSend(err)
+	}
+
+	if err != nil {
+		p.opts.ErrorCounters.AddError(err)
+		return fmt.Errorf("can't send telemetry of %s: %w", fileName, err)
+	}
+
+	logDelivery(metricsLogger, report, delivery)
+	p.observeClockSkew(metricsLogger, delivery)
+
+	_, historySpan := startSpan(ctx, "save history")
+	err = p.history.Save(ctx, fileName, report, &metrics.HistoryDelivery{SentAt: time.Now().UTC(), RequestID: delivery.RequestID})
+	EndSpan(historySpan, err)
+
+	if err != nil {
+		p.opts.ErrorCounters.AddError(err)
+		return fmt.Errorf("telemetry of %s is sent, but not saved into history: %w", fileName, err)
+	}
+
+	return nil
+}
+
+// createReport creates report of Pillar metrics file, identifying and personal data is hashed and scrubbed,
+// so the report is ready to be sent, saved into history and compared with already sent ones.
+func (p *Processor) createReport(hostInstanceID string, hostMetrics, pillarM *metrics.File) *platformReporter.GenericReport {
+	pillarReport := createPillarReport(hostInstanceID, hostMetrics, pillarM)
+	p.opts.Hasher.Hash(pillarReport.GetMetrics())
+	p.opts.Scrubber.Scrub(pillarReport.GetMetrics())
+	pillarReport.Metrics = append(pillarReport.Metrics, p.opts.ClockSkew.Metrics()...)
+	pillarReport.Metrics = append(pillarReport.Metrics, metrics.TagMetrics(p.opts.Tags)...)
+
+	// skewed creation time corrupts time series of Percona Platform, so it is corrected.
+	if createTime, corrected := p.opts.ClockSkew.Correct(pillarM.Timestamp, time.Now()); corrected {
+		pillarReport.CreateTime = timestamppb.New(createTime)
+	}
+
+	return pillarReport
+}
+
+// observeClockSkew measures skew of host clock with Percona Platform response time, it is logged
+// once it exceeds the maximum tolerated skew.
+func (p *Processor) observeClockSkew(l *zap.SugaredLogger, delivery *platform.Delivery) {
+	if !p.opts.ClockSkew.Observe(delivery.ReceivedAt, delivery.ServerTime) {
+		return
+	}
+
+	skew, _ := p.opts.ClockSkew.Skew()
+	l.Warnw("host clock is skewed relative to Percona Platform clock, creation time of reports is corrected",
+		zap.Duration("skew", skew))
+}
+
+// waitUntil waits until t or until ctx is done, it returns ctx error in the latter case.
+func waitUntil(ctx context.Context, t time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// groupFileNames returns unique names of metrics files of the group, documents of NDJSON metrics file share the same name.
+func groupFileNames(group []*metrics.File) []string {
+	fileNames := make([]string, 0, len(group))
+	for _, pillarM := range group {
+		if !slices.Contains(fileNames, pillarM.Filename) {
+			fileNames = append(fileNames, pillarM.Filename)
+		}
+	}
+
+	return fileNames
+}
+
+// processGroup sends metrics files of the group to Percona Platform within one request,
+// saves the request into history and removes the metrics files.
+// It returns true if metrics files are removed without sending as all their reports are suppressed.
+func (p *Processor) processGroup(ctx context.Context, group []*metrics.File, groupFiles []string,
+	hostInstanceID string, hostMetrics *metrics.File,
+) (suppressed bool, err error) {
+	l := logger.FromContext(ctx).Sugar()
+
+	ctx, span := startSpan(ctx, "process metrics files", trace.WithAttributes(attribute.StringSlice("files", groupFiles)))
+	defer func() {
+		span.SetAttributes(attribute.Bool("suppressed", suppressed))
+		EndSpan(span, err)
+	}()
+
+	// prepare request to Percona Platform.
+	// files of the same database instance are sent within one batched request, identical snapshots are sent once.
+	uniqueFiles := metrics.DeduplicateMetricsFiles(group)
+
+	metricsLogger := l.With(zap.Strings("files", groupFiles))
+
+	reports := make([]*platformReporter.GenericReport, 0, len(uniqueFiles))
+	reportHashes := make([]string, 0, len(uniqueFiles))
+
+	for _, pillarM := range uniqueFiles {
+		pillarReport := p.createReport(hostInstanceID, hostMetrics, pillarM)
+
+		// some Pillars re-drop unchanged snapshots, identical payload sent recently is not sent again.
+		reportHash := metrics.ReportHash(pillarReport)
+		if p.sentReports.IsDuplicate(reportHash) {
+			metricsLogger.Infow("duplicate report suppressed, identical report was sent recently",
+				zap.String("pillar file", pillarM.Filename),
+				zap.String("report hash", reportHash))
+
+			if err := p.saveSuppressed(ctx, pillarM.Filename, pillarReport); err != nil {
+				metricsLogger.Errorw("failed to record suppressed report into history file, will try on next iteration",
+					zap.Error(err))
+
+				return false, err
+			}
+
+			continue
+		}
+
+		reports = append(reports, pillarReport)
+		reportHashes = append(reportHashes, reportHash)
+	}
+
+	if len(reports) == 0 {
+		if p.opts.DryRun {
+			// metrics files are left untouched in dry-run mode.
+			return true, nil
+		}
+
+		// nothing to send, all reports are suppressed.
+		p.finishMetricsFiles(ctx, groupFiles)
+
+		return true, nil
+	}
+
+	span.SetAttributes(attribute.Int("reports", len(reports)))
+
+	report := &platformReporter.ReportRequest{
+		Reports: reports,
+	}
+
+	if p.opts.DryRun {
+		// print request instead of sending it, metrics files are not removed.
+		return false, WriteReport(p.opts.DryRunOutput, report)
+	}
+
+	// two-phase handling: files are moved into processing directory before sending and removed only after
+	// both sending and writing history succeed, files left there by terminated agent are recovered on start.
+	// Files recorded in state database are kept in place, they are recorded only after the same steps succeed.
+	processingFiles, err := p.moveToProcessing(ctx, groupFiles)
+	if err != nil {
+		p.opts.ErrorCounters.AddError(err)
+		metricsLogger.Errorw("failed to move metrics files into processing directory, will try on next iteration", zap.Error(err))
+		return false, err
+	}
+
+	delivery := &platform.Delivery{}
+
+	sendCtx, sendSpan := startSpan(ctx, "send")
+	platformCtx := platform.WithDelivery(platformLogger.GetContextWithLogger(sendCtx, metricsLogger.Desugar()), delivery)
+	// send request to Percona Platform
+	err = p.sender.SendTelemetry(platformCtx, "", report)
+	EndSpan(sendSpan, err)
+
+	if !errors.Is(err, context.Canceled) {
+		p.onSend(err)
+	}
+
+	if err != nil {
+		// we can't continue this particular metrics file processing because we don't know what was sent and what was not.
+		// try to send this metrics file again on next iteration.
+		p.releaseMetricsFiles(ctx, processingFiles, groupFiles)
+
+		switch {
+		case errors.Is(err, context.Canceled):
+			// main process loop is terminated, no need to continue.
+			return false, err
+		default:
+			// any other errors during sending data (including request timeout).
+			p.opts.ErrorCounters.AddError(err)
+			metricsLogger.Warnw("error during sending telemetry, will try on next iteration", zap.Error(err))
+
+			if p.opts.StateDB == nil {
+				// files kept in place can't be moved into failed directory, they are retried forever.
+				p.registerSendFailure(ctx, groupFiles, err)
+			}
+
+			return false, fmt.Errorf("can't send telemetry of %s: %w", strings.Join(groupFiles, ", "), err)
+		}
+	}
+
+	logDelivery(metricsLogger, report, delivery)
+	p.observeClockSkew(metricsLogger, delivery)
+
+	// save sent data into history, batched request is saved once under the name of its first file.
+	_, historySpan := startSpan(ctx, "save history")
+	err = p.history.Save(ctx, groupFiles[0], report, &metrics.HistoryDelivery{SentAt: time.Now().UTC(), RequestID: delivery.RequestID})
+	EndSpan(historySpan, err)
+
+	if err != nil {
+		p.opts.ErrorCounters.AddError(err)
+		metricsLogger.Errorw("failed to write metrics into history file, will try on next iteration", zap.Error(err))
+		p.releaseMetricsFiles(ctx, processingFiles, groupFiles)
+
+		return false, err
+	}
+
+	for _, reportHash := range reportHashes {
+		p.sentReports.Add(reportHash)
+	}
+
+	// remove original Pillar's metrics files
+	p.finishMetricsFiles(ctx, processingFiles)
+
+	return false, nil
+}
+
+// saveSuppressed records report of metrics file suppressed as a duplicate into history, so removal of
+// metrics file without sending can be audited. Nothing is recorded in dry-run mode.
+func (p *Processor) saveSuppressed(ctx context.Context, metricsFile string, report *platformReporter.GenericReport) error {
+	if p.opts.DryRun {
+		return nil
+	}
+
+	_, span := startSpan(ctx, "save suppressed")
+	err := p.history.Save(ctx, metricsFile, &platformReporter.ReportRequest{Reports: []*platformReporter.GenericReport{report}},
+		&metrics.HistoryDelivery{SentAt: time.Now().UTC(), Suppressed: true})
+	EndSpan(span, err)
+
+	if err != nil {
+		p.opts.ErrorCounters.AddError(err)
+	}
+
+	return err
+}
+
+// finishMetricsFiles removes sent or suppressed metrics files, or records them as processed in state database
+// if it is set, so they are not sent again.
+func (p *Processor) finishMetricsFiles(ctx context.Context, fileNames []string) {
+	if p.opts.StateDB == nil {
+		removeMetricsFiles(ctx, fileNames)
+		return
+	}
+
+	_, span := startSpan(ctx, "record processed", trace.WithAttributes(attribute.StringSlice("files", fileNames)))
+	err := p.opts.StateDB.MarkProcessed(fileNames...)
+	EndSpan(span, err)
+
+	if err != nil {
+		p.opts.ErrorCounters.AddError(err)
+		logger.FromContext(ctx).Sugar().Errorw("failed to record processed metrics files in state database, they are sent again on next iteration",
+			zap.Strings("files", fileNames),
+			zap.Error(err))
+	}
+}
+
+// logDelivery logs IDs of sent reports along with request ID assigned by Percona Platform,
+// so support can find the request in server-side logs.
+func logDelivery(l *zap.SugaredLogger, report *platformReporter.ReportRequest, delivery *platform.Delivery) {
+	reportIDs := make([]string, 0, len(report.GetReports()))
+	for _, r := range report.GetReports() {
+		reportIDs = append(reportIDs, r.GetId())
+	}
+
+	l.Infow("telemetry is sent to Percona Platform",
+		zap.Strings("report ids", reportIDs),
+		zap.String("request id", delivery.RequestID))
+}
+
+// moveToProcessing moves metrics files into processing directory under their path relative to telemetry root
+// and returns their new paths. Files are moved back if any of them fails to be moved.
+// Files are kept in place if state database is set.
+func (p *Processor) moveToProcessing(ctx context.Context, fileNames []string) ([]string, error) {
+	if p.opts.StateDB != nil {
+		return fileNames, nil
+	}
+
+	processingFiles := make([]string, 0, len(fileNames))
+
+	for _, fileName := range fileNames {
+		relName, err := filepath.Rel(p.opts.RootPath, fileName)
+		processingFile := filepath.Join(p.opts.ProcessingPath, relName)
+
+		if err == nil {
+			err = metrics.MoveMetricsFile(fileName, processingFile)
+		}
+
+		if err != nil {
+			restoreFromProcessing(ctx, processingFiles, fileNames[:len(processingFiles)])
+			return nil, fmt.Errorf("can't move metrics file %s into processing directory: %w", fileName, err)
+		}
+
+		processingFiles = append(processingFiles, processingFile)
+	}
+
+	return processingFiles, nil
+}
+
+// registerSendFailure counts failed attempt to send metrics files, files failed too many times are moved into
+// '<failed dir>/<Pillar directory name>/[<instance name>/]', so a poison file is not retried indefinitely.
+func (p *Processor) registerSendFailure(ctx context.Context, fileNames []string, sendErr error) {
+	l := logger.FromContext(ctx).Sugar()
+
+	for _, fileName := range fileNames {
+		fl := l.With(zap.String("file", fileName))
+
+		relDir, err := filepath.Rel(p.opts.RootPath, filepath.Dir(fileName))
+		if err != nil {
+			fl.Warnw("failed to get metrics file directory relative to telemetry root", zap.Error(err))
+			continue
+		}
+
+		moved, err := metrics.RegisterSendFailure(ctx, fileName, filepath.Join(p.opts.FailedPath, relDir), sendErr, p.opts.MaxSendAttempts)
+		if err != nil {
+			fl.Errorw("failed to register failed attempt to send metrics file", zap.Error(err))
+			continue
+		}
+
+		if moved {
+			fl.Errorw("metrics file failed to be sent too many times, it is moved into failed directory",
+				zap.String("directory", p.opts.FailedPath),
+				zap.Int("attempts", p.opts.MaxSendAttempts))
+		}
+	}
+}
+
+// releaseMetricsFiles moves metrics files failed to be sent or saved back from processing directory,
+// so they are processed again on next iteration. Files kept in place if state database is set are not moved.
+func (p *Processor) releaseMetricsFiles(ctx context.Context, processingFiles, fileNames []string) {
+	if p.opts.StateDB != nil {
+		return
+	}
+
+	restoreFromProcessing(ctx, processingFiles, fileNames)
+}
+
+// restoreFromProcessing moves metrics files from processing directory back to their original location,
+// so they are processed again on next iteration.
+func restoreFromProcessing(ctx context.Context, processingFiles, fileNames []string) {
+	l := logger.FromContext(ctx).Sugar()
+
+	for i, processingFile := range processingFiles {
+		if err := metrics.MoveMetricsFile(processingFile, fileNames[i]); err != nil {
+			l.Errorw("failed to move metrics file back from processing directory, it is recovered on next start",
+				zap.String("file", processingFile),
+				zap.Error(err))
+		}
+	}
+}
+
+// WriteReport writes Percona Platform request in JSON.
+func WriteReport(w io.Writer, report *platformReporter.ReportRequest) error {
+	jsonBytes, err := protojson.MarshalOptions{Indent: "  "}.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("can't marshal Percona Platform report into JSON: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(jsonBytes))
+
+	return err
+}
+
+// removeMetricsFiles removes original Pillar's metrics files.
+func removeMetricsFiles(ctx context.Context, fileNames []string) {
+	l := logger.FromContext(ctx).Sugar()
+
+	for _, fileName := range fileNames {
+		l.Infow("removing metrics file", zap.String("file", fileName))
+
+		_, span := startSpan(ctx, "remove", trace.WithAttributes(attribute.String("file", fileName)))
+		err := metrics.RemoveMetricsFile(fileName)
+		EndSpan(span, err)
+
+		if err != nil {
+			l.Errorw("failed to remove metrics file, will try on next iteration",
+				zap.String("file", fileName),
+				zap.Error(err))
+		}
+	}
+}
+
+// createPillarReport creates Percona Platform report from Pillar's metrics file and host metrics.
+func createPillarReport(hostInstanceID string, hostMetrics *metrics.File, pillarM *metrics.File) *platformReporter.GenericReport {
+	return &platformReporter.GenericReport{
+		Id:            uuid.New().String(), // each request shall have unique ID
+		CreateTime:    timestamppb.New(pillarM.Timestamp),
+		InstanceId:    hostInstanceID,
+		ProductFamily: pillarM.ProductFamily,
+		Metrics:       metrics.ReportMetrics(hostMetrics, pillarM),
+	}
+}
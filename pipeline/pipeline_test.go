@@ -0,0 +1,536 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+// fakeCollector returns metrics files given by test, files are created on disk.
+type fakeCollector struct {
+	files []*metrics.File
+	// batches counts calls of CollectPillarsMetrics.
+	batches int
+}
+
+func (c *fakeCollector) CollectPillarsMetrics(_ context.Context, batch *metrics.Batch) []*metrics.File {
+	c.batches++
+
+	// files removed during previous processing are not collected again.
+	collected := make([]*metrics.File, 0, len(c.files))
+
+	for _, f := range c.files {
+		if _, err := os.Stat(f.Filename); err != nil {
+			continue
+		}
+
+		if batch != nil && !batch.Take(f.Filename) {
+			continue
+		}
+
+		collected = append(collected, f)
+	}
+
+	return collected
+}
+
+func (c *fakeCollector) CollectHostMetrics(context.Context) (string, *metrics.File) {
+	return "host-instance-id", &metrics.File{Metrics: map[string]string{"OS": "Ubuntu"}}
+}
+
+// fakeSender records sent reports and fails with err if it is set.
+type fakeSender struct {
+	mu      sync.Mutex
+	err     error
+	reports []*platformReporter.ReportRequest
+}
+
+func (s *fakeSender) SendTelemetry(_ context.Context, _ string, report *platformReporter.ReportRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.reports = append(s.reports, report)
+
+	return nil
+}
+
+// fakeHistory records saved reports by metrics file name, records of suppressed reports are kept separately.
+type fakeHistory struct {
+	mu         sync.Mutex
+	saved      map[string]*platformReporter.ReportRequest
+	suppressed []string
+}
+
+func (h *fakeHistory) Save(_ context.Context, metricsFile string, report *platformReporter.ReportRequest, delivery *metrics.HistoryDelivery) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if delivery != nil && delivery.Suppressed {
+		h.suppressed = append(h.suppressed, metricsFile)
+		return nil
+	}
+
+	h.saved[metricsFile] = report
+
+	return nil
+}
+
+func newTestMetricsFile(t *testing.T, rootDir, name string) *metrics.File {
+	t.Helper()
+
+	fileName := filepath.Join(rootDir, "ps", name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0o700))
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"db_instance_id": "`+name+`"}`), 0o600))
+
+	return &metrics.File{
+		Filename:      fileName,
+		Timestamp:     time.Now(),
+		ProductFamily: platformReporter.ProductFamily_PRODUCT_FAMILY_PS,
+		Metrics:       map[string]string{"db_instance_id": name},
+	}
+}
+
+func newTestOpts(rootDir string) Opts {
+	return Opts{
+		RootPath:        rootDir,
+		ProcessingPath:  filepath.Join(rootDir, "processing"),
+		FailedPath:      filepath.Join(rootDir, "failed"),
+		Workers:         2,
+		MaxSendAttempts: 10,
+	}
+}
+
+func TestProcess(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	files := []*metrics.File{
+		newTestMetricsFile(t, rootDir, "1708026156-a.json"),
+		newTestMetricsFile(t, rootDir, "1708026157-b.json"),
+	}
+
+	sender := &fakeSender{}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+
+	var (
+		sendResults []error
+		progress    int
+	)
+
+	opts := newTestOpts(rootDir)
+	// observers are not synchronized, so requests are sent one by one.
+	opts.Workers = 1
+
+	p := New(&fakeCollector{files: files}, sender, history, opts,
+		WithSendObserver(func(err error) {
+			sendResults = append(sendResults, err)
+		}),
+		WithProgressObserver(func() {
+			progress++
+		}))
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 2, Sent: 2}, res)
+
+	require.Len(t, sender.reports, 2)
+	require.Len(t, sendResults, 2)
+	require.NoError(t, errors.Join(sendResults...))
+	// progress is reported for each request and for the batch.
+	require.Equal(t, 3, progress)
+
+	for _, f := range files {
+		require.Contains(t, history.saved, f.Filename)
+		require.NoFileExists(t, f.Filename)
+		require.NoFileExists(t, filepath.Join(rootDir, "processing", "ps", filepath.Base(f.Filename)))
+
+		report := history.saved[f.Filename].GetReports()[0]
+		require.Equal(t, "host-instance-id", report.GetInstanceId())
+		require.Equal(t, platformReporter.ProductFamily_PRODUCT_FAMILY_PS, report.GetProductFamily())
+	}
+}
+
+func TestProcessTags(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	files := []*metrics.File{newTestMetricsFile(t, rootDir, "1708026156-a.json")}
+
+	sender := &fakeSender{}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+
+	opts := newTestOpts(rootDir)
+	opts.Tags = map[string]string{"team": "dba", "environment": "prod"}
+	// tags are set by the owner of the fleet, so they are neither hashed nor scrubbed.
+	hasher, err := NewHasher([]string{"tag.environment"}, []byte("salt"))
+	require.NoError(t, err)
+
+	opts.Hasher = hasher
+
+	p := New(&fakeCollector{files: files}, sender, history, opts)
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+
+	reportMetrics := make(map[string]string)
+	for _, m := range history.saved[files[0].Filename].GetReports()[0].GetMetrics() {
+		reportMetrics[m.GetKey()] = m.GetValue()
+	}
+
+	require.Equal(t, "prod", reportMetrics["tag.environment"])
+	require.Equal(t, "dba", reportMetrics["tag.team"])
+	require.Equal(t, "1708026156-a.json", reportMetrics["db_instance_id"])
+}
+
+func TestProcessTracing(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	files := []*metrics.File{
+		newTestMetricsFile(t, rootDir, "1708026156-a.json"),
+		newTestMetricsFile(t, rootDir, "1708026157-b.json"),
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	sender := &fakeSender{err: errors.New("platform is unavailable")}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+
+	p := New(&fakeCollector{files: files}, sender, history, newTestOpts(rootDir), WithTracerProvider(provider))
+
+	_, err := p.Process(t.Context())
+	require.Error(t, err)
+
+	spans := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+
+	require.Len(t, spans["process metrics"], 1)
+	root := spans["process metrics"][0]
+	require.Equal(t, codes.Error, root.Status().Code)
+
+	// each metrics file is processed within its own span with failed send child span.
+	require.Len(t, spans["process metrics files"], 2)
+	require.Len(t, spans["send"], 2)
+
+	for _, span := range spans["process metrics files"] {
+		require.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID())
+		require.Equal(t, codes.Error, span.Status().Code)
+	}
+
+	for _, span := range spans["send"] {
+		require.Equal(t, codes.Error, span.Status().Code)
+	}
+
+	// nothing is saved or removed after failed send.
+	require.Empty(t, spans["save history"])
+	require.Empty(t, spans["remove"])
+}
+
+func TestProcessSendSpread(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	files := []*metrics.File{
+		newTestMetricsFile(t, rootDir, "1708026156-a.json"),
+		newTestMetricsFile(t, rootDir, "1708026157-b.json"),
+		newTestMetricsFile(t, rootDir, "1708026158-c.json"),
+	}
+
+	sender := &fakeSender{}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+	opts := newTestOpts(rootDir)
+	opts.SendSpread = 300 * time.Millisecond
+
+	p := New(&fakeCollector{files: files}, sender, history, opts)
+
+	// the last request is sent at 2/3 of the window.
+	start := time.Now()
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 3, Sent: 3}, res)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// waiting is interrupted by context cancellation, unsent files are kept.
+	files = append(files, newTestMetricsFile(t, rootDir, "1708026159-d.json"), newTestMetricsFile(t, rootDir, "1708026160-e.json"))
+	opts.SendSpread = time.Hour
+	p = New(&fakeCollector{files: files}, sender, history, opts)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	res, err = p.Process(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, Result{Processed: 2, Sent: 1, Failed: 1}, res)
+	require.FileExists(t, files[4].Filename)
+}
+
+func TestProcessSendFailure(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	file := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+
+	sender := &fakeSender{err: errors.New("platform is unavailable")}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+	opts := newTestOpts(rootDir)
+	opts.MaxSendAttempts = 2
+	opts.ErrorCounters = metrics.NewErrorCounters()
+
+	p := New(&fakeCollector{files: []*metrics.File{file}}, sender, history, opts)
+
+	// file is restored and its failed attempt is counted.
+	res, err := p.Process(t.Context())
+	require.ErrorIs(t, err, sender.err)
+	require.Equal(t, Result{Processed: 1, Failed: 1}, res)
+	require.FileExists(t, file.Filename)
+	require.FileExists(t, file.Filename+".attempts")
+	require.Empty(t, history.saved)
+
+	// file is moved into failed directory after the last attempt.
+	_, err = p.Process(t.Context())
+	require.ErrorIs(t, err, sender.err)
+	require.NoFileExists(t, file.Filename)
+	require.FileExists(t, filepath.Join(rootDir, "failed", "ps", filepath.Base(file.Filename)))
+	require.Empty(t, history.saved)
+
+	// both failed attempts are counted.
+	require.Equal(t, int64(2), opts.ErrorCounters.Counts()[metrics.ErrorCategoryOther])
+}
+
+func TestProcessDryRun(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	file := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+
+	var out bytes.Buffer
+
+	opts := newTestOpts(rootDir)
+	opts.DryRun = true
+	opts.DryRunOutput = &out
+
+	// sender and history store are not used in dry-run mode.
+	p := New(&fakeCollector{files: []*metrics.File{file}}, nil, nil, opts)
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Sent: 1}, res)
+	require.Contains(t, out.String(), "host-instance-id")
+	require.FileExists(t, file.Filename)
+	require.NoDirExists(t, opts.ProcessingPath)
+}
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	files := []*metrics.File{
+		newTestMetricsFile(t, rootDir, "1708026156-a.json"),
+		newTestMetricsFile(t, rootDir, "1708026157-b.json"),
+	}
+
+	opts := newTestOpts(rootDir)
+
+	// reports are only assembled, so sender and history store are not used.
+	p := New(&fakeCollector{files: files}, nil, nil, opts)
+
+	report := p.Collect(t.Context())
+	require.Len(t, report.GetReports(), 2)
+	require.Equal(t, "host-instance-id", report.GetReports()[0].GetInstanceId())
+
+	for _, f := range files {
+		require.FileExists(t, f.Filename)
+	}
+
+	require.NoDirExists(t, opts.ProcessingPath)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteReport(&out, report))
+	require.Contains(t, out.String(), "1708026157-b.json")
+
+	// no metrics files, nothing would be sent.
+	require.Empty(t, New(&fakeCollector{}, nil, nil, opts).Collect(t.Context()).GetReports())
+}
+
+func TestSendMetricsFile(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	file := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+
+	sender := &fakeSender{}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+	p := New(&fakeCollector{}, sender, history, newTestOpts(rootDir))
+
+	require.NoError(t, p.SendMetricsFile(t.Context(), []*metrics.File{file}))
+	require.Len(t, sender.reports, 1)
+	require.Equal(t, "host-instance-id", sender.reports[0].GetReports()[0].GetInstanceId())
+	require.Equal(t, sender.reports[0], history.saved[file.Filename])
+	// replayed file is left in place.
+	require.FileExists(t, file.Filename)
+
+	sender.err = errors.New("service unavailable")
+	require.ErrorContains(t, p.SendMetricsFile(t.Context(), []*metrics.File{file}), "service unavailable")
+	require.Len(t, sender.reports, 1)
+
+	require.Error(t, p.SendMetricsFile(t.Context(), nil))
+}
+
+func TestProcessDuplicateSuppression(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	file := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+
+	sender := &fakeSender{}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+	opts := newTestOpts(rootDir)
+	opts.DedupeWindow = time.Hour
+
+	collector := &fakeCollector{files: []*metrics.File{file}}
+	p := New(collector, sender, history, opts)
+
+	_, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sender.reports, 1)
+
+	// Pillar re-drops unchanged snapshot, it is removed without sending.
+	duplicate := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+	collector.files = []*metrics.File{duplicate}
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Suppressed: 1}, res)
+	require.Len(t, sender.reports, 1)
+	require.NoFileExists(t, duplicate.Filename)
+	// suppressed report is recorded into history.
+	require.Equal(t, []string{duplicate.Filename}, history.suppressed)
+}
+
+func TestProcessDuplicateSuppressionAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	historyDir := filepath.Join(rootDir, "history")
+	require.NoError(t, os.Mkdir(historyDir, 0o700))
+
+	sender := &fakeSender{}
+	opts := newTestOpts(rootDir)
+	opts.DedupeWindow = time.Hour
+
+	file := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+	p := New(&fakeCollector{files: []*metrics.File{file}}, sender, NewHistoryDir(historyDir, false), opts)
+
+	_, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sender.reports, 1)
+
+	// restarted agent loads sent reports from history.
+	sentReports := metrics.NewSentReports(opts.DedupeWindow)
+	require.NoError(t, sentReports.LoadHistory(historyDir))
+
+	duplicate := newTestMetricsFile(t, rootDir, "1708026157-a.json")
+	// re-dropped snapshot has the same metrics under new name.
+	duplicate.Metrics = file.Metrics
+
+	p = New(&fakeCollector{files: []*metrics.File{duplicate}}, sender, NewHistoryDir(historyDir, false), opts,
+		WithSentReports(sentReports))
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 1, Suppressed: 1}, res)
+	require.Len(t, sender.reports, 1)
+	require.NoFileExists(t, duplicate.Filename)
+	require.FileExists(t, filepath.Join(historyDir, "1708026157-a.suppressed.json"))
+
+	// records of suppressed reports are not taken for sent ones.
+	sentReports = metrics.NewSentReports(opts.DedupeWindow)
+	require.NoError(t, os.Remove(filepath.Join(historyDir, "1708026156-a.json")))
+	require.NoError(t, sentReports.LoadHistory(historyDir))
+	require.False(t, sentReports.IsDuplicate(metrics.ReportHash(sender.reports[0].GetReports()[0])))
+}
+
+func TestProcessBatches(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	files := []*metrics.File{
+		newTestMetricsFile(t, rootDir, "1708026156-a.json"),
+		newTestMetricsFile(t, rootDir, "1708026157-b.json"),
+		newTestMetricsFile(t, rootDir, "1708026158-c.json"),
+		newTestMetricsFile(t, rootDir, "1708026159-d.json"),
+		newTestMetricsFile(t, rootDir, "1708026160-e.json"),
+	}
+
+	opts := newTestOpts(rootDir)
+	opts.BatchSize = 2
+
+	// all files are sent within one call, at most 2 of them are held in memory at once.
+	sender := &fakeSender{}
+	collector := &fakeCollector{files: files}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+
+	res, err := New(collector, sender, history, opts).Process(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Result{Processed: 5, Sent: 5}, res)
+	require.Len(t, sender.reports, 5)
+	require.Equal(t, 3, collector.batches)
+
+	// files failed to be sent are left in place, but they are not collected again within the same call.
+	files = append(files, newTestMetricsFile(t, rootDir, "1708026161-f.json"),
+		newTestMetricsFile(t, rootDir, "1708026162-g.json"), newTestMetricsFile(t, rootDir, "1708026163-h.json"))
+	collector = &fakeCollector{files: files}
+
+	res, err = New(collector, &fakeSender{err: errors.New("platform is unavailable")}, history, opts).Process(t.Context())
+	require.Error(t, err)
+	require.Equal(t, Result{Processed: 3, Failed: 3}, res)
+	require.Equal(t, 2, collector.batches)
+}
+
+func TestProcessNoMetricsFiles(t *testing.T) {
+	t.Parallel()
+
+	sender := &fakeSender{}
+	p := New(&fakeCollector{}, sender, nil, newTestOpts(t.TempDir()))
+
+	res, err := p.Process(t.Context())
+	require.NoError(t, err)
+	require.Zero(t, res)
+	require.Empty(t, sender.reports)
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"context"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+)
+
+// FamilyRouter sends telemetry of particular product families with dedicated senders,
+// telemetry of other product families is sent with the default sender.
+type FamilyRouter struct {
+	defaultSender Sender
+	routes        map[platformReporter.ProductFamily]Sender
+}
+
+// NewFamilyRouter creates new FamilyRouter.
+func NewFamilyRouter(defaultSender Sender, routes map[platformReporter.ProductFamily]Sender) *FamilyRouter {
+	return &FamilyRouter{
+		defaultSender: defaultSender,
+		routes:        routes,
+	}
+}
+
+// SendTelemetry sends request with the sender of its product family. Metrics files of different product families
+// are never sent within one request, request with mixed product families is sent with the default sender.
+func (r *FamilyRouter) SendTelemetry(ctx context.Context, token string, report *platformReporter.ReportRequest) error {
+	return r.sender(report).SendTelemetry(ctx, token, report)
+}
+
+// sender returns the sender of request product family.
+func (r *FamilyRouter) sender(report *platformReporter.ReportRequest) Sender {
+	reports := report.GetReports()
+	if len(reports) == 0 {
+		return r.defaultSender
+	}
+
+	productFamily := reports[0].GetProductFamily()
+	for _, pillarReport := range reports[1:] {
+		if pillarReport.GetProductFamily() != productFamily {
+			return r.defaultSender
+		}
+	}
+
+	if s, ok := r.routes[productFamily]; ok {
+		return s
+	}
+
+	return r.defaultSender
+}
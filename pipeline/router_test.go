@@ -0,0 +1,81 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"testing"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFamilyRouter(t *testing.T) {
+	t.Parallel()
+
+	newReport := func(productFamilies ...platformReporter.ProductFamily) *platformReporter.ReportRequest {
+		report := &platformReporter.ReportRequest{}
+		for _, productFamily := range productFamilies {
+			report.Reports = append(report.Reports, &platformReporter.GenericReport{ProductFamily: productFamily})
+		}
+
+		return report
+	}
+
+	testCases := []struct {
+		name          string
+		report        *platformReporter.ReportRequest
+		expectPMM     bool
+		expectDefault bool
+	}{
+		{
+			name:      "routed_family",
+			report:    newReport(platformReporter.ProductFamily_PRODUCT_FAMILY_PMM, platformReporter.ProductFamily_PRODUCT_FAMILY_PMM),
+			expectPMM: true,
+		},
+		{
+			name:          "not_routed_family",
+			report:        newReport(platformReporter.ProductFamily_PRODUCT_FAMILY_PS),
+			expectDefault: true,
+		},
+		{
+			name:          "mixed_families",
+			report:        newReport(platformReporter.ProductFamily_PRODUCT_FAMILY_PMM, platformReporter.ProductFamily_PRODUCT_FAMILY_PS),
+			expectDefault: true,
+		},
+		{
+			name:          "empty_request",
+			report:        newReport(),
+			expectDefault: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			defaultSender := &fakeSender{}
+			pmmSender := &fakeSender{}
+			router := NewFamilyRouter(defaultSender, map[platformReporter.ProductFamily]Sender{
+				platformReporter.ProductFamily_PRODUCT_FAMILY_PMM: pmmSender,
+			})
+
+			require.NoError(t, router.SendTelemetry(t.Context(), "", tt.report))
+
+			require.Equal(t, tt.expectPMM, len(pmmSender.reports) == 1)
+			require.Equal(t, tt.expectDefault, len(defaultSender.reports) == 1)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+)
+
+// ScrubbedValue replaces scrubbed parts of metric values.
+const ScrubbedValue = "[SCRUBBED]"
+
+// Built-in scrubbing rules.
+const (
+	ScrubRuleHostname = "hostname" // host name of the local host, both fully qualified and short
+	ScrubRuleIP       = "ip"       // IPv4 and IPv6 addresses
+	ScrubRuleEmail    = "email"    // email-like strings
+	ScrubRulePath     = "path"     // absolute file paths
+)
+
+var (
+	ipv4Regexp  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Regexp  = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}(?:%[0-9A-Za-z]+)?`)
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// path starts at the beginning of value or after separator and its first character is not slash, so URLs are not matched.
+	pathRegexp = regexp.MustCompile(`(^|[\s=,;:'"(\[])/[^\s/,;'"()\[\]][^\s,;'"()\[\]]*`)
+)
+
+// Scrubber strips personal data from metric values before they are sent to Percona Platform.
+// Values of metrics with matching keys are replaced entirely, matching parts of other values are replaced with ScrubbedValue.
+type Scrubber struct {
+	keyPatterns []string
+	replacers   []func(string) string
+}
+
+// NewScrubber creates Scrubber applying given built-in rules, regular expressions
+// and case-insensitive shell patterns of metric keys.
+func NewScrubber(rules, patterns, keyPatterns []string) (*Scrubber, error) {
+	s := &Scrubber{}
+
+	for _, p := range keyPatterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid scrub key pattern %q: %w", p, err)
+		}
+
+		s.keyPatterns = append(s.keyPatterns, p)
+	}
+
+	for _, rule := range rules {
+		replacer, err := builtinScrubRule(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		s.replacers = append(s.replacers, replacer)
+	}
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %q: %w", p, err)
+		}
+
+		s.replacers = append(s.replacers, func(v string) string {
+			return re.ReplaceAllLiteralString(v, ScrubbedValue)
+		})
+	}
+
+	return s, nil
+}
+
+func builtinScrubRule(rule string) (func(string) string, error) {
+	switch rule {
+	case ScrubRuleHostname:
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("can't get host name for scrubbing: %w", err)
+		}
+
+		names := []string{regexp.QuoteMeta(hostname)}
+		if short, _, ok := strings.Cut(hostname, "."); ok && short != "" {
+			names = append(names, regexp.QuoteMeta(short))
+		}
+
+		// fully qualified name goes first, so it is replaced entirely.
+		re := regexp.MustCompile(`(?i)\b(?:` + strings.Join(names, "|") + `)\b`)
+
+		return func(v string) string {
+			return re.ReplaceAllLiteralString(v, ScrubbedValue)
+		}, nil
+	case ScrubRuleIP:
+		return func(v string) string {
+			v = replaceValid(ipv4Regexp, v)
+			return replaceValid(ipv6Regexp, v)
+		}, nil
+	case ScrubRuleEmail:
+		return func(v string) string {
+			return emailRegexp.ReplaceAllLiteralString(v, ScrubbedValue)
+		}, nil
+	case ScrubRulePath:
+		return func(v string) string {
+			return pathRegexp.ReplaceAllString(v, "${1}"+ScrubbedValue)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown scrub rule %q", rule)
+	}
+}
+
+// replaceValid replaces matches which are valid IP addresses, e.g. time '10:20:30' is kept.
+func replaceValid(re *regexp.Regexp, v string) string {
+	return re.ReplaceAllStringFunc(v, func(m string) string {
+		addr, _, _ := strings.Cut(m, "%")
+		if net.ParseIP(addr) == nil {
+			return m
+		}
+
+		return ScrubbedValue
+	})
+}
+
+// Scrub scrubs values of metrics in place. Nil Scrubber doesn't change metrics.
+func (s *Scrubber) Scrub(metrics []*platformReporter.GenericReport_Metric) {
+	if s == nil {
+		return
+	}
+
+	for _, m := range metrics {
+		m.Value = s.scrubValue(m.GetKey(), m.GetValue())
+	}
+}
+
+func (s *Scrubber) scrubValue(key, value string) string {
+	key = strings.ToLower(key)
+	for _, p := range s.keyPatterns {
+		if ok, _ := path.Match(p, key); ok {
+			return ScrubbedValue
+		}
+	}
+
+	for _, replace := range s.replacers {
+		value = replace(value)
+	}
+
+	return value
+}
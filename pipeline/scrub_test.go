@@ -0,0 +1,161 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	platformReporter "github.com/percona/platform/gen/telemetry/generic"
+	"github.com/stretchr/testify/require"
+
+	"github.com/percona/telemetry-agent/metrics"
+)
+
+func TestScrubber(t *testing.T) {
+	t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		rules       []string
+		patterns    []string
+		keyPatterns []string
+		key         string
+		value       string
+		expected    string
+	}{
+		{
+			name:     "no_rules",
+			key:      "datadir",
+			value:    "/var/lib/mysql",
+			expected: "/var/lib/mysql",
+		},
+		{
+			name:     "ip",
+			rules:    []string{ScrubRuleIP},
+			key:      "replica_source",
+			value:    "10.0.0.1:3306, [fe80::1%eth0]:3306, started at 10:20:30",
+			expected: "[SCRUBBED]:3306, [[SCRUBBED]]:3306, started at 10:20:30",
+		},
+		{
+			name:     "email",
+			rules:    []string{ScrubRuleEmail},
+			key:      "admin",
+			value:    "contact: dba@example.com",
+			expected: "contact: [SCRUBBED]",
+		},
+		{
+			name:     "path",
+			rules:    []string{ScrubRulePath},
+			key:      "options",
+			value:    "/usr/sbin/mysqld --datadir=/var/lib/mysql --url=https://example.com/v1",
+			expected: "[SCRUBBED] --datadir=[SCRUBBED] --url=https://example.com/v1",
+		},
+		{
+			name:     "hostname",
+			rules:    []string{ScrubRuleHostname},
+			key:      "source",
+			value:    "replica of " + hostname,
+			expected: "replica of [SCRUBBED]",
+		},
+		{
+			name:     "pattern",
+			patterns: []string{`customer-\d+`},
+			key:      "cluster_name",
+			value:    "customer-42-prod",
+			expected: "[SCRUBBED]-prod",
+		},
+		{
+			name:        "key_pattern",
+			rules:       []string{ScrubRuleIP},
+			keyPatterns: []string{"*_HOST"},
+			key:         "source_host",
+			value:       "db1",
+			expected:    "[SCRUBBED]",
+		},
+		{
+			name:     "version_is_kept",
+			rules:    []string{ScrubRuleHostname, ScrubRuleIP, ScrubRuleEmail, ScrubRulePath},
+			key:      "pillar_version",
+			value:    "8.0.36-28",
+			expected: "8.0.36-28",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := NewScrubber(tt.rules, tt.patterns, tt.keyPatterns)
+			require.NoError(t, err)
+
+			m := []*platformReporter.GenericReport_Metric{{Key: tt.key, Value: tt.value}}
+			s.Scrub(m)
+			require.Equal(t, tt.expected, m[0].GetValue())
+		})
+	}
+}
+
+func TestNewScrubberInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewScrubber([]string{"phone"}, nil, nil)
+	require.Error(t, err)
+
+	_, err = NewScrubber(nil, []string{"("}, nil)
+	require.Error(t, err)
+
+	_, err = NewScrubber(nil, nil, []string{"["})
+	require.Error(t, err)
+}
+
+func TestProcessScrubbing(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	file := newTestMetricsFile(t, rootDir, "1708026156-a.json")
+	file.Metrics["datadir"] = "/var/lib/mysql"
+
+	sender := &fakeSender{}
+	history := &fakeHistory{saved: make(map[string]*platformReporter.ReportRequest)}
+	opts := newTestOpts(rootDir)
+
+	var err error
+	opts.Scrubber, err = NewScrubber([]string{ScrubRulePath}, nil, nil)
+	require.NoError(t, err)
+
+	p := New(&fakeCollector{files: []*metrics.File{file}}, sender, history, opts)
+
+	_, err = p.Process(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sender.reports, 1)
+
+	// scrubbed report is both sent and saved into history.
+	for _, report := range []*platformReporter.ReportRequest{sender.reports[0], history.saved[filepath.Join(rootDir, "ps", "1708026156-a.json")]} {
+		require.NotNil(t, report)
+
+		values := make(map[string]string)
+		for _, m := range report.GetReports()[0].GetMetrics() {
+			values[m.GetKey()] = m.GetValue()
+		}
+
+		require.Equal(t, ScrubbedValue, values["datadir"])
+	}
+}
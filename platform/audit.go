@@ -0,0 +1,117 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	genericv1 "github.com/percona/platform/gen/telemetry/generic"
+)
+
+const (
+	auditLogFilePermissions = 0o600
+	auditLogDirPermissions  = 0o755
+)
+
+// AuditRecord is a single line of audit log describing one report sent to Percona Platform.
+type AuditRecord struct {
+	Time          time.Time `json:"time"`
+	ReportID      string    `json:"report_id"`
+	InstanceID    string    `json:"instance_id"`
+	ProductFamily string    `json:"product_family"`
+	MetricKeys    []string  `json:"metric_keys"`
+	Endpoint      string    `json:"endpoint"`
+	// ResponseCode is HTTP status code of Percona Platform response, 0 if no response was received.
+	ResponseCode int `json:"response_code"`
+	// RequestID is the request ID assigned by Percona Platform, empty if its response has none.
+	RequestID string `json:"request_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AuditLog is append-only log of reports sent to Percona Platform, one JSON line per report.
+// Metric values are not written, so the log proves what was transmitted without duplicating telemetry data.
+// It is safe for concurrent use.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenAuditLog opens audit log file for appending, the file is created if it doesn't exist.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	cleanPath := filepath.Clean(path)
+
+	if err := os.MkdirAll(filepath.Dir(cleanPath), os.ModeDir|auditLogDirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(cleanPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, auditLogFilePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &AuditLog{file: f}, nil
+}
+
+// Record writes audit records of all reports of the request sent to endpoint.
+func (a *AuditLog) Record(endpoint string, request *genericv1.ReportRequest, responseCode int, requestID string, sendErr error) error {
+	now := time.Now().UTC()
+
+	var data []byte
+
+	for _, report := range request.GetReports() {
+		metricKeys := make([]string, 0, len(report.GetMetrics()))
+		for _, m := range report.GetMetrics() {
+			metricKeys = append(metricKeys, m.GetKey())
+		}
+
+		record := AuditRecord{
+			Time:          now,
+			ReportID:      report.GetId(),
+			InstanceID:    report.GetInstanceId(),
+			ProductFamily: report.GetProductFamily().String(),
+			MetricKeys:    metricKeys,
+			Endpoint:      endpoint,
+			ResponseCode:  responseCode,
+			RequestID:     requestID,
+		}
+
+		if sendErr != nil {
+			record.Error = sendErr.Error()
+		}
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit record: %w", err)
+		}
+
+		data = append(append(data, line...), '\n')
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// records of one request are written at once, so they are not interleaved with other requests.
+	if _, err := a.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -28,8 +29,11 @@ import (
 
 	"github.com/go-resty/resty/v2"
 	genericv1 "github.com/percona/platform/gen/telemetry/generic"
-	"github.com/percona/platform/pkg/logger"
+	platformLogger "github.com/percona/platform/pkg/logger"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/percona/telemetry-agent/logger"
 )
 
 // ‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
@@ -47,13 +51,16 @@ type Logger interface {
 // Option is an option for Client returned by constructor.
 type Option func(*Client)
 
-// WithLogFullRequest enable/disables logging of request/response body and headers.
-func WithLogFullRequest() Option {
+// WithLogFullRequest enables logging of request/response body and headers on debug level,
+// values of headers and JSON body keys matching redactor patterns are masked.
+func WithLogFullRequest(redactor *logger.Redactor) Option {
 	return func(c *Client) {
 		tr, _ := c.restyClient.Transport()
-		c.restyClient.SetTransport(
-			logger.HTTP(tr, "perconaPlatformClient", logger.LogFullRequest()),
-		)
+		c.restyClient.SetTransport(&loggingRoundTripper{
+			rt:         tr,
+			loggerName: "perconaPlatformClient",
+			redactor:   redactor,
+		})
 	}
 }
 
@@ -130,9 +137,86 @@ func WithClientTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithAuditLog method sets audit log all sent reports are recorded into.
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(c *Client) {
+		c.auditLog = auditLog
+	}
+}
+
+// WithCredentials method sets provider of access token and client certificate used unless access token is passed
+// explicitly. Client certificate is requested on each TLS handshake, so refreshed certificate is used by new connections.
+func WithCredentials(credentials *CredentialProvider) Option {
+	return func(c *Client) {
+		c.credentials = credentials
+
+		tr := c.httpTransport()
+		if tr == nil {
+			return
+		}
+
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if tr.TLSClientConfig != nil {
+			tlsConfig = tr.TLSClientConfig.Clone()
+		}
+
+		tlsConfig.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return credentials.ClientCertificate(info.Context())
+		}
+		tr.TLSClientConfig = tlsConfig
+	}
+}
+
+// WithUnixSocket method makes client connect to unix socket of local relay daemon instead of base URL host,
+// the relay owns all outbound connectivity. Base URL host is still sent in Host header.
+func WithUnixSocket(socketPath string) Option {
+	return func(c *Client) {
+		c.unixSocket = socketPath
+
+		tr := c.httpTransport()
+		if tr == nil {
+			return
+		}
+
+		dialer := &net.Dialer{}
+		tr.Proxy = nil
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+}
+
 // Client is HTTP Percona Platform client.
 type Client struct {
 	restyClient *resty.Client
+	auditLog    *AuditLog
+	credentials *CredentialProvider
+	// unixSocket is the path of local relay socket requests are sent to, empty if requests are sent over network.
+	unixSocket  string
+	compression *compression
+}
+
+// endpoint returns URL of Percona Platform endpoint at path, unix socket URL is returned for local relay.
+func (c *Client) endpoint(path string) string {
+	if c.unixSocket != "" {
+		return "unix://" + c.unixSocket + ":" + path
+	}
+
+	return c.restyClient.BaseURL + path
+}
+
+// httpTransport returns HTTP transport of the client, nil if custom transport is used.
+func (c *Client) httpTransport() *http.Transport {
+	switch tr := c.restyClient.GetClient().Transport.(type) {
+	case *http.Transport:
+		return tr
+	case *loggingRoundTripper:
+		if t, ok := tr.rt.(*http.Transport); ok {
+			return t
+		}
+	}
+
+	return nil
 }
 
 // New creates new Percona Platform Telemetry client.
@@ -163,7 +247,21 @@ func (c *Client) SendTelemetry(ctx context.Context, accessToken string, report *
 		return fmt.Errorf("failed to marshal telemetry request: %w", err)
 	}
 
-	err = c.sendPostRequest(ctx, path, accessToken, body, nil)
+	info, err := c.sendPostRequest(ctx, path, accessToken, body, nil)
+
+	if delivery := deliveryFromContext(ctx); delivery != nil {
+		delivery.RequestID = info.requestID
+		delivery.ServerTime = info.serverTime
+		delivery.ReceivedAt = info.receivedAt
+	}
+
+	if c.auditLog != nil {
+		if auditErr := c.auditLog.Record(c.endpoint(path), report, info.statusCode, info.requestID, err); auditErr != nil {
+			// telemetry is already sent, so audit failure doesn't fail sending to avoid duplicates.
+			platformLogger.GetLoggerFromContext(ctx).Error("failed to record sent telemetry into audit log", zap.Error(auditErr))
+		}
+	}
+
 	if err != nil {
 		return fmt.Errorf("failed to send telemetry data: %w", err)
 	}
@@ -171,6 +269,33 @@ func (c *Client) SendTelemetry(ctx context.Context, accessToken string, report *
 	return nil
 }
 
+// ResponseError is returned when Percona Platform responds with error status code.
+type ResponseError struct {
+	StatusCode int
+	// RequestID is the request ID assigned by Percona Platform, empty if the response has none.
+	RequestID string
+	Err       error
+}
+
+// Error error interface implementation.
+func (e *ResponseError) Error() string {
+	if e.RequestID != "" {
+		return e.Err.Error() + " (request ID " + e.RequestID + ")"
+	}
+
+	return e.Err.Error()
+}
+
+// Unwrap returns the error response of Percona Platform.
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusCode returns HTTP status code of Percona Platform response.
+func (e *ResponseError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
 // Error is a model of an error response from Percona Platform.
 type Error struct {
 	Code    int      `json:"code"`
@@ -202,7 +327,43 @@ func (e Error) String() string {
 	return fmt.Sprintf("%v", parts)
 }
 
-func (c *Client) sendPostRequest(ctx context.Context, path, accessToken string, requestBody, responseBody any) error {
+// sendPostRequest sends request and returns details of the response, they are empty if no response was received.
+func (c *Client) sendPostRequest(ctx context.Context, path, accessToken string, requestBody, responseBody any) (responseInfo, error) {
+	if len(accessToken) == 0 && c.credentials != nil {
+		var err error
+
+		accessToken, err = c.credentials.Token(ctx)
+		if err != nil {
+			return responseInfo{}, err
+		}
+	}
+
+	body, encoding := c.compressRequestBody(ctx, requestBody)
+
+	resp, err := c.post(ctx, path, accessToken, body, encoding, responseBody)
+	if err == nil && encoding != "" && resp.StatusCode() == http.StatusUnsupportedMediaType {
+		// Percona Platform stopped accepting the encoding, so the request is resent uncompressed.
+		platformLogger.GetLoggerFromContext(ctx).Warn("request body encoding is not accepted by Percona Platform, resend request uncompressed",
+			zap.String("encoding", encoding))
+		c.compression.reject(encoding)
+
+		resp, err = c.post(ctx, path, accessToken, requestBody, "", responseBody)
+	}
+
+	var info responseInfo
+	if resp != nil && resp.RawResponse != nil {
+		info = newResponseInfo(resp)
+
+		if c.compression != nil {
+			c.compression.negotiate(resp.Header().Get("Accept-Encoding"))
+		}
+	}
+
+	return info, checkForError(resp, err)
+}
+
+// post sends POST request with body compressed with encoding, empty encoding means uncompressed body.
+func (c *Client) post(ctx context.Context, path, accessToken string, requestBody any, encoding string, responseBody any) (*resty.Response, error) {
 	req := c.createRequest(ctx)
 
 	if requestBody != nil {
@@ -210,6 +371,10 @@ func (c *Client) sendPostRequest(ctx context.Context, path, accessToken string,
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
 	if responseBody != nil {
 		// set object for parsing body from response
 		req = req.SetResult(responseBody)
@@ -220,9 +385,29 @@ func (c *Client) sendPostRequest(ctx context.Context, path, accessToken string,
 		req.SetAuthToken(accessToken)
 	}
 
-	resp, err := req.Post(path)
+	return req.Post(path)
+}
+
+// compressRequestBody compresses request body with encoding negotiated with Percona Platform,
+// it is returned as is with empty encoding if compression is disabled or not accepted yet.
+func (c *Client) compressRequestBody(ctx context.Context, requestBody any) (any, string) {
+	data, ok := requestBody.([]byte)
+	if !ok || c.compression == nil {
+		return requestBody, ""
+	}
+
+	encoding := c.compression.encoding()
+	if encoding == "" {
+		return requestBody, ""
+	}
+
+	compressed, err := compressBody(encoding, data)
+	if err != nil {
+		platformLogger.GetLoggerFromContext(ctx).Warn("failed to compress request body, send it uncompressed", zap.Error(err))
+		return requestBody, ""
+	}
 
-	return checkForError(resp, err)
+	return compressed, encoding
 }
 
 func (c *Client) createRequest(ctx context.Context) *resty.Request {
@@ -247,10 +432,10 @@ func checkForError(resp *resty.Response, err error) error {
 
 	if resp.IsError() {
 		if e, ok := resp.Error().(*Error); ok {
-			return e
+			return &ResponseError{StatusCode: resp.StatusCode(), RequestID: responseRequestID(resp), Err: e}
 		}
 
-		return errors.New(resp.Status())
+		return &ResponseError{StatusCode: resp.StatusCode(), RequestID: responseRequestID(resp), Err: errors.New(resp.Status())}
 	}
 
 	return nil
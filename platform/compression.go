@@ -0,0 +1,132 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Request body encodings supported by Client.
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// zstdEncoder is shared by all clients, it is safe for concurrent use with EncodeAll.
+var zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil)
+})
+
+// WithCompression method enables compressing request bodies with the first of encodings Percona Platform advertises
+// in Accept-Encoding response header. Requests are sent uncompressed until acceptance is advertised,
+// encoding rejected with 415 Unsupported Media Type response is not used anymore.
+func WithCompression(encodings ...string) Option {
+	return func(c *Client) {
+		c.compression = &compression{encodings: encodings}
+	}
+}
+
+// compression negotiates request body encoding with Percona Platform. It is safe for concurrent use.
+type compression struct {
+	// encodings are supported encodings in order of preference.
+	encodings []string
+
+	mu       sync.Mutex
+	accepted []string
+	rejected []string
+}
+
+// encoding returns encoding request body should be compressed with, empty if it should be sent uncompressed.
+func (c *compression) encoding() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, encoding := range c.encodings {
+		if slices.Contains(c.accepted, encoding) && !slices.Contains(c.rejected, encoding) {
+			return encoding
+		}
+	}
+
+	return ""
+}
+
+// negotiate remembers encodings of Accept-Encoding response header, previously accepted encodings
+// are kept if the header is absent.
+func (c *compression) negotiate(acceptEncoding string) {
+	if acceptEncoding == "" {
+		return
+	}
+
+	accepted := make([]string, 0, len(c.encodings))
+
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			// encoding is explicitly not accepted.
+			continue
+		}
+
+		accepted = append(accepted, strings.ToLower(strings.TrimSpace(name)))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.accepted = accepted
+}
+
+// reject stops using encoding Percona Platform responded with 415 Unsupported Media Type to.
+func (c *compression) reject(encoding string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rejected = append(c.rejected, encoding)
+}
+
+// compressBody compresses request body with encoding.
+func compressBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingZstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+
+		return enc.EncodeAll(body, make([]byte, 0, len(body)/4)), nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, fmt.Errorf("failed to compress request body: %w", err)
+		}
+
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress request body: %w", err)
+		}
+
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported request body encoding: %q", encoding)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	platformLogger "github.com/percona/platform/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// credentialsRefreshMargin is the time before expiry credentials are refreshed at.
+	credentialsRefreshMargin = 5 * time.Minute
+	// credentialsDefaultTTL is the time credentials without known expiry are refreshed after.
+	credentialsDefaultTTL = time.Hour
+	// credentialsRetryDelay is the time refreshing is retried after while unexpired credentials are kept.
+	credentialsRetryDelay = time.Minute
+	// credentialsFetchTimeout limits credential command run and Vault request.
+	credentialsFetchTimeout = 30 * time.Second
+)
+
+// Credentials are credentials of Percona Platform requests: access token and client certificate for mutual TLS.
+type Credentials struct {
+	Token string `json:"token,omitempty"`
+	// ClientCertificate and ClientKey are PEM encoded client certificate and its private key.
+	ClientCertificate string `json:"client_certificate,omitempty"`
+	ClientKey         string `json:"client_key,omitempty"`
+	// ExpiresAt is the time credentials expire at, zero if unknown.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// CredentialSource fetches Percona Platform credentials from secrets storage.
+type CredentialSource interface {
+	FetchCredentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialSourceFunc is an adapter allowing to use ordinary function as CredentialSource.
+type CredentialSourceFunc func(ctx context.Context) (Credentials, error)
+
+// FetchCredentials calls f(ctx).
+func (f CredentialSourceFunc) FetchCredentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// CredentialProvider caches credentials fetched from source and refreshes them before expiry.
+// Expired credentials are not used, but unexpired ones are kept if refreshing fails. It is safe for concurrent use.
+type CredentialProvider struct {
+	source CredentialSource
+
+	mu        sync.Mutex
+	creds     Credentials
+	cert      *tls.Certificate
+	refreshAt time.Time
+}
+
+// NewCredentialProvider creates CredentialProvider fetching credentials from source on first use.
+func NewCredentialProvider(source CredentialSource) *CredentialProvider {
+	return &CredentialProvider{source: source}
+}
+
+// Token returns access token of Percona Platform requests, it is empty if source provides client certificate only.
+func (p *CredentialProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.refresh(ctx); err != nil {
+		return "", err
+	}
+
+	return p.creds.Token, nil
+}
+
+// ClientCertificate returns client certificate of Percona Platform TLS connections,
+// empty certificate is returned if source provides access token only.
+func (p *CredentialProvider) ClientCertificate(ctx context.Context) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	if p.cert == nil {
+		return &tls.Certificate{}, nil
+	}
+
+	return p.cert, nil
+}
+
+// refresh fetches credentials from source if cached ones are due to refresh, p.mu must be held.
+func (p *CredentialProvider) refresh(ctx context.Context) error {
+	now := time.Now()
+	if !p.refreshAt.IsZero() && now.Before(p.refreshAt) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialsFetchTimeout)
+	defer cancel()
+
+	creds, err := p.source.FetchCredentials(ctx)
+
+	var cert *tls.Certificate
+
+	if err == nil && creds.ClientCertificate != "" {
+		var c tls.Certificate
+
+		c, err = tls.X509KeyPair([]byte(creds.ClientCertificate), []byte(creds.ClientKey))
+		if err != nil {
+			err = fmt.Errorf("invalid client certificate: %w", err)
+		}
+
+		cert = &c
+	}
+
+	if err != nil {
+		if !p.refreshAt.IsZero() && (p.creds.ExpiresAt.IsZero() || now.Before(p.creds.ExpiresAt)) {
+			platformLogger.GetLoggerFromContext(ctx).Warn("failed to refresh Percona Platform credentials, keep using unexpired ones",
+				zap.Error(err))
+
+			p.refreshAt = now.Add(credentialsRetryDelay)
+			if !p.creds.ExpiresAt.IsZero() && p.creds.ExpiresAt.Before(p.refreshAt) {
+				p.refreshAt = p.creds.ExpiresAt
+			}
+
+			return nil
+		}
+
+		return fmt.Errorf("failed to fetch Percona Platform credentials: %w", err)
+	}
+
+	p.creds = creds
+	p.cert = cert
+
+	switch {
+	case creds.ExpiresAt.IsZero():
+		p.refreshAt = now.Add(credentialsDefaultTTL)
+	default:
+		// short-living credentials are refreshed in the middle of their lifetime.
+		p.refreshAt = creds.ExpiresAt.Add(-min(credentialsRefreshMargin, creds.ExpiresAt.Sub(now)/2))
+	}
+
+	return nil
+}
+
+// CommandCredentialSource runs exec-credential helper printing Credentials JSON into stdout,
+// e.g. {"token": "...", "expires_at": "2025-01-01T00:00:00Z"}.
+type CommandCredentialSource struct {
+	Args []string
+}
+
+// FetchCredentials runs credential helper and parses its output.
+func (s CommandCredentialSource) FetchCredentials(ctx context.Context) (Credentials, error) {
+	if len(s.Args) == 0 {
+		return Credentials{}, errors.New("credential command is empty")
+	}
+
+	out, err := exec.CommandContext(ctx, s.Args[0], s.Args[1:]...).Output() //nolint:gosec
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) != 0 {
+			return Credentials{}, fmt.Errorf("credential command failed: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		return Credentials{}, fmt.Errorf("credential command failed: %w", err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse credential command output: %w", err)
+	}
+
+	return creds, nil
+}
+
+// VaultCredentialSource reads Credentials fields from HashiCorp Vault secret of KV secrets engine
+// (version 1 or 2). Secret lease duration is used as credentials expiry unless the secret sets expires_at.
+type VaultCredentialSource struct {
+	// Addr is the Vault address, e.g. https://vault.example.com:8200.
+	Addr string
+	// SecretPath is the path of the secret API, e.g. secret/data/percona-telemetry for KV version 2.
+	SecretPath string
+	// Token is the Vault token the secret is read with.
+	Token string
+	// Client is HTTP client requests are sent with, http.DefaultClient is used if it is nil.
+	Client *http.Client
+}
+
+// vaultSecretResponse is the response of Vault secret read API.
+type vaultSecretResponse struct {
+	LeaseDuration int             `json:"lease_duration"`
+	Data          json.RawMessage `json:"data"`
+	Errors        []string        `json:"errors"`
+}
+
+// FetchCredentials reads credentials secret from Vault.
+func (s VaultCredentialSource) FetchCredentials(ctx context.Context) (Credentials, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	secretURL := strings.TrimSuffix(s.Addr, "/") + "/v1/" + strings.TrimPrefix(s.SecretPath, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to create Vault request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", s.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read Vault secret: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read Vault secret: %w", err)
+	}
+
+	var secret vaultSecretResponse
+	if err := json.Unmarshal(body, &secret); err != nil && resp.StatusCode == http.StatusOK {
+		return Credentials{}, fmt.Errorf("failed to parse Vault secret: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("failed to read Vault secret: %s %s", resp.Status, strings.Join(secret.Errors, ", "))
+	}
+
+	return parseVaultCredentials(secret)
+}
+
+// parseVaultCredentials gets credentials from data of KV version 1 secret or data.data of KV version 2 secret.
+func parseVaultCredentials(secret vaultSecretResponse) (Credentials, error) {
+	data := secret.Data
+
+	var kv2 struct {
+		Data     json.RawMessage `json:"data"`
+		Metadata json.RawMessage `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(data, &kv2); err == nil && len(kv2.Data) != 0 && len(kv2.Metadata) != 0 {
+		data = kv2.Data
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse Vault secret: %w", err)
+	}
+
+	if creds.ExpiresAt.IsZero() && secret.LeaseDuration > 0 {
+		creds.ExpiresAt = time.Now().Add(time.Duration(secret.LeaseDuration) * time.Second)
+	}
+
+	return creds, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// requestIDHeaders are response headers Percona Platform and proxies in front of it put request ID into,
+// in order of preference.
+var requestIDHeaders = []string{"X-Request-Id", "X-Trace-Id", "X-Correlation-Id"}
+
+// Delivery is filled by Client with details of Percona Platform response to sent telemetry.
+type Delivery struct {
+	// RequestID is the request ID assigned by Percona Platform, empty if its response has none.
+	RequestID string
+	// ServerTime is Percona Platform time taken from Date header of its response with one second precision,
+	// zero if the response has none. Together with ReceivedAt it is used to measure skew of host clock.
+	ServerTime time.Time
+	// ReceivedAt is the host time the response was received at.
+	ReceivedAt time.Time
+}
+
+type deliveryKey struct{}
+
+// WithDelivery returns copy of ctx telemetry is sent with, so delivery details are filled into delivery.
+func WithDelivery(ctx context.Context, delivery *Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, delivery)
+}
+
+func deliveryFromContext(ctx context.Context) *Delivery {
+	delivery, _ := ctx.Value(deliveryKey{}).(*Delivery)
+
+	return delivery
+}
+
+// responseRequestID returns request ID of Percona Platform response, empty if it has none.
+func responseRequestID(resp *resty.Response) string {
+	for _, header := range requestIDHeaders {
+		if id := resp.Header().Get(header); id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// responseInfo holds details of Percona Platform response.
+type responseInfo struct {
+	// statusCode is HTTP status code of the response, 0 if no response was received.
+	statusCode int
+	requestID  string
+	serverTime time.Time
+	receivedAt time.Time
+}
+
+func newResponseInfo(resp *resty.Response) responseInfo {
+	info := responseInfo{
+		statusCode: resp.StatusCode(),
+		requestID:  responseRequestID(resp),
+		receivedAt: resp.ReceivedAt(),
+	}
+
+	if date, err := http.ParseTime(resp.Header().Get("Date")); err == nil {
+		info.serverTime = date
+	}
+
+	return info
+}
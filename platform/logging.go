@@ -0,0 +1,129 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+
+	platformLogger "github.com/percona/platform/pkg/logger"
+	"go.uber.org/zap"
+
+	"github.com/percona/telemetry-agent/logger"
+)
+
+// loggingRoundTripper logs requests and responses. Full requests and responses (headers and body)
+// are logged on debug level with sensitive values masked by redactor.
+type loggingRoundTripper struct {
+	rt         http.RoundTripper
+	loggerName string
+	redactor   *logger.Redactor
+}
+
+func (rt *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rl := platformLogger.GetLoggerFromContext(req.Context()).Named(rt.loggerName)
+	logFull := rl.Core().Enabled(zap.DebugLevel)
+
+	if logFull {
+		if b := rt.dumpRequest(req); len(b) != 0 {
+			rl.Debug(fmt.Sprintf("Sending request:\n%s.", b))
+		}
+	} else {
+		rl.Info(fmt.Sprintf("Sending request to host=%s.", req.URL.Host))
+	}
+
+	resp, err := rt.rt.RoundTrip(req)
+
+	switch {
+	case err != nil:
+		rl.Error("Received error", zap.Error(err))
+	case resp == nil:
+	case logFull:
+		if b := rt.dumpResponse(resp); len(b) != 0 {
+			rl.Debug(fmt.Sprintf("Received response:\n%s", b))
+		}
+	default:
+		rl.Info("Received response: " + resp.Status)
+	}
+
+	return resp, err
+}
+
+// dumpRequest returns request dump with redacted headers and body, the request is not modified.
+func (rt *loggingRoundTripper) dumpRequest(req *http.Request) []byte {
+	dumpReq := req.Clone(req.Context())
+	dumpReq.Header = rt.redactor.Header(req.Header)
+
+	withBody := false
+
+	// compressed body is binary, so only its headers are logged.
+	if req.Body != nil && req.GetBody != nil && req.Header.Get("Content-Encoding") == "" {
+		body, err := req.GetBody()
+		if err == nil {
+			data, err := io.ReadAll(body)
+			_ = body.Close()
+
+			if err == nil {
+				data = rt.redactor.JSON(data)
+				dumpReq.Body = io.NopCloser(bytes.NewReader(data))
+				dumpReq.ContentLength = int64(len(data))
+				dumpReq.Header.Set("Content-Length", strconv.Itoa(len(data)))
+				withBody = true
+			}
+		}
+	}
+
+	if !withBody {
+		// body can't be read without consuming it, so it is not logged.
+		dumpReq.Body = nil
+		dumpReq.ContentLength = 0
+	}
+
+	b, _ := httputil.DumpRequestOut(dumpReq, withBody)
+
+	return b
+}
+
+// dumpResponse returns response dump with redacted headers and body.
+// Response body is read and replaced with in-memory copy.
+func (rt *loggingRoundTripper) dumpResponse(resp *http.Response) []byte {
+	dumpResp := *resp
+	dumpResp.Header = rt.redactor.Header(resp.Header)
+	dumpResp.Body = nil
+
+	withBody := false
+
+	if resp.Body != nil {
+		data, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+
+		if err == nil {
+			data = rt.redactor.JSON(data)
+			dumpResp.Body = io.NopCloser(bytes.NewReader(data))
+			dumpResp.ContentLength = int64(len(data))
+			withBody = true
+		}
+	}
+
+	b, _ := httputil.DumpResponse(&dumpResp, withBody)
+
+	return b
+}
@@ -0,0 +1,333 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+// Package platformtest provides mock Percona Platform telemetry endpoint for integration testing
+// of Telemetry Agent, responses of the endpoint are programmed by test.
+package platformtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	genericv1 "github.com/percona/platform/gen/telemetry/generic"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/percona/telemetry-agent/platform"
+)
+
+// GenericReportPath is the path of Percona Platform telemetry endpoint.
+const GenericReportPath = "/v1/telemetry/GenericReport"
+
+// RemoteConfigPath is the path remote configuration document is served on.
+const RemoteConfigPath = "/v1/telemetry/RemoteConfig"
+
+// Response represents programmed response of mock Percona Platform.
+type Response struct {
+	// StatusCode is HTTP status code of the response, 200 is used if it is 0.
+	StatusCode int
+	// Delay is the time the response is delayed for, the request is abandoned if client closes connection meanwhile.
+	Delay time.Duration
+	// RetryAfter is the value of Retry-After header in seconds, the header is not set if it is 0.
+	RetryAfter int
+}
+
+// OK returns successful response.
+func OK() Response {
+	return Response{StatusCode: http.StatusOK}
+}
+
+// TooManyRequests returns rate limiting response asking to retry after given number of seconds.
+func TooManyRequests(retryAfter int) Response {
+	return Response{StatusCode: http.StatusTooManyRequests, RetryAfter: retryAfter}
+}
+
+// ServerError returns server error response with given 5xx status code.
+func ServerError(statusCode int) Response {
+	return Response{StatusCode: statusCode}
+}
+
+// Slow returns successful response delayed for given time.
+func Slow(delay time.Duration) Response {
+	return Response{StatusCode: http.StatusOK, Delay: delay}
+}
+
+// Request represents request received by mock Percona Platform.
+type Request struct {
+	Header http.Header
+	Report *genericv1.ReportRequest
+	// StatusCode is HTTP status code the request was responded with.
+	StatusCode int
+	// RequestID is the request ID returned in X-Request-Id response header.
+	RequestID string
+}
+
+// Server is mock Percona Platform serving telemetry endpoint over HTTP. Programmed responses are returned
+// one by one in the order they were added, then the default response is returned. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	responses       []Response
+	defaultResponse Response
+	requests        []Request
+	remoteConfig    *platform.SignedRemoteConfig
+	acceptEncoding  []string
+	clockOffset     time.Duration
+}
+
+// NewServer starts new mock Percona Platform responding successfully by default,
+// the caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{defaultResponse: OK()}
+	s.Server = httptest.NewServer(s.handler())
+
+	return s
+}
+
+// NewUnixServer starts new mock Percona Platform listening on unix socket at socketPath
+// the same way local relay daemon does, the caller should call Close when finished, to shut it down.
+func NewUnixServer(socketPath string) (*Server, error) {
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{defaultResponse: OK()}
+	s.Server = httptest.NewUnstartedServer(s.handler())
+	// TCP listener created by httptest is replaced with unix socket one.
+	_ = s.Listener.Close()
+	s.Listener = l
+	s.Start()
+
+	return s, nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+GenericReportPath, s.handleGenericReport)
+	mux.HandleFunc("GET "+RemoteConfigPath, s.handleRemoteConfig)
+
+	return mux
+}
+
+// Respond adds responses returned to the next requests.
+func (s *Server) Respond(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, responses...)
+}
+
+// SetDefault sets response returned once all programmed responses are returned.
+func (s *Server) SetDefault(response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultResponse = response
+}
+
+// SetRemoteConfig sets remote configuration document served on RemoteConfigPath, 404 is responded until it is set.
+func (s *Server) SetRemoteConfig(signed platform.SignedRemoteConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remoteConfig = &signed
+}
+
+// SetAcceptEncoding sets request body encodings advertised in Accept-Encoding header of responses,
+// bodies with other encodings are responded with 415 Unsupported Media Type. Nothing is advertised by default.
+func (s *Server) SetAcceptEncoding(encodings ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.acceptEncoding = encodings
+}
+
+// SetClockOffset sets offset of mock Percona Platform clock relative to host one, it is reported
+// in Date header of responses, so skew of host clock can be simulated.
+func (s *Server) SetClockOffset(offset time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clockOffset = offset
+}
+
+// Requests returns all requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Reports returns reports of all requests responded successfully so far.
+func (s *Server) Reports() []*genericv1.GenericReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reports []*genericv1.GenericReport
+
+	for _, req := range s.requests {
+		if req.StatusCode == http.StatusOK {
+			reports = append(reports, req.Report.GetReports()...)
+		}
+	}
+
+	return reports
+}
+
+func (s *Server) nextResponse() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.responses) == 0 {
+		return s.defaultResponse
+	}
+
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+
+	return response
+}
+
+func (s *Server) handleGenericReport(w http.ResponseWriter, r *http.Request) {
+	response := s.nextResponse()
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	s.mu.Lock()
+	acceptEncoding, clockOffset := s.acceptEncoding, s.clockOffset
+	s.mu.Unlock()
+
+	report := &genericv1.ReportRequest{}
+
+	body, err := io.ReadAll(r.Body)
+
+	encoding := r.Header.Get("Content-Encoding")
+
+	switch {
+	case err != nil:
+		statusCode = http.StatusBadRequest
+	case encoding != "" && !slices.Contains(acceptEncoding, encoding):
+		statusCode = http.StatusUnsupportedMediaType
+		err = errors.New("unsupported content encoding: " + encoding)
+	default:
+		body, err = decodeBody(encoding, body)
+		if err == nil {
+			err = protojson.Unmarshal(body, report)
+		}
+
+		if err != nil {
+			statusCode = http.StatusBadRequest
+		}
+	}
+
+	if response.Delay > 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-r.Context().Done():
+			// client gave up waiting, the request is not recorded as it is never responded.
+			return
+		}
+	}
+
+	s.mu.Lock()
+	requestID := "request-" + strconv.Itoa(len(s.requests)+1)
+	s.requests = append(s.requests, Request{Header: r.Header.Clone(), Report: report, StatusCode: statusCode, RequestID: requestID})
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", requestID)
+	w.Header().Set("Date", time.Now().Add(clockOffset).UTC().Format(http.TimeFormat))
+
+	if len(acceptEncoding) != 0 {
+		w.Header().Set("Accept-Encoding", strings.Join(acceptEncoding, ", "))
+	}
+
+	if response.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(response.RetryAfter))
+	}
+
+	w.WriteHeader(statusCode)
+
+	if statusCode == http.StatusOK {
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+
+	message := http.StatusText(statusCode)
+	if err != nil {
+		message = err.Error()
+	}
+
+	_ = json.NewEncoder(w).Encode(platform.Error{Code: statusCode, Message: message})
+}
+
+// decodeBody decompresses request body with encoding, empty encoding means uncompressed body.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return body, nil
+	case platform.EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		return io.ReadAll(zr)
+	case platform.EncodingZstd:
+		zr, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		return zr.DecodeAll(body, nil)
+	default:
+		return nil, errors.New("unsupported content encoding: " + encoding)
+	}
+}
+
+func (s *Server) handleRemoteConfig(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	remoteConfig := s.remoteConfig
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if remoteConfig == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(platform.Error{Code: http.StatusNotFound, Message: http.StatusText(http.StatusNotFound)})
+
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(remoteConfig)
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// RemoteConfig is the configuration document published by Percona Platform, it adjusts collecting
+// of telemetry without reinstalling the agent. Zero values mean the setting is not overridden.
+type RemoteConfig struct {
+	// CheckInterval is the interval in seconds between metrics processing iterations.
+	CheckInterval int `json:"check_interval,omitempty"`
+	// DisabledCollectors are names of host metrics collectors which are skipped (e.g. "containers").
+	DisabledCollectors []string `json:"disabled_collectors,omitempty"`
+	// ExtraPackagePatterns are package name patterns queried in addition to the built-in ones.
+	ExtraPackagePatterns []string `json:"extra_package_patterns,omitempty"`
+}
+
+// SignedRemoteConfig is the envelope remote configuration document is served in,
+// Signature is ed25519 signature of Config bytes. Both are base64 encoded in JSON.
+type SignedRemoteConfig struct {
+	Config    []byte `json:"config"`
+	Signature []byte `json:"signature"`
+}
+
+// ErrInvalidSignature is returned when remote configuration document is not signed with the trusted key.
+var ErrInvalidSignature = errors.New("invalid remote configuration signature")
+
+// SignRemoteConfig creates envelope of remote configuration signed with privateKey.
+func SignRemoteConfig(rc RemoteConfig, privateKey ed25519.PrivateKey) (SignedRemoteConfig, error) {
+	data, err := json.Marshal(rc)
+	if err != nil {
+		return SignedRemoteConfig{}, fmt.Errorf("failed to marshal remote configuration: %w", err)
+	}
+
+	return SignedRemoteConfig{Config: data, Signature: ed25519.Sign(privateKey, data)}, nil
+}
+
+// VerifyRemoteConfig checks signature of remote configuration envelope with publicKey and parses the configuration.
+func VerifyRemoteConfig(signed SignedRemoteConfig, publicKey ed25519.PublicKey) (*RemoteConfig, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid remote configuration public key size: %d", len(publicKey))
+	}
+
+	if !ed25519.Verify(publicKey, signed.Config, signed.Signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	rc := &RemoteConfig{}
+	if err := json.Unmarshal(signed.Config, rc); err != nil {
+		return nil, fmt.Errorf("failed to parse remote configuration: %w", err)
+	}
+
+	return rc, nil
+}
+
+// FetchRemoteConfig gets signed remote configuration document from path of Percona Platform
+// and verifies it with publicKey, unsigned or tampered documents are rejected.
+func (c *Client) FetchRemoteConfig(ctx context.Context, path string, publicKey ed25519.PublicKey) (*RemoteConfig, error) {
+	var signed SignedRemoteConfig
+
+	resp, err := c.createRequest(ctx).
+		SetResult(&signed).
+		Get(path)
+	if err := checkForError(resp, err); err != nil {
+		return nil, fmt.Errorf("failed to fetch remote configuration: %w", err)
+	}
+
+	return VerifyRemoteConfig(signed, publicKey)
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// dirModeMask selects directory mode bits checked by CheckDir.
+	dirModeMask          = os.ModePerm | os.ModeSetgid | os.ModeSetuid | os.ModeSticky
+	dirCreatePermissions = 0o755
+)
+
+// DirExpectation describes expected owner, group and mode of directory.
+type DirExpectation struct {
+	Path string
+	// UID and GID are expected owner and group of directory, they are not checked if negative.
+	UID int
+	GID int
+	// Mode holds permission bits (and os.ModeSetgid) directory mode must have, other bits are not checked.
+	Mode os.FileMode
+	// Optional directories may be absent, e.g. Pillar directories are created by Pillars.
+	Optional bool
+	// Accessible requires the current process to be able to list, create and remove files in directory.
+	Accessible bool
+}
+
+// CheckDir returns human-readable problems of directory not meeting expectation, nil if there are none.
+func CheckDir(e DirExpectation) []string {
+	cleanPath := filepath.Clean(e.Path)
+
+	st, err := os.Stat(cleanPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			if e.Optional {
+				return nil
+			}
+
+			return []string{"directory is absent"}
+		}
+
+		return []string{fmt.Sprintf("can't stat directory: %s", err)}
+	}
+
+	if !st.IsDir() {
+		return []string{"not a directory"}
+	}
+
+	var problems []string
+
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		if e.UID >= 0 && int(sys.Uid) != e.UID {
+			problems = append(problems, fmt.Sprintf("owner is %d, expected %d", sys.Uid, e.UID))
+		}
+
+		if e.GID >= 0 && int(sys.Gid) != e.GID {
+			problems = append(problems, fmt.Sprintf("group is %d, expected %d", sys.Gid, e.GID))
+		}
+	}
+
+	if mode := st.Mode() & dirModeMask; mode&e.Mode != e.Mode {
+		problems = append(problems, fmt.Sprintf("mode is %s, expected at least %s", mode, e.Mode))
+	}
+
+	if e.Accessible {
+		if err := unix.Access(cleanPath, unix.R_OK|unix.W_OK|unix.X_OK); err != nil {
+			problems = append(problems, fmt.Sprintf("not writable by Telemetry Agent (uid %d): %s", os.Getuid(), err))
+		}
+	}
+
+	return problems
+}
+
+// FixDir changes owner, group and mode of directory to meet expectation, absent required directory is created.
+func FixDir(e DirExpectation) error {
+	cleanPath := filepath.Clean(e.Path)
+
+	st, err := os.Stat(cleanPath)
+	if errors.Is(err, os.ErrNotExist) {
+		if e.Optional {
+			return nil
+		}
+
+		if err := os.MkdirAll(cleanPath, os.ModeDir|dirCreatePermissions); err != nil {
+			return fmt.Errorf("can't create %s: %w", cleanPath, err)
+		}
+
+		st, err = os.Stat(cleanPath)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if e.UID >= 0 || e.GID >= 0 {
+		if err := os.Chown(cleanPath, e.UID, e.GID); err != nil {
+			return fmt.Errorf("can't change owner of %s: %w", cleanPath, err)
+		}
+	}
+
+	if mode := st.Mode() & dirModeMask; mode&e.Mode != e.Mode {
+		if err := os.Chmod(cleanPath, mode|e.Mode); err != nil {
+			return fmt.Errorf("can't change mode of %s: %w", cleanPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// WatchDirs watches dirs for new or changed files with fileExt extension and sends notification into
+// returned channel once no more such events happen during delay, so files being written are not picked up too early.
+// dirs that are absent on start are picked up once they are created inside rootDir.
+// Subdirectories of dirs (one level deep) are watched as well, e.g. per instance Pillar directories.
+// Watching stops when ctx is done.
+func WatchDirs(ctx context.Context, rootDir string, dirs []string, fileExt string, delay time.Duration) (<-chan struct{}, error) {
+	l := zap.L().Sugar()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// the root directory is watched for picking up Pillar directories created later.
+	if err := watcher.Add(filepath.Clean(rootDir)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	cleanDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		cleanDir := filepath.Clean(dir)
+		cleanDirs = append(cleanDirs, cleanDir)
+
+		if err := watcher.Add(cleanDir); err != nil {
+			l.Debugw("directory can't be watched yet, waiting for its creation", zap.String("directory", cleanDir), zap.Error(err))
+			continue
+		}
+
+		watchSubdirs(watcher, cleanDir)
+	}
+
+	notifyC := make(chan struct{}, 1)
+
+	go func() {
+		defer func() {
+			if err := watcher.Close(); err != nil {
+				l.Errorw("failed to close directory watcher", zap.Error(err))
+			}
+		}()
+
+		timer := time.NewTimer(delay)
+		timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+					continue
+				}
+
+				if slices.Contains(cleanDirs, event.Name) {
+					// watched directory is created.
+					l.Debugw("start watching directory", zap.String("directory", event.Name))
+
+					if err := watcher.Add(event.Name); err != nil {
+						l.Warnw("failed to watch directory", zap.String("directory", event.Name), zap.Error(err))
+						continue
+					}
+
+					watchSubdirs(watcher, event.Name)
+					// files may be created in the directory before watch is added.
+				} else if slices.Contains(cleanDirs, filepath.Dir(event.Name)) && isDir(event.Name) {
+					// subdirectory of watched directory is created.
+					l.Debugw("start watching subdirectory", zap.String("directory", event.Name))
+
+					if err := watcher.Add(event.Name); err != nil {
+						l.Warnw("failed to watch subdirectory", zap.String("directory", event.Name), zap.Error(err))
+						continue
+					}
+					// files may be created in the subdirectory before watch is added.
+				} else if filepath.Ext(event.Name) != fileExt ||
+					(!slices.Contains(cleanDirs, filepath.Dir(event.Name)) && !slices.Contains(cleanDirs, filepath.Dir(filepath.Dir(event.Name)))) {
+					continue
+				}
+
+				// postpone notification until files stop changing.
+				timer.Reset(delay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				l.Warnw("directory watcher error", zap.Error(err))
+			case <-timer.C:
+				select {
+				case notifyC <- struct{}{}:
+				default:
+					// notification is already pending.
+				}
+			}
+		}
+	}()
+
+	return notifyC, nil
+}
+
+// watchSubdirs adds existing subdirectories of dir to watcher.
+func watchSubdirs(watcher *fsnotify.Watcher, dir string) {
+	l := zap.L().Sugar()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		l.Warnw("failed to read directory for watching its subdirectories", zap.String("directory", dir), zap.Error(err))
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		subdir := filepath.Join(dir, entry.Name())
+		if err := watcher.Add(subdir); err != nil {
+			l.Warnw("failed to watch subdirectory", zap.String("directory", subdir), zap.Error(err))
+		}
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && info.IsDir()
+}
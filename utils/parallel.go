@@ -0,0 +1,47 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package utils
+
+import "sync"
+
+// ForEachParallel calls fn for each index in [0, count) using not more than workers goroutines.
+// It returns when all calls are finished. If workers is less than 1, calls are done sequentially.
+func ForEachParallel(count, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	workers = min(workers, count)
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Go(func() {
+			for i := range indexes {
+				fn(i)
+			}
+		})
+	}
+
+	for i := range count {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2024 Percona LLC
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemd service manager notification states.
+const (
+	// SdNotifyReady tells systemd that service startup is finished.
+	SdNotifyReady = "READY=1"
+	// SdNotifyStopping tells systemd that service is beginning its shutdown.
+	SdNotifyStopping = "STOPPING=1"
+	// SdNotifyWatchdog tells systemd to update the watchdog timestamp.
+	SdNotifyWatchdog = "WATCHDOG=1"
+)
+
+// SdNotify sends state notification to systemd service manager, see sd_notify(3).
+// It does nothing if the process is not started by systemd with Type=notify.
+func SdNotify(state string) error {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return nil
+	}
+
+	if socketAddr[0] == '@' {
+		// abstract namespace socket.
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketAddr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("can't connect to systemd notification socket: %w", err)
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("can't send systemd notification: %w", err)
+	}
+
+	return nil
+}
+
+// SdWatchdogInterval returns the interval systemd expects watchdog notifications to be sent with,
+// it is a half of configured WatchdogSec as recommended by sd_watchdog_enabled(3).
+// 0 is returned if the watchdog is not enabled for the process.
+func SdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		// watchdog is enabled for another process.
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond / 2
+}